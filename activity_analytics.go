@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type ActivityBucket struct {
+	BucketStart time.Time `json:"bucket_start"`
+	TradeCount  int       `json:"trade_count"`
+	Volume      Quantity  `json:"volume"`
+}
+
+// bucket query values and the date_trunc unit each maps to
+var activityBucketUnits = map[string]string{
+	"1m": "minute",
+	"1h": "hour",
+	"1d": "day",
+}
+
+// Upper bound on points per response (e.g. 1m buckets over 30 days would be 43200)
+const maxActivityBuckets = 2000
+
+// Trade count and matched volume per bucket over the last `hours`, all
+// projects combined. Every bucket in the window is present - empty ones are
+// zero - so the series is continuous and oldest first.
+func getActivitySeries(ctx context.Context, database *sql.DB, hours int, unit string) ([]ActivityBucket, error) {
+	// unit comes from activityBucketUnits, never from the request directly
+	rows, err := database.QueryContext(ctx, fmt.Sprintf(`
+		SELECT b.bucket_start, COUNT(mo.id), COALESCE(SUM(mo.matched_qty), 0)
+		FROM generate_series(
+			date_trunc('%[1]s', LOCALTIMESTAMP - $1 * INTERVAL '1 hour'),
+			date_trunc('%[1]s', LOCALTIMESTAMP),
+			INTERVAL '1 %[1]s'
+		) AS b(bucket_start)
+		LEFT JOIN matched_orders mo
+			ON mo.created_at >= b.bucket_start
+			AND mo.created_at < b.bucket_start + INTERVAL '1 %[1]s'
+			AND mo.created_at >= LOCALTIMESTAMP - $1 * INTERVAL '1 hour'
+		GROUP BY b.bucket_start
+		ORDER BY b.bucket_start ASC
+	`, unit), hours)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	series := []ActivityBucket{}
+	for rows.Next() {
+		var b ActivityBucket
+		if err := rows.Scan(&b.BucketStart, &b.TradeCount, &b.Volume); err != nil {
+			return nil, err
+		}
+		series = append(series, b)
+	}
+	return series, rows.Err()
+}
+
+// GET /api/analytics/activity?hours=24&bucket=1h - sparkline data (admin)
+func getActivityAnalytics(w http.ResponseWriter, r *http.Request) {
+	token, err := extractToken(r)
+	if err != nil {
+		writeJSONError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized: "+err.Error())
+		return
+	}
+
+	userID, err := getUserIDFromToken(token, db)
+	if err != nil {
+		writeJSONError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized: Invalid token")
+		return
+	}
+
+	if !isAdmin(userID, db) {
+		writeJSONError(w, http.StatusForbidden, "FORBIDDEN", "Forbidden: Admin access required")
+		return
+	}
+
+	hours := 24
+	if hoursStr := r.URL.Query().Get("hours"); hoursStr != "" {
+		hours, err = strconv.Atoi(hoursStr)
+		if err != nil || hours < 1 || hours > 720 {
+			writeJSONError(w, http.StatusBadRequest, "INVALID_HOURS", "hours must be between 1 and 720")
+			return
+		}
+	}
+
+	bucket := r.URL.Query().Get("bucket")
+	if bucket == "" {
+		bucket = "1h"
+	}
+	unit, ok := activityBucketUnits[bucket]
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_BUCKET", "bucket must be one of 1m, 1h or 1d")
+		return
+	}
+
+	bucketSize := map[string]int{"minute": 1, "hour": 60, "day": 1440}[unit]
+	if hours*60/bucketSize > maxActivityBuckets {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_BUCKET",
+			fmt.Sprintf("Too many buckets - use a larger bucket or fewer hours (max %d points)", maxActivityBuckets))
+		return
+	}
+
+	ctx, cancel := requestQueryContext(r)
+	defer cancel()
+
+	series, err := getActivitySeries(ctx, dbRead, hours, unit)
+	if err != nil {
+		writeQueryError(w, ctx, err, "Error fetching activity")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(series)
+}
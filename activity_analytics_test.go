@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestActivitySeriesFillsEmptyBuckets(t *testing.T) {
+	openTestDB(t)
+	_, adminToken := createTestUser(t, "admin", true)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+
+	tradeTestOrders(t, defaultProjectID, buyerUser, sellerUser, 10, 2)
+	earlier := tradeTestOrders(t, defaultProjectID, buyerUser, sellerUser, 10, 2)
+	if _, err := db.Exec("UPDATE matched_orders SET created_at = created_at - INTERVAL '3 hours' WHERE id = $1", earlier); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := doTestRequest(t, http.MethodGet, "/api/v1/analytics/activity?hours=6&bucket=1h", adminToken, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("activity: status %d (%s)", rec.Code, rec.Body.String())
+	}
+	var series []ActivityBucket
+	decodeTestResponse(t, rec, &series)
+
+	// Six hours back through the current hour, both ends included
+	if len(series) != 7 {
+		t.Fatalf("got %d buckets, want 7", len(series))
+	}
+	for i := 1; i < len(series); i++ {
+		if gap := series[i].BucketStart.Sub(series[i-1].BucketStart); gap != time.Hour {
+			t.Errorf("bucket %d starts %s after the previous one, want 1h", i, gap)
+		}
+	}
+	for i, b := range series {
+		wantCount, wantVolume := 0, Quantity(0)
+		if i == len(series)-1 || i == len(series)-4 {
+			wantCount, wantVolume = 1, wholeQuantity(2)
+		}
+		if b.TradeCount != wantCount || b.Volume != wantVolume {
+			t.Errorf("bucket %d (%s) = %d trades, volume %s; want %d, %s",
+				i, b.BucketStart.Format(time.RFC3339), b.TradeCount, b.Volume, wantCount, wantVolume)
+		}
+	}
+}
@@ -1,16 +1,26 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"log"
 	"net/http"
 	"strconv"
-	"strings"
 
 	"github.com/gorilla/mux"
 )
 
+// Matched orders that count towards analytics. Busted or cancelled trades were
+// corrected after the fact and must not move OHLC, VWAP or volume.
+var countedTradeCondition = countedTradeStatus("status")
+
+// countedTradeCondition for a qualified status column or expression, for
+// queries that join or read the archive
+func countedTradeStatus(status string) string {
+	return "(" + status + " IS NULL OR " + status + " NOT IN ('Busted', 'Cancelled'))"
+}
+
 type ProjectAnalytics struct {
 	ProjectID       int     `json:"project_id"`
 	ProjectName     string  `json:"project_name"`
@@ -20,7 +30,7 @@ type ProjectAnalytics struct {
 	LowestValue     float64 `json:"lowest_value"`
 	MedianValue     float64 `json:"median_value"`
 	TotalMatches    int     `json:"total_matches"`
-	TotalVolume     int     `json:"total_volume"`
+	TotalVolume     Quantity `json:"total_volume"`
 	LastUpdated     string  `json:"last_updated"`
 }
 
@@ -31,15 +41,13 @@ type OverallAnalytics struct {
 	LowestValue     float64            `json:"lowest_value"`
 	MedianValue     float64            `json:"median_value"`
 	TotalMatches    int                `json:"total_matches"`
-	TotalVolume     int                `json:"total_volume"`
+	TotalVolume     Quantity           `json:"total_volume"`
 	ProjectStats    []ProjectAnalytics `json:"project_stats"`
 	LastUpdated     string             `json:"last_updated"`
 }
 
-// Helper function to get user ID from token
+// Helper function to get user ID from token (as returned by extractToken)
 func getUserIDFromToken(token string, database *sql.DB) (int, error) {
-	token = strings.TrimPrefix(token, "Bearer ")
-	
 	var userID int
 	err := database.QueryRow(`
 		SELECT user_id FROM sessions WHERE token = $1
@@ -62,35 +70,20 @@ func isAdmin(userID int, database *sql.DB) bool {
 // Get analytics for a specific project
 func getProjectAnalytics(w http.ResponseWriter, r *http.Request) {
 	// Verify admin access
-	token := r.Header.Get("Authorization")
-	if token == "" {
-		http.Error(w, "Unauthorized: No token provided", http.StatusUnauthorized)
-		return
-	}
-	
-	userID, err := getUserIDFromToken(token, db)
-	if err != nil {
-		http.Error(w, "Unauthorized: Invalid token", http.StatusUnauthorized)
-		return
-	}
-	
-	if !isAdmin(userID, db) {
-		http.Error(w, "Forbidden: Admin access required", http.StatusForbidden)
-		return
-	}
-	
 	vars := mux.Vars(r)
 	projectIDStr := vars["project_id"]
 	projectID, err := strconv.Atoi(projectIDStr)
 	if err != nil {
-		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "INVALID_PROJECT_ID", "Invalid project ID")
 		return
 	}
 
-	analytics, err := calculateProjectAnalytics(db, projectID)
+	ctx, cancel := requestQueryContext(r)
+	defer cancel()
+
+	analytics, err := calculateProjectAnalytics(ctx, dbRead, projectID)
 	if err != nil {
-		log.Println("Error calculating project analytics:", err)
-		http.Error(w, "Error fetching analytics", http.StatusInternalServerError)
+		writeQueryError(w, ctx, err, "Error fetching analytics")
 		return
 	}
 
@@ -101,27 +94,12 @@ func getProjectAnalytics(w http.ResponseWriter, r *http.Request) {
 // Get overall analytics across all projects
 func getOverallAnalytics(w http.ResponseWriter, r *http.Request) {
 	// Verify admin access
-	token := r.Header.Get("Authorization")
-	if token == "" {
-		http.Error(w, "Unauthorized: No token provided", http.StatusUnauthorized)
-		return
-	}
-	
-	userID, err := getUserIDFromToken(token, db)
-	if err != nil {
-		http.Error(w, "Unauthorized: Invalid token", http.StatusUnauthorized)
-		return
-	}
-	
-	if !isAdmin(userID, db) {
-		http.Error(w, "Forbidden: Admin access required", http.StatusForbidden)
-		return
-	}
+	ctx, cancel := requestQueryContext(r)
+	defer cancel()
 
-	analytics, err := calculateOverallAnalytics(db)
+	analytics, err := calculateOverallAnalytics(ctx, dbRead)
 	if err != nil {
-		log.Println("Error calculating overall analytics:", err)
-		http.Error(w, "Error fetching analytics", http.StatusInternalServerError)
+		writeQueryError(w, ctx, err, "Error fetching analytics")
 		return
 	}
 
@@ -129,22 +107,25 @@ func getOverallAnalytics(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(analytics)
 }
 
-func calculateProjectAnalytics(database *sql.DB, projectID int) (*ProjectAnalytics, error) {
+// Individual metrics fall back to 0 on error, but a cancelled or expired ctx
+// fails the whole calculation
+func calculateProjectAnalytics(ctx context.Context, database *sql.DB, projectID int) (*ProjectAnalytics, error) {
 	analytics := &ProjectAnalytics{
 		ProjectID: projectID,
 	}
 
 	// Get project name
-	err := database.QueryRow("SELECT name FROM projects WHERE id = $1", projectID).Scan(&analytics.ProjectName)
+	err := database.QueryRowContext(ctx, "SELECT name FROM projects WHERE id = $1", projectID).Scan(&analytics.ProjectName)
 	if err != nil {
 		analytics.ProjectName = "Unknown Project"
 	}
 
 	// Day start value (previous day's last matched price for this project)
-	err = database.QueryRow(`
+	err = database.QueryRowContext(ctx, `
 		SELECT COALESCE(AVG((buyer_price + seller_price) / 2), 0)
 		FROM matched_orders
 		WHERE project_id = $1
+		AND `+countedTradeCondition+`
 		AND DATE(created_at) = CURRENT_DATE - INTERVAL '1 day'
 		ORDER BY created_at DESC
 		LIMIT 1
@@ -154,10 +135,11 @@ func calculateProjectAnalytics(database *sql.DB, projectID int) (*ProjectAnalyti
 	}
 
 	// Day close value (latest matched price for this project today)
-	err = database.QueryRow(`
+	err = database.QueryRowContext(ctx, `
 		SELECT COALESCE(AVG((buyer_price + seller_price) / 2), 0)
 		FROM matched_orders
 		WHERE project_id = $1
+		AND `+countedTradeCondition+`
 		AND DATE(created_at) = CURRENT_DATE
 		ORDER BY created_at DESC
 		LIMIT 1
@@ -167,10 +149,11 @@ func calculateProjectAnalytics(database *sql.DB, projectID int) (*ProjectAnalyti
 	}
 
 	// Highest value of the day
-	err = database.QueryRow(`
+	err = database.QueryRowContext(ctx, `
 		SELECT COALESCE(MAX(GREATEST(buyer_price, seller_price)), 0)
 		FROM matched_orders
 		WHERE project_id = $1
+		AND `+countedTradeCondition+`
 		AND DATE(created_at) = CURRENT_DATE
 	`, projectID).Scan(&analytics.HighestValue)
 	if err != nil {
@@ -178,10 +161,11 @@ func calculateProjectAnalytics(database *sql.DB, projectID int) (*ProjectAnalyti
 	}
 
 	// Lowest value of the day
-	err = database.QueryRow(`
+	err = database.QueryRowContext(ctx, `
 		SELECT COALESCE(MIN(LEAST(buyer_price, seller_price)), 0)
 		FROM matched_orders
 		WHERE project_id = $1
+		AND `+countedTradeCondition+`
 		AND DATE(created_at) = CURRENT_DATE
 	`, projectID).Scan(&analytics.LowestValue)
 	if err != nil {
@@ -189,10 +173,11 @@ func calculateProjectAnalytics(database *sql.DB, projectID int) (*ProjectAnalyti
 	}
 
 	// Median value (average of all matched prices today)
-	err = database.QueryRow(`
+	err = database.QueryRowContext(ctx, `
 		SELECT COALESCE(AVG((buyer_price + seller_price) / 2), 0)
 		FROM matched_orders
 		WHERE project_id = $1
+		AND `+countedTradeCondition+`
 		AND DATE(created_at) = CURRENT_DATE
 	`, projectID).Scan(&analytics.MedianValue)
 	if err != nil {
@@ -200,10 +185,11 @@ func calculateProjectAnalytics(database *sql.DB, projectID int) (*ProjectAnalyti
 	}
 
 	// Total matches today
-	err = database.QueryRow(`
+	err = database.QueryRowContext(ctx, `
 		SELECT COUNT(*)
 		FROM matched_orders
 		WHERE project_id = $1
+		AND `+countedTradeCondition+`
 		AND DATE(created_at) = CURRENT_DATE
 	`, projectID).Scan(&analytics.TotalMatches)
 	if err != nil {
@@ -211,10 +197,11 @@ func calculateProjectAnalytics(database *sql.DB, projectID int) (*ProjectAnalyti
 	}
 
 	// Total volume today
-	err = database.QueryRow(`
+	err = database.QueryRowContext(ctx, `
 		SELECT COALESCE(SUM(matched_qty), 0)
 		FROM matched_orders
 		WHERE project_id = $1
+		AND `+countedTradeCondition+`
 		AND DATE(created_at) = CURRENT_DATE
 	`, projectID).Scan(&analytics.TotalVolume)
 	if err != nil {
@@ -222,69 +209,79 @@ func calculateProjectAnalytics(database *sql.DB, projectID int) (*ProjectAnalyti
 	}
 
 	// Last updated
-	database.QueryRow("SELECT TO_CHAR(NOW(), 'YYYY-MM-DD HH24:MI:SS')").Scan(&analytics.LastUpdated)
+	database.QueryRowContext(ctx, "SELECT TO_CHAR(NOW(), 'YYYY-MM-DD HH24:MI:SS')").Scan(&analytics.LastUpdated)
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	return analytics, nil
 }
 
-func calculateOverallAnalytics(database *sql.DB) (*OverallAnalytics, error) {
+func calculateOverallAnalytics(ctx context.Context, database *sql.DB) (*OverallAnalytics, error) {
 	analytics := &OverallAnalytics{}
 
 	// Overall day start value (previous day's last matched price across all projects)
-	database.QueryRow(`
+	database.QueryRowContext(ctx, `
 		SELECT COALESCE(AVG((buyer_price + seller_price) / 2), 0)
 		FROM matched_orders
 		WHERE DATE(created_at) = CURRENT_DATE - INTERVAL '1 day'
+		AND `+countedTradeCondition+`
 		ORDER BY created_at DESC
 		LIMIT 1
 	`).Scan(&analytics.DayStartValue)
 
 	// Overall day close value
-	database.QueryRow(`
+	database.QueryRowContext(ctx, `
 		SELECT COALESCE(AVG((buyer_price + seller_price) / 2), 0)
 		FROM matched_orders
 		WHERE DATE(created_at) = CURRENT_DATE
+		AND `+countedTradeCondition+`
 		ORDER BY created_at DESC
 		LIMIT 1
 	`).Scan(&analytics.DayCloseValue)
 
 	// Overall highest value
-	database.QueryRow(`
+	database.QueryRowContext(ctx, `
 		SELECT COALESCE(MAX(GREATEST(buyer_price, seller_price)), 0)
 		FROM matched_orders
 		WHERE DATE(created_at) = CURRENT_DATE
+		AND `+countedTradeCondition+`
 	`).Scan(&analytics.HighestValue)
 
 	// Overall lowest value
-	database.QueryRow(`
+	database.QueryRowContext(ctx, `
 		SELECT COALESCE(MIN(LEAST(buyer_price, seller_price)), 0)
 		FROM matched_orders
 		WHERE DATE(created_at) = CURRENT_DATE
+		AND `+countedTradeCondition+`
 	`).Scan(&analytics.LowestValue)
 
 	// Overall median value
-	database.QueryRow(`
+	database.QueryRowContext(ctx, `
 		SELECT COALESCE(AVG((buyer_price + seller_price) / 2), 0)
 		FROM matched_orders
 		WHERE DATE(created_at) = CURRENT_DATE
+		AND `+countedTradeCondition+`
 	`).Scan(&analytics.MedianValue)
 
 	// Overall total matches
-	database.QueryRow(`
+	database.QueryRowContext(ctx, `
 		SELECT COUNT(*)
 		FROM matched_orders
 		WHERE DATE(created_at) = CURRENT_DATE
+		AND `+countedTradeCondition+`
 	`).Scan(&analytics.TotalMatches)
 
 	// Overall total volume
-	database.QueryRow(`
+	database.QueryRowContext(ctx, `
 		SELECT COALESCE(SUM(matched_qty), 0)
 		FROM matched_orders
 		WHERE DATE(created_at) = CURRENT_DATE
+		AND `+countedTradeCondition+`
 	`).Scan(&analytics.TotalVolume)
 
 	// Get all project IDs
-	rows, err := database.Query("SELECT id FROM projects ORDER BY id ASC")
+	rows, err := database.QueryContext(ctx, "SELECT id FROM projects ORDER BY id ASC")
 	if err != nil {
 		return nil, err
 	}
@@ -300,7 +297,7 @@ func calculateOverallAnalytics(database *sql.DB) (*OverallAnalytics, error) {
 	// Calculate analytics for each project
 	analytics.ProjectStats = []ProjectAnalytics{}
 	for _, projectID := range projectIDs {
-		projectAnalytics, err := calculateProjectAnalytics(database, projectID)
+		projectAnalytics, err := calculateProjectAnalytics(ctx, database, projectID)
 		if err != nil {
 			log.Printf("Warning: Error calculating analytics for project %d: %v", projectID, err)
 			continue
@@ -309,8 +306,11 @@ func calculateOverallAnalytics(database *sql.DB) (*OverallAnalytics, error) {
 	}
 
 	// Last updated
-	database.QueryRow("SELECT TO_CHAR(NOW(), 'YYYY-MM-DD HH24:MI:SS')").Scan(&analytics.LastUpdated)
+	database.QueryRowContext(ctx, "SELECT TO_CHAR(NOW(), 'YYYY-MM-DD HH24:MI:SS')").Scan(&analytics.LastUpdated)
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	return analytics, nil
 }
 
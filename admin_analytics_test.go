@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestBustedTradeExcludedFromAnalyticsAndPositions(t *testing.T) {
+	openTestDB(t)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+
+	tradeTestOrders(t, defaultProjectID, buyerUser, sellerUser, 10, 5)
+	busted := tradeTestOrders(t, defaultProjectID, buyerUser, sellerUser, 20, 5)
+	tradeTestOrders(t, defaultProjectID, buyerUser, sellerUser, 8, 2)
+	if _, err := db.Exec("UPDATE matched_orders SET status = $1 WHERE id = $2", matchBusted, busted); err != nil {
+		t.Fatal(err)
+	}
+
+	analytics, err := calculateProjectAnalytics(context.Background(), db, defaultProjectID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analytics.TotalMatches != 2 || analytics.TotalVolume != wholeQuantity(7) {
+		t.Errorf("analytics = %d matches for %s, want 2 for 7", analytics.TotalMatches, analytics.TotalVolume)
+	}
+	if analytics.HighestValue != 10 || analytics.LowestValue != 8 {
+		t.Errorf("analytics high/low = %v/%v, want 10/8 without the busted 20", analytics.HighestValue, analytics.LowestValue)
+	}
+
+	positions, err := calculateUserPositions(db, buyerUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(positions) != 1 || positions[0].BoughtQty != wholeQuantity(7) || positions[0].LastPrice != 8 {
+		t.Errorf("buyer positions = %+v, want 7 bought, last price 8", positions)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Engine event types on the admin feed
+const (
+	adminEventOrderCreated   = "order_created"
+	adminEventMatchExecuted  = "match_executed"
+	adminEventBreakerHalted  = "circuit_breaker_halted"
+	adminEventBreakerResumed = "circuit_breaker_resumed"
+	adminEventEngineToggled  = "engine_toggled"
+)
+
+// One engine event as the admin console receives it. Data carries the
+// event-specific fields.
+type AdminEngineEvent struct {
+	Type      string                 `json:"type"` // always "engine_event"
+	Event     string                 `json:"event"`
+	Seq       uint64                 `json:"seq"` // increases by one per event since startup
+	ProjectID int                    `json:"project_id,omitempty"`
+	Data      map[string]interface{} `json:"data"`
+	At        time.Time              `json:"at"`
+}
+
+var adminEventSeq uint64
+
+// Publishes to every connected admin console. Each event has its own key, so
+// nothing is coalesced - the console sees every event in order.
+func publishAdminEvent(event string, projectID int, data map[string]interface{}) {
+	seq := atomic.AddUint64(&adminEventSeq, 1)
+	hub.publish(wsEvent{
+		Channel:   "admin",
+		Key:       fmt.Sprintf("admin:%d", seq),
+		ProjectID: projectID,
+		Data: AdminEngineEvent{
+			Type:      "engine_event",
+			Event:     event,
+			Seq:       seq,
+			ProjectID: projectID,
+			Data:      data,
+			At:        time.Now(),
+		},
+	})
+}
+
+// GET /ws/admin/events?token= - live engine event stream (admin). The token
+// comes in the query string like /ws/user.
+func adminEventsWebSocket(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		writeJSONError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized: No token provided")
+		return
+	}
+
+	userID, err := getUserIDFromToken(token, db)
+	if err != nil {
+		writeJSONError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized: Invalid token")
+		return
+	}
+
+	if !isAdmin(userID, db) {
+		writeJSONError(w, http.StatusForbidden, "FORBIDDEN", "Forbidden: Admin access required")
+		return
+	}
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println("WebSocket upgrade failed:", err)
+		return
+	}
+
+	log.Printf("🔌 Admin event stream connected (User ID: %d)", userID)
+
+	initial := func() (interface{}, error) {
+		return map[string]interface{}{
+			"type":    "subscribed",
+			"channel": "admin",
+		}, nil
+	}
+
+	filter := func(evt wsEvent) bool {
+		return evt.Channel == "admin"
+	}
+
+	serveWSClient(conn, initial, filter, nil)
+
+	log.Printf("🔌 Admin event stream disconnected (User ID: %d)", userID)
+}
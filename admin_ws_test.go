@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestEngineToggleReachesAdminConsole(t *testing.T) {
+	openTestDB(t)
+	adminID, adminToken := createTestUser(t, "admin", true)
+	_, traderToken := createTestUser(t, "trader", false)
+
+	testHandlerOnce.Do(func() { testHandler = newHandler() })
+	server := httptest.NewServer(testHandler)
+	defer server.Close()
+	feed := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/admin/events?token="
+
+	if _, resp, err := websocket.DefaultDialer.Dial(feed+traderToken, nil); err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("non-admin token: err %v, response %v; want a 403", err, resp)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(feed+adminToken, nil)
+	if err != nil {
+		t.Fatalf("dial admin feed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var hello map[string]interface{}
+	if err := conn.ReadJSON(&hello); err != nil || hello["type"] != "subscribed" {
+		t.Fatalf("subscription message = %v (err %v)", hello, err)
+	}
+
+	rec := doTestRequest(t, http.MethodPost, "/api/v1/admin/matching-engine/toggle", adminToken, map[string]bool{"enabled": false})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("toggle: status %d (%s)", rec.Code, rec.Body.String())
+	}
+
+	for {
+		var evt AdminEngineEvent
+		if err := conn.ReadJSON(&evt); err != nil {
+			t.Fatalf("no engine_toggled event on the admin feed: %v", err)
+		}
+		if evt.Event != adminEventEngineToggled {
+			continue
+		}
+		if evt.Type != "engine_event" || evt.Data["enabled"] != false || evt.Data["acted_by"] != float64(adminID) {
+			t.Errorf("event = %+v, want engine_toggled with enabled false by admin %d", evt, adminID)
+		}
+		return
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+// The current API version. Every version gets its own /api/<version>
+// subrouter, so a v2 can be mounted next to v1 with its own route set.
+const currentAPIPrefix = "/api/v1"
+
+// Legacy routes already warned about, keyed by method + route template
+var deprecatedRoutesLogged sync.Map
+
+// Marks responses on the unversioned /api alias as deprecated and points
+// clients at the same path under currentAPIPrefix. The warning is logged once
+// per route so busy clients don't flood the log.
+func deprecatedAPIMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		successor := currentAPIPrefix + strings.TrimPrefix(r.URL.Path, "/api")
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+
+		route := r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			if tmpl, err := current.GetPathTemplate(); err == nil {
+				route = tmpl
+			}
+		}
+		if _, seen := deprecatedRoutesLogged.LoadOrStore(r.Method+" "+route, true); !seen {
+			log.Printf("⚠️ Deprecated unversioned API call: %s %s - use %s", r.Method, route, currentAPIPrefix+strings.TrimPrefix(route, "/api"))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestVersionedAndLegacyAPIPaths(t *testing.T) {
+	// No token, so verify answers 401 before touching the database
+	current := doTestRequest(t, http.MethodGet, "/api/v1/auth/verify", "", nil)
+	legacy := doTestRequest(t, http.MethodGet, "/api/auth/verify", "", nil)
+
+	if current.Code != http.StatusUnauthorized || legacy.Code != http.StatusUnauthorized {
+		t.Fatalf("status %d on /api/v1 and %d on /api, want 401 on both", current.Code, legacy.Code)
+	}
+	if current.Body.String() != legacy.Body.String() {
+		t.Errorf("legacy body %q differs from versioned body %q", legacy.Body.String(), current.Body.String())
+	}
+
+	if h := current.Header().Get("Deprecation"); h != "" {
+		t.Errorf("versioned path sent Deprecation: %q", h)
+	}
+	if h := legacy.Header().Get("Deprecation"); h != "true" {
+		t.Errorf("legacy Deprecation header = %q, want true", h)
+	}
+	if h, want := legacy.Header().Get("Link"), `</api/v1/auth/verify>; rel="successor-version"`; h != want {
+		t.Errorf("legacy Link header = %q, want %q", h, want)
+	}
+}
@@ -5,10 +5,13 @@ import (
 	"database/sql"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -31,13 +34,32 @@ type RegisterRequest struct {
 type LoginRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	TOTPCode string `json:"totp_code"` // only for accounts with 2FA enabled
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type UserStats struct {
+	OpenOrders   int      `json:"open_orders"`
+	TotalTrades  int      `json:"total_trades"`
+	TradedVolume Quantity `json:"traded_volume"`
 }
 
 type AuthResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
-	Token   string `json:"token,omitempty"`
-	User    *User  `json:"user,omitempty"`
+	Success           bool       `json:"success"`
+	Message           string     `json:"message"`
+	Token             string     `json:"token,omitempty"`
+	User              *User      `json:"user,omitempty"`
+	Stats             *UserStats `json:"stats,omitempty"`
+	VerificationToken string     `json:"verification_token,omitempty"`
+	TwoFactorRequired bool       `json:"two_factor_required,omitempty"`
 }
 
 // Create users and sessions tables
@@ -69,9 +91,80 @@ func createAuthTables(database *sql.DB) {
 		log.Fatal("Error creating sessions table:", err)
 	}
 
+	// Existing accounts predate verification, so the column defaults to verified;
+	// registerHandler inserts new users explicitly unverified
+	_, err = database.Exec(`ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified BOOLEAN NOT NULL DEFAULT true`)
+	if err != nil {
+		log.Printf("Warning: Could not add email_verified column: %v", err)
+	}
+
+	// 2FA is off until a code from the stored secret has been confirmed
+	_, err = database.Exec(`ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret VARCHAR(64)`)
+	if err != nil {
+		log.Printf("Warning: Could not add totp_secret column: %v", err)
+	}
+	_, err = database.Exec(`ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled BOOLEAN NOT NULL DEFAULT false`)
+	if err != nil {
+		log.Printf("Warning: Could not add totp_enabled column: %v", err)
+	}
+
+	// Last accepted code's time step (replay protection) and failed-code lockout
+	for _, column := range []string{
+		"totp_last_step BIGINT",
+		"totp_failed_attempts INTEGER NOT NULL DEFAULT 0",
+		"totp_locked_until TIMESTAMP",
+	} {
+		if _, err := database.Exec(`ALTER TABLE users ADD COLUMN IF NOT EXISTS ` + column); err != nil {
+			log.Printf("Warning: Could not add users.%s column: %v", column, err)
+		}
+	}
+
+	// Emails are stored lowercase; this also catches mixed-case rows from before.
+	// Fails (with a warning) if existing accounts differ only by email case.
+	_, err = database.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON users (LOWER(email))`)
+	if err != nil {
+		log.Printf("Warning: Could not create case-insensitive email index: %v", err)
+	}
+
+	verificationTable := `CREATE TABLE IF NOT EXISTS email_verifications (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+		token VARCHAR(255) UNIQUE NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`
+
+	_, err = database.Exec(verificationTable)
+	if err != nil {
+		log.Fatal("Error creating email_verifications table:", err)
+	}
+
+	passwordResetTable := `CREATE TABLE IF NOT EXISTS password_resets (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+		token VARCHAR(255) UNIQUE NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`
+
+	_, err = database.Exec(passwordResetTable)
+	if err != nil {
+		log.Fatal("Error creating password_resets table:", err)
+	}
+
 	log.Println("✅ Authentication tables created successfully")
 }
 
+// Column limits from the users table
+const (
+	maxUsernameLength = 50
+	maxEmailLength    = 100
+)
+
+// Emails are case-insensitive: User@x.com and user@x.com are one account
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
 // Generate secure random token
 func generateToken() (string, error) {
 	b := make([]byte, 32)
@@ -82,30 +175,85 @@ func generateToken() (string, error) {
 	return base64.URLEncoding.EncodeToString(b), nil
 }
 
+// BCRYPT_COST (default 12), clamped to 10-15: lower is too weak, higher makes
+// every login take seconds
+var bcryptCost = loadBcryptCost()
+
+func loadBcryptCost() int {
+	cost := getEnvInt("BCRYPT_COST", 12)
+	if cost < 10 {
+		log.Printf("Warning: BCRYPT_COST %d is below 10, using 10", cost)
+		return 10
+	}
+	if cost > 15 {
+		log.Printf("Warning: BCRYPT_COST %d is above 15, using 15", cost)
+		return 15
+	}
+	return cost
+}
+
 // Hash password
 func hashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
 	return string(bytes), err
 }
 
+// Re-hashes a verified password whose stored hash uses a different cost than
+// configured. Best effort - a failure just leaves the old hash in place.
+func upgradePasswordHash(database *sql.DB, userID int, password, hash string) {
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil || cost == bcryptCost {
+		return
+	}
+
+	newHash, err := hashPassword(password)
+	if err != nil {
+		log.Printf("Warning: Could not re-hash password for user %d: %v", userID, err)
+		return
+	}
+
+	// Only replace the hash we verified, in case the password changed meanwhile
+	_, err = database.Exec(`UPDATE users SET password = $1 WHERE id = $2 AND password = $3`, newHash, userID, hash)
+	if err != nil {
+		log.Printf("Warning: Could not upgrade password hash for user %d: %v", userID, err)
+		return
+	}
+	log.Printf("🔐 Upgraded password hash for user %d from cost %d to %d", userID, cost, bcryptCost)
+}
+
 // Check password
 func checkPasswordHash(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
 
+// Login requires a verified email unless REQUIRE_EMAIL_VERIFICATION=false (local dev)
+func emailVerificationRequired() bool {
+	return getEnv("REQUIRE_EMAIL_VERIFICATION", "true") != "false"
+}
+
+// Stub until an email provider is wired in - the token is also returned by registerHandler
+func sendVerificationEmail(email, token string) {
+	log.Printf("📧 Verification email for %s: %s/auth/verify-email?token=%s", email, currentAPIPrefix, token)
+}
+
+// Stub until an email provider is wired in
+func sendPasswordResetEmail(email, token string) {
+	log.Printf("📧 Password reset email for %s: reset token %s", email, token)
+}
+
 // Register handler
 func registerHandler(w http.ResponseWriter, r *http.Request) {
 	var req RegisterRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		json.NewEncoder(w).Encode(AuthResponse{
-			Success: false,
-			Message: "Invalid request body",
-		})
+		writeBodyDecodeError(w, err)
 		return
 	}
 
+	req.Username = strings.TrimSpace(req.Username)
+	req.Email = normalizeEmail(req.Email)
+
 	// Validate input
 	if len(req.Username) < 3 {
 		w.WriteHeader(http.StatusBadRequest)
@@ -116,6 +264,15 @@ func registerHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if utf8.RuneCountInString(req.Username) > maxUsernameLength {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(AuthResponse{
+			Success: false,
+			Message: fmt.Sprintf("Username must be at most %d characters", maxUsernameLength),
+		})
+		return
+	}
+
 	if len(req.Password) < 6 {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(AuthResponse{
@@ -134,9 +291,18 @@ func registerHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if utf8.RuneCountInString(req.Email) > maxEmailLength {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(AuthResponse{
+			Success: false,
+			Message: fmt.Sprintf("Email must be at most %d characters", maxEmailLength),
+		})
+		return
+	}
+
 	// Check if user exists
 	var exists bool
-	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = $1 OR username = $2)", 
+	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE LOWER(email) = $1 OR username = $2)", 
 		req.Email, req.Username).Scan(&exists)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -167,14 +333,46 @@ func registerHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Insert user
+	verificationToken, err := generateToken()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(AuthResponse{
+			Success: false,
+			Message: "Error creating account",
+		})
+		return
+	}
+
+	// Insert user and verification token together
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(AuthResponse{
+			Success: false,
+			Message: "Error creating account",
+		})
+		return
+	}
+	defer tx.Rollback()
+
 	var userID int
-	err = db.QueryRow(`
-		INSERT INTO users (username, email, password)
-		VALUES ($1, $2, $3)
+	err = tx.QueryRow(`
+		INSERT INTO users (username, email, password, email_verified)
+		VALUES ($1, $2, $3, false)
 		RETURNING id
 	`, req.Username, req.Email, hashedPassword).Scan(&userID)
 
+	if err == nil {
+		_, err = tx.Exec(`
+			INSERT INTO email_verifications (user_id, token)
+			VALUES ($1, $2)
+		`, userID, verificationToken)
+	}
+
+	if err == nil {
+		err = tx.Commit()
+	}
+
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(AuthResponse{
@@ -184,12 +382,81 @@ func registerHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("✅ New user registered: %s (ID: %d)", req.Username, userID)
+	sendVerificationEmail(req.Email, verificationToken)
+
+	log.Printf("✅ New user registered: %s (ID: %d) - awaiting email verification", req.Username, userID)
 
 	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(AuthResponse{
+		Success:           true,
+		Message:           "Account created successfully. Please verify your email",
+		VerificationToken: verificationToken,
+	})
+}
+
+// Verify email handler
+func verifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(AuthResponse{
+			Success: false,
+			Message: "No verification token provided",
+		})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(AuthResponse{
+			Success: false,
+			Message: "Database error",
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	// Delete the token and mark its user verified in one step
+	var userID int
+	err = tx.QueryRow(`
+		DELETE FROM email_verifications
+		WHERE token = $1
+		RETURNING user_id
+	`, token).Scan(&userID)
+
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(AuthResponse{
+			Success: false,
+			Message: "Invalid or already used verification token",
+		})
+		return
+	}
+
+	if err == nil {
+		_, err = tx.Exec("UPDATE users SET email_verified = true WHERE id = $1", userID)
+	}
+
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(AuthResponse{
+			Success: false,
+			Message: "Database error",
+		})
+		return
+	}
+
+	log.Printf("✅ Email verified for user ID: %d", userID)
+
+	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(AuthResponse{
 		Success: true,
-		Message: "Account created successfully",
+		Message: "Email verified successfully",
 	})
 }
 
@@ -198,21 +465,20 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 	var req LoginRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(AuthResponse{
-			Success: false,
-			Message: "Invalid request body",
-		})
+		writeBodyDecodeError(w, err)
 		return
 	}
 
 	// Get user from database
 	var user User
+	var emailVerified, totpEnabled bool
+	var totpSecret sql.NullString
 	err = db.QueryRow(`
-		SELECT id, username, email, password, COALESCE(is_admin, false), created_at
+		SELECT id, username, email, password, COALESCE(is_admin, false), email_verified, totp_enabled, totp_secret, created_at
 		FROM users
-		WHERE email = $1
-	`, req.Email).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.IsAdmin, &user.CreatedAt)
+		WHERE LOWER(email) = $1
+	`, normalizeEmail(req.Email)).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.IsAdmin,
+		&emailVerified, &totpEnabled, &totpSecret, &user.CreatedAt)
 
 	if err == sql.ErrNoRows {
 		w.WriteHeader(http.StatusUnauthorized)
@@ -242,6 +508,41 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !emailVerified && emailVerificationRequired() {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(AuthResponse{
+			Success: false,
+			Message: "Email not verified. Please check your inbox for the verification link",
+		})
+		return
+	}
+
+	// Only asked for once the password is known to be right
+	if totpEnabled {
+		code := strings.TrimSpace(req.TOTPCode)
+		if code == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(AuthResponse{
+				Success:           false,
+				Message:           "Two-factor code required",
+				TwoFactorRequired: true,
+			})
+			return
+		}
+		if err := checkTOTPCode(db, user.ID, totpSecret.String, code); err != nil {
+			status, message := totpErrorResponse(err)
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(AuthResponse{
+				Success:           false,
+				Message:           message,
+				TwoFactorRequired: true,
+			})
+			return
+		}
+	}
+
+	upgradePasswordHash(db, user.ID, req.Password, user.Password)
+
 	// Generate session token
 	token, err := generateToken()
 	if err != nil {
@@ -285,23 +586,52 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// Worded for the client - handlers return them as the error message
+var (
+	errNoToken        = errors.New("No token provided")
+	errMalformedToken = errors.New(`Malformed Authorization header (expected "Bearer <token>")`)
+)
+
+// The session token from the Authorization header. Takes "Bearer <token>"
+// (any case) or, for older clients, the bare token, ignoring surrounding
+// whitespace. Another scheme, "Bearer" alone or a token with spaces in it is
+// errMalformedToken.
+func extractToken(r *http.Request) (string, error) {
+	header := strings.TrimSpace(r.Header.Get("Authorization"))
+	if header == "" {
+		return "", errNoToken
+	}
+
+	token := header
+	if scheme, rest, found := strings.Cut(header, " "); found {
+		if !strings.EqualFold(scheme, "Bearer") {
+			return "", errMalformedToken
+		}
+		token = strings.TrimSpace(rest)
+	} else if strings.EqualFold(header, "Bearer") {
+		return "", errMalformedToken
+	}
+
+	if token == "" || strings.ContainsAny(token, " \t") {
+		return "", errMalformedToken
+	}
+	return token, nil
+}
+
 // Logout handler
 func logoutHandler(w http.ResponseWriter, r *http.Request) {
-	token := r.Header.Get("Authorization")
-	if token == "" {
+	token, err := extractToken(r)
+	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(AuthResponse{
 			Success: false,
-			Message: "No token provided",
+			Message: err.Error(),
 		})
 		return
 	}
 
-	// Remove "Bearer " prefix if present
-	token = strings.TrimPrefix(token, "Bearer ")
-
 	// Delete session
-	_, err := db.Exec("DELETE FROM sessions WHERE token = $1", token)
+	_, err = db.Exec("DELETE FROM sessions WHERE token = $1", token)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(AuthResponse{
@@ -320,22 +650,20 @@ func logoutHandler(w http.ResponseWriter, r *http.Request) {
 
 // Verify token handler
 func verifyTokenHandler(w http.ResponseWriter, r *http.Request) {
-	token := r.Header.Get("Authorization")
-	if token == "" {
+	token, err := extractToken(r)
+	if err != nil {
 		w.WriteHeader(http.StatusUnauthorized)
 		json.NewEncoder(w).Encode(AuthResponse{
 			Success: false,
-			Message: "No token provided",
+			Message: err.Error(),
 		})
 		return
 	}
 
-	token = strings.TrimPrefix(token, "Bearer ")
-
 	// Check if session exists and is valid
 	var user User
 	var expiresAt time.Time
-	err := db.QueryRow(`
+	err = db.QueryRow(`
 		SELECT u.id, u.username, u.email, COALESCE(u.is_admin, false), u.created_at, s.expires_at
 		FROM sessions s
 		JOIN users u ON s.user_id = u.id
@@ -377,4 +705,257 @@ func verifyTokenHandler(w http.ResponseWriter, r *http.Request) {
 		Message: "Token is valid",
 		User:    &user,
 	})
-}
\ No newline at end of file
+}
+
+// Forgot password handler - always answers 200 so callers can't probe which emails exist
+func forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req ForgotPasswordRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeBodyDecodeError(w, err)
+		return
+	}
+
+	response := AuthResponse{
+		Success: true,
+		Message: "If an account exists for that email, a reset link has been sent",
+	}
+
+	var userID int
+	err = db.QueryRow("SELECT id FROM users WHERE LOWER(email) = $1", normalizeEmail(req.Email)).Scan(&userID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Error looking up user for password reset: %v", err)
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		log.Printf("Error generating password reset token: %v", err)
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// Reset tokens expire after 1 hour. expires_at has no time zone, so it
+	// is set and checked on the database clock rather than the process's.
+	_, err = db.Exec(`
+		INSERT INTO password_resets (user_id, token, expires_at)
+		VALUES ($1, $2, LOCALTIMESTAMP + INTERVAL '1 hour')
+	`, userID, token)
+	if err != nil {
+		log.Printf("Error storing password reset token: %v", err)
+	} else {
+		sendPasswordResetEmail(req.Email, token)
+		log.Printf("🔑 Password reset requested for user ID: %d", userID)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Reset password handler
+func resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeBodyDecodeError(w, err)
+		return
+	}
+
+	if req.Token == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(AuthResponse{
+			Success: false,
+			Message: "No reset token provided",
+		})
+		return
+	}
+
+	if len(req.Password) < 6 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(AuthResponse{
+			Success: false,
+			Message: "Password must be at least 6 characters",
+		})
+		return
+	}
+
+	hashedPassword, err := hashPassword(req.Password)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(AuthResponse{
+			Success: false,
+			Message: "Error resetting password",
+		})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(AuthResponse{
+			Success: false,
+			Message: "Database error",
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	// Consume the token - a used token is gone, an expired one is rejected below
+	var userID int
+	var unexpired bool
+	err = tx.QueryRow(`
+		DELETE FROM password_resets
+		WHERE token = $1
+		RETURNING user_id, expires_at > LOCALTIMESTAMP
+	`, req.Token).Scan(&userID, &unexpired)
+
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(AuthResponse{
+			Success: false,
+			Message: "Invalid or already used reset token",
+		})
+		return
+	}
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(AuthResponse{
+			Success: false,
+			Message: "Database error",
+		})
+		return
+	}
+
+	if !unexpired {
+		// Commit so the expired token is removed
+		tx.Commit()
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(AuthResponse{
+			Success: false,
+			Message: "Reset token has expired",
+		})
+		return
+	}
+
+	_, err = tx.Exec("UPDATE users SET password = $1 WHERE id = $2", hashedPassword, userID)
+	if err == nil {
+		// Log out everywhere - old sessions may belong to whoever knew the old password
+		_, err = tx.Exec("DELETE FROM sessions WHERE user_id = $1", userID)
+	}
+	if err == nil {
+		_, err = tx.Exec("DELETE FROM password_resets WHERE user_id = $1", userID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(AuthResponse{
+			Success: false,
+			Message: "Error resetting password",
+		})
+		return
+	}
+
+	log.Printf("🔑 Password reset completed for user ID: %d", userID)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(AuthResponse{
+		Success: true,
+		Message: "Password reset successfully. Please log in with your new password",
+	})
+}
+
+// Current user handler - profile plus order/trade stats in one call
+func meHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := extractToken(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(AuthResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	userID, err := getUserIDFromToken(token, db)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(AuthResponse{
+			Success: false,
+			Message: "Invalid or expired token",
+		})
+		return
+	}
+
+	var user User
+	err = db.QueryRow(`
+		SELECT id, username, email, COALESCE(is_admin, false), created_at
+		FROM users
+		WHERE id = $1
+	`, userID).Scan(&user.ID, &user.Username, &user.Email, &user.IsAdmin, &user.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(AuthResponse{
+			Success: false,
+			Message: "Invalid or expired token",
+		})
+		return
+	}
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(AuthResponse{
+			Success: false,
+			Message: "Database error",
+		})
+		return
+	}
+
+	var stats UserStats
+
+	// Open orders live in either the main or the top table, never both.
+	// Expired good-till-date orders wait for the sweeper but can't trade.
+	err = db.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM buyer WHERE user_id = $1 AND `+notExpiredCondition+`) +
+			(SELECT COUNT(*) FROM seller WHERE user_id = $1 AND `+notExpiredCondition+`) +
+			(SELECT COUNT(*) FROM top_buyer WHERE user_id = $1 AND `+notExpiredCondition+`) +
+			(SELECT COUNT(*) FROM top_seller WHERE user_id = $1 AND `+notExpiredCondition+`)
+	`, userID).Scan(&stats.OpenOrders)
+
+	// Archived trades count; busted and cancelled ones don't, as in positions
+	if err == nil {
+		err = db.QueryRow(`
+			SELECT COUNT(*), COALESCE(SUM(matched_qty), 0)
+			FROM matched_orders_all
+			WHERE (buyer_user_id = $1 OR seller_user_id = $1)
+			AND `+countedTradeCondition+`
+		`, userID).Scan(&stats.TotalTrades, &stats.TradedVolume)
+	}
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(AuthResponse{
+			Success: false,
+			Message: "Database error",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(AuthResponse{
+		Success: true,
+		Message: "Current user",
+		User:    &user,
+		Stats:   &stats,
+	})
+}
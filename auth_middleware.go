@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"net/http"
+)
+
+type authContextKey struct{}
+
+// Rejects requests without a valid session token (401) and passes the
+// session's user id on to next, which reads it with userIDFromContext
+func requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := extractToken(r)
+		if err != nil {
+			writeJSONError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized: "+err.Error())
+			return
+		}
+
+		userID, err := getUserIDFromToken(token, db)
+		if err != nil {
+			writeJSONError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized: Invalid token")
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), authContextKey{}, userID)))
+	}
+}
+
+// requireAuth plus an admin check (403 for everyone else)
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		if !isAdmin(userIDFromContext(r), db) {
+			writeJSONError(w, http.StatusForbidden, "FORBIDDEN", "Forbidden: Admin access required")
+			return
+		}
+		next(w, r)
+	})
+}
+
+// The authenticated user's id; 0 outside requireAuth/requireAdmin
+func userIDFromContext(r *http.Request) int {
+	userID, _ := r.Context().Value(authContextKey{}).(int)
+	return userID
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Runs handler behind middleware with token as the bearer token and returns
+// the response and the user id the handler saw (-1 if it never ran)
+func callWithAuth(middleware func(http.HandlerFunc) http.HandlerFunc, token string) (*httptest.ResponseRecorder, int) {
+	seen := -1
+	handler := middleware(func(w http.ResponseWriter, r *http.Request) {
+		seen = userIDFromContext(r)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec, seen
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	openTestDB(t)
+	userID, userToken := createTestUser(t, "trader", false)
+	adminID, adminToken := createTestUser(t, "admin", true)
+
+	tests := []struct {
+		name       string
+		middleware func(http.HandlerFunc) http.HandlerFunc
+		token      string
+		wantStatus int
+		wantUser   int
+	}{
+		{"auth without token", requireAuth, "", http.StatusUnauthorized, -1},
+		{"auth with unknown token", requireAuth, "not-a-session", http.StatusUnauthorized, -1},
+		{"auth as user", requireAuth, userToken, http.StatusNoContent, userID},
+		{"admin without token", requireAdmin, "", http.StatusUnauthorized, -1},
+		{"admin as user", requireAdmin, userToken, http.StatusForbidden, -1},
+		{"admin as admin", requireAdmin, adminToken, http.StatusNoContent, adminID},
+	}
+	for _, tt := range tests {
+		rec, seen := callWithAuth(tt.middleware, tt.token)
+		if rec.Code != tt.wantStatus || seen != tt.wantUser {
+			t.Errorf("%s: status %d, handler saw user %d; want %d, %d", tt.name, rec.Code, seen, tt.wantStatus, tt.wantUser)
+		}
+	}
+
+	if id := userIDFromContext(httptest.NewRequest(http.MethodGet, "/", nil)); id != 0 {
+		t.Errorf("userIDFromContext outside the middleware = %d, want 0", id)
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// The user's stored password hash
+func testPasswordHash(t *testing.T, userID int) string {
+	t.Helper()
+	var hash string
+	if err := db.QueryRow("SELECT password FROM users WHERE id = $1", userID).Scan(&hash); err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestPasswordResetHappyPath(t *testing.T) {
+	openTestDB(t)
+	userID, _ := createTestUser(t, "alice", false)
+
+	rec := doTestRequest(t, http.MethodPost, "/api/auth/forgot-password", "", ForgotPasswordRequest{Email: "Alice@example.com"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("forgot-password: status %d, want 200", rec.Code)
+	}
+	var token string
+	if err := db.QueryRow("SELECT token FROM password_resets WHERE user_id = $1", userID).Scan(&token); err != nil {
+		t.Fatalf("reset token not stored: %v", err)
+	}
+
+	reset := ResetPasswordRequest{Token: token, Password: "new-secret"}
+	rec = doTestRequest(t, http.MethodPost, "/api/auth/reset-password", "", reset)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("reset-password: status %d (%s), want 200", rec.Code, rec.Body.String())
+	}
+	if !checkPasswordHash("new-secret", testPasswordHash(t, userID)) {
+		t.Error("password was not changed")
+	}
+	if n := testCount(t, "sessions"); n != 0 {
+		t.Errorf("%d sessions left, want the user logged out everywhere", n)
+	}
+
+	// The token is single use
+	rec = doTestRequest(t, http.MethodPost, "/api/auth/reset-password", "", reset)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("reused token: status %d, want 400", rec.Code)
+	}
+}
+
+func TestPasswordResetRejectsExpiredToken(t *testing.T) {
+	openTestDB(t)
+	userID, _ := createTestUser(t, "alice", false)
+	before := testPasswordHash(t, userID)
+
+	_, err := db.Exec(`
+		INSERT INTO password_resets (user_id, token, expires_at) VALUES ($1, 'expired-token', LOCALTIMESTAMP - INTERVAL '1 minute')
+	`, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := doTestRequest(t, http.MethodPost, "/api/auth/reset-password", "", ResetPasswordRequest{Token: "expired-token", Password: "new-secret"})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expired token: status %d, want 400", rec.Code)
+	}
+	if testPasswordHash(t, userID) != before {
+		t.Error("password changed with an expired token")
+	}
+	if n := testCount(t, "password_resets"); n != 0 {
+		t.Errorf("%d reset tokens left, want the expired one removed", n)
+	}
+}
+
+// POSTs a JSON body one byte over the limit without a Content-Length, so it
+// gets past the up-front check and fails while being decoded
+func postOversizedBody(t *testing.T, target, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	testHandlerOnce.Do(func() { testHandler = newHandler() })
+	body := `{"username":"` + strings.Repeat("a", int(maxRequestBodyBytes)) + `"}`
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	req.ContentLength = -1
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	testHandler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuthHandlersRejectOversizedBody(t *testing.T) {
+	for _, target := range []string{"/api/auth/register", "/api/auth/login", "/api/auth/forgot-password", "/api/auth/reset-password"} {
+		rec := postOversizedBody(t, target, "")
+		if rec.Code != http.StatusRequestEntityTooLarge || errorCode(t, rec) != "REQUEST_BODY_TOO_LARGE" {
+			t.Errorf("%s: status %d (%.80s), want 413 REQUEST_BODY_TOO_LARGE", target, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestTwoFactorVerifyRejectsOversizedBody(t *testing.T) {
+	openTestDB(t)
+	_, token := createTestUser(t, "alice", false)
+	rec := postOversizedBody(t, "/api/auth/2fa/verify", token)
+	if rec.Code != http.StatusRequestEntityTooLarge || errorCode(t, rec) != "REQUEST_BODY_TOO_LARGE" {
+		t.Errorf("status %d (%.80s), want 413 REQUEST_BODY_TOO_LARGE", rec.Code, rec.Body.String())
+	}
+}
+
+func TestExtractToken(t *testing.T) {
+	tests := []struct {
+		header  string
+		want    string
+		wantErr error
+	}{
+		{"Bearer abc123", "abc123", nil},
+		{"bearer   abc123  ", "abc123", nil},
+		{"abc123", "abc123", nil},
+		{"", "", errNoToken},
+		{"Bearer", "", errMalformedToken},
+		{"Bearer ", "", errMalformedToken},
+		{"Basic abc123", "", errMalformedToken},
+		{"Bearer abc 123", "", errMalformedToken},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", tt.header)
+		got, err := extractToken(req)
+		if got != tt.want || err != tt.wantErr {
+			t.Errorf("extractToken(%q) = %q, %v; want %q, %v", tt.header, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestLoginUpgradesLowCostHash(t *testing.T) {
+	openTestDB(t)
+	userID, _ := createTestUser(t, "alice", false)
+	weak, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("UPDATE users SET password = $1, email_verified = true WHERE id = $2", string(weak), userID); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := doTestRequest(t, http.MethodPost, "/api/auth/login", "", LoginRequest{Email: "alice@example.com", Password: "secret"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: status %d (%s)", rec.Code, rec.Body.String())
+	}
+
+	hash := testPasswordHash(t, userID)
+	if cost, err := bcrypt.Cost([]byte(hash)); err != nil || cost != bcryptCost {
+		t.Errorf("stored hash cost = %d (err %v), want the configured %d", cost, err, bcryptCost)
+	}
+	if !checkPasswordHash("secret", hash) {
+		t.Error("upgraded hash no longer matches the password")
+	}
+}
+
+func TestRegisterRejectsOverLengthUsernameAndEmail(t *testing.T) {
+	for _, req := range []RegisterRequest{
+		{Username: strings.Repeat("u", maxUsernameLength+1), Email: "alice@example.com", Password: "secret1"},
+		{Username: "alice", Email: strings.Repeat("e", maxEmailLength) + "@example.com", Password: "secret1"},
+	} {
+		rec := doTestRequest(t, http.MethodPost, "/api/auth/register", "", req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%d-char username, %d-char email: status %d, want 400",
+				len(req.Username), len(req.Email), rec.Code)
+		}
+	}
+}
+
+func TestRegisterTreatsEmailCaseInsensitively(t *testing.T) {
+	openTestDB(t)
+	rec := doTestRequest(t, http.MethodPost, "/api/auth/register", "",
+		RegisterRequest{Username: "  alice  ", Email: "Alice@Example.com", Password: "secret1"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("register: status %d (%s)", rec.Code, rec.Body.String())
+	}
+	var username, email string
+	if err := db.QueryRow("SELECT username, email FROM users").Scan(&username, &email); err != nil {
+		t.Fatal(err)
+	}
+	if username != "alice" || email != "alice@example.com" {
+		t.Errorf("stored %q <%s>, want the trimmed username and lowercased email", username, email)
+	}
+
+	rec = doTestRequest(t, http.MethodPost, "/api/auth/register", "",
+		RegisterRequest{Username: "alice2", Email: " ALICE@example.COM ", Password: "secret1"})
+	if rec.Code != http.StatusConflict {
+		t.Errorf("same email in another case: status %d, want 409", rec.Code)
+	}
+}
+
+func TestMeStatsSkipBustedTradesAndExpiredOrders(t *testing.T) {
+	openTestDB(t)
+	buyerUser, buyerToken := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+
+	archived := tradeTestOrders(t, defaultProjectID, buyerUser, sellerUser, 10, 3)
+	busted := tradeTestOrders(t, defaultProjectID, buyerUser, sellerUser, 10, 5)
+	tradeTestOrders(t, defaultProjectID, buyerUser, sellerUser, 10, 2)
+	waitForTestCount(t, "match_assignments", 3)
+	if _, err := db.Exec("UPDATE matched_orders SET status = $1 WHERE id = $2", matchBusted, busted); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("UPDATE matched_orders SET created_at = created_at - INTERVAL '10 days' WHERE id = $1", archived); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := archiveOldTrades(db, 5, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// One resting order, and one past its good-till-date the sweeper hasn't reached
+	placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 1, Quantity: wholeQuantity(1)})
+	expired := placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 1, Quantity: wholeQuantity(1)})
+	for _, q := range []string{
+		"UPDATE buyer SET good_till_date = CURRENT_DATE - 1 WHERE id = $1",
+		"UPDATE top_buyer SET good_till_date = CURRENT_DATE - 1 WHERE order_id = $1",
+	} {
+		if _, err := db.Exec(q, expired.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := doTestRequest(t, http.MethodGet, "/api/v1/auth/me", buyerToken, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("me: status %d (%s), want 200", rec.Code, rec.Body.String())
+	}
+	var resp AuthResponse
+	decodeTestResponse(t, rec, &resp)
+	if resp.Stats == nil {
+		t.Fatal("me returned no stats")
+	}
+	if resp.Stats.TotalTrades != 2 || resp.Stats.TradedVolume != wholeQuantity(5) {
+		t.Errorf("stats = %d trades for %s, want 2 for 5 (archived counted, busted not)",
+			resp.Stats.TotalTrades, resp.Stats.TradedVolume)
+	}
+	if resp.Stats.OpenOrders != 1 {
+		t.Errorf("open orders = %d, want 1 without the expired order", resp.Stats.OpenOrders)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Largest request body any handler will read (MAX_REQUEST_BODY_BYTES)
+var maxRequestBodyBytes = int64(getEnvInt("MAX_REQUEST_BODY_BYTES", 1<<20))
+
+// Caps every request body so a client can't stream an arbitrarily large
+// payload into a JSON decoder. Bodies that declare an oversized
+// Content-Length are refused up front; the rest fail on read once they
+// cross the limit (see writeBodyDecodeError).
+func bodyLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > maxRequestBodyBytes {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, "REQUEST_BODY_TOO_LARGE", "Request body too large")
+			return
+		}
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Error response for a failed body decode: 413 if the body hit the size
+// limit, 400 for anything else
+func writeBodyDecodeError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, "REQUEST_BODY_TOO_LARGE", "Request body too large")
+		return
+	}
+	writeJSONError(w, http.StatusBadRequest, "INVALID_REQUEST_BODY", "Invalid request body")
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// Best prices come from the top tables; resting quantity covers main and top.
+// Price fields are null when that side of the book is empty.
+type BookSnapshot struct {
+	ProjectID      int      `json:"project_id"`
+	BestBid        *float64 `json:"best_bid"`
+	BestAsk        *float64 `json:"best_ask"`
+	Spread         *float64 `json:"spread"` // best_ask - best_bid; negative when the book is crossed
+	Crossed        bool     `json:"crossed"`
+	RestingBuyQty  Quantity `json:"resting_buy_qty"`
+	RestingSellQty Quantity `json:"resting_sell_qty"`
+	// (buy - sell) / (buy + sell): 1 is all bids, -1 all asks, null when empty
+	Imbalance *float64 `json:"imbalance"`
+}
+
+func calculateBookSnapshot(ctx context.Context, database *sql.DB, projectID int) (*BookSnapshot, error) {
+	snapshot := &BookSnapshot{ProjectID: projectID}
+	var bestBid, bestAsk sql.NullFloat64
+
+	// Market buyers carry no price, so they don't set the best bid
+	err := database.QueryRowContext(ctx, `
+		SELECT
+			(SELECT MAX(price) FROM top_buyer WHERE ` + projectIDOrDefault("project_id") + ` = $1 AND order_kind <> 'market'),
+			(SELECT MIN(price) FROM top_seller WHERE ` + projectIDOrDefault("project_id") + ` = $1),
+			(SELECT COALESCE(SUM(quantity), 0) FROM top_buyer WHERE ` + projectIDOrDefault("project_id") + ` = $1) +
+			(SELECT COALESCE(SUM(quantity), 0) FROM buyer WHERE ` + projectIDOrDefault("project_id") + ` = $1),
+			(SELECT COALESCE(SUM(quantity), 0) FROM top_seller WHERE ` + projectIDOrDefault("project_id") + ` = $1) +
+			(SELECT COALESCE(SUM(quantity), 0) FROM seller WHERE ` + projectIDOrDefault("project_id") + ` = $1)
+	`, projectID).Scan(&bestBid, &bestAsk, &snapshot.RestingBuyQty, &snapshot.RestingSellQty)
+	if err != nil {
+		return nil, err
+	}
+
+	if bestBid.Valid {
+		snapshot.BestBid = &bestBid.Float64
+	}
+	if bestAsk.Valid {
+		snapshot.BestAsk = &bestAsk.Float64
+	}
+	if bestBid.Valid && bestAsk.Valid {
+		spread := roundMoney(bestAsk.Float64 - bestBid.Float64)
+		snapshot.Spread = &spread
+		snapshot.Crossed = comparePrices(bestBid.Float64, bestAsk.Float64) >= 0
+	}
+
+	if total := snapshot.RestingBuyQty + snapshot.RestingSellQty; total > 0 {
+		imbalance := roundMoney(float64(snapshot.RestingBuyQty-snapshot.RestingSellQty) / float64(total))
+		snapshot.Imbalance = &imbalance
+	}
+
+	return snapshot, nil
+}
+
+// GET /api/analytics/book/{project_id} - best bid/ask, spread and imbalance
+// for any signed-in user
+func getBookAnalytics(w http.ResponseWriter, r *http.Request) {
+	token, err := extractToken(r)
+	if err != nil {
+		writeJSONError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized: "+err.Error())
+		return
+	}
+
+	if _, err := getUserIDFromToken(token, db); err != nil {
+		writeJSONError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized: Invalid token")
+		return
+	}
+
+	projectID, err := strconv.Atoi(mux.Vars(r)["project_id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_PROJECT_ID", "Invalid project ID")
+		return
+	}
+
+	ctx, cancel := requestQueryContext(r)
+	defer cancel()
+
+	snapshot, err := calculateBookSnapshot(ctx, dbRead, projectID)
+	if err != nil {
+		writeQueryError(w, ctx, err, "Error fetching analytics")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snapshot)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestBookAnalyticsCrossedAndOneSided(t *testing.T) {
+	openTestDB(t)
+	userID, token := createTestUser(t, "trader", false)
+	oneSided := createTestProject(t, "One Sided")
+	empty := createTestProject(t, "Empty")
+
+	// Not matched yet, so the book is crossed
+	placeTestOrder(t, Order{UserID: userID, Role: "buyer", Price: 12, Quantity: wholeQuantity(3)})
+	placeTestOrder(t, Order{UserID: userID, Role: "seller", Price: 10, Quantity: wholeQuantity(1)})
+	placeTestOrder(t, Order{UserID: userID, Role: "seller", Price: 10, Quantity: wholeQuantity(2), ProjectID: intPtr(oneSided)})
+
+	book := func(projectID int) BookSnapshot {
+		t.Helper()
+		rec := doTestRequest(t, http.MethodGet, fmt.Sprintf("/api/v1/analytics/book/%d", projectID), token, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("project %d: status %d (%s)", projectID, rec.Code, rec.Body.String())
+		}
+		var snapshot BookSnapshot
+		decodeTestResponse(t, rec, &snapshot)
+		return snapshot
+	}
+
+	crossed := book(defaultProjectID)
+	if crossed.BestBid == nil || *crossed.BestBid != 12 || crossed.BestAsk == nil || *crossed.BestAsk != 10 {
+		t.Errorf("crossed book bid/ask = %v/%v, want 12/10", crossed.BestBid, crossed.BestAsk)
+	}
+	if !crossed.Crossed || crossed.Spread == nil || *crossed.Spread != -2 {
+		t.Errorf("crossed book = crossed %v, spread %v; want true, -2", crossed.Crossed, crossed.Spread)
+	}
+	if crossed.Imbalance == nil || *crossed.Imbalance != 0.5 {
+		t.Errorf("crossed book imbalance = %v, want 0.5", crossed.Imbalance)
+	}
+
+	sells := book(oneSided)
+	if sells.BestBid != nil || sells.Spread != nil || sells.Crossed {
+		t.Errorf("one-sided book = bid %v, spread %v, crossed %v; want no bid or spread", sells.BestBid, sells.Spread, sells.Crossed)
+	}
+	if sells.BestAsk == nil || *sells.BestAsk != 10 || sells.Imbalance == nil || *sells.Imbalance != -1 {
+		t.Errorf("one-sided book = ask %v, imbalance %v; want 10, -1", sells.BestAsk, sells.Imbalance)
+	}
+
+	if none := book(empty); none.BestBid != nil || none.BestAsk != nil || none.Imbalance != nil || none.RestingBuyQty != 0 {
+		t.Errorf("empty book = %+v, want nulls and zeros", none)
+	}
+
+	if rec := doTestRequest(t, http.MethodGet, fmt.Sprintf("/api/v1/analytics/book/%d", defaultProjectID), "", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("no token: status %d, want 401", rec.Code)
+	}
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+type CancelledOrder struct {
+	ID              int       `json:"id"`
+	OrderID         int       `json:"order_id"`
+	Role            string    `json:"role"`
+	UserID          int       `json:"user_id"`
+	TransactionID   string    `json:"transaction_id"`
+	Price           float64   `json:"price"`
+	Quantity        Quantity  `json:"quantity"`
+	TransactionType int       `json:"transaction_type"`
+	ProjectID       int       `json:"project_id"`
+	OrderCreatedAt  time.Time `json:"order_created_at"`
+	Reason          string    `json:"reason"`
+	CancelledBy     int       `json:"cancelled_by"`
+	CancelledByRole string    `json:"cancelled_by_role"`
+	CancelledAt     time.Time `json:"cancelled_at"`
+}
+
+func initCancelledOrdersTable(database *sql.DB) {
+	query := `CREATE TABLE IF NOT EXISTS cancelled_orders (
+		id SERIAL PRIMARY KEY,
+		order_id INTEGER NOT NULL,
+		role VARCHAR(6) NOT NULL CHECK (role IN ('buyer', 'seller')),
+		user_id INTEGER NOT NULL,
+		transaction_id VARCHAR(8) NOT NULL,
+		price DECIMAL(18, 6) NOT NULL,
+		quantity DECIMAL(18, 8) NOT NULL,
+		transaction_type INTEGER NOT NULL,
+		project_id INTEGER NOT NULL,
+		order_created_at TIMESTAMP,
+		reason TEXT NOT NULL DEFAULT '',
+		cancelled_by INTEGER NOT NULL,
+		cancelled_by_role VARCHAR(5) NOT NULL CHECK (cancelled_by_role IN ('owner', 'admin')),
+		cancelled_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`
+
+	_, err := database.Exec(query)
+	if err != nil {
+		log.Fatal("Error creating cancelled_orders table:", err)
+	}
+
+	indexes := []string{
+		`CREATE INDEX IF NOT EXISTS idx_cancelled_orders_project ON cancelled_orders(project_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_cancelled_orders_user ON cancelled_orders(user_id)`,
+	}
+	for _, idx := range indexes {
+		if _, err := database.Exec(idx); err != nil {
+			log.Printf("Warning: Could not create cancelled_orders index: %v", err)
+		}
+	}
+
+	log.Println("✅ Cancelled orders table created successfully")
+}
+
+// Snapshots the order into cancelled_orders. Must run in the same transaction
+// as the delete so the audit row and the cancellation commit or roll back together.
+func recordCancelledOrderTx(tx *sql.Tx, role string, orderID int, inTopTable bool, reason string, cancelledBy int, cancelledByRole string) error {
+	table, idColumn := role, "id"
+	if inTopTable {
+		table, idColumn = "top_"+role, "order_id"
+	}
+
+	result, err := tx.Exec(fmt.Sprintf(`
+		INSERT INTO cancelled_orders
+		(order_id, role, user_id, transaction_id, price, quantity, transaction_type, project_id,
+		 order_created_at, reason, cancelled_by, cancelled_by_role)
+		SELECT %s, $1, user_id, transaction_id, price, quantity, transaction_type, ` + projectIDOrDefault("project_id") + `,
+		       created_at, $2, $3, $4
+		FROM %s WHERE %s = $5
+	`, idColumn, table, idColumn), role, reason, cancelledBy, cancelledByRole, orderID)
+	if err != nil {
+		return err
+	}
+
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("order #%d not found in %s", orderID, table)
+	}
+	return nil
+}
+
+// Cancels every resting order of userID for one role inside tx, optionally
+// limited to a project (0 = all). Returns the ids and whether any were in the top table.
+func cancelUserOrdersTx(tx *sql.Tx, role string, userID, projectID int, reason string, cancelledBy int, cancelledByRole string) ([]int, bool, error) {
+	return cancelOrdersWhereTx(tx, role, "user_id = $1 AND ($2 = 0 OR "+projectIDOrDefault("project_id")+" = $2)",
+		[]interface{}{userID, projectID}, reason, cancelledBy, cancelledByRole)
+}
+
+// Cancels every resting order in the project for one role inside tx, whoever
+// owns it. Returns the ids and whether any were in the top table.
+func cancelProjectOrdersTx(tx *sql.Tx, role string, projectID int, reason string, cancelledBy int) ([]int, bool, error) {
+	return cancelOrdersWhereTx(tx, role, projectIDOrDefault("project_id")+" = $1",
+		[]interface{}{projectID}, reason, cancelledBy, "admin")
+}
+
+// Cancels the role's resting orders matching condition (a WHERE clause over
+// args), recording each in cancelled_orders and marking its history Cancelled
+func cancelOrdersWhereTx(tx *sql.Tx, role, condition string, args []interface{}, reason string, cancelledBy int, cancelledByRole string) ([]int, bool, error) {
+	rows, err := tx.Query(fmt.Sprintf(`
+		SELECT order_id, true FROM top_%s WHERE %s
+		UNION ALL
+		SELECT id, false FROM %s WHERE %s
+	`, role, condition, role, condition), args...)
+	if err != nil {
+		return nil, false, err
+	}
+
+	type found struct {
+		id    int
+		inTop bool
+	}
+	var orders []found
+	for rows.Next() {
+		var o found
+		if err := rows.Scan(&o.id, &o.inTop); err != nil {
+			rows.Close()
+			return nil, false, err
+		}
+		orders = append(orders, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	ids := []int{}
+	touchedTop := false
+	for _, o := range orders {
+		if err := recordCancelledOrderTx(tx, role, o.id, o.inTop, reason, cancelledBy, cancelledByRole); err != nil {
+			return nil, false, err
+		}
+
+		if o.inTop {
+			_, err = tx.Exec(fmt.Sprintf("DELETE FROM top_%s WHERE order_id = $1", role), o.id)
+			touchedTop = true
+		} else {
+			_, err = tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE id = $1", role), o.id)
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("deleting %s order #%d: %v", role, o.id, err)
+		}
+		ids = append(ids, o.id)
+	}
+
+	if len(ids) > 0 {
+		_, err = tx.Exec(fmt.Sprintf(`
+			UPDATE %s_order_history
+			SET status = 'Cancelled', updated_at = CURRENT_TIMESTAMP
+			WHERE %s_order_id = ANY($1)
+		`, role, role), pq.Array(ids))
+		if err != nil {
+			return nil, false, fmt.Errorf("updating %s history: %v", role, err)
+		}
+	}
+
+	return ids, touchedTop, nil
+}
+
+// DELETE /api/orders/user/{user_id}/all?project_id=&reason= - cancel all of a
+// user's resting orders in one transaction (owner or admin)
+func cancelAllUserOrders(w http.ResponseWriter, r *http.Request) {
+	token, err := extractToken(r)
+	if err != nil {
+		writeJSONError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized: "+err.Error())
+		return
+	}
+
+	requesterID, err := getUserIDFromToken(token, db)
+	if err != nil {
+		writeJSONError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized: Invalid token")
+		return
+	}
+
+	userID, err := strconv.Atoi(mux.Vars(r)["user_id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_USER_ID", "Invalid user ID")
+		return
+	}
+
+	cancelledByRole := "owner"
+	if requesterID != userID {
+		if !isAdmin(requesterID, db) {
+			writeJSONError(w, http.StatusForbidden, "FORBIDDEN", "Forbidden: You can only cancel your own orders")
+			return
+		}
+		cancelledByRole = "admin"
+	}
+
+	projectID := 0
+	if projectIDStr := r.URL.Query().Get("project_id"); projectIDStr != "" {
+		projectID, err = strconv.Atoi(projectIDStr)
+		if err != nil || projectID <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "INVALID_PROJECT_ID", "Invalid project ID")
+			return
+		}
+	}
+
+	reason := r.URL.Query().Get("reason")
+	if reason == "" {
+		reason = "Cancel all by " + cancelledByRole
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Transaction error")
+		return
+	}
+	defer tx.Rollback()
+
+	cancelled := map[string][]int{}
+	touchedTop := map[string]bool{}
+	for _, role := range []string{"buyer", "seller"} {
+		ids, inTop, err := cancelUserOrdersTx(tx, role, userID, projectID, reason, requesterID, cancelledByRole)
+		if err != nil {
+			log.Printf("Error cancelling %s orders for user %d: %v", role, userID, err)
+			writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to cancel orders")
+			return
+		}
+		cancelled[role] = ids
+		touchedTop[role] = inTop
+	}
+
+	if err = tx.Commit(); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Commit error")
+		return
+	}
+
+	log.Printf("🧹 Cancelled all orders for user %d (project: %d) by %s (User ID: %d): %d buyer, %d seller",
+		userID, projectID, cancelledByRole, requesterID, len(cancelled["buyer"]), len(cancelled["seller"]))
+
+	// Refill the top tables the cancelled orders were taken from
+	for _, role := range []string{"buyer", "seller"} {
+		if !touchedTop[role] {
+			continue
+		}
+		notifyOrderBookChanged(role)
+		if err := smartSyncTopOrders(db, role); err != nil {
+			log.Printf("Error syncing top %s orders after cancel all: %v", role, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":           true,
+		"cancelled_buyers":  len(cancelled["buyer"]),
+		"cancelled_sellers": len(cancelled["seller"]),
+		"buyer_order_ids":   cancelled["buyer"],
+		"seller_order_ids":  cancelled["seller"],
+	})
+}
+
+// DELETE /api/admin/projects/{project_id}/orders?reason= - cancel every resting
+// order in the project in one transaction (admin). Other projects are untouched.
+func cancelProjectOrders(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+
+	projectID, err := strconv.Atoi(mux.Vars(r)["project_id"])
+	if err != nil || projectID <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_PROJECT_ID", "Invalid project ID")
+		return
+	}
+
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM projects WHERE id = $1)", projectID).Scan(&exists); err != nil {
+		log.Println("Error checking project:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to cancel orders")
+		return
+	}
+	if !exists {
+		writeJSONError(w, http.StatusNotFound, "PROJECT_NOT_FOUND", "Project not found")
+		return
+	}
+
+	reason := r.URL.Query().Get("reason")
+	if reason == "" {
+		reason = "Project orders cleared by admin"
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Transaction error")
+		return
+	}
+	defer tx.Rollback()
+
+	cancelled := map[string][]int{}
+	touchedTop := map[string]bool{}
+	for _, role := range []string{"buyer", "seller"} {
+		ids, inTop, err := cancelProjectOrdersTx(tx, role, projectID, reason, userID)
+		if err != nil {
+			log.Printf("Error cancelling %s orders for project %d: %v", role, projectID, err)
+			writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to cancel orders")
+			return
+		}
+		cancelled[role] = ids
+		touchedTop[role] = inTop
+	}
+
+	if err = tx.Commit(); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Commit error")
+		return
+	}
+
+	log.Printf("🧹 Cancelled all orders in project %d by admin (User ID: %d): %d buyer, %d seller",
+		projectID, userID, len(cancelled["buyer"]), len(cancelled["seller"]))
+
+	// The top tables are shared by every project - refill the freed slots
+	for _, role := range []string{"buyer", "seller"} {
+		if !touchedTop[role] {
+			continue
+		}
+		notifyOrderBookChanged(role)
+		if err := smartSyncTopOrders(db, role); err != nil {
+			log.Printf("Error syncing top %s orders after project cancel: %v", role, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":           true,
+		"project_id":        projectID,
+		"cancelled_buyers":  len(cancelled["buyer"]),
+		"cancelled_sellers": len(cancelled["seller"]),
+		"buyer_order_ids":   cancelled["buyer"],
+		"seller_order_ids":  cancelled["seller"],
+	})
+}
+
+// List cancelled orders (admin), optionally filtered by project_id and user_id
+func getCancelledOrders(w http.ResponseWriter, r *http.Request) {
+	query := `
+		SELECT id, order_id, role, user_id, transaction_id, price, quantity, transaction_type, project_id,
+		       order_created_at, reason, cancelled_by, cancelled_by_role, cancelled_at
+		FROM cancelled_orders
+		WHERE 1 = 1
+	`
+	args := []interface{}{}
+
+	if projectIDStr := r.URL.Query().Get("project_id"); projectIDStr != "" {
+		projectID, err := strconv.Atoi(projectIDStr)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "INVALID_PROJECT_ID", "Invalid project ID")
+			return
+		}
+		args = append(args, projectID)
+		query += fmt.Sprintf(" AND project_id = $%d", len(args))
+	}
+
+	if userIDStr := r.URL.Query().Get("user_id"); userIDStr != "" {
+		filterUserID, err := strconv.Atoi(userIDStr)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "INVALID_USER_ID", "Invalid user ID")
+			return
+		}
+		args = append(args, filterUserID)
+		query += fmt.Sprintf(" AND user_id = $%d", len(args))
+	}
+
+	query += " ORDER BY cancelled_at DESC"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		log.Println("Error querying cancelled orders:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error fetching cancelled orders")
+		return
+	}
+	defer rows.Close()
+
+	orders := []CancelledOrder{}
+	for rows.Next() {
+		var o CancelledOrder
+		var orderCreatedAt sql.NullTime
+		err := rows.Scan(&o.ID, &o.OrderID, &o.Role, &o.UserID, &o.TransactionID, &o.Price, &o.Quantity,
+			&o.TransactionType, &o.ProjectID, &orderCreatedAt, &o.Reason, &o.CancelledBy,
+			&o.CancelledByRole, &o.CancelledAt)
+		if err != nil {
+			log.Println("Error scanning cancelled order:", err)
+			continue
+		}
+		o.OrderCreatedAt = orderCreatedAt.Time
+		orders = append(orders, o)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(orders)
+}
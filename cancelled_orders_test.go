@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// Resting orders (main and top tables) of the user in role
+func countTestUserOrders(t *testing.T, role string, userID int) int {
+	t.Helper()
+	var n int
+	err := db.QueryRow(fmt.Sprintf(`
+		SELECT (SELECT COUNT(*) FROM %s WHERE user_id = $1) + (SELECT COUNT(*) FROM %s WHERE user_id = $1)
+	`, getTableName(role), getTopTableName(role)), userID).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestCancelAllUserOrdersBothRolesTwoProjects(t *testing.T) {
+	openTestDB(t)
+	traderID, traderToken := createTestUser(t, "trader", false)
+	otherID, otherToken := createTestUser(t, "other", false)
+	second := createTestProject(t, "Second")
+
+	for _, projectID := range []int{defaultProjectID, second} {
+		placeTestOrder(t, Order{UserID: traderID, Role: "buyer", Price: 5, Quantity: wholeQuantity(1), ProjectID: intPtr(projectID)})
+		placeTestOrder(t, Order{UserID: traderID, Role: "seller", Price: 20, Quantity: wholeQuantity(1), ProjectID: intPtr(projectID)})
+		placeTestOrder(t, Order{UserID: otherID, Role: "buyer", Price: 5, Quantity: wholeQuantity(1), ProjectID: intPtr(projectID)})
+	}
+
+	target := fmt.Sprintf("/api/v1/orders/user/%d/all", traderID)
+	if rec := doTestRequest(t, http.MethodDelete, target, otherToken, nil); rec.Code != http.StatusForbidden {
+		t.Fatalf("another user's cancel all: status %d, want 403", rec.Code)
+	}
+
+	var resp struct {
+		CancelledBuyers  int `json:"cancelled_buyers"`
+		CancelledSellers int `json:"cancelled_sellers"`
+	}
+	rec := doTestRequest(t, http.MethodDelete, fmt.Sprintf("%s?project_id=%d", target, second), traderToken, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("cancel all in one project: status %d (%s)", rec.Code, rec.Body.String())
+	}
+	decodeTestResponse(t, rec, &resp)
+	if resp.CancelledBuyers != 1 || resp.CancelledSellers != 1 {
+		t.Errorf("scoped cancel = %+v, want 1 buyer and 1 seller", resp)
+	}
+	if b, s := countTestUserOrders(t, "buyer", traderID), countTestUserOrders(t, "seller", traderID); b != 1 || s != 1 {
+		t.Errorf("after the scoped cancel the trader has %d buyer and %d seller orders, want 1 and 1", b, s)
+	}
+
+	rec = doTestRequest(t, http.MethodDelete, target, traderToken, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("cancel all: status %d (%s)", rec.Code, rec.Body.String())
+	}
+	decodeTestResponse(t, rec, &resp)
+	if resp.CancelledBuyers != 1 || resp.CancelledSellers != 1 {
+		t.Errorf("unscoped cancel = %+v, want the remaining 1 buyer and 1 seller", resp)
+	}
+	if b, s := countTestUserOrders(t, "buyer", traderID), countTestUserOrders(t, "seller", traderID); b != 0 || s != 0 {
+		t.Errorf("trader still has %d buyer and %d seller orders", b, s)
+	}
+	if n := countTestUserOrders(t, "buyer", otherID); n != 2 {
+		t.Errorf("other user has %d buyer orders left, want 2", n)
+	}
+
+	var cancelledHistory int
+	err := db.QueryRow("SELECT COUNT(*) FROM buyer_order_history WHERE buyer_user_id = $1 AND status = 'Cancelled'", traderID).Scan(&cancelledHistory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancelledHistory != 2 {
+		t.Errorf("%d of the trader's buyer history rows are Cancelled, want 2", cancelledHistory)
+	}
+}
+
+func TestCancelProjectOrdersLeavesOtherProjectsAlone(t *testing.T) {
+	openTestDB(t)
+	_, adminToken := createTestUser(t, "admin", true)
+	traderID, _ := createTestUser(t, "trader", false)
+	windingDown := createTestProject(t, "Winding down")
+	other := createTestProject(t, "Other")
+
+	for i := 0; i < 3; i++ {
+		placeTestOrder(t, Order{UserID: traderID, Role: "buyer", Price: 20, Quantity: wholeQuantity(1), ProjectID: intPtr(windingDown)})
+	}
+	for i := 0; i < 2; i++ {
+		placeTestOrder(t, Order{UserID: traderID, Role: "seller", Price: 30, Quantity: wholeQuantity(1), ProjectID: intPtr(windingDown)})
+		placeTestOrder(t, Order{UserID: traderID, Role: "seller", Price: 40, Quantity: wholeQuantity(1), ProjectID: intPtr(other)})
+	}
+	// Seven of these fit beside the winding-down project's buyers in the top table
+	for i := 0; i < 10; i++ {
+		placeTestOrder(t, Order{UserID: traderID, Role: "buyer", Price: 10, Quantity: wholeQuantity(2), ProjectID: intPtr(other)})
+	}
+
+	projectOrders := func(role string, projectID int) (n int, qty Quantity) {
+		t.Helper()
+		err := db.QueryRow(fmt.Sprintf(`
+			SELECT COUNT(*), COALESCE(SUM(quantity), 0) FROM (
+				SELECT quantity FROM %s WHERE project_id = $1
+				UNION ALL
+				SELECT quantity FROM %s WHERE project_id = $1
+			) o
+		`, getTableName(role), getTopTableName(role)), projectID).Scan(&n, &qty)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n, qty
+	}
+
+	rec := doTestRequest(t, http.MethodDelete, fmt.Sprintf("/api/v1/admin/projects/%d/orders", windingDown), adminToken, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("cancel project orders: status %d (%s)", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		CancelledBuyers  int `json:"cancelled_buyers"`
+		CancelledSellers int `json:"cancelled_sellers"`
+	}
+	decodeTestResponse(t, rec, &resp)
+	if resp.CancelledBuyers != 3 || resp.CancelledSellers != 2 {
+		t.Errorf("cancelled %d buyers and %d sellers, want 3 and 2", resp.CancelledBuyers, resp.CancelledSellers)
+	}
+
+	for _, role := range []string{"buyer", "seller"} {
+		if n, _ := projectOrders(role, windingDown); n != 0 {
+			t.Errorf("%d %s orders left in the cleared project", n, role)
+		}
+	}
+	if n, qty := projectOrders("buyer", other); n != 10 || qty != wholeQuantity(20) {
+		t.Errorf("other project has %d buyers for %s, want 10 for 20", n, qty)
+	}
+	if n, qty := projectOrders("seller", other); n != 2 || qty != wholeQuantity(2) {
+		t.Errorf("other project has %d sellers for %s, want 2 for 2", n, qty)
+	}
+	if n := testCount(t, "top_buyer"); n != 10 {
+		t.Errorf("top_buyer holds %d orders after the resync, want the other project's 10", n)
+	}
+
+	var cancelledOther int
+	err := db.QueryRow("SELECT COUNT(*) FROM buyer_order_history WHERE project_id = $1 AND status = 'Cancelled'", other).Scan(&cancelledOther)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancelledOther != 0 {
+		t.Errorf("%d of the other project's buyer history rows were marked Cancelled", cancelledOther)
+	}
+}
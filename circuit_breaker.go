@@ -7,10 +7,15 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
+// Breakers only act on a price whose latest trade is at most this old; on a
+// thin market an hours-old fill says nothing about where the price is now
+var breakerPriceFreshness = getEnvDuration("CIRCUIT_BREAKER_PRICE_FRESHNESS", 15*time.Minute)
+
 type CircuitBreakerSettings struct {
 	ProjectID            int     `json:"project_id"`
 	ProjectName          string  `json:"project_name"`
@@ -19,7 +24,11 @@ type CircuitBreakerSettings struct {
 	DayOpenPrice         float64 `json:"day_open_price"`
 	CurrentPrice         float64 `json:"current_price"`
 	PriceDropPercentage  float64 `json:"price_drop_percentage"`
+	CooldownMinutes      *int    `json:"cooldown_minutes"`
+	HaltReason           string  `json:"halt_reason,omitempty"` // threshold or manual
+	MLPExemptFromHalt    bool    `json:"mlp_exempt_from_halt"`
 	HaltedAt             string  `json:"halted_at,omitempty"`
+	LastMatchAt          string  `json:"last_match_at,omitempty"`
 	LastChecked          string  `json:"last_checked"`
 }
 
@@ -30,8 +39,8 @@ func initCircuitBreakerTable(database *sql.DB) {
 		threshold_percentage DECIMAL(5,2) NOT NULL DEFAULT 0,
 		is_halted BOOLEAN DEFAULT false,
 		halted_at TIMESTAMP,
-		day_open_price DECIMAL(10,2) DEFAULT 0,
-		current_price DECIMAL(10,2) DEFAULT 0,
+		day_open_price DECIMAL(18,6) DEFAULT 0,
+		current_price DECIMAL(18,6) DEFAULT 0,
 		price_drop_percentage DECIMAL(5,2) DEFAULT 0,
 		last_checked TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	)`
@@ -41,58 +50,78 @@ func initCircuitBreakerTable(database *sql.DB) {
 		log.Fatal("Error creating circuit breaker table:", err)
 	}
 
-	log.Println("✅ Circuit breaker table created successfully")
-}
-
-// Set circuit breaker threshold for a project
-func setCircuitBreakerThreshold(w http.ResponseWriter, r *http.Request) {
-	token := r.Header.Get("Authorization")
-	if token == "" {
-		http.Error(w, "Unauthorized: No token provided", http.StatusUnauthorized)
-		return
+	// NULL = no auto-resume, halt lasts until a manual reset
+	_, err = database.Exec(`ALTER TABLE project_circuit_breakers ADD COLUMN IF NOT EXISTS cooldown_minutes INTEGER CHECK (cooldown_minutes > 0)`)
+	if err != nil {
+		log.Printf("Warning: Could not add cooldown_minutes column: %v", err)
 	}
 
-	userID, err := getUserIDFromToken(token, db)
+	// Manual halts ignore cooldowns and the daily reset - only an admin reset lifts them
+	_, err = database.Exec(`ALTER TABLE project_circuit_breakers ADD COLUMN IF NOT EXISTS halt_reason VARCHAR(10) CHECK (halt_reason IN ('threshold', 'manual'))`)
 	if err != nil {
-		http.Error(w, "Unauthorized: Invalid token", http.StatusUnauthorized)
-		return
+		log.Printf("Warning: Could not add halt_reason column: %v", err)
 	}
 
-	if !isAdmin(userID, db) {
-		http.Error(w, "Forbidden: Admin access required", http.StatusForbidden)
-		return
+	// Lets market-lead-program orders keep trading through a halt to stabilize the book
+	_, err = database.Exec(`ALTER TABLE project_circuit_breakers ADD COLUMN IF NOT EXISTS mlp_exempt_from_halt BOOLEAN NOT NULL DEFAULT false`)
+	if err != nil {
+		log.Printf("Warning: Could not add mlp_exempt_from_halt column: %v", err)
 	}
 
+	log.Println("✅ Circuit breaker table created successfully")
+}
+
+// Set circuit breaker threshold for a project
+func setCircuitBreakerThreshold(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+
+	// cooldown_minutes: omitted = unchanged, 0 = disable auto-resume
+	// mlp_exempt_from_halt: omitted = unchanged
 	var settings struct {
 		ProjectID           int     `json:"project_id"`
 		ThresholdPercentage float64 `json:"threshold_percentage"`
+		CooldownMinutes     *int    `json:"cooldown_minutes"`
+		MLPExemptFromHalt   *bool   `json:"mlp_exempt_from_halt"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeBodyDecodeError(w, err)
 		return
 	}
 
 	// Validate threshold (0-100%)
 	if settings.ThresholdPercentage < 0 || settings.ThresholdPercentage > 100 {
-		http.Error(w, "Threshold percentage must be between 0 and 100", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "INVALID_THRESHOLD", "Threshold percentage must be between 0 and 100")
+		return
+	}
+
+	if settings.CooldownMinutes != nil && *settings.CooldownMinutes < 0 {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_COOLDOWN", "Cooldown minutes cannot be negative")
 		return
 	}
 
 	// Insert or update circuit breaker settings
-	_, err = db.Exec(`
-		INSERT INTO project_circuit_breakers (project_id, threshold_percentage)
-		VALUES ($1, $2)
+	_, err := db.Exec(`
+		INSERT INTO project_circuit_breakers (project_id, threshold_percentage, cooldown_minutes, mlp_exempt_from_halt)
+		VALUES ($1, $2, NULLIF($4, 0), COALESCE($5, false))
 		ON CONFLICT (project_id) 
-		DO UPDATE SET threshold_percentage = $2, last_checked = CURRENT_TIMESTAMP
-	`, settings.ProjectID, settings.ThresholdPercentage)
+		DO UPDATE SET threshold_percentage = $2,
+		    cooldown_minutes = CASE WHEN $3 THEN NULLIF($4, 0) ELSE project_circuit_breakers.cooldown_minutes END,
+		    mlp_exempt_from_halt = COALESCE($5, project_circuit_breakers.mlp_exempt_from_halt),
+		    last_checked = CURRENT_TIMESTAMP
+	`, settings.ProjectID, settings.ThresholdPercentage, settings.CooldownMinutes != nil, cooldownValue(settings.CooldownMinutes),
+		settings.MLPExemptFromHalt)
 
 	if err != nil {
 		log.Println("Error setting circuit breaker:", err)
-		http.Error(w, "Error setting circuit breaker", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error setting circuit breaker")
 		return
 	}
 
+	if err := refreshBreakerCache(db); err != nil {
+		log.Printf("⚠️ Warning: Could not refresh circuit breaker cache: %v", err)
+	}
+
 	log.Printf("✅ Circuit breaker threshold set to %.2f%% for project %d by admin (User ID: %d)",
 		settings.ThresholdPercentage, settings.ProjectID, userID)
 
@@ -106,23 +135,6 @@ func setCircuitBreakerThreshold(w http.ResponseWriter, r *http.Request) {
 
 // Get all circuit breaker statuses
 func getCircuitBreakerStatuses(w http.ResponseWriter, r *http.Request) {
-	token := r.Header.Get("Authorization")
-	if token == "" {
-		http.Error(w, "Unauthorized: No token provided", http.StatusUnauthorized)
-		return
-	}
-
-	userID, err := getUserIDFromToken(token, db)
-	if err != nil {
-		http.Error(w, "Unauthorized: Invalid token", http.StatusUnauthorized)
-		return
-	}
-
-	if !isAdmin(userID, db) {
-		http.Error(w, "Forbidden: Admin access required", http.StatusForbidden)
-		return
-	}
-
 	rows, err := db.Query(`
 		SELECT 
 			p.id, 
@@ -132,7 +144,12 @@ func getCircuitBreakerStatuses(w http.ResponseWriter, r *http.Request) {
 			COALESCE(cb.day_open_price, 0),
 			COALESCE(cb.current_price, 0),
 			COALESCE(cb.price_drop_percentage, 0),
+			cb.cooldown_minutes,
+			COALESCE(cb.halt_reason, ''),
+			COALESCE(cb.mlp_exempt_from_halt, false),
 			COALESCE(TO_CHAR(cb.halted_at, 'YYYY-MM-DD HH24:MI:SS'), ''),
+			COALESCE(TO_CHAR((SELECT MAX(mo.created_at) FROM matched_orders mo WHERE mo.project_id = p.id),
+				'YYYY-MM-DD HH24:MI:SS'), ''),
 			COALESCE(TO_CHAR(cb.last_checked, 'YYYY-MM-DD HH24:MI:SS'), '')
 		FROM projects p
 		LEFT JOIN project_circuit_breakers cb ON p.id = cb.project_id
@@ -140,7 +157,7 @@ func getCircuitBreakerStatuses(w http.ResponseWriter, r *http.Request) {
 	`)
 	if err != nil {
 		log.Println("Error fetching circuit breaker statuses:", err)
-		http.Error(w, "Error fetching statuses", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error fetching statuses")
 		return
 	}
 	defer rows.Close()
@@ -148,13 +165,18 @@ func getCircuitBreakerStatuses(w http.ResponseWriter, r *http.Request) {
 	statuses := []CircuitBreakerSettings{}
 	for rows.Next() {
 		var s CircuitBreakerSettings
+		var cooldown sql.NullInt64
 		err := rows.Scan(&s.ProjectID, &s.ProjectName, &s.ThresholdPercentage,
 			&s.IsHalted, &s.DayOpenPrice, &s.CurrentPrice, &s.PriceDropPercentage,
-			&s.HaltedAt, &s.LastChecked)
+			&cooldown, &s.HaltReason, &s.MLPExemptFromHalt, &s.HaltedAt, &s.LastMatchAt, &s.LastChecked)
 		if err != nil {
 			log.Println("Error scanning row:", err)
 			continue
 		}
+		if cooldown.Valid {
+			minutes := int(cooldown.Int64)
+			s.CooldownMinutes = &minutes
+		}
 		statuses = append(statuses, s)
 	}
 
@@ -164,28 +186,20 @@ func getCircuitBreakerStatuses(w http.ResponseWriter, r *http.Request) {
 
 // Reset circuit breaker for a project (manual resume)
 func resetCircuitBreaker(w http.ResponseWriter, r *http.Request) {
-	token := r.Header.Get("Authorization")
-	if token == "" {
-		http.Error(w, "Unauthorized: No token provided", http.StatusUnauthorized)
-		return
-	}
+	userID := userIDFromContext(r)
 
-	userID, err := getUserIDFromToken(token, db)
+	vars := mux.Vars(r)
+	projectIDStr := vars["project_id"]
+	projectID, err := strconv.Atoi(projectIDStr)
 	if err != nil {
-		http.Error(w, "Unauthorized: Invalid token", http.StatusUnauthorized)
+		writeJSONError(w, http.StatusBadRequest, "INVALID_PROJECT_ID", "Invalid project ID")
 		return
 	}
 
-	if !isAdmin(userID, db) {
-		http.Error(w, "Forbidden: Admin access required", http.StatusForbidden)
-		return
-	}
-
-	vars := mux.Vars(r)
-	projectIDStr := vars["project_id"]
-	projectID, err := strconv.Atoi(projectIDStr)
+	wasHalted, err := isProjectHalted(db, projectID)
 	if err != nil {
-		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		log.Println("Error checking circuit breaker:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error resetting circuit breaker")
 		return
 	}
 
@@ -193,6 +207,7 @@ func resetCircuitBreaker(w http.ResponseWriter, r *http.Request) {
 		UPDATE project_circuit_breakers
 		SET is_halted = false, 
 		    halted_at = NULL, 
+		    halt_reason = NULL,
 		    day_open_price = 0,
 		    current_price = 0,
 		    price_drop_percentage = 0,
@@ -202,10 +217,16 @@ func resetCircuitBreaker(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		log.Println("Error resetting circuit breaker:", err)
-		http.Error(w, "Error resetting circuit breaker", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error resetting circuit breaker")
 		return
 	}
 
+	// Resume matching right away rather than at the next breaker check
+	updateBreakerCache(projectID, false)
+	if wasHalted {
+		recordCircuitBreakerEvent(db, projectID, "resume", "admin_reset", nil, &userID)
+	}
+
 	log.Printf("✅ Circuit breaker manually reset for project %d by admin (User ID: %d)", projectID, userID)
 
 	w.Header().Set("Content-Type", "application/json")
@@ -215,6 +236,72 @@ func resetCircuitBreaker(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// Halt a project immediately, regardless of price movement (e.g. breaking news)
+func haltProject(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+
+	vars := mux.Vars(r)
+	projectID, err := strconv.Atoi(vars["project_id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_PROJECT_ID", "Invalid project ID")
+		return
+	}
+
+	if _, err := getProjectByID(db, projectID); err != nil {
+		if err == sql.ErrNoRows {
+			writeJSONError(w, http.StatusNotFound, "PROJECT_NOT_FOUND", "Project not found")
+		} else {
+			writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database error")
+		}
+		return
+	}
+
+	wasHalted, err := isProjectHalted(db, projectID)
+	if err != nil {
+		log.Println("Error checking circuit breaker:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error halting project")
+		return
+	}
+
+	// A threshold halt already in place becomes manual, so its cooldown no longer applies
+	_, err = db.Exec(`
+		INSERT INTO project_circuit_breakers (project_id, is_halted, halted_at, halt_reason)
+		VALUES ($1, true, NOW(), 'manual')
+		ON CONFLICT (project_id)
+		DO UPDATE SET is_halted = true,
+		    halted_at = NOW(),
+		    halt_reason = 'manual',
+		    last_checked = CURRENT_TIMESTAMP
+	`, projectID)
+	if err != nil {
+		log.Println("Error halting project:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error halting project")
+		return
+	}
+
+	// The matcher reads the cache, so this takes effect on its next pass
+	updateBreakerCache(projectID, true)
+	if !wasHalted {
+		circuitBreakerHaltsTotal.Inc()
+		recordCircuitBreakerEvent(db, projectID, "halt", "manual", nil, &userID)
+	}
+
+	log.Printf("🛑 Project %d manually halted by admin (User ID: %d)", projectID, userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Project %d halted - Trading suspended until reset", projectID),
+	})
+}
+
+func cooldownValue(minutes *int) int {
+	if minutes == nil {
+		return 0
+	}
+	return *minutes
+}
+
 // Check if a project is halted
 func isProjectHalted(database *sql.DB, projectID int) (bool, error) {
 	var isHalted bool
@@ -231,8 +318,89 @@ func isProjectHalted(database *sql.DB, projectID int) (bool, error) {
 	return isHalted, err
 }
 
+// Resume halted projects whose cooldown has elapsed. The current price becomes
+// the new day-open reference so the same drop doesn't halt them again.
+func autoResumeCircuitBreakers(database *sql.DB) (int, error) {
+	rows, err := database.Query(`
+		UPDATE project_circuit_breakers
+		SET is_halted = false,
+		    halted_at = NULL,
+		    halt_reason = NULL,
+		    day_open_price = current_price,
+		    price_drop_percentage = 0,
+		    last_checked = CURRENT_TIMESTAMP
+		WHERE is_halted = true
+		AND COALESCE(halt_reason, 'threshold') = 'threshold'
+		AND cooldown_minutes IS NOT NULL
+		AND halted_at <= NOW() - cooldown_minutes * INTERVAL '1 minute'
+		RETURNING project_id, cooldown_minutes, current_price
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var resumedIDs []int
+	for rows.Next() {
+		var projectID, cooldown int
+		var currentPrice float64
+		if err := rows.Scan(&projectID, &cooldown, &currentPrice); err != nil {
+			continue
+		}
+		resumedIDs = append(resumedIDs, projectID)
+		log.Printf("▶️ CIRCUIT BREAKER COOLDOWN ELAPSED - Project %d auto-resumed after %d min (new reference $%.2f)",
+			projectID, cooldown, currentPrice)
+	}
+	if err := rows.Err(); err != nil {
+		return len(resumedIDs), err
+	}
+	rows.Close()
+
+	for _, projectID := range resumedIDs {
+		recordCircuitBreakerEvent(database, projectID, "resume", "cooldown", nil, nil)
+	}
+	return len(resumedIDs), nil
+}
+
+// Periodic breaker check (CIRCUIT_BREAKER_CHECK_INTERVAL) so cooldowns expire
+// even when no orders arrive
+func startCircuitBreakerMonitor(database *sql.DB) {
+	interval := getEnvDuration("CIRCUIT_BREAKER_CHECK_INTERVAL", 30*time.Second)
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			resumed, err := autoResumeCircuitBreakers(database)
+			if err != nil {
+				log.Printf("⚠️ Circuit breaker auto-resume failed: %v", err)
+			}
+
+			if err := checkAndUpdateCircuitBreakers(database); err != nil {
+				log.Printf("⚠️ Circuit breaker check failed: %v", err)
+				continue
+			}
+
+			// Orders for a resumed project may already be crossable
+			if resumed > 0 {
+				if err := checkAndTriggerMatching(database); err != nil {
+					log.Printf("⚠️ Matching after auto-resume failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
 // Check and update circuit breakers based on price movements
 func checkAndUpdateCircuitBreakers(database *sql.DB) error {
+	if _, err := autoResumeCircuitBreakers(database); err != nil {
+		log.Printf("⚠️ Warning: Circuit breaker auto-resume failed: %v", err)
+	}
+
 	rows, err := database.Query(`
 		SELECT project_id, threshold_percentage, day_open_price, is_halted
 		FROM project_circuit_breakers
@@ -258,14 +426,35 @@ func checkAndUpdateCircuitBreakers(database *sql.DB) error {
 			continue
 		}
 
+		// Stale price guard - no recent trade, no decision either way
+		// (age computed in SQL - created_at is a plain TIMESTAMP in the DB's zone)
+		var lastMatchAgeSeconds sql.NullFloat64
+		err = database.QueryRow(`
+			SELECT EXTRACT(EPOCH FROM LOCALTIMESTAMP - MAX(created_at))
+			FROM matched_orders
+			WHERE project_id = $1
+			AND DATE(created_at) = CURRENT_DATE
+			AND `+countedTradeCondition+`
+		`, projectID).Scan(&lastMatchAgeSeconds)
+		if err != nil || !lastMatchAgeSeconds.Valid {
+			continue
+		}
+		age := time.Duration(lastMatchAgeSeconds.Float64 * float64(time.Second))
+		if breakerPriceFreshness > 0 && age > breakerPriceFreshness {
+			log.Printf("⏸️ Circuit breaker check skipped for project %d - last trade %s ago is older than %s",
+				projectID, age.Round(time.Second), breakerPriceFreshness)
+			continue
+		}
+
 		// Get current price (latest matched order today)
 		var currentPrice float64
 		err = database.QueryRow(`
-			SELECT COALESCE(AVG((buyer_price + seller_price) / 2), 0)
+			SELECT (buyer_price + seller_price) / 2
 			FROM matched_orders
 			WHERE project_id = $1
 			AND DATE(created_at) = CURRENT_DATE
-			ORDER BY created_at DESC
+			AND `+countedTradeCondition+`
+			ORDER BY created_at DESC, id DESC
 			LIMIT 1
 		`, projectID).Scan(&currentPrice)
 
@@ -276,11 +465,12 @@ func checkAndUpdateCircuitBreakers(database *sql.DB) error {
 		// If no day open price set, use first price of the day
 		if dayOpenPrice == 0 {
 			err = database.QueryRow(`
-				SELECT COALESCE(AVG((buyer_price + seller_price) / 2), 0)
+				SELECT (buyer_price + seller_price) / 2
 				FROM matched_orders
 				WHERE project_id = $1
 				AND DATE(created_at) = CURRENT_DATE
-				ORDER BY created_at ASC
+				AND `+countedTradeCondition+`
+				ORDER BY created_at ASC, id ASC
 				LIMIT 1
 			`, projectID).Scan(&dayOpenPrice)
 
@@ -310,39 +500,79 @@ func checkAndUpdateCircuitBreakers(database *sql.DB) error {
 
 		// Check if threshold breached
 		if priceDropPct >= threshold {
-			_, err := database.Exec(`
+			result, err := database.Exec(`
 				UPDATE project_circuit_breakers
 				SET is_halted = true, 
-				    halted_at = CURRENT_TIMESTAMP
+				    halted_at = CURRENT_TIMESTAMP,
+				    halt_reason = 'threshold'
 				WHERE project_id = $1 AND is_halted = false
 			`, projectID)
 
 			if err == nil {
+				if rows, _ := result.RowsAffected(); rows > 0 {
+					circuitBreakerHaltsTotal.Inc()
+					recordCircuitBreakerEvent(database, projectID, "halt", "threshold", &priceDropPct, nil)
+				}
 				log.Printf("🚨 CIRCUIT BREAKER TRIGGERED - Project %d halted (%.2f%% drop from $%.2f to $%.2f)",
 					projectID, priceDropPct, dayOpenPrice, currentPrice)
 			}
 		}
 	}
 
-	return nil
+	// Sync the matcher's cache with the DB (covers new halts and manual changes)
+	return refreshBreakerCache(database)
 }
 
 // Reset all circuit breakers at start of new day (run daily)
 func resetDailyCircuitBreakers(database *sql.DB) error {
-	_, err := database.Exec(`
-		UPDATE project_circuit_breakers
+	// prev carries is_halted from before the update, so only real halts log a resume
+	rows, err := database.Query(`
+		WITH prev AS (
+			SELECT project_id, COALESCE(is_halted, false) AS was_halted
+			FROM project_circuit_breakers
+			WHERE DATE(last_checked) < CURRENT_DATE
+			AND COALESCE(halt_reason, '') <> 'manual'
+			FOR UPDATE
+		)
+		UPDATE project_circuit_breakers cb
 		SET is_halted = false,
 		    halted_at = NULL,
+		    halt_reason = NULL,
 		    day_open_price = 0,
 		    current_price = 0,
 		    price_drop_percentage = 0,
 		    last_checked = CURRENT_TIMESTAMP
-		WHERE DATE(last_checked) < CURRENT_DATE
+		FROM prev
+		WHERE cb.project_id = prev.project_id
+		RETURNING cb.project_id, prev.was_halted
 	`)
 
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
+
+	// Clear the matcher's cached halts for exactly the rows reset above
+	var resumedIDs []int
+	for rows.Next() {
+		var projectID int
+		var wasHalted bool
+		if err := rows.Scan(&projectID, &wasHalted); err != nil {
+			return err
+		}
+		updateBreakerCache(projectID, false)
+		if wasHalted {
+			resumedIDs = append(resumedIDs, projectID)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for _, projectID := range resumedIDs {
+		recordCircuitBreakerEvent(database, projectID, "resume", "daily_reset", nil, nil)
+	}
 
 	log.Println("✅ Daily circuit breaker reset completed - All projects ready for new trading day")
 	return nil
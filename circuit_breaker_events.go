@@ -0,0 +1,122 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// project_circuit_breakers only holds the current state; every halt and
+// resume is also appended here so the record survives a reset
+type CircuitBreakerEvent struct {
+	ID                  int       `json:"id"`
+	ProjectID           int       `json:"project_id"`
+	EventType           string    `json:"event_type"` // halt or resume
+	Reason              string    `json:"reason"`     // threshold, manual, admin_reset, cooldown or daily_reset
+	PriceDropPercentage *float64  `json:"price_drop_percentage"`
+	ActedBy             *int      `json:"acted_by"` // admin user for manual halts and resets
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+func initCircuitBreakerEventsTable(database *sql.DB) {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS circuit_breaker_events (
+			id SERIAL PRIMARY KEY,
+			project_id INTEGER NOT NULL,
+			event_type VARCHAR(10) NOT NULL CHECK (event_type IN ('halt', 'resume')),
+			reason VARCHAR(20) NOT NULL,
+			price_drop_percentage DECIMAL(5, 2),
+			acted_by INTEGER,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_circuit_breaker_events_project_created ON circuit_breaker_events (project_id, created_at DESC)`,
+	}
+
+	for _, query := range queries {
+		if _, err := database.Exec(query); err != nil {
+			log.Printf("Warning: Could not create circuit_breaker_events table: %v", err)
+		}
+	}
+}
+
+// Best effort - a failed insert is logged but never blocks the halt or resume itself.
+// dropPct and actedBy are optional.
+func recordCircuitBreakerEvent(database *sql.DB, projectID int, eventType, reason string, dropPct *float64, actedBy *int) {
+	_, err := database.Exec(`
+		INSERT INTO circuit_breaker_events (project_id, event_type, reason, price_drop_percentage, acted_by)
+		VALUES ($1, $2, $3, $4, $5)
+	`, projectID, eventType, reason, dropPct, actedBy)
+	if err != nil {
+		log.Printf("⚠️ Warning: Could not record circuit breaker %s event for project %d: %v", eventType, projectID, err)
+	}
+
+	event := adminEventBreakerHalted
+	if eventType == "resume" {
+		event = adminEventBreakerResumed
+	}
+	publishAdminEvent(event, projectID, map[string]interface{}{
+		"reason":                reason,
+		"price_drop_percentage": dropPct,
+		"acted_by":              actedBy,
+	})
+}
+
+// GET /api/admin/circuit-breaker/history?project_id=&limit= - newest first (admin)
+func getCircuitBreakerHistory(w http.ResponseWriter, r *http.Request) {
+	projectID := 0
+	if projectIDStr := r.URL.Query().Get("project_id"); projectIDStr != "" {
+		var err error
+		projectID, err = strconv.Atoi(projectIDStr)
+		if err != nil || projectID <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "INVALID_PROJECT_ID", "Invalid project ID")
+			return
+		}
+	}
+
+	limit := 100
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > 1000 {
+			writeJSONError(w, http.StatusBadRequest, "INVALID_LIMIT", "limit must be between 1 and 1000")
+			return
+		}
+	}
+
+	rows, err := db.Query(`
+		SELECT id, project_id, event_type, reason, price_drop_percentage, acted_by, created_at
+		FROM circuit_breaker_events
+		WHERE $1 = 0 OR project_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`, projectID, limit)
+	if err != nil {
+		log.Println("Error fetching circuit breaker history:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error fetching circuit breaker history")
+		return
+	}
+	defer rows.Close()
+
+	events := []CircuitBreakerEvent{}
+	for rows.Next() {
+		var e CircuitBreakerEvent
+		var dropPct sql.NullFloat64
+		var actedBy sql.NullInt64
+		if err := rows.Scan(&e.ID, &e.ProjectID, &e.EventType, &e.Reason, &dropPct, &actedBy, &e.CreatedAt); err != nil {
+			log.Println("Error scanning circuit breaker event:", err)
+			continue
+		}
+		e.PriceDropPercentage = nullFloatPtr(dropPct)
+		if actedBy.Valid {
+			admin := int(actedBy.Int64)
+			e.ActedBy = &admin
+		}
+		events = append(events, e)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// Halts the project as the breaker would have minutesAgo, with a cooldown
+func haltTestProject(t *testing.T, projectID int, reason string, minutesAgo, cooldown int) {
+	t.Helper()
+	_, err := db.Exec(`
+		INSERT INTO project_circuit_breakers
+			(project_id, threshold_percentage, is_halted, halted_at, halt_reason, cooldown_minutes, day_open_price, current_price, price_drop_percentage)
+		VALUES ($1, 10, true, LOCALTIMESTAMP - $2 * INTERVAL '1 minute', $3, $4, 100, 80, 20)
+		ON CONFLICT (project_id) DO UPDATE
+		SET is_halted = true, halted_at = EXCLUDED.halted_at, halt_reason = EXCLUDED.halt_reason, cooldown_minutes = EXCLUDED.cooldown_minutes
+	`, projectID, minutesAgo, reason, cooldown)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCircuitBreakerCooldownAutoResume(t *testing.T) {
+	openTestDB(t)
+	manual := createTestProject(t, "Manual")
+
+	haltTestProject(t, defaultProjectID, "threshold", 4, 5)
+	haltTestProject(t, manual, "manual", 60, 5)
+	if n, err := autoResumeCircuitBreakers(db); err != nil || n != 0 {
+		t.Fatalf("resumed %d (err %v) a minute before the cooldown ends, want 0", n, err)
+	}
+	if halted, _ := isProjectHalted(db, defaultProjectID); !halted {
+		t.Fatal("project resumed before its cooldown elapsed")
+	}
+
+	haltTestProject(t, defaultProjectID, "threshold", 6, 5)
+	if n, err := autoResumeCircuitBreakers(db); err != nil || n != 1 {
+		t.Fatalf("resumed %d (err %v) after the cooldown, want 1", n, err)
+	}
+	if halted, _ := isProjectHalted(db, defaultProjectID); halted {
+		t.Error("project still halted after its cooldown elapsed")
+	}
+	var dayOpen float64
+	if err := db.QueryRow("SELECT day_open_price FROM project_circuit_breakers WHERE project_id = $1", defaultProjectID).Scan(&dayOpen); err != nil {
+		t.Fatal(err)
+	}
+	if dayOpen != 80 {
+		t.Errorf("day-open reference = %v after resuming, want the current price 80", dayOpen)
+	}
+
+	if halted, _ := isProjectHalted(db, manual); !halted {
+		t.Error("manual halt lifted by the cooldown")
+	}
+}
+
+func TestMatcherSkipsManuallyHaltedProject(t *testing.T) {
+	openTestDB(t)
+	_, adminToken := createTestUser(t, "admin", true)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+	halted := createTestProject(t, "Halted")
+
+	for _, projectID := range []int{defaultProjectID, halted} {
+		placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 10, Quantity: wholeQuantity(5), ProjectID: intPtr(projectID)})
+		placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 10, Quantity: wholeQuantity(5), ProjectID: intPtr(projectID)})
+	}
+
+	rec := doTestRequest(t, http.MethodPost, fmt.Sprintf("/api/v1/admin/circuit-breaker/halt/%d", halted), adminToken, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("halt: status %d (%s)", rec.Code, rec.Body.String())
+	}
+	if !isProjectHaltedCached(halted) {
+		t.Fatal("halt did not reach the breaker cache")
+	}
+
+	if _, err := runMatching(db, 0); err != nil {
+		t.Fatalf("matching: %v", err)
+	}
+
+	matchedIn := func(projectID int) int {
+		t.Helper()
+		var n int
+		if err := db.QueryRow("SELECT COUNT(*) FROM matched_orders WHERE project_id = $1", projectID).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if n := matchedIn(defaultProjectID); n != 1 {
+		t.Errorf("%d matches in the running project, want 1", n)
+	}
+	if n := matchedIn(halted); n != 0 {
+		t.Errorf("%d matches in the manually halted project, want 0", n)
+	}
+	if stillHalted, _ := isProjectHalted(db, halted); !stillHalted {
+		t.Error("matching pass lifted the manual halt")
+	}
+}
+
+func TestStaleLastTradeDoesNotHalt(t *testing.T) {
+	openTestDB(t)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+
+	// A trade at half the day-open price is well past a 10% threshold
+	matchedID := tradeTestOrders(t, defaultProjectID, buyerUser, sellerUser, 50, 1)
+	_, err := db.Exec(`
+		INSERT INTO project_circuit_breakers (project_id, threshold_percentage, is_halted, day_open_price)
+		VALUES ($1, 10, false, 100)
+	`, defaultProjectID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setTradeAge := func(age time.Duration) {
+		t.Helper()
+		_, err := db.Exec("UPDATE matched_orders SET created_at = LOCALTIMESTAMP - $1 * INTERVAL '1 second' WHERE id = $2",
+			age.Seconds(), matchedID)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	setTradeAge(breakerPriceFreshness + time.Minute)
+	if err := checkAndUpdateCircuitBreakers(db); err != nil {
+		t.Fatal(err)
+	}
+	if halted, _ := isProjectHalted(db, defaultProjectID); halted {
+		t.Fatal("halted on a trade older than the freshness window")
+	}
+
+	setTradeAge(0)
+	if err := checkAndUpdateCircuitBreakers(db); err != nil {
+		t.Fatal(err)
+	}
+	if halted, _ := isProjectHalted(db, defaultProjectID); !halted {
+		t.Error("fresh trade past the threshold did not halt")
+	}
+}
+
+func TestMLPBuyerTradesThroughExemptHalt(t *testing.T) {
+	openTestDB(t)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+	exempt := createTestProject(t, "Exempt")
+	plainBuyers := createTestProject(t, "Plain buyers")
+	notExempt := createTestProject(t, "Not exempt")
+
+	for _, p := range []struct {
+		projectID int
+		mlpBuyer  bool
+		exempt    bool
+	}{
+		{exempt, true, true},
+		{plainBuyers, false, true},
+		{notExempt, true, false},
+	} {
+		haltTestProject(t, p.projectID, "manual", 0, 5)
+		if _, err := db.Exec("UPDATE project_circuit_breakers SET mlp_exempt_from_halt = $1 WHERE project_id = $2", p.exempt, p.projectID); err != nil {
+			t.Fatal(err)
+		}
+		placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 10, Quantity: wholeQuantity(1), ProjectID: intPtr(p.projectID)})
+		placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 10, Quantity: wholeQuantity(1), MarketLeadProgram: p.mlpBuyer, ProjectID: intPtr(p.projectID)})
+	}
+
+	if _, err := runMatching(db, 0); err != nil {
+		t.Fatalf("matching: %v", err)
+	}
+
+	for _, want := range []struct {
+		projectID int
+		matches   int
+		why       string
+	}{
+		{exempt, 1, "MLP buyer in an exempt halted project"},
+		{plainBuyers, 0, "non-MLP orders in an exempt halted project"},
+		{notExempt, 0, "MLP buyer in a halted project without the exemption"},
+	} {
+		var n int
+		if err := db.QueryRow("SELECT COUNT(*) FROM matched_orders WHERE project_id = $1", want.projectID).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n != want.matches {
+			t.Errorf("%s: %d matches, want %d", want.why, n, want.matches)
+		}
+	}
+}
+
+func TestResetClearsBreakerCacheImmediately(t *testing.T) {
+	openTestDB(t)
+	_, adminToken := createTestUser(t, "admin", true)
+	daily := createTestProject(t, "Daily")
+	manual := createTestProject(t, "Manual")
+
+	haltTestProject(t, defaultProjectID, "manual", 0, 5)
+	haltTestProject(t, daily, "threshold", 0, 0)
+	haltTestProject(t, manual, "manual", 0, 0)
+	if _, err := db.Exec("UPDATE project_circuit_breakers SET last_checked = CURRENT_DATE - INTERVAL '1 day'"); err != nil {
+		t.Fatal(err)
+	}
+	if err := refreshBreakerCache(db); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := doTestRequest(t, http.MethodPost, fmt.Sprintf("/api/v1/admin/circuit-breaker/reset/%d", defaultProjectID), adminToken, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("reset: status %d (%s)", rec.Code, rec.Body.String())
+	}
+	if isProjectHaltedCached(defaultProjectID) {
+		t.Error("cache still reports the reset project halted")
+	}
+
+	if err := resetDailyCircuitBreakers(db); err != nil {
+		t.Fatal(err)
+	}
+	if isProjectHaltedCached(daily) {
+		t.Error("cache still reports the daily-reset project halted")
+	}
+	if !isProjectHaltedCached(manual) {
+		t.Error("daily reset cleared a manual halt from the cache")
+	}
+}
+
+func TestTriggeredHaltWritesEvent(t *testing.T) {
+	openTestDB(t)
+	adminID, adminToken := createTestUser(t, "admin", true)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+	other := createTestProject(t, "Other")
+
+	tradeTestOrders(t, defaultProjectID, buyerUser, sellerUser, 60, 1)
+	_, err := db.Exec(`
+		INSERT INTO project_circuit_breakers (project_id, threshold_percentage, is_halted, day_open_price)
+		VALUES ($1, 10, false, 100)
+	`, defaultProjectID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkAndUpdateCircuitBreakers(db); err != nil {
+		t.Fatal(err)
+	}
+	haltTestProject(t, other, "manual", 0, 0)
+	recordCircuitBreakerEvent(db, other, "halt", "manual", nil, &adminID)
+
+	rec := doTestRequest(t, http.MethodPost, fmt.Sprintf("/api/v1/admin/circuit-breaker/reset/%d", defaultProjectID), adminToken, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("reset: status %d (%s)", rec.Code, rec.Body.String())
+	}
+
+	rec = doTestRequest(t, http.MethodGet, fmt.Sprintf("/api/v1/admin/circuit-breaker/history?project_id=%d", defaultProjectID), adminToken, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("history: status %d (%s)", rec.Code, rec.Body.String())
+	}
+	var events []CircuitBreakerEvent
+	decodeTestResponse(t, rec, &events)
+	if len(events) != 2 {
+		t.Fatalf("got %d events for the project, want the halt and the reset: %+v", len(events), events)
+	}
+
+	// Newest first
+	resume, halt := events[0], events[1]
+	if halt.EventType != "halt" || halt.Reason != "threshold" || halt.ActedBy != nil {
+		t.Errorf("halt event = %s/%s by %v, want a threshold halt with no admin", halt.EventType, halt.Reason, halt.ActedBy)
+	}
+	if halt.PriceDropPercentage == nil || *halt.PriceDropPercentage != 40 {
+		t.Errorf("halt recorded a drop of %v, want 40%%", halt.PriceDropPercentage)
+	}
+	if resume.EventType != "resume" || resume.ActedBy == nil || *resume.ActedBy != adminID {
+		t.Errorf("reset event = %s by %v, want a resume by admin %d", resume.EventType, resume.ActedBy, adminID)
+	}
+}
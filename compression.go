@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// Responses smaller than this go out uncompressed (GZIP_MIN_BYTES); gzip
+// framing would eat most of the saving
+var gzipMinBytes = getEnvInt("GZIP_MIN_BYTES", 1024)
+
+// Buffers the response until it reaches gzipMinBytes, then switches to gzip.
+// Anything that finishes below the threshold is written as-is.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	status  int
+	buf     bytes.Buffer
+	gz      *gzip.Writer
+	written bool // headers sent, plain or compressed
+}
+
+func (g *gzipResponseWriter) WriteHeader(status int) {
+	if g.status == 0 {
+		g.status = status
+	}
+}
+
+func (g *gzipResponseWriter) Write(p []byte) (int, error) {
+	if g.status == 0 {
+		g.status = http.StatusOK
+	}
+	if g.gz != nil {
+		return g.gz.Write(p)
+	}
+	if g.written {
+		return g.ResponseWriter.Write(p)
+	}
+
+	g.buf.Write(p)
+	if g.buf.Len() < gzipMinBytes {
+		return len(p), nil
+	}
+
+	header := g.Header()
+	if header.Get("Content-Encoding") != "" || !bodyAllowedForStatus(g.status) {
+		// Already encoded by the handler - pass through untouched
+		g.flushPlain()
+		return len(p), nil
+	}
+	header.Set("Content-Encoding", "gzip")
+	header.Del("Content-Length")
+	g.ResponseWriter.WriteHeader(g.status)
+	g.written = true
+
+	g.gz = gzip.NewWriter(g.ResponseWriter)
+	if _, err := g.gz.Write(g.buf.Bytes()); err != nil {
+		return 0, err
+	}
+	g.buf.Reset()
+	return len(p), nil
+}
+
+func (g *gzipResponseWriter) flushPlain() {
+	if g.written {
+		return
+	}
+	if g.status == 0 {
+		g.status = http.StatusOK
+	}
+	g.ResponseWriter.WriteHeader(g.status)
+	g.written = true
+	if g.buf.Len() > 0 {
+		g.ResponseWriter.Write(g.buf.Bytes())
+		g.buf.Reset()
+	}
+}
+
+// Sends what has been written so far, so streaming handlers aren't held up by
+// the buffer or the compressor. A response flushed below gzipMinBytes goes out
+// uncompressed.
+func (g *gzipResponseWriter) Flush() {
+	if g.gz != nil {
+		g.gz.Flush()
+	} else {
+		g.flushPlain()
+	}
+	if f, ok := g.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Sends whatever is still buffered once the handler returns
+func (g *gzipResponseWriter) finish() {
+	if g.gz != nil {
+		g.gz.Close()
+		return
+	}
+	if g.status == 0 && g.buf.Len() == 0 {
+		return // handler wrote nothing; net/http sends its default 200
+	}
+	g.flushPlain()
+}
+
+func bodyAllowedForStatus(status int) bool {
+	return !(status >= 100 && status <= 199) && status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+// Gzips responses of gzipMinBytes or more for clients that send
+// Accept-Encoding: gzip. WebSocket upgrades need the raw writer (Hijacker)
+// and /metrics negotiates its own compression, so both are passed through.
+func gzipMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/metrics" || websocket.IsWebSocketUpgrade(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// The response depends on Accept-Encoding whether or not this one is compressed
+		w.Header().Add("Vary", "Accept-Encoding")
+		if r.Method == http.MethodHead || !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(gw, r)
+		gw.finish()
+	})
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding := strings.TrimSpace(part)
+		if i := strings.Index(coding, ";"); i != -1 {
+			if strings.ReplaceAll(coding[i+1:], " ", "") == "q=0" {
+				continue
+			}
+			coding = strings.TrimSpace(coding[:i])
+		}
+		if strings.EqualFold(coding, "gzip") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Writes first, flushes, checks what reached the client, then writes rest.
+// The whole stack is wrapped the way newHandler wraps routes.
+func runFlushingHandler(t *testing.T, acceptGzip bool, first, rest string, checkFlushed func(*httptest.ResponseRecorder)) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler := metricsMiddleware(gzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, first)
+		f, ok := w.(http.Flusher)
+		if !ok {
+			t.Fatal("response writer does not implement http.Flusher")
+		}
+		f.Flush()
+		if !rec.Flushed {
+			t.Error("flush did not reach the client")
+		}
+		checkFlushed(rec)
+		io.WriteString(w, rest)
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/stream", nil)
+	if acceptGzip {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func gunzip(t *testing.T, body []byte) string {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	out, err := io.ReadAll(zr)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		t.Fatalf("gunzip: %v", err)
+	}
+	return string(out)
+}
+
+func TestGzipMiddlewareFlushesCompressed(t *testing.T) {
+	first := strings.Repeat("a", gzipMinBytes+10)
+	rest := strings.Repeat("b", 100)
+
+	rec := runFlushingHandler(t, true, first, rest, func(rec *httptest.ResponseRecorder) {
+		// A sync flush makes everything written so far decodable
+		if got := gunzip(t, rec.Body.Bytes()); got != first {
+			t.Errorf("flushed %d bytes, want the %d written before the flush", len(got), len(first))
+		}
+	})
+
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", rec.Header().Get("Content-Encoding"))
+	}
+	if got := gunzip(t, rec.Body.Bytes()); got != first+rest {
+		t.Errorf("body is %d bytes, want %d", len(got), len(first+rest))
+	}
+}
+
+func TestGzipMiddlewareFlushesUncompressed(t *testing.T) {
+	for _, acceptGzip := range []bool{false, true} {
+		// Flushed below the threshold, so it goes out plain either way
+		rec := runFlushingHandler(t, acceptGzip, "small", " tail", func(rec *httptest.ResponseRecorder) {
+			if rec.Body.String() != "small" {
+				t.Errorf("accept gzip %v: flushed %q, want %q", acceptGzip, rec.Body.String(), "small")
+			}
+		})
+		if rec.Header().Get("Content-Encoding") != "" {
+			t.Errorf("accept gzip %v: Content-Encoding = %q, want none", acceptGzip, rec.Header().Get("Content-Encoding"))
+		}
+		if rec.Body.String() != "small tail" {
+			t.Errorf("accept gzip %v: body = %q, want %q", acceptGzip, rec.Body.String(), "small tail")
+		}
+	}
+}
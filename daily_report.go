@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// One project's trading day. Price fields are null when the project had no
+// trades that day. Prices are execution prices (the maker's price).
+type DailyProjectReport struct {
+	ProjectID    int      `json:"project_id"`
+	ProjectName  string   `json:"project_name"`
+	Open         *float64 `json:"open"`
+	Close        *float64 `json:"close"`
+	High         *float64 `json:"high"`
+	Low          *float64 `json:"low"`
+	VWAP         *float64 `json:"vwap"`
+	TotalMatches int      `json:"total_matches"`
+	TotalVolume  Quantity `json:"total_volume"`
+	Halts        int      `json:"halts"`
+	ActiveUsers  int      `json:"active_users"` // distinct buyers and sellers that traded
+}
+
+type DailyReport struct {
+	Date        string               `json:"date"`
+	GeneratedAt time.Time            `json:"generated_at"`
+	Projects    []DailyProjectReport `json:"projects"`
+}
+
+// Builds the report for date (YYYY-MM-DD, "" = today in the DB's zone). The
+// day's bounds are computed in SQL - created_at is a plain TIMESTAMP.
+func buildDailyReport(ctx context.Context, database *sql.DB, date string) (*DailyReport, error) {
+	report := &DailyReport{GeneratedAt: time.Now(), Projects: []DailyProjectReport{}}
+
+	rows, err := database.QueryContext(ctx, `
+		WITH day AS (
+			SELECT COALESCE(NULLIF($1, '')::DATE, CURRENT_DATE) AS d
+		), trades AS (
+			SELECT mo.id, mo.project_id, mo.created_at, mo.matched_qty, mo.buyer_user_id, mo.seller_user_id,
+			       COALESCE(mo.execution_price, mo.seller_price) AS price
+			FROM matched_orders mo, day
+			WHERE mo.created_at >= day.d AND mo.created_at < day.d + 1
+			AND `+countedTradeCondition+`
+		)
+		SELECT TO_CHAR((SELECT d FROM day), 'YYYY-MM-DD'), p.id, p.name,
+		       (array_agg(t.price ORDER BY t.created_at ASC, t.id ASC))[1],
+		       (array_agg(t.price ORDER BY t.created_at DESC, t.id DESC))[1],
+		       MAX(t.price), MIN(t.price),
+		       SUM(t.price * t.matched_qty) / NULLIF(SUM(t.matched_qty), 0),
+		       COUNT(t.id), COALESCE(SUM(t.matched_qty), 0),
+		       (SELECT COUNT(*) FROM circuit_breaker_events e, day
+		        WHERE e.project_id = p.id AND e.event_type = 'halt'
+		        AND e.created_at >= day.d AND e.created_at < day.d + 1),
+		       (SELECT COUNT(DISTINCT u) FROM trades t2, unnest(ARRAY[t2.buyer_user_id, t2.seller_user_id]) AS u
+		        WHERE t2.project_id = p.id)
+		FROM projects p
+		LEFT JOIN trades t ON t.project_id = p.id
+		GROUP BY p.id, p.name
+		ORDER BY p.id
+	`, date)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pr DailyProjectReport
+		var open, closePrice, high, low, vwap sql.NullFloat64
+		if err := rows.Scan(&report.Date, &pr.ProjectID, &pr.ProjectName, &open, &closePrice, &high, &low, &vwap,
+			&pr.TotalMatches, &pr.TotalVolume, &pr.Halts, &pr.ActiveUsers); err != nil {
+			return nil, err
+		}
+		pr.Open = nullFloatPtr(open)
+		pr.Close = nullFloatPtr(closePrice)
+		pr.High = nullFloatPtr(high)
+		pr.Low = nullFloatPtr(low)
+		if vwap.Valid {
+			rounded := roundMoney(vwap.Float64)
+			pr.VWAP = &rounded
+		}
+		report.Projects = append(report.Projects, pr)
+	}
+	return report, rows.Err()
+}
+
+func nullFloatPtr(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Float64
+}
+
+// GET /api/admin/daily-report?date=YYYY-MM-DD - end-of-day summary per project (admin)
+func getDailyReport(w http.ResponseWriter, r *http.Request) {
+	date := r.URL.Query().Get("date")
+	if date != "" {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "INVALID_DATE", "date must be in YYYY-MM-DD format")
+			return
+		}
+	}
+
+	ctx, cancel := requestQueryContext(r)
+	defer cancel()
+
+	report, err := buildDailyReport(ctx, dbRead, date)
+	if err != nil {
+		writeQueryError(w, ctx, err, "Error building daily report")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDailyReportForSeededProject(t *testing.T) {
+	openTestDB(t)
+	_, adminToken := createTestUser(t, "admin", true)
+	firstBuyer, _ := createTestUser(t, "buyer1", false)
+	secondBuyer, _ := createTestUser(t, "buyer2", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+
+	tradeTestOrders(t, defaultProjectID, firstBuyer, sellerUser, 10, 1)
+	tradeTestOrders(t, defaultProjectID, secondBuyer, sellerUser, 14, 2)
+	tradeTestOrders(t, defaultProjectID, firstBuyer, sellerUser, 8, 1)
+	yesterdays := tradeTestOrders(t, defaultProjectID, firstBuyer, sellerUser, 100, 1)
+	if _, err := db.Exec("UPDATE matched_orders SET created_at = created_at - INTERVAL '1 day' WHERE id = $1", yesterdays); err != nil {
+		t.Fatal(err)
+	}
+	recordCircuitBreakerEvent(db, defaultProjectID, "halt", "manual", nil, nil)
+
+	report := func(query string) DailyProjectReport {
+		t.Helper()
+		rec := doTestRequest(t, http.MethodGet, "/api/v1/admin/daily-report"+query, adminToken, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("daily report: status %d (%s)", rec.Code, rec.Body.String())
+		}
+		var r DailyReport
+		decodeTestResponse(t, rec, &r)
+		if len(r.Projects) != 1 || r.Projects[0].ProjectID != defaultProjectID {
+			t.Fatalf("report projects = %+v, want only project %d", r.Projects, defaultProjectID)
+		}
+		return r.Projects[0]
+	}
+	price := func(p *float64) float64 {
+		if p == nil {
+			return -1
+		}
+		return *p
+	}
+
+	today := report("")
+	if price(today.Open) != 10 || price(today.Close) != 8 || price(today.High) != 14 || price(today.Low) != 8 {
+		t.Errorf("open/close/high/low = %v/%v/%v/%v, want 10/8/14/8",
+			price(today.Open), price(today.Close), price(today.High), price(today.Low))
+	}
+	// (10*1 + 14*2 + 8*1) / 4
+	if price(today.VWAP) != 11.5 {
+		t.Errorf("vwap = %v, want 11.5", price(today.VWAP))
+	}
+	if today.TotalMatches != 3 || today.TotalVolume != wholeQuantity(4) {
+		t.Errorf("%d matches for %s, want 3 for 4", today.TotalMatches, today.TotalVolume)
+	}
+	if today.Halts != 1 || today.ActiveUsers != 3 {
+		t.Errorf("%d halts and %d active users, want 1 and 3", today.Halts, today.ActiveUsers)
+	}
+
+	dbToday, err := databaseToday(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	yesterday := report("?date=" + dbToday.AddDate(0, 0, -1).Format("2006-01-02"))
+	if yesterday.TotalMatches != 1 || price(yesterday.Open) != 100 || yesterday.Halts != 0 || yesterday.ActiveUsers != 2 {
+		t.Errorf("yesterday = %d matches opening at %v, %d halts, %d users; want 1 at 100, 0, 2",
+			yesterday.TotalMatches, price(yesterday.Open), yesterday.Halts, yesterday.ActiveUsers)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	pauseReasonAdmin       = "admin"
+	pauseReasonDBUnhealthy = "db_unhealthy"
+)
+
+var (
+	dbHealthy      = true
+	dbHealthyMutex sync.RWMutex
+)
+
+func isDBHealthy() bool {
+	dbHealthyMutex.RLock()
+	defer dbHealthyMutex.RUnlock()
+	return dbHealthy
+}
+
+// Dead-man's switch: pings the DB periodically and pauses matching after
+// DB_HEALTH_FAILURE_THRESHOLD consecutive failures, resuming on recovery
+// only if the pause was ours
+func startDBHealthMonitor(database *sql.DB) {
+	interval := getEnvDuration("DB_HEALTH_CHECK_INTERVAL", 5*time.Second)
+	timeout := getEnvDuration("DB_HEALTH_PING_TIMEOUT", 2*time.Second)
+	threshold, err := strconv.Atoi(getEnv("DB_HEALTH_FAILURE_THRESHOLD", "3"))
+	if err != nil || threshold < 1 {
+		log.Printf("Warning: Invalid DB_HEALTH_FAILURE_THRESHOLD, using 3")
+		threshold = 3
+	}
+
+	log.Printf("🩺 DB health monitor started (every %s, pause after %d failures)", interval, threshold)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		failures := 0
+		for range ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			err := database.PingContext(ctx)
+			cancel()
+
+			if err != nil {
+				failures++
+				log.Printf("⚠️ DB health check failed (%d/%d): %v", failures, threshold, err)
+				if failures == threshold {
+					markDBUnhealthy()
+				}
+				continue
+			}
+
+			if failures >= threshold {
+				markDBHealthy()
+			}
+			failures = 0
+		}
+	}()
+}
+
+func markDBUnhealthy() {
+	dbHealthyMutex.Lock()
+	dbHealthy = false
+	dbHealthyMutex.Unlock()
+
+	matchingEnabledMutex.Lock()
+	defer matchingEnabledMutex.Unlock()
+
+	if !matchingEnabled {
+		log.Printf("🚨 CRITICAL: Database unhealthy - matching already paused (reason: %s)", matchingPauseReason)
+		return
+	}
+
+	matchingEnabled = false
+	matchingPauseReason = pauseReasonDBUnhealthy
+	log.Println("🚨 CRITICAL: Database unhealthy - MATCHING ENGINE PAUSED automatically")
+	publishAdminEvent(adminEventEngineToggled, 0, map[string]interface{}{"enabled": false, "reason": pauseReasonDBUnhealthy})
+}
+
+func markDBHealthy() {
+	dbHealthyMutex.Lock()
+	dbHealthy = true
+	dbHealthyMutex.Unlock()
+
+	matchingEnabledMutex.Lock()
+	defer matchingEnabledMutex.Unlock()
+
+	// Leave admin pauses alone
+	if matchingEnabled || matchingPauseReason != pauseReasonDBUnhealthy {
+		log.Println("✅ Database recovered")
+		return
+	}
+
+	matchingEnabled = true
+	matchingPauseReason = ""
+	log.Println("✅ Database recovered - MATCHING ENGINE RESUMED automatically")
+	publishAdminEvent(adminEventEngineToggled, 0, map[string]interface{}{"enabled": true, "reason": pauseReasonDBUnhealthy})
+}
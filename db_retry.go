@@ -0,0 +1,73 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Retries for transactions that lose a serialization/deadlock race
+const (
+	txMaxAttempts = 3
+	txRetryDelay  = 10 * time.Millisecond
+)
+
+var debugLogging = os.Getenv("LOG_LEVEL") == "debug"
+
+func logDebugf(format string, args ...interface{}) {
+	if debugLogging {
+		log.Printf("[debug] "+format, args...)
+	}
+}
+
+// serialization_failure (40001) and deadlock_detected (40P01) are safe to retry:
+// Postgres rolled the whole transaction back
+func isRetryableTxError(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "40001" || pqErr.Code == "40P01"
+	}
+	return false
+}
+
+// Runs fn in a transaction and commits it, retrying the whole transaction on
+// retryable errors. fn must not have side effects outside tx, since it can run
+// more than once.
+func withRetry(database *sql.DB, fn func(tx *sql.Tx) error) error {
+	var err error
+	for attempt := 1; attempt <= txMaxAttempts; attempt++ {
+		err = runTx(database, fn)
+		if err == nil || !isRetryableTxError(err) {
+			return err
+		}
+
+		if attempt < txMaxAttempts {
+			delay := txRetryDelay * time.Duration(attempt)
+			logDebugf("Retrying transaction (attempt %d/%d) in %v after: %v", attempt+1, txMaxAttempts, delay, err)
+			time.Sleep(delay)
+		}
+	}
+	return err
+}
+
+func runTx(database *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := database.Begin()
+	if err != nil {
+		return fmt.Errorf("transaction start failed: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit failed: %w", err)
+	}
+	return nil
+}
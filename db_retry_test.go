@@ -0,0 +1,92 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestIsRetryableTxError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"serialization failure", &pq.Error{Code: "40001"}, true},
+		{"deadlock", &pq.Error{Code: "40P01"}, true},
+		{"wrapped", fmt.Errorf("commit failed: %w", &pq.Error{Code: "40001"}), true},
+		{"unique violation", &pq.Error{Code: "23505"}, false},
+		{"not a pq error", errors.New("40001"), false},
+		{"nil", nil, false},
+	}
+	for _, c := range cases {
+		if got := isRetryableTxError(c.err); got != c.want {
+			t.Errorf("%s: isRetryableTxError = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+// The project each attempt wrote is committed or rolled back with it
+func testProjectExists(t *testing.T, name string) bool {
+	t.Helper()
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM projects WHERE name = $1)", name).Scan(&exists); err != nil {
+		t.Fatal(err)
+	}
+	return exists
+}
+
+func TestWithRetryRerunsSerializationFailure(t *testing.T) {
+	openTestDB(t)
+
+	calls := 0
+	err := withRetry(db, func(tx *sql.Tx) error {
+		calls++
+		name := fmt.Sprintf("Attempt %d", calls)
+		if _, err := tx.Exec("INSERT INTO projects (name) VALUES ($1)", name); err != nil {
+			return err
+		}
+		if calls == 1 {
+			return &pq.Error{Code: "40001"}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("withRetry: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("fn ran %d times, want 2", calls)
+	}
+	if testProjectExists(t, "Attempt 1") {
+		t.Error("the failed attempt's write was committed")
+	}
+	if !testProjectExists(t, "Attempt 2") {
+		t.Error("the retried attempt's write was not committed")
+	}
+}
+
+func TestWithRetryStopsOnOtherErrorsAndAfterMaxAttempts(t *testing.T) {
+	openTestDB(t)
+
+	calls := 0
+	uniqueViolation := &pq.Error{Code: "23505"}
+	err := withRetry(db, func(tx *sql.Tx) error {
+		calls++
+		return uniqueViolation
+	})
+	if err != uniqueViolation || calls != 1 {
+		t.Errorf("non-retryable error: %d calls, err %v; want 1 call and the error back", calls, err)
+	}
+
+	calls = 0
+	err = withRetry(db, func(tx *sql.Tx) error {
+		calls++
+		return &pq.Error{Code: "40P01"}
+	})
+	if !isRetryableTxError(err) || calls != txMaxAttempts {
+		t.Errorf("persistent deadlock: %d calls, err %v; want %d calls and the deadlock back", calls, err, txMaxAttempts)
+	}
+}
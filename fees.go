@@ -0,0 +1,123 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sync"
+)
+
+type FeeRates struct {
+	MakerFeeBps float64 `json:"maker_fee_bps"`
+	TakerFeeBps float64 `json:"taker_fee_bps"`
+}
+
+// Cached fee rates so the matcher never reads fee_config inside its loop
+var (
+	feeRates      FeeRates
+	feeRatesMutex sync.RWMutex
+)
+
+func initFeeConfigTable(database *sql.DB) {
+	query := `CREATE TABLE IF NOT EXISTS fee_config (
+		id INTEGER PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+		maker_fee_bps DECIMAL(8, 4) NOT NULL DEFAULT 0,
+		taker_fee_bps DECIMAL(8, 4) NOT NULL DEFAULT 0,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`
+
+	_, err := database.Exec(query)
+	if err != nil {
+		log.Fatal("Error creating fee_config table:", err)
+	}
+
+	// Single row, zero rates by default = no fees
+	_, err = database.Exec(`INSERT INTO fee_config (id) VALUES (1) ON CONFLICT (id) DO NOTHING`)
+	if err != nil {
+		log.Printf("Warning: Could not seed fee_config: %v", err)
+	}
+
+	if err := loadFeeRates(database); err != nil {
+		log.Printf("Warning: Could not load fee rates: %v", err)
+	}
+
+	log.Println("✅ Fee config table created successfully")
+}
+
+func loadFeeRates(database *sql.DB) error {
+	var rates FeeRates
+	err := database.QueryRow(`
+		SELECT maker_fee_bps, taker_fee_bps FROM fee_config WHERE id = 1
+	`).Scan(&rates.MakerFeeBps, &rates.TakerFeeBps)
+	if err != nil {
+		return err
+	}
+
+	feeRatesMutex.Lock()
+	feeRates = rates
+	feeRatesMutex.Unlock()
+	return nil
+}
+
+func currentFeeRates() FeeRates {
+	feeRatesMutex.RLock()
+	defer feeRatesMutex.RUnlock()
+	return feeRates
+}
+
+// Fee on a fill's notional (qty * price), rounded to the 6dp stored in matched_orders
+func calculateFee(qty Quantity, price float64, bps float64) float64 {
+	fee := qty.Float64() * price * bps / 10000
+	return math.Round(fee*1e6) / 1e6
+}
+
+// Get fee rates (admin)
+func getFeeConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(currentFeeRates())
+}
+
+// Set fee rates (admin)
+func setFeeConfig(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+
+	var rates FeeRates
+	if err := json.NewDecoder(r.Body).Decode(&rates); err != nil {
+		writeBodyDecodeError(w, err)
+		return
+	}
+
+	// Validate rates (0-10000 bps = 0-100%)
+	if rates.MakerFeeBps < 0 || rates.MakerFeeBps > 10000 || rates.TakerFeeBps < 0 || rates.TakerFeeBps > 10000 {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_FEE_RATES", "Fee rates must be between 0 and 10000 basis points")
+		return
+	}
+
+	_, err := db.Exec(`
+		UPDATE fee_config
+		SET maker_fee_bps = $1, taker_fee_bps = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = 1
+	`, rates.MakerFeeBps, rates.TakerFeeBps)
+	if err != nil {
+		log.Println("Error updating fee config:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error updating fee config")
+		return
+	}
+
+	feeRatesMutex.Lock()
+	feeRates = rates
+	feeRatesMutex.Unlock()
+
+	log.Printf("💰 Fee rates set to maker %.4f bps / taker %.4f bps by admin (User ID: %d)",
+		rates.MakerFeeBps, rates.TakerFeeBps, userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Fee rates set to maker %.4f bps, taker %.4f bps", rates.MakerFeeBps, rates.TakerFeeBps),
+		"rates":   rates,
+	})
+}
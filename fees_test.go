@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCalculateFeeAtTenBasisPoints(t *testing.T) {
+	tests := []struct {
+		qty   Quantity
+		price float64
+		want  float64
+	}{
+		{wholeQuantity(100), 25.5, 2.55},
+		{wholeQuantity(1), 10, 0.01},
+		{wholeQuantity(3), 0.333333, 0.001},
+		{wholeQuantity(1) / 2, 10, 0.005},
+	}
+	for _, tt := range tests {
+		if got := calculateFee(tt.qty, tt.price, 10); got != tt.want {
+			t.Errorf("calculateFee(%s, %v, 10) = %v, want %v", tt.qty, tt.price, got, tt.want)
+		}
+	}
+	if got := calculateFee(wholeQuantity(100), 25.5, 0); got != 0 {
+		t.Errorf("fee at 0bps = %v, want 0", got)
+	}
+}
+
+func TestPlannedFillChargesMakerAndTaker(t *testing.T) {
+	now := time.Now()
+	seller := OrderData{ID: 2, Price: 20, Quantity: wholeQuantity(50), CreatedAt: now.Add(-time.Minute)}
+	buyer := OrderData{ID: 1, Price: 20, Quantity: wholeQuantity(50), CreatedAt: now}
+
+	fills, _ := planBuyerFills(buyer, []OrderData{seller}, FeeRates{MakerFeeBps: 5, TakerFeeBps: 10}, 0, false)
+	if len(fills) != 1 {
+		t.Fatalf("fills = %+v, want one", fills)
+	}
+	// Notional 50 * 20 = 1000; the later buyer is the taker
+	if fill := fills[0]; fill.TakerSide != "buyer" || fill.MakerFee != 0.5 || fill.TakerFee != 1 {
+		t.Errorf("fill = taker %s, maker fee %v, taker fee %v; want buyer, 0.5, 1", fill.TakerSide, fill.MakerFee, fill.TakerFee)
+	}
+}
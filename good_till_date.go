@@ -0,0 +1,142 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// good_till_date orders are live through the end of that day (database date).
+// The matcher skips anything past it so an expired order never fills while it
+// waits for the sweeper below.
+const notExpiredCondition = "(good_till_date IS NULL OR good_till_date >= CURRENT_DATE)"
+
+// The database's CURRENT_DATE, so order entry agrees with the matcher and the
+// sweeper about which day it is even when the app runs in another time zone
+func databaseToday(database *sql.DB) (time.Time, error) {
+	var today string
+	if err := database.QueryRow("SELECT TO_CHAR(CURRENT_DATE, 'YYYY-MM-DD')").Scan(&today); err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse("2006-01-02", today)
+}
+
+// Periodically cancels orders past their good_till_date
+// (GTD_EXPIRY_INTERVAL, default 1m; 0 disables)
+func startGoodTillDateSweeper(database *sql.DB) {
+	interval := getEnvDuration("GTD_EXPIRY_INTERVAL", time.Minute)
+	if interval <= 0 {
+		log.Println("⏸️ Good-till-date expiry sweeper disabled (GTD_EXPIRY_INTERVAL=0)")
+		return
+	}
+
+	go func() {
+		// Orders imported or left over from before a restart expire right away
+		expireGoodTillDateOrders(database)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			expireGoodTillDateOrders(database)
+		}
+	}()
+
+	log.Printf("📅 Good-till-date expiry sweeper started (every %v)", interval)
+}
+
+func expireGoodTillDateOrders(database *sql.DB) {
+	for _, role := range []string{"buyer", "seller"} {
+		var ids []int
+		var touchedTop bool
+		err := withRetry(database, func(tx *sql.Tx) error {
+			var err error
+			ids, touchedTop, err = expireGoodTillDateOrdersTx(tx, role)
+			return err
+		})
+		if err != nil {
+			log.Printf("⚠️ Warning: Could not expire good-till-date %s orders: %v", role, err)
+			continue
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		log.Printf("📅 Expired %d good-till-date %s order(s): %v", len(ids), role, ids)
+		if touchedTop {
+			notifyOrderBookChanged(role)
+			go smartSyncTopOrders(database, role)
+		}
+	}
+}
+
+// Cancels one role's expired orders inside tx. They land in cancelled_orders
+// as cancelled by their owner, who chose the expiry date. Returns the ids and
+// whether any were in the top table.
+func expireGoodTillDateOrdersTx(tx *sql.Tx, role string) ([]int, bool, error) {
+	rows, err := tx.Query(fmt.Sprintf(`
+		SELECT order_id, true, user_id, TO_CHAR(good_till_date, 'YYYY-MM-DD') FROM top_%s
+		WHERE good_till_date < CURRENT_DATE
+		UNION ALL
+		SELECT id, false, user_id, TO_CHAR(good_till_date, 'YYYY-MM-DD') FROM %s
+		WHERE good_till_date < CURRENT_DATE
+	`, role, role))
+	if err != nil {
+		return nil, false, err
+	}
+
+	type expired struct {
+		id           int
+		inTop        bool
+		userID       int
+		goodTillDate string
+	}
+	var orders []expired
+	for rows.Next() {
+		var o expired
+		if err := rows.Scan(&o.id, &o.inTop, &o.userID, &o.goodTillDate); err != nil {
+			rows.Close()
+			return nil, false, err
+		}
+		orders = append(orders, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	ids := []int{}
+	touchedTop := false
+	for _, o := range orders {
+		reason := "Expired: good till " + o.goodTillDate
+		if err := recordCancelledOrderTx(tx, role, o.id, o.inTop, reason, o.userID, "owner"); err != nil {
+			return nil, false, err
+		}
+
+		if o.inTop {
+			_, err = tx.Exec(fmt.Sprintf("DELETE FROM top_%s WHERE order_id = $1", role), o.id)
+			touchedTop = true
+		} else {
+			_, err = tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE id = $1", role), o.id)
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("deleting %s order #%d: %v", role, o.id, err)
+		}
+		ids = append(ids, o.id)
+	}
+
+	if len(ids) > 0 {
+		_, err = tx.Exec(fmt.Sprintf(`
+			UPDATE %s_order_history
+			SET status = 'Cancelled', updated_at = CURRENT_TIMESTAMP
+			WHERE %s_order_id = ANY($1)
+		`, role, role), pq.Array(ids))
+		if err != nil {
+			return nil, false, fmt.Errorf("updating %s history: %v", role, err)
+		}
+	}
+
+	return ids, touchedTop, nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestYesterdaysGoodTillDateOrderIsExpired(t *testing.T) {
+	openTestDB(t)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+
+	today, err := databaseToday(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	yesterday := today.AddDate(0, 0, -1).Format("2006-01-02")
+
+	// As an import would load it: rested through yesterday, never swept
+	placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 10, Quantity: wholeQuantity(1), TradeDate: yesterday, GoodTillDate: &yesterday})
+	placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 10, Quantity: wholeQuantity(1)})
+
+	result, err := runMatching(db, defaultProjectID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Matches != 0 {
+		t.Errorf("matcher filled %d orders against an expired seller", result.Matches)
+	}
+
+	expireGoodTillDateOrders(db)
+	if n := testCount(t, "seller") + testCount(t, "top_seller"); n != 0 {
+		t.Errorf("%d seller orders left after the expiry sweep, want 0", n)
+	}
+	if n := testCount(t, "cancelled_orders"); n != 1 {
+		t.Errorf("cancelled_orders has %d rows, want 1", n)
+	}
+
+	rec := postTestOrder(t, map[string]interface{}{"user_id": sellerUser, "role": "seller", "price": 10, "quantity": 1, "good_till_date": yesterday})
+	if rec.Code != http.StatusBadRequest || errorCode(t, rec) != "INVALID_GOOD_TILL_DATE" {
+		t.Errorf("new order good till yesterday: status %d (%s), want 400 INVALID_GOOD_TILL_DATE", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var healthPingTimeout = getEnvDuration("HEALTH_DB_TIMEOUT", 2*time.Second)
+
+var (
+	preparedStatementsReady bool
+	preparedStatementsMutex sync.RWMutex
+)
+
+func setPreparedStatementsReady(ready bool) {
+	preparedStatementsMutex.Lock()
+	preparedStatementsReady = ready
+	preparedStatementsMutex.Unlock()
+}
+
+func arePreparedStatementsReady() bool {
+	preparedStatementsMutex.RLock()
+	defer preparedStatementsMutex.RUnlock()
+	return preparedStatementsReady
+}
+
+func pingDB(r *http.Request) error {
+	ctx, cancel := context.WithTimeout(r.Context(), healthPingTimeout)
+	defer cancel()
+	return db.PingContext(ctx)
+}
+
+func writeHealth(w http.ResponseWriter, status int, body map[string]string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// GET /health - 503 when the database does not answer a ping
+func healthCheck(w http.ResponseWriter, r *http.Request) {
+	if err := pingDB(r); err != nil {
+		writeHealth(w, http.StatusServiceUnavailable, map[string]string{"status": "degraded", "db": "unreachable"})
+		return
+	}
+	writeHealth(w, http.StatusOK, map[string]string{"status": "ok", "db": "ok"})
+}
+
+// GET /health/live - the process is up; never touches the database so a DB
+// outage doesn't get the instance restarted
+func livenessCheck(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// GET /health/ready - safe to route traffic here: the database answers and
+// the matching engine's prepared statements are in place
+func readinessCheck(w http.ResponseWriter, r *http.Request) {
+	if err := pingDB(r); err != nil {
+		writeHealth(w, http.StatusServiceUnavailable, map[string]string{"status": "degraded", "db": "unreachable"})
+		return
+	}
+	if !arePreparedStatementsReady() {
+		writeHealth(w, http.StatusServiceUnavailable, map[string]string{"status": "degraded", "db": "ok", "prepared_statements": "not_initialized"})
+		return
+	}
+	writeHealth(w, http.StatusOK, map[string]string{"status": "ok", "db": "ok", "prepared_statements": "ok"})
+}
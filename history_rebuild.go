@@ -0,0 +1,96 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// Recomputes every buyer_order_history row from the fills recorded in
+// matched_orders (and matched_orders_archive, so archived trades still count).
+// match_assignments is written fire-and-forget and can miss rows, so it is not
+// used as the source. Trades deleted with MATCHED_ORDERS_ARCHIVE=false are
+// gone for good and will be dropped from the counters.
+//
+// Returns the number of rows that were changed.
+func rebuildBuyerOrderHistoryTx(tx *sql.Tx) (int, error) {
+	// Holds off the matcher's history updates so a fill can't commit between
+	// the aggregate and the update
+	if _, err := tx.Exec(`LOCK TABLE buyer_order_history IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		return 0, fmt.Errorf("locking buyer_order_history: %w", err)
+	}
+
+	// seller_count is bumped once per fill by updateBuyerOrderHistoryTx, so it
+	// is rebuilt the same way as match_count
+	result, err := tx.Exec(`
+		WITH fills AS (
+			SELECT buyer_order_id, SUM(matched_qty) AS qty, COUNT(*) AS fills
+			FROM (
+				SELECT buyer_order_id, matched_qty FROM matched_orders
+				WHERE `+countedTradeCondition+`
+				UNION ALL
+				SELECT (data->>'buyer_order_id')::INTEGER, (data->>'matched_qty')::DECIMAL
+				FROM matched_orders_archive
+				WHERE `+countedTradeStatus("data->>'status'")+`
+			) all_fills
+			GROUP BY buyer_order_id
+		), expected AS (
+			SELECT h.id,
+			       COALESCE(f.qty, 0) AS qty,
+			       COALESCE(f.fills, 0) AS fills,
+			       GREATEST(h.original_qty - COALESCE(f.qty, 0), 0) AS remaining,
+			       CASE
+			           WHEN h.status = 'Cancelled' THEN 'Cancelled'
+			           WHEN COALESCE(f.qty, 0) >= h.original_qty THEN 'Completed'
+			           WHEN COALESCE(f.qty, 0) > 0 THEN 'Partially Matched'
+			           ELSE 'Pending'
+			       END AS status
+			FROM buyer_order_history h
+			LEFT JOIN fills f ON f.buyer_order_id = h.buyer_order_id
+		)
+		UPDATE buyer_order_history h
+		SET total_matched_qty = e.qty,
+		    remaining_qty = e.remaining,
+		    match_count = e.fills,
+		    seller_count = e.fills,
+		    status = e.status,
+		    updated_at = CURRENT_TIMESTAMP
+		FROM expected e
+		WHERE e.id = h.id
+		  AND (h.total_matched_qty, h.remaining_qty, h.match_count, h.seller_count, COALESCE(h.status, ''))
+		      IS DISTINCT FROM (e.qty, e.remaining, e.fills, e.fills, e.status)
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("rebuilding buyer_order_history: %w", err)
+	}
+
+	corrected, _ := result.RowsAffected()
+	return int(corrected), nil
+}
+
+// Rebuild buyer order history counters from matched orders (admin)
+func rebuildOrderHistory(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+
+	var corrected int
+	err := withRetry(db, func(tx *sql.Tx) error {
+		var err error
+		corrected, err = rebuildBuyerOrderHistoryTx(tx)
+		return err
+	})
+	if err != nil {
+		log.Println("Error rebuilding order history:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error rebuilding order history")
+		return
+	}
+
+	log.Printf("🩹 Buyer order history rebuilt by admin (User ID: %d): %d rows corrected", userID, corrected)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"corrected": corrected,
+	})
+}
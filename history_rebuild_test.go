@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRebuildHistoryFixesCorruptedCounters(t *testing.T) {
+	openTestDB(t)
+	_, adminToken := createTestUser(t, "admin", true)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+
+	placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 10, Quantity: wholeQuantity(2)})
+	placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 10, Quantity: wholeQuantity(2)})
+	buyer := placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 10, Quantity: wholeQuantity(5)})
+	if _, err := runMatching(db, defaultProjectID); err != nil {
+		t.Fatalf("matching: %v", err)
+	}
+
+	type history struct {
+		matched, remaining Quantity
+		matches, sellers   int
+		status             string
+	}
+	read := func() history {
+		t.Helper()
+		var h history
+		err := db.QueryRow(`
+			SELECT total_matched_qty, remaining_qty, match_count, seller_count, status
+			FROM buyer_order_history WHERE buyer_order_id = $1
+		`, buyer.ID).Scan(&h.matched, &h.remaining, &h.matches, &h.sellers, &h.status)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	want := history{wholeQuantity(4), wholeQuantity(1), 2, 2, "Partially Matched"}
+	if got := read(); got != want {
+		t.Fatalf("history after matching = %+v, want %+v", got, want)
+	}
+
+	_, err := db.Exec(`
+		UPDATE buyer_order_history
+		SET total_matched_qty = 1, remaining_qty = 0, match_count = 7, seller_count = 7, status = 'Completed'
+		WHERE buyer_order_id = $1
+	`, buyer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rebuild := func() int {
+		t.Helper()
+		rec := doTestRequest(t, http.MethodPost, "/api/v1/admin/rebuild-history", adminToken, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("rebuild: status %d (%s)", rec.Code, rec.Body.String())
+		}
+		var resp struct {
+			Corrected int `json:"corrected"`
+		}
+		decodeTestResponse(t, rec, &resp)
+		return resp.Corrected
+	}
+	if n := rebuild(); n != 1 {
+		t.Errorf("rebuild corrected %d rows, want 1", n)
+	}
+	if got := read(); got != want {
+		t.Errorf("history after rebuild = %+v, want %+v", got, want)
+	}
+	if n := rebuild(); n != 0 {
+		t.Errorf("second rebuild corrected %d rows, want 0", n)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// Keys are remembered for 24h; after that the same key creates a new order
+const idempotencyWindow = "24 hours"
+
+var errIdempotencyInProgress = errors.New("request with this idempotency key is still being processed")
+
+// The response first sent for a key, replayed byte for byte on retries
+type idempotentResponse struct {
+	Status int
+	Body   []byte
+}
+
+func initIdempotencyTable(database *sql.DB) {
+	query := `CREATE TABLE IF NOT EXISTS idempotency_keys (
+		user_id INTEGER NOT NULL,
+		idempotency_key VARCHAR(255) NOT NULL,
+		order_id INTEGER,
+		response TEXT,
+		response_status INTEGER,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, idempotency_key)
+	)`
+
+	_, err := database.Exec(query)
+	if err != nil {
+		log.Fatal("Error creating idempotency_keys table:", err)
+	}
+
+	// Responses are replayed exactly as sent, which JSONB would not preserve
+	migrations := []string{
+		`ALTER TABLE idempotency_keys ALTER COLUMN response TYPE TEXT USING response::text`,
+		`ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS response_status INTEGER`,
+	}
+	for _, migration := range migrations {
+		if _, err := database.Exec(migration); err != nil {
+			log.Fatal("Error migrating idempotency_keys table:", err)
+		}
+	}
+
+	_, err = database.Exec(`CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created_at ON idempotency_keys(created_at)`)
+	if err != nil {
+		log.Printf("Warning: Could not create idempotency_keys index: %v", err)
+	}
+
+	log.Println("✅ Idempotency keys table created successfully")
+}
+
+// Claims the key for this user. Returns the stored response when the key was
+// already used within the window, nil when the caller should create the order.
+func reserveIdempotencyKey(database *sql.DB, userID int, key string) (*idempotentResponse, error) {
+	// Expired keys may be reused
+	_, err := database.Exec(`
+		DELETE FROM idempotency_keys
+		WHERE user_id = $1 AND idempotency_key = $2
+		AND created_at < NOW() - INTERVAL '`+idempotencyWindow+`'
+	`, userID, key)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := database.Exec(`
+		INSERT INTO idempotency_keys (user_id, idempotency_key)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id, idempotency_key) DO NOTHING
+	`, userID, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if rows, _ := result.RowsAffected(); rows == 1 {
+		return nil, nil
+	}
+
+	var response sql.NullString
+	var status sql.NullInt64
+	err = database.QueryRow(`
+		SELECT response, response_status FROM idempotency_keys
+		WHERE user_id = $1 AND idempotency_key = $2
+	`, userID, key).Scan(&response, &status)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reserved by a concurrent request that has not finished yet. Once the
+	// order is bound to the key this also covers a request that died before
+	// storing its response - the order exists, so it must not be created again.
+	if !response.Valid {
+		return nil, errIdempotencyInProgress
+	}
+
+	// Keys stored before the status column were plain 200 replays
+	if !status.Valid {
+		status.Int64 = http.StatusOK
+	}
+	return &idempotentResponse{Status: int(status.Int64), Body: []byte(response.String)}, nil
+}
+
+// Binds the created order to the reserved key in the order's insert
+// transaction, so the key can no longer be released and reused for a second
+// order once the first one is committed
+func bindIdempotencyKeyTx(tx *sql.Tx, userID int, key string, orderID int) error {
+	_, err := tx.Exec(`
+		UPDATE idempotency_keys SET order_id = $1
+		WHERE user_id = $2 AND idempotency_key = $3
+	`, orderID, userID, key)
+	return err
+}
+
+// Stores the response sent for the key's order so retries get the same one
+func completeIdempotencyKey(database *sql.DB, userID int, key string, response idempotentResponse) error {
+	_, err := database.Exec(`
+		UPDATE idempotency_keys
+		SET response = $1, response_status = $2
+		WHERE user_id = $3 AND idempotency_key = $4
+	`, string(response.Body), response.Status, userID, key)
+	return err
+}
+
+// Drops the reservation so the client can retry after a failed create
+func releaseIdempotencyKey(database *sql.DB, userID int, key string) {
+	_, err := database.Exec(`
+		DELETE FROM idempotency_keys
+		WHERE user_id = $1 AND idempotency_key = $2 AND response IS NULL AND order_id IS NULL
+	`, userID, key)
+	if err != nil {
+		log.Printf("⚠️ Warning: Could not release idempotency key %q for user %d: %v", key, userID, err)
+	}
+}
@@ -0,0 +1,496 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Posts body to createOrder and returns the recorded response
+func postTestOrder(t *testing.T, body map[string]interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	return postTestOrderWithKey(t, body, "")
+}
+
+// Like postTestOrder, sending key as the Idempotency-Key header when set
+func postTestOrderWithKey(t *testing.T, body map[string]interface{}, key string) *httptest.ResponseRecorder {
+	t.Helper()
+	if _, ok := body["trade_date"]; !ok {
+		body["trade_date"] = time.Now().Format("2006-01-02")
+	}
+	if _, ok := body["trade_time"]; !ok {
+		body["trade_time"] = time.Now().Format("15:04:05")
+	}
+	if _, ok := body["project_id"]; !ok {
+		body["project_id"] = defaultProjectID
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/orders", bytes.NewReader(payload))
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	rec := httptest.NewRecorder()
+	createOrder(rec, req)
+	return rec
+}
+
+func TestCreateOrderRejectsMarketOrderThatCannotTrade(t *testing.T) {
+	openTestDB(t)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	market := func() map[string]interface{} {
+		return map[string]interface{}{"user_id": buyerUser, "role": "buyer", "order_kind": "market", "quantity": 1}
+	}
+
+	matchingEnabledMutex.Lock()
+	matchingEnabled = false
+	matchingEnabledMutex.Unlock()
+	rec := postTestOrder(t, market())
+	if rec.Code != http.StatusServiceUnavailable || errorCode(t, rec) != "MATCHING_DISABLED" {
+		t.Errorf("engine off: status %d, want 503 MATCHING_DISABLED", rec.Code)
+	}
+
+	matchingEnabledMutex.Lock()
+	matchingEnabled = true
+	matchingEnabledMutex.Unlock()
+	updateBreakerCache(defaultProjectID, true)
+	rec = postTestOrder(t, market())
+	if rec.Code != http.StatusLocked || errorCode(t, rec) != "PROJECT_HALTED" {
+		t.Errorf("project halted: status %d, want 423 PROJECT_HALTED", rec.Code)
+	}
+
+	if n := testCount(t, "buyer") + testCount(t, "top_buyer"); n != 0 {
+		t.Errorf("%d buyer orders stored, want none", n)
+	}
+}
+
+func TestCreateOrderReplaysFirstResponseForDuplicateKey(t *testing.T) {
+	openTestDB(t)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	order := func() map[string]interface{} {
+		return map[string]interface{}{"user_id": buyerUser, "role": "buyer", "price": 10, "quantity": 3}
+	}
+
+	first := postTestOrderWithKey(t, order(), "retry-1")
+	if first.Code != http.StatusCreated {
+		t.Fatalf("first submit: status %d (%s), want 201", first.Code, first.Body.String())
+	}
+	var ack OrderAck
+	decodeTestResponse(t, first, &ack)
+	if ack.ID == 0 || ack.BookPosition == nil {
+		t.Fatalf("first submit: ack = %s, want the order with its book position", first.Body.String())
+	}
+
+	second := postTestOrderWithKey(t, order(), "retry-1")
+	if second.Code != first.Code || second.Body.String() != first.Body.String() {
+		t.Errorf("duplicate submit: %d %s, want the first response %d %s",
+			second.Code, second.Body.String(), first.Code, first.Body.String())
+	}
+	if n := testCount(t, "buyer") + testCount(t, "top_buyer"); n != 1 {
+		t.Errorf("%d buyer orders stored, want 1", n)
+	}
+
+	var boundOrderID int
+	err := db.QueryRow("SELECT order_id FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2",
+		buyerUser, "retry-1").Scan(&boundOrderID)
+	if err != nil || boundOrderID != ack.ID {
+		t.Errorf("key bound to order %d (%v), want %d", boundOrderID, err, ack.ID)
+	}
+}
+
+func TestTriggerProjectMatchingReachesMainTableOrders(t *testing.T) {
+	openTestDB(t)
+	_, adminToken := createTestUser(t, "admin", true)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+	otherProject := createTestProject(t, "Other")
+
+	// The other project's buyers outrank ours and fill the shared top table
+	for i := 0; i < 10; i++ {
+		placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 50, Quantity: wholeQuantity(1), ProjectID: intPtr(otherProject)})
+		placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 60, Quantity: wholeQuantity(1), ProjectID: intPtr(otherProject)})
+	}
+	for i := 0; i < 3; i++ {
+		placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 10, Quantity: wholeQuantity(1)})
+		placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 10, Quantity: wholeQuantity(1)})
+	}
+
+	rec := doTestRequest(t, http.MethodPost, fmt.Sprintf("/api/match/project/%d", defaultProjectID), adminToken, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d (%s), want 200", rec.Code, rec.Body.String())
+	}
+	var body struct {
+		MatchCount int `json:"match_count"`
+	}
+	decodeTestResponse(t, rec, &body)
+	if body.MatchCount != 3 {
+		t.Errorf("match_count = %d, want 3", body.MatchCount)
+	}
+
+	var otherOrders int
+	var otherQty Quantity
+	err := db.QueryRow(`
+		SELECT COUNT(*), SUM(quantity) FROM (
+			SELECT quantity, project_id FROM buyer UNION ALL SELECT quantity, project_id FROM top_buyer
+			UNION ALL SELECT quantity, project_id FROM seller UNION ALL SELECT quantity, project_id FROM top_seller
+		) o WHERE project_id = $1
+	`, otherProject).Scan(&otherOrders, &otherQty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otherOrders != 20 || otherQty != wholeQuantity(20) {
+		t.Errorf("other project has %d orders for %s, want its 20 untouched", otherOrders, otherQty)
+	}
+}
+
+func TestCreateOrderInHaltedProject(t *testing.T) {
+	openTestDB(t)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	limit := func(mlp bool) map[string]interface{} {
+		return map[string]interface{}{"user_id": buyerUser, "role": "buyer", "price": 10, "quantity": 1, "market_lead_program": mlp}
+	}
+	setReject := func(reject bool) {
+		if _, err := db.Exec("UPDATE projects SET reject_orders_when_halted = $1 WHERE id = $2", reject, defaultProjectID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	updateBreakerCache(defaultProjectID, true)
+
+	setReject(false)
+	if rec := postTestOrder(t, limit(false)); rec.Code != http.StatusCreated {
+		t.Errorf("rejection off: status %d (%s), want 201", rec.Code, rec.Body.String())
+	}
+
+	setReject(true)
+	rec := postTestOrder(t, limit(false))
+	if rec.Code != http.StatusLocked || errorCode(t, rec) != "PROJECT_HALTED" {
+		t.Errorf("rejection on: status %d, want 423 PROJECT_HALTED", rec.Code)
+	}
+	if rec := postTestOrder(t, limit(true)); rec.Code != http.StatusLocked {
+		t.Errorf("rejection on, MLP not exempt: status %d, want 423", rec.Code)
+	}
+
+	breakerCacheMutex.Lock()
+	breakerMLPExempt[defaultProjectID] = true
+	breakerCacheMutex.Unlock()
+	if rec := postTestOrder(t, limit(true)); rec.Code != http.StatusCreated {
+		t.Errorf("rejection on, MLP exempt: status %d (%s), want 201", rec.Code, rec.Body.String())
+	}
+
+	if n := testCount(t, "buyer") + testCount(t, "top_buyer"); n != 2 {
+		t.Errorf("%d buyer orders stored, want 2", n)
+	}
+}
+
+func TestCreateOrderRejectsNonexistentProject(t *testing.T) {
+	openTestDB(t)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+
+	rec := postTestOrder(t, map[string]interface{}{"user_id": buyerUser, "role": "buyer", "price": 10, "quantity": 1, "project_id": 9999})
+	if rec.Code != http.StatusBadRequest || errorCode(t, rec) != "INVALID_PROJECT_ID" {
+		t.Errorf("status %d (%s), want 400 INVALID_PROJECT_ID", rec.Code, rec.Body.String())
+	}
+	if n := testCount(t, "buyer") + testCount(t, "top_buyer"); n != 0 {
+		t.Errorf("%d buyer orders stored, want none", n)
+	}
+}
+
+func TestParseAllowedOrigins(t *testing.T) {
+	got := parseAllowedOrigins(" https://app.example.com,https://admin.example.com , ,http://localhost:3000")
+	want := []string{"https://app.example.com", "https://admin.example.com", "http://localhost:3000"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("parseAllowedOrigins = %q, want %q", got, want)
+	}
+	if allowsAnyOrigin(got) {
+		t.Error("explicit origins treated as a wildcard")
+	}
+
+	if got := parseAllowedOrigins(" , "); fmt.Sprint(got) != fmt.Sprint(defaultAllowedOrigins) {
+		t.Errorf("blank list = %q, want the defaults", got)
+	}
+	if !allowsAnyOrigin(parseAllowedOrigins("*")) {
+		t.Error(`"*" not treated as a wildcard`)
+	}
+}
+
+func TestSellerAssignmentsAcrossTwoBuyers(t *testing.T) {
+	openTestDB(t)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, sellerToken := createTestUser(t, "seller", false)
+	_, otherToken := createTestUser(t, "other", false)
+
+	seller := placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", TransactionID: "S1", Price: 10, Quantity: wholeQuantity(5)})
+	placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", TransactionID: "B1", Price: 10, Quantity: wholeQuantity(3)})
+	placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", TransactionID: "B2", Price: 10, Quantity: wholeQuantity(2)})
+	if _, err := runMatching(db, defaultProjectID); err != nil {
+		t.Fatal(err)
+	}
+	waitForTestCount(t, "match_assignments", 2)
+
+	target := fmt.Sprintf("/api/v1/match-assignments/seller/%d", sellerUser)
+	rec := doTestRequest(t, http.MethodGet, target, sellerToken, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d (%s)", rec.Code, rec.Body.String())
+	}
+	var assignments []SellerAssignment
+	decodeTestResponse(t, rec, &assignments)
+
+	byBuyer := map[string]Quantity{}
+	for _, a := range assignments {
+		if a.SellerOrderID != seller.ID || a.TradePrice != 10 || a.ProjectID != defaultProjectID {
+			t.Errorf("assignment = %+v, want seller order #%d traded at 10 in project %d", a, seller.ID, defaultProjectID)
+		}
+		byBuyer[a.BuyerTransactionID] += a.AssignedQty
+	}
+	if len(assignments) != 2 || byBuyer["B1"] != wholeQuantity(3) || byBuyer["B2"] != wholeQuantity(2) {
+		t.Errorf("assigned by buyer = %v over %d rows, want B1: 3, B2: 2", byBuyer, len(assignments))
+	}
+	if len(assignments) == 2 && assignments[0].AssignedAt.Before(assignments[1].AssignedAt) {
+		t.Error("assignments not sorted newest first")
+	}
+
+	if rec := doTestRequest(t, http.MethodGet, target, otherToken, nil); rec.Code != http.StatusForbidden {
+		t.Errorf("another user's request: status %d, want 403", rec.Code)
+	}
+	if rec := doTestRequest(t, http.MethodGet, target, "", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("request without a token: status %d, want 401", rec.Code)
+	}
+}
+
+func TestMatchedOrdersCursorPagesAreStable(t *testing.T) {
+	openTestDB(t)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+
+	var want []int
+	for i := 0; i < 5; i++ {
+		want = append([]int{tradeTestOrders(t, defaultProjectID, buyerUser, sellerUser, 10, 1)}, want...)
+	}
+	// Equal timestamps leave id as the only tie-breaker
+	if _, err := db.Exec("UPDATE matched_orders SET created_at = date_trunc('second', LOCALTIMESTAMP)"); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []int
+	cursor := ""
+	for page := 0; page < 5; page++ {
+		rec := doTestRequest(t, http.MethodGet, "/api/v1/matched-orders?limit=2&cursor="+cursor, "", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("page %d: status %d (%s)", page, rec.Code, rec.Body.String())
+		}
+		var matches []MatchedOrder
+		decodeTestResponse(t, rec, &matches)
+		for _, m := range matches {
+			got = append(got, m.ID)
+		}
+
+		// A trade arriving mid-walk is newer than the cursor and must not shift later pages
+		if page == 0 {
+			tradeTestOrders(t, defaultProjectID, buyerUser, sellerUser, 10, 1)
+		}
+		cursor = rec.Header().Get("X-Next-Cursor")
+		if rec.Header().Get("X-Has-More") != "true" {
+			break
+		}
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("ids across pages = %v, want %v", got, want)
+	}
+}
+
+func TestCreateOrderErrorJSONShape(t *testing.T) {
+	rec := postTestOrder(t, map[string]interface{}{"role": "buyer", "price": 10, "quantity": 1})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want 400", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+
+	var body map[string]map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body %q is not an error object: %v", rec.Body.String(), err)
+	}
+	errObj, ok := body["error"]
+	if len(body) != 1 || !ok || len(errObj) != 2 {
+		t.Fatalf("body = %s, want exactly {\"error\":{\"code\",\"message\"}}", rec.Body.String())
+	}
+	if errObj["code"] != "MISSING_FIELDS" {
+		t.Errorf("code = %v, want MISSING_FIELDS", errObj["code"])
+	}
+	if msg, _ := errObj["message"].(string); msg == "" {
+		t.Error("message is empty")
+	}
+}
+
+func TestCreateOrderRejectsNegativePriceAndQuantity(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		body map[string]interface{}
+		code string
+	}{
+		{"negative price", map[string]interface{}{"price": -5, "quantity": 1}, "INVALID_PRICE"},
+		{"negative quantity", map[string]interface{}{"price": 10, "quantity": -100}, "INVALID_QUANTITY"},
+	} {
+		tc.body["user_id"], tc.body["role"] = 1, "buyer"
+		rec := postTestOrder(t, tc.body)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", tc.name, rec.Code)
+			continue
+		}
+		if code := errorCode(t, rec); code != tc.code {
+			t.Errorf("%s: error code %q, want %s", tc.name, code, tc.code)
+		}
+	}
+}
+
+func TestOrderTablesRejectNegativePriceAndQuantity(t *testing.T) {
+	openTestDB(t)
+	userID, _ := createTestUser(t, "trader", false)
+	for _, table := range []string{"buyer", "seller"} {
+		insert := func(price, quantity float64) error {
+			_, err := db.Exec(fmt.Sprintf(`
+				INSERT INTO %s (user_id, price, quantity, trade_date, trade_time, transaction_type, project_id)
+				VALUES ($1, $2, $3, CURRENT_DATE, LOCALTIME, 0, $4)
+			`, table), userID, price, quantity, defaultProjectID)
+			return err
+		}
+		if err := insert(10, 1); err != nil {
+			t.Fatalf("%s rejected a valid row: %v", table, err)
+		}
+		if insert(-5, 1) == nil {
+			t.Errorf("%s accepted a negative price", table)
+		}
+		if insert(10, -100) == nil {
+			t.Errorf("%s accepted a negative quantity", table)
+		}
+	}
+}
+
+func TestOpenOrderLimitPerUserPerProject(t *testing.T) {
+	openTestDB(t)
+	previous := maxOpenOrdersPerUser
+	maxOpenOrdersPerUser = 3
+	t.Cleanup(func() { maxOpenOrdersPerUser = previous })
+
+	traderID, _ := createTestUser(t, "trader", false)
+	adminID, _ := createTestUser(t, "admin", true)
+	second := createTestProject(t, "Second")
+	buy := func(userID, projectID int) *httptest.ResponseRecorder {
+		return postTestOrder(t, map[string]interface{}{
+			"user_id": userID, "role": "buyer", "price": 10, "quantity": 1, "project_id": projectID,
+		})
+	}
+
+	for i := 1; i <= 3; i++ {
+		if rec := buy(traderID, defaultProjectID); rec.Code != http.StatusCreated {
+			t.Fatalf("order %d of 3: status %d (%s)", i, rec.Code, rec.Body.String())
+		}
+	}
+	rec := buy(traderID, defaultProjectID)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("order over the limit: status %d, want 429", rec.Code)
+	}
+	if code := errorCode(t, rec); code != "TOO_MANY_OPEN_ORDERS" {
+		t.Errorf("error code %q, want TOO_MANY_OPEN_ORDERS", code)
+	}
+	if n := countTestUserOrders(t, "buyer", traderID); n != 3 {
+		t.Errorf("trader has %d open orders, want 3", n)
+	}
+
+	if rec := buy(traderID, second); rec.Code != http.StatusCreated {
+		t.Errorf("order in another project: status %d, want it accepted", rec.Code)
+	}
+	for i := 1; i <= 4; i++ {
+		if rec := buy(adminID, defaultProjectID); rec.Code != http.StatusCreated {
+			t.Fatalf("admin order %d: status %d, admins are exempt", i, rec.Code)
+		}
+	}
+}
+
+func TestGetOrdersPriceWindow(t *testing.T) {
+	openTestDB(t)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	second := createTestProject(t, "Second")
+
+	// Better-priced buyers fill the top table, so the rest rest in the main table
+	for i := 0; i < 10; i++ {
+		placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 100, Quantity: wholeQuantity(1)})
+	}
+	for _, price := range []float64{5, 10, 15, 20} {
+		placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: price, Quantity: wholeQuantity(1)})
+	}
+	placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 12, Quantity: wholeQuantity(1), ProjectID: intPtr(second)})
+
+	prices := func(query string) []float64 {
+		t.Helper()
+		rec := doTestRequest(t, http.MethodGet, "/api/v1/orders/buyer/all"+query, "", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d (%s)", query, rec.Code, rec.Body.String())
+		}
+		var orders []Order
+		decodeTestResponse(t, rec, &orders)
+		var got []float64
+		for _, o := range orders {
+			got = append(got, o.Price)
+		}
+		return got
+	}
+
+	for _, tc := range []struct {
+		query string
+		want  []float64
+	}{
+		{"?min_price=10&max_price=15", []float64{15, 12, 10}},
+		{fmt.Sprintf("?min_price=10&max_price=15&project_id=%d", defaultProjectID), []float64{15, 10}},
+		{"?min_price=16", []float64{20}},
+		{"?max_price=5", []float64{5}},
+	} {
+		if got := prices(tc.query); fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Errorf("%s: prices %v, want %v", tc.query, got, tc.want)
+		}
+	}
+}
+
+func TestGetOrdersRejectsInvertedPriceRange(t *testing.T) {
+	rec := doTestRequest(t, http.MethodGet, "/api/v1/orders/buyer/all?min_price=20&max_price=10", "", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want 400", rec.Code)
+	}
+	if code := errorCode(t, rec); code != "INVALID_PRICE_RANGE" {
+		t.Errorf("error code %q, want INVALID_PRICE_RANGE", code)
+	}
+}
+
+func TestCreateOrderRejectsOversizedBody(t *testing.T) {
+	openTestDB(t)
+	_, token := createTestUser(t, "alice", false)
+
+	for _, target := range []string{"/api/v1/orders", "/api/orders"} {
+		rec := postOversizedBody(t, target, token)
+		if rec.Code != http.StatusRequestEntityTooLarge || errorCode(t, rec) != "REQUEST_BODY_TOO_LARGE" {
+			t.Errorf("%s: status %d (%.80s), want 413 REQUEST_BODY_TOO_LARGE", target, rec.Code, rec.Body.String())
+		}
+	}
+
+	// A declared Content-Length over the limit is refused before the body is read
+	body := bytes.Repeat([]byte("a"), int(maxRequestBodyBytes)+1)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/orders", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	testHandler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge || errorCode(t, rec) != "REQUEST_BODY_TOO_LARGE" {
+		t.Errorf("declared length: status %d (%.80s), want 413 REQUEST_BODY_TOO_LARGE", rec.Code, rec.Body.String())
+	}
+
+	if n := testCount(t, "buyer") + testCount(t, "seller") + testCount(t, "top_buyer") + testCount(t, "top_seller"); n != 0 {
+		t.Errorf("%d orders inserted from oversized bodies, want 0", n)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// An assignment with the trade it belongs to, for walking a project's fills in order
+type ProjectAssignment struct {
+	MatchAssignment
+	BuyerTransactionID string  `json:"buyer_transaction_id"`
+	MatchedPrice       float64 `json:"matched_price"` // execution price of the matched order
+	ProjectID          int     `json:"project_id"`
+}
+
+// Assignments of projectID with id > afterID, oldest first. Keyed on the
+// assignment id so pages never overlap or skip rows, even while new fills
+// arrive. Assignments without a matched order can't be tied to a project
+// and are not returned.
+func getProjectAssignmentsPage(ctx context.Context, database *sql.DB, projectID, afterID, limit int) ([]ProjectAssignment, bool, error) {
+	rows, err := database.QueryContext(ctx, `
+		SELECT ma.id, ma.buyer_order_id, ma.seller_order_id, ma.seller_user_id, ma.seller_transaction_id,
+		       ma.seller_total_qty, ma.assigned_qty, ma.seller_price, ma.matched_order_id,
+		       ma.matched_transaction_type, ma.assigned_at,
+		       mo.buyer_transaction_id, COALESCE(mo.execution_price, mo.seller_price), `+projectIDOrDefault("mo.project_id")+`
+		FROM match_assignments ma
+		JOIN matched_orders mo ON mo.id = ma.matched_order_id
+		WHERE `+projectIDOrDefault("mo.project_id")+` = $1 AND ma.id > $2
+		ORDER BY ma.id ASC
+		LIMIT $3
+	`, projectID, afterID, limit+1)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	assignments := []ProjectAssignment{}
+	for rows.Next() {
+		var pa ProjectAssignment
+		if err := rows.Scan(&pa.ID, &pa.BuyerOrderID, &pa.SellerOrderID, &pa.SellerUserID,
+			&pa.SellerTransactionID, &pa.SellerTotalQty, &pa.AssignedQty,
+			&pa.SellerPrice, &pa.MatchedOrderID, &pa.MatchedTxnType, &pa.AssignedAt,
+			&pa.BuyerTransactionID, &pa.MatchedPrice, &pa.ProjectID); err != nil {
+			return nil, false, err
+		}
+		assignments = append(assignments, pa)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	// One extra row was fetched to know whether another page exists
+	hasMore := len(assignments) > limit
+	if hasMore {
+		assignments = assignments[:limit]
+	}
+	return assignments, hasMore, nil
+}
+
+// GET /api/admin/match-assignments?project_id=&after_id=&limit= - ordered by
+// assignment id, 500 per page by default (admin). The body is a plain array;
+// pass X-Next-After-ID back as after_id for the next page.
+func getProjectAssignmentsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	projectID, err := strconv.Atoi(query.Get("project_id"))
+	if err != nil || projectID <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_PROJECT_ID", "project_id is required and must be a positive integer")
+		return
+	}
+
+	afterID := 0
+	if afterIDStr := query.Get("after_id"); afterIDStr != "" {
+		afterID, err = strconv.Atoi(afterIDStr)
+		if err != nil || afterID < 0 {
+			writeJSONError(w, http.StatusBadRequest, "INVALID_CURSOR", "after_id must be a non-negative integer")
+			return
+		}
+	}
+
+	limit := 500
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > 5000 {
+			writeJSONError(w, http.StatusBadRequest, "INVALID_LIMIT", "limit must be between 1 and 5000")
+			return
+		}
+	}
+
+	ctx, cancel := requestQueryContext(r)
+	defer cancel()
+
+	assignments, hasMore, err := getProjectAssignmentsPage(ctx, dbRead, projectID, afterID, limit)
+	if err != nil {
+		writeQueryError(w, ctx, err, "Error fetching match assignments")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Has-More", strconv.FormatBool(hasMore))
+	if hasMore {
+		w.Header().Set("X-Next-After-ID", strconv.Itoa(assignments[len(assignments)-1].ID))
+	}
+	json.NewEncoder(w).Encode(assignments)
+}
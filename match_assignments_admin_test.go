@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProjectAssignmentsWalkTwoPages(t *testing.T) {
+	openTestDB(t)
+	_, adminToken := createTestUser(t, "admin", true)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+	for i := 0; i < 3; i++ {
+		tradeTestOrders(t, defaultProjectID, buyerUser, sellerUser, 10, 1)
+	}
+	waitForTestCount(t, "match_assignments", 3)
+
+	origin := "http://example.com"
+	if !allowsAnyOrigin(allowedOrigins) {
+		origin = allowedOrigins[0]
+	}
+	testHandlerOnce.Do(func() { testHandler = newHandler() })
+	getPage := func(afterID string) ([]SellerAssignment, http.Header) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet,
+			fmt.Sprintf("/api/v1/admin/match-assignments?project_id=%d&limit=2&after_id=%s", defaultProjectID, afterID), nil)
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		req.Header.Set("Origin", origin)
+		rec := httptest.NewRecorder()
+		testHandler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("after_id=%s: status %d (%s)", afterID, rec.Code, rec.Body.String())
+		}
+		var page []SellerAssignment
+		decodeTestResponse(t, rec, &page)
+		return page, rec.Header()
+	}
+
+	first, header := getPage("0")
+	if len(first) != 2 || header.Get("X-Has-More") != "true" {
+		t.Fatalf("first page: %d rows, X-Has-More %q; want 2, true", len(first), header.Get("X-Has-More"))
+	}
+	if !strings.Contains(strings.ToLower(header.Get("Access-Control-Expose-Headers")), "x-next-after-id") {
+		t.Errorf("Access-Control-Expose-Headers = %q, want X-Next-After-ID readable by browsers", header.Get("Access-Control-Expose-Headers"))
+	}
+
+	second, header := getPage(header.Get("X-Next-After-ID"))
+	if len(second) != 1 || header.Get("X-Has-More") != "false" {
+		t.Fatalf("second page: %d rows, X-Has-More %q; want 1, false", len(second), header.Get("X-Has-More"))
+	}
+
+	seen := map[int]bool{}
+	lastID := 0
+	for _, a := range append(first, second...) {
+		if seen[a.ID] || a.ID <= lastID {
+			t.Errorf("assignment #%d repeated or out of order", a.ID)
+		}
+		seen[a.ID] = true
+		lastID = a.ID
+	}
+	if len(seen) != 3 {
+		t.Errorf("walked %d assignments, want all 3", len(seen))
+	}
+}
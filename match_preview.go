@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+type MatchPreview struct {
+	BuyerOrderID       int      `json:"buyer_order_id"`
+	SellerOrderID      int      `json:"seller_order_id"`
+	BuyerUserID        int      `json:"buyer_user_id"`
+	SellerUserID       int      `json:"seller_user_id"`
+	ProjectID          int      `json:"project_id"`
+	BuyerPrice         float64  `json:"buyer_price"`
+	SellerPrice        float64  `json:"seller_price"`
+	MatchedQty         Quantity `json:"matched_qty"`
+	TransactionType    int      `json:"transaction_type"`
+	TakerSide          string   `json:"taker_side"`
+	MakerFee           float64  `json:"maker_fee"`
+	TakerFee           float64  `json:"taker_fee"`
+	BuyerRemainingQty  Quantity `json:"buyer_remaining_qty"`
+	SellerRemainingQty Quantity `json:"seller_remaining_qty"`
+}
+
+// Replays the matcher's loop in memory over the current top tables: same
+// candidates, same fill decisions, same restart-from-the-top order. Nothing is
+// written. Orders that a real run would promote from the main tables after a
+// fill are not seen, so the preview can under-report long runs.
+func previewMatches(ctx context.Context, projectID int) ([]MatchPreview, error) {
+	sellers, err := loadMatchSellers(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	buyers, err := loadMatchBuyers(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	rates := currentFeeRates()
+	maxFills := currentMatchingConfig().MaxFillsPerMatch
+	bestFit := sellerSelectionBestFitEnabled()
+	cappedBuyers := map[int]bool{}
+	previews := []MatchPreview{}
+
+	for {
+		matched := false
+		for i := range buyers {
+			buyer := &buyers[i]
+			if buyer.Quantity <= 0 || cappedBuyers[buyer.ID] {
+				continue
+			}
+
+			liveSellers := []OrderData{}
+			for _, seller := range sellers {
+				if seller.Quantity > 0 {
+					liveSellers = append(liveSellers, seller)
+				}
+			}
+
+			compatibleSellers, _ := haltAllowedSellers(*buyer, compatibleSellersFor(*buyer, liveSellers))
+			if len(compatibleSellers) == 0 {
+				continue
+			}
+
+			if !meetsMinFill(*buyer, compatibleSellers) {
+				continue
+			}
+			fills, remainingBuyerQty := planBuyerFills(*buyer, compatibleSellers, rates, maxFills, bestFit)
+			for _, fill := range fills {
+				sellerRemaining := fill.Seller.Quantity - fill.MatchedQty
+				for j := range sellers {
+					if sellers[j].ID == fill.Seller.ID {
+						sellers[j].Quantity = sellerRemaining
+					}
+				}
+				previews = append(previews, MatchPreview{
+					BuyerOrderID:       buyer.ID,
+					SellerOrderID:      fill.Seller.ID,
+					BuyerUserID:        buyer.UserID,
+					SellerUserID:       fill.Seller.UserID,
+					ProjectID:          buyer.ProjectID,
+					BuyerPrice:         fill.BuyerPrice,
+					SellerPrice:        fill.Seller.Price,
+					MatchedQty:         fill.MatchedQty,
+					TransactionType:    fill.MatchedTxnType,
+					TakerSide:          fill.TakerSide,
+					MakerFee:           fill.MakerFee,
+					TakerFee:           fill.TakerFee,
+					BuyerRemainingQty:  buyer.Quantity - fill.MatchedQty,
+					SellerRemainingQty: sellerRemaining,
+				})
+				buyer.Quantity -= fill.MatchedQty
+			}
+			buyer.MinFillQty = 0 // met by the first fill, as in matchOrders
+
+			if remainingBuyerQty > 0 && maxFills > 0 && len(fills) >= maxFills {
+				cappedBuyers[buyer.ID] = true
+			}
+			matched = true
+			break // Restart from the top buyer, as runMatching does
+		}
+
+		if matched {
+			continue
+		}
+		if len(cappedBuyers) > 0 {
+			cappedBuyers = map[int]bool{}
+			continue
+		}
+		break
+	}
+
+	return previews, nil
+}
+
+// POST /api/admin/match/preview?project_id= - what matching would do right now,
+// without writing anything (admin)
+func previewMatchingHandler(w http.ResponseWriter, r *http.Request) {
+	projectID := 0
+	if projectIDStr := r.URL.Query().Get("project_id"); projectIDStr != "" {
+		var err error
+		projectID, err = strconv.Atoi(projectIDStr)
+		if err != nil || projectID <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "INVALID_PROJECT_ID", "Invalid project ID")
+			return
+		}
+	}
+
+	if !arePreparedStatementsReady() {
+		writeJSONError(w, http.StatusServiceUnavailable, "MATCHING_NOT_READY", "Matching engine not initialized")
+		return
+	}
+
+	ctx, cancel := requestQueryContext(r)
+	defer cancel()
+
+	previews, err := previewMatches(ctx, projectID)
+	if err != nil {
+		writeQueryError(w, ctx, err, "Error previewing matches")
+		return
+	}
+
+	totalQty := Quantity(0)
+	for _, p := range previews {
+		totalQty += p.MatchedQty
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"project_id":  projectID,
+		"match_count": len(previews),
+		"matched_qty": totalQty,
+		"matches":     previews,
+	})
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// Digest of every row in the tables matching writes to
+func snapshotMatchingTables(t *testing.T) map[string]string {
+	t.Helper()
+	snapshot := map[string]string{}
+	for _, table := range []string{"buyer", "seller", "top_buyer", "top_seller", "matched_orders", "match_assignments",
+		"buyer_order_history", "seller_order_history"} {
+		var digest string
+		err := db.QueryRow(fmt.Sprintf(`SELECT md5(COALESCE(string_agg(t::text, ',' ORDER BY t::text), '')) FROM %s t`, table)).Scan(&digest)
+		if err != nil {
+			t.Fatalf("snapshot %s: %v", table, err)
+		}
+		snapshot[table] = digest
+	}
+	return snapshot
+}
+
+func TestMatchPreviewLeavesDatabaseUnchanged(t *testing.T) {
+	openTestDB(t)
+	_, adminToken := createTestUser(t, "admin", true)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+
+	placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 10, Quantity: wholeQuantity(5)})
+	placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 10, Quantity: wholeQuantity(3)})
+	before := snapshotMatchingTables(t)
+
+	rec := doTestRequest(t, http.MethodPost, fmt.Sprintf("/api/v1/admin/match/preview?project_id=%d", defaultProjectID), adminToken, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("preview: status %d (%s)", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		MatchCount int      `json:"match_count"`
+		MatchedQty Quantity `json:"matched_qty"`
+	}
+	decodeTestResponse(t, rec, &resp)
+	if resp.MatchCount != 1 || resp.MatchedQty != wholeQuantity(3) {
+		t.Errorf("preview = %d matches for %s, want 1 for 3", resp.MatchCount, resp.MatchedQty)
+	}
+
+	after := snapshotMatchingTables(t)
+	for table, digest := range before {
+		if after[table] != digest {
+			t.Errorf("%s changed during the preview", table)
+		}
+	}
+}
@@ -1,16 +1,23 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"sync"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 // Global cache for circuit breakers to avoid DB hits during matching loop
 var (
 	breakerCache      = make(map[int]bool)
+	breakerMLPExempt  = make(map[int]bool) // mlp_exempt_from_halt per project
 	breakerCacheMutex sync.RWMutex
 )
 
@@ -27,13 +34,66 @@ func isProjectHaltedCached(projectID int) bool {
 	return breakerCache[projectID]
 }
 
+func isMLPExemptFromHaltCached(projectID int) bool {
+	breakerCacheMutex.RLock()
+	defer breakerCacheMutex.RUnlock()
+	return breakerMLPExempt[projectID]
+}
+
+// Narrows the buyer's compatible sellers to what its project's breaker allows.
+// A halted project trades nothing, unless mlp_exempt_from_halt is set - then
+// fills with an MLP order on either side still go through. The bool reports
+// whether the project is halted.
+func haltAllowedSellers(buyer OrderData, sellers []OrderData) ([]OrderData, bool) {
+	if !isProjectHaltedCached(buyer.ProjectID) {
+		return sellers, false
+	}
+	if !isMLPExemptFromHaltCached(buyer.ProjectID) {
+		return nil, true
+	}
+	if buyer.MarketLeadProgram {
+		return sellers, true
+	}
+	var mlpSellers []OrderData
+	for _, seller := range sellers {
+		if seller.MarketLeadProgram {
+			mlpSellers = append(mlpSellers, seller)
+		}
+	}
+	return mlpSellers, true
+}
+
+// Reload halt flags for every project so the cache reflects the DB state
+func refreshBreakerCache(database *sql.DB) error {
+	rows, err := database.Query(`
+		SELECT project_id, COALESCE(is_halted, false), mlp_exempt_from_halt FROM project_circuit_breakers
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var projectID int
+		var isHalted, mlpExempt bool
+		if err := rows.Scan(&projectID, &isHalted, &mlpExempt); err != nil {
+			continue
+		}
+		updateBreakerCache(projectID, isHalted)
+		breakerCacheMutex.Lock()
+		breakerMLPExempt[projectID] = mlpExempt
+		breakerCacheMutex.Unlock()
+	}
+	return rows.Err()
+}
+
 type MatchedOrder struct {
 	ID                  int       `json:"id"`
 	SellerPrice         float64   `json:"seller_price"`
 	BuyerPrice          float64   `json:"buyer_price"`
-	SellerQty           int       `json:"seller_qty"`
-	BuyerQty            int       `json:"buyer_qty"`
-	MatchedQty          int       `json:"matched_qty"`
+	SellerQty           Quantity  `json:"seller_qty"`
+	BuyerQty            Quantity  `json:"buyer_qty"`
+	MatchedQty          Quantity  `json:"matched_qty"`
 	SellerTime          string    `json:"seller_time"`
 	BuyerTime           string    `json:"buyer_time"`
 	SellerDate          string    `json:"seller_date"`
@@ -51,6 +111,13 @@ type MatchedOrder struct {
 	BuyerOrderID        int       `json:"buyer_order_id"`
 	SellerOrderID       int       `json:"seller_order_id"`
 	IsMultiMatch        bool      `json:"is_multi_match"`
+	MakerFee            float64   `json:"maker_fee"`
+	TakerFee            float64   `json:"taker_fee"`
+	TakerSide           string    `json:"taker_side"`
+	ExecutionPrice      float64   `json:"execution_price"` // the maker's price, the same for both sides
+	BuyerAny            bool      `json:"buyer_any"`       // buyer was transaction_type 2 (any)
+	SellerAny           bool      `json:"seller_any"`      // seller was transaction_type 2 (any)
+	CreatedAt           time.Time `json:"created_at"`
 }
 
 type MatchAssignment struct {
@@ -59,13 +126,22 @@ type MatchAssignment struct {
 	SellerOrderID       int       `json:"seller_order_id"`
 	SellerUserID        int       `json:"seller_user_id"`
 	SellerTransactionID string    `json:"seller_transaction_id"`
-	SellerTotalQty      int       `json:"seller_total_qty"`
-	AssignedQty         int       `json:"assigned_qty"`
+	SellerTotalQty      Quantity  `json:"seller_total_qty"`
+	AssignedQty         Quantity  `json:"assigned_qty"`
 	SellerPrice         float64   `json:"seller_price"`
 	MatchedOrderID      int       `json:"matched_order_id"`
+	MatchedTxnType      *int      `json:"matched_transaction_type"`
 	AssignedAt          time.Time `json:"assigned_at"`
 }
 
+// Seller-side view of an assignment, with the buyer and trade it belongs to
+type SellerAssignment struct {
+	MatchAssignment
+	BuyerTransactionID string  `json:"buyer_transaction_id"`
+	TradePrice         float64 `json:"trade_price"`
+	ProjectID          int     `json:"project_id"`
+}
+
 var (
 	getBuyerQuery        string
 	getAllSellersQuery   string
@@ -85,11 +161,11 @@ var (
 func initMatchedOrdersTable(database *sql.DB) {
 	query := `CREATE TABLE IF NOT EXISTS matched_orders (
 		id SERIAL PRIMARY KEY,
-		seller_price DECIMAL(10, 2) NOT NULL,
-		buyer_price DECIMAL(10, 2) NOT NULL,
-		seller_qty INTEGER NOT NULL,
-		buyer_qty INTEGER NOT NULL,
-		matched_qty INTEGER NOT NULL,
+		seller_price DECIMAL(18, 6) NOT NULL,
+		buyer_price DECIMAL(18, 6) NOT NULL,
+		seller_qty DECIMAL(18, 8) NOT NULL,
+		buyer_qty DECIMAL(18, 8) NOT NULL,
+		matched_qty DECIMAL(18, 8) NOT NULL,
 		seller_time TIME NOT NULL,
 		buyer_time TIME NOT NULL,
 		seller_date DATE NOT NULL,
@@ -116,9 +192,20 @@ func initMatchedOrdersTable(database *sql.DB) {
 	}
 
 	alterQueries := []string{
-		`ALTER TABLE matched_orders ADD COLUMN IF NOT EXISTS matched_qty INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE matched_orders ADD COLUMN IF NOT EXISTS matched_qty DECIMAL(18, 8) NOT NULL DEFAULT 0`,
 		`ALTER TABLE matched_orders ADD COLUMN IF NOT EXISTS project_id INTEGER NOT NULL DEFAULT 1`,
 		`ALTER TABLE matched_orders ADD COLUMN IF NOT EXISTS is_multi_match BOOLEAN DEFAULT false`,
+		`ALTER TABLE matched_orders ADD COLUMN IF NOT EXISTS maker_fee DECIMAL(18, 6) NOT NULL DEFAULT 0`,
+		`ALTER TABLE matched_orders ADD COLUMN IF NOT EXISTS taker_fee DECIMAL(18, 6) NOT NULL DEFAULT 0`,
+		`ALTER TABLE matched_orders ADD COLUMN IF NOT EXISTS taker_side VARCHAR(6) NOT NULL DEFAULT ''`,
+		`ALTER TABLE matched_orders ADD COLUMN IF NOT EXISTS execution_price DECIMAL(18, 6)`,
+		// Trades from before execution_price existed: the maker is the side that wasn't the taker
+		`UPDATE matched_orders SET execution_price = CASE WHEN taker_side = 'seller' THEN buyer_price ELSE seller_price END
+		 WHERE execution_price IS NULL`,
+		// Which side was a type-2 (any) order; transaction_type holds the resolved
+		// type. Trades from before these existed read false on both sides.
+		`ALTER TABLE matched_orders ADD COLUMN IF NOT EXISTS buyer_any BOOLEAN NOT NULL DEFAULT false`,
+		`ALTER TABLE matched_orders ADD COLUMN IF NOT EXISTS seller_any BOOLEAN NOT NULL DEFAULT false`,
 	}
 
 	for _, q := range alterQueries {
@@ -135,6 +222,7 @@ func initMatchedOrdersTable(database *sql.DB) {
 		`CREATE INDEX IF NOT EXISTS idx_top_buyer_order ON top_buyer (order_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_top_seller_order ON top_seller (order_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_matched_orders_created ON matched_orders (created_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_matched_orders_project_created ON matched_orders (project_id, created_at DESC)`,
 		`CREATE INDEX IF NOT EXISTS idx_top_buyer_project ON top_buyer (project_id)`, // Added for faster project lookup
 	}
 
@@ -154,13 +242,20 @@ func initMatchAssignmentsTable(database *sql.DB) {
 		seller_order_id INTEGER NOT NULL,
 		seller_user_id INTEGER NOT NULL,
 		seller_transaction_id VARCHAR(8) NOT NULL,
-		seller_total_qty INTEGER NOT NULL,
-		assigned_qty INTEGER NOT NULL,
-		seller_price DECIMAL(10, 2) NOT NULL,
+		seller_total_qty DECIMAL(18, 8) NOT NULL,
+		assigned_qty DECIMAL(18, 8) NOT NULL,
+		seller_price DECIMAL(18, 6) NOT NULL,
 		matched_order_id INTEGER REFERENCES matched_orders(id) ON DELETE CASCADE,
+		matched_transaction_type INTEGER,
 		assigned_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	)`
 	database.Exec(query)
+
+	// Concrete type (0/1) a fill was treated as, resolved from any (2) orders
+	_, err := database.Exec(`ALTER TABLE match_assignments ADD COLUMN IF NOT EXISTS matched_transaction_type INTEGER`)
+	if err != nil {
+		log.Printf("Warning: Could not add matched_transaction_type column: %v", err)
+	}
 }
 
 func initBuyerOrderHistoryTable(database *sql.DB) {
@@ -169,13 +264,13 @@ func initBuyerOrderHistoryTable(database *sql.DB) {
 		buyer_order_id INTEGER NOT NULL UNIQUE,
 		buyer_user_id INTEGER NOT NULL,
 		buyer_transaction_id VARCHAR(8) NOT NULL,
-		original_price DECIMAL(10, 2) NOT NULL,
-		original_qty INTEGER NOT NULL,
+		original_price DECIMAL(18, 6) NOT NULL,
+		original_qty DECIMAL(18, 8) NOT NULL,
 		buyer_trade_date DATE NOT NULL,
 		buyer_trade_time TIME NOT NULL,
 		project_id INTEGER NOT NULL DEFAULT 1,
-		total_matched_qty INTEGER NOT NULL DEFAULT 0,
-		remaining_qty INTEGER NOT NULL,
+		total_matched_qty DECIMAL(18, 8) NOT NULL DEFAULT 0,
+		remaining_qty DECIMAL(18, 8) NOT NULL,
 		match_count INTEGER NOT NULL DEFAULT 0,
 		seller_count INTEGER NOT NULL DEFAULT 0,
 		status VARCHAR(20) DEFAULT 'Pending',
@@ -186,61 +281,142 @@ func initBuyerOrderHistoryTable(database *sql.DB) {
 	database.Exec(`ALTER TABLE buyer_order_history ADD COLUMN IF NOT EXISTS seller_count INTEGER NOT NULL DEFAULT 0`)
 }
 
-// Optimized: Fire and forget
-func recordBuyerOrderHistory(database *sql.DB, order Order) error {
-	go func() {
-		query := `
-			INSERT INTO buyer_order_history 
-			(buyer_order_id, buyer_user_id, buyer_transaction_id, original_price, original_qty, 
-			 buyer_trade_date, buyer_trade_time, project_id, remaining_qty, status)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, 'Pending')
-			ON CONFLICT (buyer_order_id) DO NOTHING
-		`
-		projectID := 1
-		if order.ProjectID != nil {
-			projectID = *order.ProjectID
-		}
-		database.Exec(query, order.ID, order.UserID, order.TransactionID, 
-			order.Price, order.Quantity, order.TradeDate, order.TradeTime, 
-			projectID, order.Quantity)
-	}()
-	return nil
+// Runs in the order's insert transaction, so the row commits with the order
+// and exists before it can be matched; the fill updates below need it
+func recordBuyerOrderHistoryTx(tx *sql.Tx, order Order) error {
+	query := `
+		INSERT INTO buyer_order_history 
+		(buyer_order_id, buyer_user_id, buyer_transaction_id, original_price, original_qty, 
+		 buyer_trade_date, buyer_trade_time, project_id, remaining_qty, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, 'Pending')
+		ON CONFLICT (buyer_order_id) DO NOTHING
+	`
+	projectID := defaultProjectID
+	if order.ProjectID != nil {
+		projectID = *order.ProjectID
+	}
+	_, err := tx.Exec(query, order.ID, order.UserID, order.TransactionID, 
+		order.Price, order.Quantity, order.TradeDate, order.TradeTime, 
+		projectID, order.Quantity)
+	return err
 }
 
-// Optimized: Fire and forget
-func updateBuyerOrderHistory(database *sql.DB, buyerID int, matchedQty int) error {
-	go func() {
-		query := `
-			UPDATE buyer_order_history
-			SET total_matched_qty = total_matched_qty + $1,
-			    remaining_qty = remaining_qty - $1,
-			    match_count = match_count + 1,
-			    seller_count = seller_count + 1,
-			    updated_at = CURRENT_TIMESTAMP,
-			    status = CASE 
-			        WHEN remaining_qty - $1 <= 0 THEN 'Completed'
-			        ELSE 'Partially Matched'
-			    END
-			WHERE buyer_order_id = $2
-		`
-		database.Exec(query, matchedQty, buyerID)
-	}()
-	return nil
+// Applies one fill to the buyer's history row inside the match transaction,
+// so concurrent fills on the same order commit (or roll back) with the match
+// itself. remaining_qty never goes below zero.
+func updateBuyerOrderHistoryTx(tx *sql.Tx, buyerID int, matchedQty Quantity) error {
+	_, err := tx.Exec(`
+		UPDATE buyer_order_history
+		SET total_matched_qty = total_matched_qty + $1,
+		    remaining_qty = GREATEST(remaining_qty - $1, 0),
+		    match_count = match_count + 1,
+		    seller_count = seller_count + 1,
+		    updated_at = CURRENT_TIMESTAMP,
+		    status = CASE 
+		        WHEN remaining_qty - $1 <= 0 THEN 'Completed'
+		        ELSE 'Partially Matched'
+		    END
+		WHERE buyer_order_id = $2
+	`, matchedQty, buyerID)
+	return err
+}
+
+func initSellerOrderHistoryTable(database *sql.DB) {
+	query := `CREATE TABLE IF NOT EXISTS seller_order_history (
+		id SERIAL PRIMARY KEY,
+		seller_order_id INTEGER NOT NULL UNIQUE,
+		seller_user_id INTEGER NOT NULL,
+		seller_transaction_id VARCHAR(8) NOT NULL,
+		original_price DECIMAL(18, 6) NOT NULL,
+		original_qty DECIMAL(18, 8) NOT NULL,
+		seller_trade_date DATE NOT NULL,
+		seller_trade_time TIME NOT NULL,
+		project_id INTEGER NOT NULL DEFAULT 1,
+		total_matched_qty DECIMAL(18, 8) NOT NULL DEFAULT 0,
+		remaining_qty DECIMAL(18, 8) NOT NULL,
+		match_count INTEGER NOT NULL DEFAULT 0,
+		buyer_count INTEGER NOT NULL DEFAULT 0,
+		status VARCHAR(20) DEFAULT 'Pending',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`
+	database.Exec(query)
+}
+
+// Seller counterpart of recordBuyerOrderHistoryTx
+func recordSellerOrderHistoryTx(tx *sql.Tx, order Order) error {
+	query := `
+		INSERT INTO seller_order_history 
+		(seller_order_id, seller_user_id, seller_transaction_id, original_price, original_qty, 
+		 seller_trade_date, seller_trade_time, project_id, remaining_qty, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, 'Pending')
+		ON CONFLICT (seller_order_id) DO NOTHING
+	`
+	projectID := defaultProjectID
+	if order.ProjectID != nil {
+		projectID = *order.ProjectID
+	}
+	_, err := tx.Exec(query, order.ID, order.UserID, order.TransactionID, 
+		order.Price, order.Quantity, order.TradeDate, order.TradeTime, 
+		projectID, order.Quantity)
+	return err
+}
+
+// Seller counterpart of updateBuyerOrderHistoryTx
+func updateSellerOrderHistoryTx(tx *sql.Tx, sellerID int, matchedQty Quantity) error {
+	_, err := tx.Exec(`
+		UPDATE seller_order_history
+		SET total_matched_qty = total_matched_qty + $1,
+		    remaining_qty = GREATEST(remaining_qty - $1, 0),
+		    match_count = match_count + 1,
+		    buyer_count = buyer_count + 1,
+		    updated_at = CURRENT_TIMESTAMP,
+		    status = CASE 
+		        WHEN remaining_qty - $1 <= 0 THEN 'Completed'
+		        ELSE 'Partially Matched'
+		    END
+		WHERE seller_order_id = $2
+	`, matchedQty, sellerID)
+	return err
 }
 
 // Optimized: Fire and forget
 func recordMatchAssignment(database *sql.DB, buyerOrderID, sellerOrderID, sellerUserID int, 
-	sellerTransactionID string, sellerTotalQty, assignedQty int, sellerPrice float64, matchedOrderID int) error {
+	sellerTransactionID string, sellerTotalQty, assignedQty Quantity, sellerPrice float64, matchedOrderID int,
+	matchedTxnType int) error {
 	
 	go func() {
-		query := `
+		args := []interface{}{buyerOrderID, sellerOrderID, sellerUserID,
+			sellerTransactionID, sellerTotalQty, assignedQty, sellerPrice, matchedOrderID, matchedTxnType}
+		result, err := database.Exec(`
 			INSERT INTO match_assignments 
 			(buyer_order_id, seller_order_id, seller_user_id, seller_transaction_id, 
-			 seller_total_qty, assigned_qty, seller_price, matched_order_id)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		`
-		database.Exec(query, buyerOrderID, sellerOrderID, sellerUserID, 
-			sellerTransactionID, sellerTotalQty, assignedQty, sellerPrice, matchedOrderID)
+			 seller_total_qty, assigned_qty, seller_price, matched_order_id, matched_transaction_type)
+			SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9
+			WHERE EXISTS (SELECT 1 FROM matched_orders WHERE id = $8)
+		`, args...)
+
+		// The matched order was archived before this ran - file the
+		// assignment straight into the archive alongside it
+		var rows int64
+		if err == nil {
+			rows, _ = result.RowsAffected()
+		}
+		if err == nil && rows == 0 {
+			_, err = database.Exec(`
+				INSERT INTO match_assignments_archive (id, matched_order_id, data)
+				SELECT a.id, a.matched_order_id, to_jsonb(a) FROM (
+					SELECT nextval(pg_get_serial_sequence('match_assignments', 'id'))::INTEGER AS id,
+					       $1::INTEGER AS buyer_order_id, $2::INTEGER AS seller_order_id, $3::INTEGER AS seller_user_id,
+					       $4::VARCHAR AS seller_transaction_id, $5::DECIMAL AS seller_total_qty, $6::DECIMAL AS assigned_qty,
+					       $7::DECIMAL AS seller_price, $8::INTEGER AS matched_order_id, $9::INTEGER AS matched_transaction_type,
+					       LOCALTIMESTAMP AS assigned_at
+				) a
+			`, args...)
+		}
+		if err != nil {
+			log.Printf("⚠️ Warning: Could not record assignment for matched order #%d: %v", matchedOrderID, err)
+		}
 	}()
 	return nil
 }
@@ -267,10 +443,32 @@ func getBuyerOrderHistory(database *sql.DB, buyerID int) (*BuyerOrderHistory, er
 	return &history, nil
 }
 
+func getSellerOrderHistory(database *sql.DB, sellerID int) (*SellerOrderHistory, error) {
+	query := `
+		SELECT id, seller_order_id, seller_user_id, seller_transaction_id, original_price, original_qty,
+		       seller_trade_date, TO_CHAR(seller_trade_time, 'HH24:MI:SS'), project_id, 
+		       total_matched_qty, remaining_qty, match_count, buyer_count, status, created_at, updated_at
+		FROM seller_order_history
+		WHERE seller_order_id = $1
+	`
+	var history SellerOrderHistory
+	var tradeTime string
+	err := database.QueryRow(query, sellerID).Scan(
+		&history.ID, &history.SellerOrderID, &history.SellerUserID, &history.SellerTransactionID,
+		&history.OriginalPrice, &history.OriginalQty, &history.SellerTradeDate, &tradeTime,
+		&history.ProjectID, &history.TotalMatchedQty, &history.RemainingQty, &history.MatchCount,
+		&history.BuyerCount, &history.Status, &history.CreatedAt, &history.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	history.SellerTradeTime = tradeTime
+	return &history, nil
+}
+
 func getMatchAssignments(database *sql.DB, buyerOrderID int) ([]MatchAssignment, error) {
 	query := `
 		SELECT id, buyer_order_id, seller_order_id, seller_user_id, seller_transaction_id,
-		       seller_total_qty, assigned_qty, seller_price, matched_order_id, assigned_at
+		       seller_total_qty, assigned_qty, seller_price, matched_order_id, matched_transaction_type, assigned_at
 		FROM match_assignments
 		WHERE buyer_order_id = $1
 		ORDER BY assigned_at ASC
@@ -285,22 +483,94 @@ func getMatchAssignments(database *sql.DB, buyerOrderID int) ([]MatchAssignment,
 		var ma MatchAssignment
 		rows.Scan(&ma.ID, &ma.BuyerOrderID, &ma.SellerOrderID, &ma.SellerUserID,
 			&ma.SellerTransactionID, &ma.SellerTotalQty, &ma.AssignedQty,
-			&ma.SellerPrice, &ma.MatchedOrderID, &ma.AssignedAt)
+			&ma.SellerPrice, &ma.MatchedOrderID, &ma.MatchedTxnType, &ma.AssignedAt)
 		assignments = append(assignments, ma)
 	}
 	return assignments, nil
 }
 
+func getMatchAssignmentsBySeller(database *sql.DB, sellerUserID int) ([]SellerAssignment, error) {
+	query := `
+		SELECT ma.id, ma.buyer_order_id, ma.seller_order_id, ma.seller_user_id, ma.seller_transaction_id,
+		       ma.seller_total_qty, ma.assigned_qty, ma.seller_price, COALESCE(ma.matched_order_id, 0),
+		       ma.matched_transaction_type, ma.assigned_at,
+		       COALESCE(mo.buyer_transaction_id, ''), COALESCE(mo.buyer_price, ma.seller_price), ` + projectIDOrDefault("mo.project_id") + `
+		FROM match_assignments ma
+		LEFT JOIN matched_orders mo ON mo.id = ma.matched_order_id
+		WHERE ma.seller_user_id = $1
+		ORDER BY ma.assigned_at DESC
+	`
+	rows, err := database.Query(query, sellerUserID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying seller match assignments: %v", err)
+	}
+	defer rows.Close()
+	assignments := []SellerAssignment{}
+	for rows.Next() {
+		var sa SellerAssignment
+		if err := rows.Scan(&sa.ID, &sa.BuyerOrderID, &sa.SellerOrderID, &sa.SellerUserID,
+			&sa.SellerTransactionID, &sa.SellerTotalQty, &sa.AssignedQty,
+			&sa.SellerPrice, &sa.MatchedOrderID, &sa.MatchedTxnType, &sa.AssignedAt,
+			&sa.BuyerTransactionID, &sa.TradePrice, &sa.ProjectID); err != nil {
+			return nil, fmt.Errorf("error scanning seller match assignment: %v", err)
+		}
+		assignments = append(assignments, sa)
+	}
+	return assignments, nil
+}
+
+// The pool the matcher statements were prepared on. database/sql re-prepares
+// a *sql.Stmt on whichever connection runs it, so statements survive
+// connection resets and only need preparing once per pool.
+var (
+	preparedStmtsDB    *sql.DB
+	preparedStmtsMutex sync.Mutex
+)
+
+// Prepares the matcher statements on first use (or for a different pool).
+// Unlike sync.Once, a failed attempt is retried on the next call.
+func ensurePreparedStatements(database *sql.DB) error {
+	preparedStmtsMutex.Lock()
+	defer preparedStmtsMutex.Unlock()
+
+	if preparedStmtsDB == database {
+		return nil
+	}
+	closePreparedStatements()
+	if err := initPreparedStatements(database); err != nil {
+		closePreparedStatements()
+		setPreparedStatementsReady(false)
+		return err
+	}
+	preparedStmtsDB = database
+	return nil
+}
+
+func closePreparedStatements() {
+	for _, stmt := range []**sql.Stmt{&getBuyerStmt, &getAllSellersStmt, &insertMatchedStmt, &countBuyerStmt, &countSellerStmt} {
+		if *stmt != nil {
+			(*stmt).Close()
+			*stmt = nil
+		}
+	}
+	preparedStmtsDB = nil
+}
+
+// Call through ensurePreparedStatements; preparing on every run leaked the
+// previous statements and swapped them under concurrent runs
 func initPreparedStatements(database *sql.DB) error {
 	var err error
 
 	// UPDATED: Increased LIMIT from 1 to 20 to allow checking multiple buyers
+	// Market buyers go first - they execute immediately and never rest
 	getBuyerQuery = `
 		SELECT order_id, user_id, transaction_id, price, quantity, 
 		       trade_date, trade_time, transaction_type, created_at, 
-			   match_type, COALESCE(project_id, 1)
+			   match_type, order_kind, ` + projectIDOrDefault("project_id") + `, market_lead_program, max_slippage_percentage,
+			   min_fill_quantity
 		FROM top_buyer
-		ORDER BY market_lead_program DESC, price DESC, quantity DESC, trade_date ASC, trade_time ASC
+		WHERE ($1 = 0 OR ` + projectIDOrDefault("project_id") + ` = $1) AND ` + notExpiredCondition + `
+		ORDER BY (order_kind = 'market') DESC, market_lead_program DESC, price DESC, quantity DESC, trade_date ASC, trade_time ASC, created_at ASC, order_id ASC
 		LIMIT 20
 	`
 	getBuyerStmt, err = database.Prepare(getBuyerQuery)
@@ -311,9 +581,10 @@ func initPreparedStatements(database *sql.DB) error {
 	// UPDATED: Increased LIMIT from 10 to 50 to see sellers for 2nd/3rd ranked buyers
 	getAllSellersQuery = `
 		SELECT order_id, user_id, transaction_id, price, quantity,
-		       trade_date, trade_time, transaction_type, created_at, COALESCE(project_id, 1)
+		       trade_date, trade_time, transaction_type, created_at, ` + projectIDOrDefault("project_id") + `, market_lead_program
 		FROM top_seller
-		ORDER BY market_lead_program DESC, price ASC, quantity DESC, trade_date ASC, trade_time ASC
+		WHERE ($1 = 0 OR ` + projectIDOrDefault("project_id") + ` = $1) AND ` + notExpiredCondition + `
+		ORDER BY market_lead_program DESC, price ASC, quantity DESC, trade_date ASC, trade_time ASC, created_at ASC, order_id ASC
 		LIMIT 50
 	`
 	getAllSellersStmt, err = database.Prepare(getAllSellersQuery)
@@ -327,8 +598,9 @@ func initPreparedStatements(database *sql.DB) error {
 		(seller_price, buyer_price, seller_qty, buyer_qty, matched_qty, seller_time, buyer_time, 
 		 seller_date, buyer_date, incoming_time, outgoing_time, time_taken, status, 
 		 transaction_type, buyer_order_id, seller_order_id, buyer_user_id, seller_user_id,
-		 buyer_transaction_id, seller_transaction_id, project_id, is_multi_match)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22)
+		 buyer_transaction_id, seller_transaction_id, project_id, is_multi_match,
+		 maker_fee, taker_fee, taker_side, execution_price, buyer_any, seller_any)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28)
 		RETURNING id
 	`
 	insertMatchedStmt, err = database.Prepare(insertMatchedQuery)
@@ -336,18 +608,20 @@ func initPreparedStatements(database *sql.DB) error {
 		return fmt.Errorf("failed to prepare insert matched query: %v", err)
 	}
 
-	countBuyerQuery = "SELECT COUNT(*) FROM top_buyer"
+	// $1 is the project to match (0 = all projects) for the queries above and below
+	countBuyerQuery = "SELECT COUNT(*) FROM top_buyer WHERE ($1 = 0 OR " + projectIDOrDefault("project_id") + " = $1) AND " + notExpiredCondition
 	countBuyerStmt, err = database.Prepare(countBuyerQuery)
 	if err != nil {
 		return fmt.Errorf("failed to prepare count buyer query: %v", err)
 	}
 
-	countSellerQuery = "SELECT COUNT(*) FROM top_seller"
+	countSellerQuery = "SELECT COUNT(*) FROM top_seller WHERE ($1 = 0 OR " + projectIDOrDefault("project_id") + " = $1) AND " + notExpiredCondition
 	countSellerStmt, err = database.Prepare(countSellerQuery)
 	if err != nil {
 		return fmt.Errorf("failed to prepare count seller query: %v", err)
 	}
 
+	setPreparedStatementsReady(true)
 	log.Println("✅ Prepared statements initialized with optimizations")
 	return nil
 }
@@ -360,250 +634,794 @@ func isTransactionTypeCompatible(buyerType, sellerType int) bool {
 }
 
 func matchAllOrdersContinuous(database *sql.DB) error {
-	if err := initPreparedStatements(database); err != nil {
-		return err
+	_, err := runMatching(database, 0)
+	return err
+}
+
+// Safety cap on matching loop passes per run so a pathological book can't
+// monopolize the calling goroutine; whatever is left is picked up next run
+var matchingMaxIterations = getEnvInt("MATCHING_MAX_ITERATIONS", 100000)
+
+// Goroutines matching projects in parallel during a global run. 1 keeps the
+// projects sequential.
+var matchingWorkers = getEnvInt("MATCHING_WORKERS", 1)
+
+type MatchingRunResult struct {
+	Matches       int            `json:"match_count"`
+	Iterations    int            `json:"iterations"`
+	IterationsCap bool           `json:"iteration_cap_hit"`
+	TimedOut      bool           `json:"timed_out"` // stopped at MATCHING_RUN_TIMEOUT
+	Phases        MatchingPhases `json:"phases"`
+}
+
+// Where a run's time went, in milliseconds. Other covers the per-iteration
+// counts, fill planning and loop overhead, so the phases add up to the run.
+type MatchingPhases struct {
+	FetchSellersMs float64 `json:"fetch_sellers_ms"`
+	FetchBuyersMs  float64 `json:"fetch_buyers_ms"`
+	ExecutionMs    float64 `json:"execution_ms"` // match transactions, retries included
+	DispatchMs     float64 `json:"dispatch_ms"`  // metrics, ticker, user events and starting the async work
+	OtherMs        float64 `json:"other_ms"`
+}
+
+// Per-phase totals accumulated by matchOrders across a run
+type matchPhaseTimings struct {
+	fetchSellers, fetchBuyers, execution, dispatch time.Duration
+}
+
+func (t matchPhaseTimings) phases(total time.Duration) MatchingPhases {
+	ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000.0 }
+	other := total - t.fetchSellers - t.fetchBuyers - t.execution - t.dispatch
+	if other < 0 {
+		other = 0
+	}
+	return MatchingPhases{
+		FetchSellersMs: ms(t.fetchSellers),
+		FetchBuyersMs:  ms(t.fetchBuyers),
+		ExecutionMs:    ms(t.execution),
+		DispatchMs:     ms(t.dispatch),
+		OtherMs:        ms(other),
+	}
+}
+
+// Matches until nothing more can be matched (or the iteration cap is hit).
+// A non-zero projectID limits the loop to that project's top-table orders;
+// other projects' orders are not read or touched. Without one, each project
+// is matched on its own in turn, so a project full of orders that can't
+// cross never holds up the others.
+func runMatching(database *sql.DB, projectID int) (MatchingRunResult, error) {
+	var result MatchingRunResult
+	if err := ensurePreparedStatements(database); err != nil {
+		return result, err
 	}
 
-	matchCount := 0
 	totalStartTime := time.Now()
-	
+
+	// Bounds the reads; a match transaction that has started is left to finish
+	ctx, cancel := context.WithTimeout(context.Background(), matchingRunTimeout)
+	defer cancel()
+
 	// Update cache once at start of loop
 	checkAndUpdateCircuitBreakers(database)
 
+	var timings matchPhaseTimings
+	var idle bool
+	var err error
+	if projectID != 0 {
+		idle, err = matchProjectUntilDone(ctx, database, projectID, &result, &timings)
+	} else {
+		idle, err = matchEachProject(ctx, database, &result, &timings)
+	}
+	if err != nil {
+		return result, err
+	}
+
+	if idle {
+		// Trigger a final sync when idle to ensure tables are full for next run.
+		// Only empty slots are filled - a full re-rank would let orders in
+		// without the project's minimum top improvement.
+		go func() {
+			smartSyncTopOrders(database, "buyer")
+			smartSyncTopOrders(database, "seller")
+		}()
+	}
+
+	duration := time.Since(totalStartTime)
+	if result.Matches > 0 {
+		log.Printf("⚡ Batch complete: %d matches in %.3fms", result.Matches, float64(duration.Microseconds())/1000.0)
+	}
+
+	result.Phases = timings.phases(duration)
+	recordMatchingRun(database, projectID, result, duration)
+	return result, nil
+}
+
+// Runs rounds over every project with orders on both sides of the top
+// tables, matching each to exhaustion, until a round makes no match - the
+// async top-table refills after a match can bring in orders for a project
+// that was already visited. Idle means a top table ran empty.
+func matchEachProject(ctx context.Context, database *sql.DB, result *MatchingRunResult, timings *matchPhaseTimings) (bool, error) {
 	for {
+		roundStartMatches := result.Matches
+
+		projectIDs, err := projectsWithTopOrders(ctx, database)
+		if err != nil {
+			if ctx.Err() != nil {
+				result.TimedOut = true
+				return false, nil
+			}
+			return false, err
+		}
+
+		if matchingWorkers > 1 && len(projectIDs) > 1 {
+			if err := matchProjectsConcurrently(ctx, database, projectIDs, result, timings); err != nil {
+				return false, err
+			}
+			if result.IterationsCap || result.TimedOut {
+				return false, nil
+			}
+		} else {
+			for _, projectID := range projectIDs {
+				if _, err := matchProjectUntilDone(ctx, database, projectID, result, timings); err != nil {
+					return false, err
+				}
+				if result.IterationsCap || result.TimedOut {
+					return false, nil
+				}
+			}
+		}
+
+		if result.Matches == roundStartMatches {
+			break
+		}
+	}
+
+	var buyerCount, sellerCount int
+	countBuyerStmt.QueryRowContext(ctx, 0).Scan(&buyerCount)
+	countSellerStmt.QueryRowContext(ctx, 0).Scan(&sellerCount)
+	return buyerCount < 1 || sellerCount < 1, nil
+}
+
+// Matches the round's projects on up to matchingWorkers goroutines. Each
+// project is only ever on one worker, and the matcher never crosses project
+// ids, so workers don't contend for orders; the prepared statements and the
+// breaker cache are safe for concurrent use. Each project counts into its
+// own result starting from the run's iterations so far, merged in when it
+// finishes - the iteration cap is checked against that, so a run can go
+// over it by up to one project per worker. Phase timings are summed across
+// workers and can add up to more than the run's wall time.
+func matchProjectsConcurrently(ctx context.Context, database *sql.DB, projectIDs []int, result *MatchingRunResult, timings *matchPhaseTimings) error {
+	jobs := make(chan int)
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+
+	workers := matchingWorkers
+	if workers > len(projectIDs) {
+		workers = len(projectIDs)
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for projectID := range jobs {
+				mu.Lock()
+				stop := firstErr != nil || result.IterationsCap || result.TimedOut
+				projectResult := MatchingRunResult{Iterations: result.Iterations}
+				mu.Unlock()
+				if stop {
+					continue // drain the rest of the round
+				}
+
+				var projectTimings matchPhaseTimings
+				startIterations := projectResult.Iterations
+				_, err := matchProjectUntilDone(ctx, database, projectID, &projectResult, &projectTimings)
+
+				mu.Lock()
+				result.Matches += projectResult.Matches
+				result.Iterations += projectResult.Iterations - startIterations
+				result.IterationsCap = result.IterationsCap || projectResult.IterationsCap
+				result.TimedOut = result.TimedOut || projectResult.TimedOut
+				timings.fetchSellers += projectTimings.fetchSellers
+				timings.fetchBuyers += projectTimings.fetchBuyers
+				timings.execution += projectTimings.execution
+				timings.dispatch += projectTimings.dispatch
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, projectID := range projectIDs {
+		jobs <- projectID
+	}
+	close(jobs)
+	wg.Wait()
+
+	return firstErr
+}
+
+// Projects that could match right now: resting orders in both top tables
+func projectIDsWithTopOrdersQuery() string {
+	return `
+		SELECT ` + projectIDOrDefault("project_id") + ` FROM top_buyer
+		INTERSECT
+		SELECT ` + projectIDOrDefault("project_id") + ` FROM top_seller
+		ORDER BY 1
+	`
+}
+
+func projectsWithTopOrders(ctx context.Context, database *sql.DB) ([]int, error) {
+	rows, err := database.QueryContext(ctx, projectIDsWithTopOrdersQuery())
+	if err != nil {
+		return nil, fmt.Errorf("listing projects to match: %w", err)
+	}
+	defer rows.Close()
+
+	var projectIDs []int
+	for rows.Next() {
+		var projectID int
+		if err := rows.Scan(&projectID); err != nil {
+			return nil, fmt.Errorf("listing projects to match: %w", err)
+		}
+		projectIDs = append(projectIDs, projectID)
+	}
+	return projectIDs, rows.Err()
+}
+
+// One project's matching loop; counts and flags accumulate in result. Stops
+// when the project has nothing left to match, or at the iteration cap or
+// run timeout. Idle means one of the project's top-table sides is empty.
+func matchProjectUntilDone(ctx context.Context, database *sql.DB, projectID int, result *MatchingRunResult, timings *matchPhaseTimings) (bool, error) {
+	// Buyers that hit the fill cap sit out until every other buyer has had a turn
+	cappedBuyers := map[int]bool{}
+
+	for {
+		if matchingMaxIterations > 0 && result.Iterations >= matchingMaxIterations {
+			log.Printf("⚠️ Matching loop stopped after %d iterations (MATCHING_MAX_ITERATIONS) with %d matches - remaining orders wait for the next run",
+				result.Iterations, result.Matches)
+			result.IterationsCap = true
+			return false, nil
+		}
+		if ctx.Err() != nil {
+			log.Printf("⚠️ Matching loop stopped after %v (MATCHING_RUN_TIMEOUT) with %d matches - remaining orders wait for the next run",
+				matchingRunTimeout, result.Matches)
+			result.TimedOut = true
+			return false, nil
+		}
+		result.Iterations++
+
 		var buyerCount, sellerCount int
 		// Run counts in parallel? No, overhead of goroutines > query time for simple count
-		countBuyerStmt.QueryRow().Scan(&buyerCount)
-		countSellerStmt.QueryRow().Scan(&sellerCount)
+		err := countBuyerStmt.QueryRowContext(ctx, projectID).Scan(&buyerCount)
+		if err == nil {
+			err = countSellerStmt.QueryRowContext(ctx, projectID).Scan(&sellerCount)
+		}
+		if err != nil && ctx.Err() != nil {
+			continue // reported as a timeout at the top of the loop
+		}
+		if err != nil {
+			return false, fmt.Errorf("order count failed: %v", err)
+		}
 
 		if buyerCount < 1 || sellerCount < 1 {
-			// Trigger a final sync when idle to ensure tables are full for next run
-			go func() {
-				syncAllTopOrders(database)
-			}()
-			break
+			return true, nil
 		}
 
-		matchMade, err := matchOrders(database)
+		matchMade, err := matchOrders(ctx, database, projectID, cappedBuyers, timings)
+		if errors.Is(err, errMatchStale) {
+			continue // re-read the book and plan again
+		}
+		if err != nil && ctx.Err() != nil {
+			continue // reported as a timeout at the top of the loop
+		}
 		if err != nil {
-			return fmt.Errorf("match failed: %v", err)
+			return false, fmt.Errorf("match failed: %v", err)
 		}
 
 		if matchMade {
-			matchCount++
-			if !quietMode {
-				// log.Printf("✅ Match #%d completed", matchCount) 
-				// Logging slows down high frequency loops, enable only if debugging
-			}
+			result.Matches++
+		} else if len(cappedBuyers) > 0 {
+			// Nobody else can match - start a new round for the capped buyers
+			cappedBuyers = map[int]bool{}
 		} else {
 			// No match found despite having orders (incompatible types/prices)
 			// Break to prevent infinite loop of non-matching orders
-			break
+			return false, nil
 		}
 	}
-	
-	if matchCount > 0 {
-		duration := time.Since(totalStartTime)
-		log.Printf("⚡ Batch complete: %d matches in %.3fms", matchCount, float64(duration.Microseconds())/1000.0)
-	}
+}
 
-	return nil
+// One side of a potential match as the matcher sees it
+type OrderData struct {
+	ID                int
+	UserID            int
+	TransactionID     string
+	Price             float64
+	Quantity          Quantity
+	Date              string
+	TradeTime         time.Time
+	Time              string
+	TransactionType   int
+	ProjectID         int
+	CreatedAt         time.Time
+	MatchType         int    // Only used for Buyer
+	OrderKind         string // Only used for Buyer
+	MarketLeadProgram bool
+	MaxSlippagePct    *float64 // Only used for Buyer; nil = no floor
+	MinFillQty        Quantity // Only used for Buyer; 0 = any fill
 }
 
-func matchOrders(database *sql.DB) (bool, error) {
-	matchingStartTime := time.Now()
+// A fill the matcher has decided on but not yet written
+type plannedFill struct {
+	Seller         OrderData
+	MatchedQty     Quantity
+	BuyerPrice     float64
+	MatchedTxnType int
+	TakerSide      string
+	MakerFee       float64
+	TakerFee       float64
+	ExecutionPrice float64 // the maker's price
+	IsMultiMatch   bool
+}
+
+// Top 50 sellers in priority order
+func loadMatchSellers(ctx context.Context, projectID int) ([]OrderData, error) {
+	sellersRows, err := getAllSellersStmt.QueryContext(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("get sellers failed: %v", err)
+	}
+	defer sellersRows.Close()
+
+	var topSellers []OrderData
+	for sellersRows.Next() {
+		var seller OrderData
+		err := sellersRows.Scan(
+			&seller.ID, &seller.UserID, &seller.TransactionID, &seller.Price, &seller.Quantity,
+			&seller.Date, &seller.TradeTime, &seller.TransactionType, &seller.CreatedAt, &seller.ProjectID,
+			&seller.MarketLeadProgram,
+		)
+		if err != nil { continue }
+
+		seller.Time = seller.TradeTime.Format("15:04:05")
+		topSellers = append(topSellers, seller)
+	}
+	return topSellers, nil
+}
 
-	type OrderData struct {
-		ID              int
-		UserID          int
-		TransactionID   string
-		Price           float64
-		Quantity        int
-		Date            string
-		TradeTime       time.Time
-		Time            string
-		TransactionType int
-		ProjectID       int
-		CreatedAt       time.Time
-		MatchType       int // Only used for Buyer
-	}
-
-	// 1. Get Top 20 Buyers (Loop through them)
-	buyerRows, err := getBuyerStmt.Query()
+// Top 20 buyers in priority order
+func loadMatchBuyers(ctx context.Context, projectID int) ([]OrderData, error) {
+	buyerRows, err := getBuyerStmt.QueryContext(ctx, projectID)
 	if err != nil {
-		return false, fmt.Errorf("get buyers failed: %v", err)
+		return nil, fmt.Errorf("get buyers failed: %v", err)
 	}
 	defer buyerRows.Close()
 
+	var buyers []OrderData
 	for buyerRows.Next() {
 		var buyer OrderData
+		var maxSlippage sql.NullFloat64
+		var minFill NullQuantity
 		err := buyerRows.Scan(
 			&buyer.ID, &buyer.UserID, &buyer.TransactionID, &buyer.Price, &buyer.Quantity,
 			&buyer.Date, &buyer.TradeTime, &buyer.TransactionType, &buyer.CreatedAt,
-			&buyer.MatchType, &buyer.ProjectID,
+			&buyer.MatchType, &buyer.OrderKind, &buyer.ProjectID, &buyer.MarketLeadProgram, &maxSlippage,
+			&minFill,
 		)
 		if err != nil {
 			continue // Skip bad row
 		}
+		if maxSlippage.Valid {
+			buyer.MaxSlippagePct = &maxSlippage.Float64
+		}
+		buyer.MinFillQty = minFill.Quantity
 
 		buyer.Time = buyer.TradeTime.Format("15:04:05")
+		buyers = append(buyers, buyer)
+	}
+	return buyers, nil
+}
 
-		// Circuit Breaker Check
-		if isProjectHaltedCached(buyer.ProjectID) {
-			// log.Printf("🛑 Circuit Breaker: Project %d halted - Skipping buyer %d", buyer.ProjectID, buyer.ID)
+// Sellers the buyer may trade with, cheapest first. The book puts MLP sellers
+// ahead of price, so without the re-sort a match_type=1 or market buyer could
+// fill against a dearer MLP seller while a cheaper one rests (trading through
+// it). Within a price level the book's priority order is kept.
+func compatibleSellersFor(buyer OrderData, sellers []OrderData) []OrderData {
+	// A capped match_type=1 buyer won't sweep below its slippage floor
+	var slippageFloor float64
+	if buyer.OrderKind != "market" && buyer.MatchType == 1 && buyer.MaxSlippagePct != nil {
+		slippageFloor = buyer.Price * (1 - *buyer.MaxSlippagePct/100)
+	}
+
+	var compatibleSellers []OrderData
+	for _, seller := range sellers {
+		// STRICT Project ID Match
+		if buyer.ProjectID != seller.ProjectID {
 			continue
 		}
 
-		// 2. Get Top 50 Sellers (Fetch specifically for this iteration)
-		sellersRows, err := getAllSellersStmt.Query()
-		if err != nil {
-			log.Printf("Warning: Failed to fetch sellers for buyer %d: %v", buyer.ID, err)
+		if !isTransactionTypeCompatible(buyer.TransactionType, seller.TransactionType) {
 			continue
 		}
-		
-		var compatibleSellers []OrderData
-		for sellersRows.Next() {
-			var seller OrderData
-			err := sellersRows.Scan(
-				&seller.ID, &seller.UserID, &seller.TransactionID, &seller.Price, &seller.Quantity,
-				&seller.Date, &seller.TradeTime, &seller.TransactionType, &seller.CreatedAt, &seller.ProjectID,
-			)
-			if err != nil { continue }
-			
-			seller.Time = seller.TradeTime.Format("15:04:05")
-
-			// STRICT Project ID Match
-			if buyer.ProjectID != seller.ProjectID {
-				continue
-			}
 
-			if !isTransactionTypeCompatible(buyer.TransactionType, seller.TransactionType) {
-				continue
+		// Market buyers take any price; otherwise Exact vs Highest-to-Lowest Logic
+		if buyer.OrderKind == "market" {
+			compatibleSellers = append(compatibleSellers, seller)
+		} else if buyer.MatchType == 0 {
+			if comparePrices(buyer.Price, seller.Price) == 0 { compatibleSellers = append(compatibleSellers, seller) }
+		} else {
+			if comparePrices(buyer.Price, seller.Price) > 0 && comparePrices(seller.Price, slippageFloor) >= 0 {
+				compatibleSellers = append(compatibleSellers, seller)
 			}
+		}
+	}
 
-			// Exact vs Highest-to-Lowest Logic
-			if buyer.MatchType == 0 {
-				if buyer.Price == seller.Price { compatibleSellers = append(compatibleSellers, seller) }
-			} else {
-				if buyer.Price > seller.Price { compatibleSellers = append(compatibleSellers, seller) }
-			}
+	sort.SliceStable(compatibleSellers, func(i, j int) bool {
+		return comparePrices(compatibleSellers[i].Price, compatibleSellers[j].Price) < 0
+	})
+	return compatibleSellers
+}
+
+// Best-fit pick among the sellers at the head's price level (same price and
+// MLP flag, so it never overrides price or MLP priority): the seller whose
+// quantity is closest to qty. Ties keep book order.
+func bestFitSeller(sellers []OrderData, qty Quantity) int {
+	best, bestDiff := 0, Quantity(-1)
+	for i, seller := range sellers {
+		if comparePrices(seller.Price, sellers[0].Price) != 0 || seller.MarketLeadProgram != sellers[0].MarketLeadProgram {
+			break
+		}
+		diff := seller.Quantity - qty
+		if diff < 0 { diff = -diff }
+		if bestDiff < 0 || diff < bestDiff {
+			best, bestDiff = i, diff
 		}
-		sellersRows.Close() // Close immediately to free resources
+	}
+	return best
+}
 
-		if len(compatibleSellers) == 0 {
-			// This buyer has no matches, try the NEXT buyer in the loop (e.g. Project 5)
-			continue
+// Decides how the buyer fills against its compatible sellers. Pure - nothing is
+// written, so the same decisions drive both matchOrders and the preview.
+// With bestFit, sellers at the same price are taken by bestFitSeller instead of
+// book order. Returns the fills and the buyer quantity left afterwards.
+func planBuyerFills(buyer OrderData, compatibleSellers []OrderData, rates FeeRates, maxFills int, bestFit bool) ([]plannedFill, Quantity) {
+	var fills []plannedFill
+	remainingBuyerQty := buyer.Quantity
+	candidates := append([]OrderData(nil), compatibleSellers...)
+
+	for len(candidates) > 0 {
+		if remainingBuyerQty <= 0 { break }
+		// Fairness cap: the rest of the buyer stays resting for a later turn
+		if maxFills > 0 && len(fills) >= maxFills { break }
+
+		pick := 0
+		if bestFit {
+			pick = bestFitSeller(candidates, remainingBuyerQty)
+		}
+		seller := candidates[pick]
+		candidates = append(candidates[:pick], candidates[pick+1:]...)
+
+		// Fill as much of the remaining buyer quantity as this seller can cover.
+		// Whether the buyer is finished is decided from remainingBuyerQty,
+		// never from a single seller's fill.
+		matchedQty := remainingBuyerQty
+		if seller.Quantity < matchedQty {
+			matchedQty = seller.Quantity
 		}
 
-		// 3. Match Found! Execute Transaction
-		tx, err := database.Begin()
-		if err != nil { return false, err }
-		defer tx.Rollback()
-
-		remainingBuyerQty := buyer.Quantity
-		matchedSellers := 0
-		shouldDeleteBuyer := false
-		isMultiMatch := false
-
-		// Prepare data for async history updates
-		type MatchRecord struct {
-			BuyerID, SellerID, SellerUserID, MatchedQty int
-			SellerTxnID string
-			SellerPrice float64
-			MatchedID int
-		}
-		var matchRecords []MatchRecord
-
-		for _, seller := range compatibleSellers {
-			if remainingBuyerQty <= 0 { break }
-
-			var incomingTime, outgoingTime time.Time
-			if buyer.CreatedAt.Before(seller.CreatedAt) {
-				incomingTime = buyer.CreatedAt; outgoingTime = seller.CreatedAt
-			} else {
-				incomingTime = seller.CreatedAt; outgoingTime = buyer.CreatedAt
-			}
+		var matchedTxnType int
+		if buyer.TransactionType == 2 && seller.TransactionType != 2 {
+			matchedTxnType = seller.TransactionType
+		} else if seller.TransactionType == 2 && buyer.TransactionType != 2 {
+			matchedTxnType = buyer.TransactionType
+		} else {
+			matchedTxnType = buyer.TransactionType
+		}
 
-			var matchedQty int
-			var shouldDeleteSeller bool
+		// A market buyer has no price of its own - it pays the seller's price
+		buyerPrice := buyer.Price
+		if buyer.OrderKind == "market" {
+			buyerPrice = seller.Price
+		}
 
-			if seller.Quantity >= remainingBuyerQty {
-				matchedQty = remainingBuyerQty
-				shouldDeleteSeller = (seller.Quantity == remainingBuyerQty)
-				shouldDeleteBuyer = true
-			} else {
-				matchedQty = seller.Quantity
-				shouldDeleteSeller = true
-			}
+		// The later order is the aggressor (taker); the resting order is the maker
+		// and its price is the traded price the fees are charged on
+		takerSide := "buyer"
+		makerPrice := seller.Price
+		if buyer.CreatedAt.Before(seller.CreatedAt) {
+			takerSide = "seller"
+			makerPrice = buyerPrice
+		}
 
-			if matchedSellers > 0 { isMultiMatch = true }
-			timeTaken := fmt.Sprintf("%.3f ms", float64(time.Since(matchingStartTime).Microseconds())/1000.0)
+		fills = append(fills, plannedFill{
+			Seller:         seller,
+			MatchedQty:     matchedQty,
+			BuyerPrice:     buyerPrice,
+			MatchedTxnType: matchedTxnType,
+			TakerSide:      takerSide,
+			MakerFee:       calculateFee(matchedQty, makerPrice, rates.MakerFeeBps),
+			TakerFee:       calculateFee(matchedQty, makerPrice, rates.TakerFeeBps),
+			ExecutionPrice: makerPrice,
+			IsMultiMatch:   len(fills) > 0,
+		})
+		remainingBuyerQty -= matchedQty
+	}
 
-			var matchedTxnType int
-			if buyer.TransactionType == 2 && seller.TransactionType != 2 {
-				matchedTxnType = seller.TransactionType
-			} else if seller.TransactionType == 2 && buyer.TransactionType != 2 {
-				matchedTxnType = buyer.TransactionType
-			} else {
-				matchedTxnType = buyer.TransactionType
-			}
+	return fills, remainingBuyerQty
+}
 
-			// Insert Match
-			insertTxStmt := tx.Stmt(insertMatchedStmt)
-			var matchedID int
-			err = insertTxStmt.QueryRow(
-				seller.Price, buyer.Price, seller.Quantity, buyer.Quantity, matchedQty,
-				seller.Time, buyer.Time, seller.Date, buyer.Date,
-				incomingTime, outgoingTime, timeTaken, "Closed",
-				matchedTxnType, buyer.ID, seller.ID, buyer.UserID, seller.UserID,
-				buyer.TransactionID, seller.TransactionID,
-				buyer.ProjectID, isMultiMatch,
-			).Scan(&matchedID)
-			if err != nil { return false, fmt.Errorf("insert matched failed: %v", err) }
-
-			// Store for async processing
-			matchRecords = append(matchRecords, MatchRecord{
-				BuyerID: buyer.ID, SellerID: seller.ID, SellerUserID: seller.UserID,
-				MatchedQty: matchedQty, SellerTxnID: seller.TransactionID, 
-				SellerPrice: seller.Price, MatchedID: matchedID,
-			})
+// A min_fill_quantity buyer only trades when its compatible sellers hold that
+// much between them, or everything it has left once that is less. Otherwise
+// it keeps resting untouched until the book can give it enough. All of the
+// liquidity counts, not just what the fill cap lets one pass take - the
+// minimum is cleared by the first fill, so the rest follows in later passes.
+func meetsMinFill(buyer OrderData, compatibleSellers []OrderData) bool {
+	if buyer.MinFillQty <= 0 {
+		return true
+	}
+	required := buyer.MinFillQty
+	if buyer.Quantity < required {
+		required = buyer.Quantity
+	}
+	var available Quantity
+	for _, seller := range compatibleSellers {
+		available += seller.Quantity
+		if available >= required {
+			return true
+		}
+	}
+	return false
+}
 
-			// Update Top Seller Table
-			if shouldDeleteSeller {
-				_, err = tx.Exec("DELETE FROM top_seller WHERE order_id = $1", seller.ID)
-			} else {
-				remaining := seller.Quantity - matchedQty
-				_, err = tx.Exec("UPDATE top_seller SET quantity = $1 WHERE order_id = $2", remaining, seller.ID)
-				go func(rid, qty int) {
-					database.Exec("UPDATE seller SET quantity = $1 WHERE id = $2", qty, rid)
-				}(seller.ID, remaining)
-			}
-			if err != nil { return false, fmt.Errorf("seller update failed: %v", err) }
+// A fill as it was written, for the notifications sent after the commit
+type matchRecord struct {
+	BuyerID, SellerID, SellerUserID, MatchedTxnType int
+	SellerQty, MatchedQty, BuyerRemaining, SellerRemaining Quantity
+	SellerTxnID string
+	SellerPrice, BuyerPrice float64
+	MatchedID int
+}
 
-			remainingBuyerQty -= matchedQty
-			matchedSellers++
+// The buyer or a planned seller changed (cancelled, reduced, expired, filled
+// elsewhere) between planning and the match transaction; plan again
+var errMatchStale = errors.New("order book changed since the match was planned")
+
+// Locks the buyer's and the planned sellers' top-table rows and checks they
+// still hold the quantities the fills were planned from. The writes below set
+// absolute quantities, so without this a retried transaction could write back
+// quantity a concurrent cancel or reduce had already removed.
+func lockPlannedOrdersTx(tx *sql.Tx, buyer OrderData, fills []plannedFill) error {
+	var buyerQty Quantity
+	err := tx.QueryRow("SELECT quantity FROM top_buyer WHERE order_id = $1 FOR UPDATE", buyer.ID).Scan(&buyerQty)
+	if err == sql.ErrNoRows || (err == nil && buyerQty != buyer.Quantity) {
+		return errMatchStale
+	}
+	if err != nil {
+		return fmt.Errorf("lock buyer failed: %w", err)
+	}
+
+	sellerIDs := make([]int64, len(fills))
+	for i, fill := range fills {
+		sellerIDs[i] = int64(fill.Seller.ID)
+	}
+	// Locked in id order so two matchers can't deadlock on each other's sellers
+	rows, err := tx.Query(`
+		SELECT order_id, quantity FROM top_seller
+		WHERE order_id = ANY($1)
+		ORDER BY order_id
+		FOR UPDATE
+	`, pq.Array(sellerIDs))
+	if err != nil {
+		return fmt.Errorf("lock sellers failed: %w", err)
+	}
+	locked := map[int]Quantity{}
+	for rows.Next() {
+		var id int
+		var qty Quantity
+		if err := rows.Scan(&id, &qty); err != nil {
+			rows.Close()
+			return fmt.Errorf("lock sellers failed: %w", err)
 		}
+		locked[id] = qty
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("lock sellers failed: %w", err)
+	}
+
+	for _, fill := range fills {
+		if qty, ok := locked[fill.Seller.ID]; !ok || qty != fill.Seller.Quantity {
+			return errMatchStale
+		}
+	}
+	return nil
+}
+
+// Writes the buyer's planned fills inside tx: the matched orders, history
+// counters and the new book quantities. errMatchStale when the book moved
+// after planning.
+func executeBuyerFillsTx(tx *sql.Tx, buyer OrderData, fills []plannedFill, remainingBuyerQty Quantity, matchingStartTime time.Time) ([]matchRecord, error) {
+	if err := lockPlannedOrdersTx(tx, buyer, fills); err != nil {
+		return nil, err
+	}
 
-		// Update Top Buyer Table
-		if shouldDeleteBuyer {
-			_, err = tx.Exec("DELETE FROM top_buyer WHERE order_id = $1", buyer.ID)
+	var records []matchRecord
+	buyerRemaining := buyer.Quantity
+
+	for _, fill := range fills {
+		seller := fill.Seller
+
+		var incomingTime, outgoingTime time.Time
+		if buyer.CreatedAt.Before(seller.CreatedAt) {
+			incomingTime = buyer.CreatedAt; outgoingTime = seller.CreatedAt
 		} else {
-			_, err = tx.Exec("UPDATE top_buyer SET quantity = $1 WHERE order_id = $2", remainingBuyerQty, buyer.ID)
-			go func(bid, qty int) {
-				database.Exec("UPDATE buyer SET quantity = $1 WHERE id = $2", qty, bid)
-			}(buyer.ID, remainingBuyerQty)
+			incomingTime = seller.CreatedAt; outgoingTime = buyer.CreatedAt
+		}
+
+		timeTaken := fmt.Sprintf("%.3f ms", float64(time.Since(matchingStartTime).Microseconds())/1000.0)
+
+		// Insert Match
+		insertTxStmt := tx.Stmt(insertMatchedStmt)
+		var matchedID int
+		err := insertTxStmt.QueryRow(
+			seller.Price, fill.BuyerPrice, seller.Quantity, buyer.Quantity, fill.MatchedQty,
+			seller.Time, buyer.Time, seller.Date, buyer.Date,
+			incomingTime, outgoingTime, timeTaken, newMatchStatus(),
+			fill.MatchedTxnType, buyer.ID, seller.ID, buyer.UserID, seller.UserID,
+			buyer.TransactionID, seller.TransactionID,
+			buyer.ProjectID, fill.IsMultiMatch,
+			fill.MakerFee, fill.TakerFee, fill.TakerSide, fill.ExecutionPrice,
+			buyer.TransactionType == 2, seller.TransactionType == 2,
+		).Scan(&matchedID)
+		if err != nil { return nil, fmt.Errorf("insert matched failed: %w", err) }
+
+		if err := updateBuyerOrderHistoryTx(tx, buyer.ID, fill.MatchedQty); err != nil {
+			return nil, fmt.Errorf("buyer history update failed: %w", err)
+		}
+		if err := updateSellerOrderHistoryTx(tx, seller.ID, fill.MatchedQty); err != nil {
+			return nil, fmt.Errorf("seller history update failed: %w", err)
+		}
+
+		// Store for async processing
+		records = append(records, matchRecord{
+			BuyerID: buyer.ID, SellerID: seller.ID, SellerUserID: seller.UserID,
+			SellerQty: seller.Quantity, MatchedQty: fill.MatchedQty, SellerTxnID: seller.TransactionID, 
+			SellerPrice: seller.Price, BuyerPrice: fill.BuyerPrice, MatchedID: matchedID, MatchedTxnType: fill.MatchedTxnType,
+			BuyerRemaining: buyerRemaining - fill.MatchedQty, SellerRemaining: seller.Quantity - fill.MatchedQty,
+		})
+		buyerRemaining -= fill.MatchedQty
+
+		// Update Top Seller Table (and its main-table row, in the same tx)
+		remaining := seller.Quantity - fill.MatchedQty
+		if remaining <= 0 {
+			_, err = tx.Exec("DELETE FROM top_seller WHERE order_id = $1", seller.ID)
+		} else {
+			_, err = tx.Exec("UPDATE top_seller SET quantity = $1 WHERE order_id = $2", remaining, seller.ID)
+			if err == nil {
+				_, err = tx.Exec("UPDATE seller SET quantity = $1 WHERE id = $2", remaining, seller.ID)
+			}
+		}
+		if err != nil { return nil, fmt.Errorf("seller update failed: %w", err) }
+	}
+
+	// Update Top Buyer Table. A min_fill_quantity is met once the buyer has
+	// traded, so the rest fills like any other order.
+	var err error
+	if remainingBuyerQty <= 0 {
+		_, err = tx.Exec("DELETE FROM top_buyer WHERE order_id = $1", buyer.ID)
+	} else {
+		_, err = tx.Exec("UPDATE top_buyer SET quantity = $1, min_fill_quantity = NULL WHERE order_id = $2", remainingBuyerQty, buyer.ID)
+		if err == nil {
+			_, err = tx.Exec("UPDATE buyer SET quantity = $1, min_fill_quantity = NULL WHERE id = $2", remainingBuyerQty, buyer.ID)
 		}
-		if err != nil { return false, fmt.Errorf("buyer update failed: %v", err) }
+	}
+	if err != nil { return nil, fmt.Errorf("buyer update failed: %w", err) }
 
-		// Commit
-		if err = tx.Commit(); err != nil { return false, fmt.Errorf("commit failed: %v", err) }
+	return records, nil
+}
+
+// Phase durations are added to timings; time.Now is only read at phase edges.
+func matchOrders(ctx context.Context, database *sql.DB, projectID int, cappedBuyers map[int]bool, timings *matchPhaseTimings) (bool, error) {
+	matchingStartTime := time.Now()
+	defer func() { matchingDuration.Observe(time.Since(matchingStartTime).Seconds()) }()
+
+	// 1. Get Top 50 Sellers once per pass and filter them in memory for each buyer.
+	// A committed match mutates top_seller, but we return right after it, so the
+	// caller's next iteration re-reads fresh data.
+	phaseStart := time.Now()
+	topSellers, err := loadMatchSellers(ctx, projectID)
+	timings.fetchSellers += time.Since(phaseStart)
+	if err != nil {
+		return false, err
+	}
+
+	rates := currentFeeRates()
+	maxFills := currentMatchingConfig().MaxFillsPerMatch
+	bestFit := sellerSelectionBestFitEnabled()
+
+	if len(topSellers) == 0 {
+		return false, nil
+	}
+
+	// 2. Get Top 20 Buyers (Loop through them)
+	phaseStart = time.Now()
+	buyers, err := loadMatchBuyers(ctx, projectID)
+	timings.fetchBuyers += time.Since(phaseStart)
+	if err != nil {
+		return false, err
+	}
+
+	for _, buyer := range buyers {
+		if cappedBuyers[buyer.ID] {
+			continue
+		}
+
+		// Circuit Breaker Check (halted projects only trade MLP fills, if exempt)
+		compatibleSellers, halted := haltAllowedSellers(buyer, compatibleSellersFor(buyer, topSellers))
+		if len(compatibleSellers) == 0 {
+			// This buyer has no matches, try the NEXT buyer in the loop (e.g. Project 5)
+			continue
+		}
+
+		if !meetsMinFill(buyer, compatibleSellers) {
+			continue
+		}
+		fills, remainingBuyerQty := planBuyerFills(buyer, compatibleSellers, rates, maxFills, bestFit)
+
+		// 3. Match Found! Execute Transaction (retried on serialization/deadlock errors)
+		var matchRecords []matchRecord
+		phaseStart = time.Now()
+		err = withRetry(database, func(tx *sql.Tx) error {
+			// Reset per attempt - a retried transaction starts from scratch
+			var err error
+			matchRecords, err = executeBuyerFillsTx(tx, buyer, fills, remainingBuyerQty, matchingStartTime)
+			return err
+		})
+		dispatchStart := time.Now()
+		timings.execution += dispatchStart.Sub(phaseStart)
+		if err != nil { return false, err }
+
+		shouldDeleteBuyer := remainingBuyerQty <= 0
+		if !shouldDeleteBuyer && maxFills > 0 && len(matchRecords) >= maxFills {
+			cappedBuyers[buyer.ID] = true
+		}
+		notifyOrderBookChanged("buyer", "seller")
+
+		if halted {
+			log.Printf("⭐ MLP exemption: project %d is halted but buyer #%d traded %d fill(s) with MLP liquidity",
+				buyer.ProjectID, buyer.ID, len(matchRecords))
+		}
+
+		matchesExecutedTotal.Add(float64(len(matchRecords)))
+		tradeTime := time.Now()
+		for _, rec := range matchRecords {
+			matchedVolumeTotal.Add(rec.MatchedQty.Float64())
+			recordTickerTrade(buyer.ProjectID, (rec.BuyerPrice+rec.SellerPrice)/2, rec.MatchedQty, tradeTime)
+			publishUserMatch(UserMatchEvent{
+				MatchedOrderID: rec.MatchedID, ProjectID: buyer.ProjectID,
+				BuyerOrderID: rec.BuyerID, SellerOrderID: rec.SellerID,
+				BuyerPrice: rec.BuyerPrice, SellerPrice: rec.SellerPrice,
+				MatchedQty: rec.MatchedQty, TransactionType: rec.MatchedTxnType, MatchedAt: tradeTime,
+			}, buyer.UserID, rec.BuyerRemaining, rec.SellerUserID, rec.SellerRemaining)
+			publishAdminEvent(adminEventMatchExecuted, buyer.ProjectID, map[string]interface{}{
+				"matched_order_id": rec.MatchedID,
+				"buyer_order_id":   rec.BuyerID,
+				"seller_order_id":  rec.SellerID,
+				"matched_qty":      rec.MatchedQty,
+				"buyer_price":      rec.BuyerPrice,
+				"seller_price":     rec.SellerPrice,
+			})
+		}
 
 		// --- ASYNC TASKS ---
 		go func() {
 			for _, rec := range matchRecords {
-				updateBuyerOrderHistory(database, rec.BuyerID, rec.MatchedQty)
 				recordMatchAssignment(database, rec.BuyerID, rec.SellerID, rec.SellerUserID, 
-					rec.SellerTxnID, rec.MatchedQty+0, rec.MatchedQty, rec.SellerPrice, rec.MatchedID)
+					rec.SellerTxnID, rec.SellerQty, rec.MatchedQty, rec.SellerPrice, rec.MatchedID, rec.MatchedTxnType)
 			}
 			if shouldDeleteBuyer {
 				smartSyncTopOrders(database, "buyer")
@@ -611,6 +1429,8 @@ func matchOrders(database *sql.DB) (bool, error) {
 			smartSyncTopOrders(database, "seller")
 		}()
 
+		timings.dispatch += time.Since(dispatchStart)
+
 		// IMPORTANT: Return true immediately to restart main loop from top priority
 		return true, nil
 	}
@@ -619,63 +1439,176 @@ func matchOrders(database *sql.DB) (bool, error) {
 	return false, nil
 }
 
+// Serializes global runs, so the ticker never overlaps an event-triggered run
+var matchAllOrdersMutex sync.Mutex
+
 func matchAllOrders(database *sql.DB) error {
+	matchAllOrdersMutex.Lock()
+	defer matchAllOrdersMutex.Unlock()
 	return matchAllOrdersContinuous(database)
 }
 
+// Optional background matcher (MATCHING_TICK_INTERVAL) so matches still happen
+// if an event-driven trigger was missed due to a transient error
+func startMatchingTicker(database *sql.DB) {
+	interval := getEnvDuration("MATCHING_TICK_INTERVAL", 0)
+	if interval <= 0 {
+		return
+	}
+
+	log.Printf("⏱️ Matching ticker enabled - running every %s", interval)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			matchingEnabledMutex.RLock()
+			enabled := matchingEnabled
+			matchingEnabledMutex.RUnlock()
+
+			if !enabled {
+				continue
+			}
+
+			// A run already in progress covers this tick
+			if !matchAllOrdersMutex.TryLock() {
+				continue
+			}
+			// runMatching refreshes the circuit breakers and only visits
+			// projects with orders on both sides, so an empty book is one query
+			err := matchAllOrdersContinuous(database)
+			matchAllOrdersMutex.Unlock()
+			if err != nil {
+				log.Printf("❌ Matching ticker error: %v", err)
+			}
+		}
+	}()
+}
+
 func getMatchedOrdersByUser(database *sql.DB, userID int) ([]MatchedOrder, error) {
+	matches := []MatchedOrder{}
+	err := forEachMatchedOrderByUser(context.Background(), database, userID, time.Time{}, time.Time{}, func(m MatchedOrder) error {
+		matches = append(matches, m)
+		return nil
+	})
+	return matches, err
+}
+
+// Calls fn for each of the user's matched orders, archived ones included,
+// newest first, without holding them all in memory. Zero from/to leave that
+// end of the range open; to is exclusive.
+func forEachMatchedOrderByUser(ctx context.Context, database *sql.DB, userID int, from, to time.Time, fn func(MatchedOrder) error) error {
 	query := `
 		SELECT id, seller_price, buyer_price, seller_qty, buyer_qty, matched_qty,
 		       seller_time, buyer_time, seller_date, buyer_date,
 		       incoming_time, outgoing_time, time_taken, status, transaction_type,
 		       buyer_user_id, seller_user_id, buyer_transaction_id, seller_transaction_id,
-		       COALESCE(project_id, 1) as project_id, buyer_order_id, seller_order_id,
-		       COALESCE(is_multi_match, false) as is_multi_match
-		FROM matched_orders
-		WHERE buyer_user_id = $1 OR seller_user_id = $1
-		ORDER BY created_at DESC
+		       ` + projectIDOrDefault("project_id") + ` as project_id, buyer_order_id, seller_order_id,
+		       COALESCE(is_multi_match, false) as is_multi_match, maker_fee, taker_fee, taker_side,
+		       COALESCE(execution_price, seller_price) as execution_price, buyer_any, seller_any, created_at
+		FROM matched_orders_all
+		WHERE (buyer_user_id = $1 OR seller_user_id = $1)
+		AND ($2::timestamp IS NULL OR created_at >= $2)
+		AND ($3::timestamp IS NULL OR created_at < $3)
+		ORDER BY created_at DESC, id DESC
 	`
-	rows, err := database.Query(query, userID)
-	if err != nil { return nil, err }
+	rows, err := database.QueryContext(ctx, query, userID, optionalTime(from), optionalTime(to))
+	if err != nil { return err }
 	defer rows.Close()
 
-	matches := []MatchedOrder{}
 	for rows.Next() {
 		var m MatchedOrder
-		rows.Scan(&m.ID, &m.SellerPrice, &m.BuyerPrice, &m.SellerQty, &m.BuyerQty, &m.MatchedQty,
+		if err := rows.Scan(&m.ID, &m.SellerPrice, &m.BuyerPrice, &m.SellerQty, &m.BuyerQty, &m.MatchedQty,
 			&m.SellerTime, &m.BuyerTime, &m.SellerDate, &m.BuyerDate,
 			&m.IncomingTime, &m.OutgoingTime, &m.TimeTaken, &m.Status, &m.TransactionType,
 			&m.BuyerUserID, &m.SellerUserID, &m.BuyerTransactionID, &m.SellerTransactionID,
-			&m.ProjectID, &m.BuyerOrderID, &m.SellerOrderID, &m.IsMultiMatch)
-		matches = append(matches, m)
+			&m.ProjectID, &m.BuyerOrderID, &m.SellerOrderID, &m.IsMultiMatch,
+			&m.MakerFee, &m.TakerFee, &m.TakerSide, &m.ExecutionPrice, &m.BuyerAny, &m.SellerAny, &m.CreatedAt); err != nil {
+			return err
+		}
+		if err := fn(m); err != nil {
+			return err
+		}
 	}
-	return matches, nil
+	return rows.Err()
 }
 
-func getMatchedOrdersData(database *sql.DB) ([]MatchedOrder, error) {
+// Newest-first page of matched orders using a keyset cursor on (created_at, id).
+// The created_at <= bound keeps the scan on idx_matched_orders_created; id breaks ties.
+func getMatchedOrdersData(ctx context.Context, database *sql.DB, limit int, cursor *matchedOrdersCursor) ([]MatchedOrder, *matchedOrdersCursor, error) {
 	query := `
 		SELECT id, seller_price, buyer_price, seller_qty, buyer_qty, matched_qty,
 		       seller_time, buyer_time, seller_date, buyer_date,
 		       incoming_time, outgoing_time, time_taken, status, transaction_type,
 		       buyer_user_id, seller_user_id, buyer_transaction_id, seller_transaction_id,
-		       COALESCE(project_id, 1) as project_id, buyer_order_id, seller_order_id,
-		       COALESCE(is_multi_match, false) as is_multi_match
+		       ` + projectIDOrDefault("project_id") + ` as project_id, buyer_order_id, seller_order_id,
+		       COALESCE(is_multi_match, false) as is_multi_match, maker_fee, taker_fee, taker_side,
+		       COALESCE(execution_price, seller_price) as execution_price, buyer_any, seller_any, created_at
 		FROM matched_orders
-		ORDER BY created_at DESC
 	`
-	rows, err := database.Query(query)
-	if err != nil { return nil, err }
+	args := []interface{}{}
+	if cursor != nil {
+		query += `
+		WHERE created_at <= $1 AND (created_at < $1 OR id < $2)
+		`
+		args = append(args, cursor.CreatedAt, cursor.ID)
+	}
+	// Fetch one extra row to know whether another page exists
+	args = append(args, limit+1)
+	query += fmt.Sprintf(`
+		ORDER BY created_at DESC, id DESC
+		LIMIT $%d
+	`, len(args))
+
+	rows, err := database.QueryContext(ctx, query, args...)
+	if err != nil { return nil, nil, err }
 	defer rows.Close()
 
 	matches := []MatchedOrder{}
 	for rows.Next() {
 		var m MatchedOrder
-		rows.Scan(&m.ID, &m.SellerPrice, &m.BuyerPrice, &m.SellerQty, &m.BuyerQty, &m.MatchedQty,
+		if err := rows.Scan(&m.ID, &m.SellerPrice, &m.BuyerPrice, &m.SellerQty, &m.BuyerQty, &m.MatchedQty,
 			&m.SellerTime, &m.BuyerTime, &m.SellerDate, &m.BuyerDate,
 			&m.IncomingTime, &m.OutgoingTime, &m.TimeTaken, &m.Status, &m.TransactionType,
 			&m.BuyerUserID, &m.SellerUserID, &m.BuyerTransactionID, &m.SellerTransactionID,
-			&m.ProjectID, &m.BuyerOrderID, &m.SellerOrderID, &m.IsMultiMatch)
+			&m.ProjectID, &m.BuyerOrderID, &m.SellerOrderID, &m.IsMultiMatch,
+			&m.MakerFee, &m.TakerFee, &m.TakerSide, &m.ExecutionPrice, &m.BuyerAny, &m.SellerAny, &m.CreatedAt); err != nil {
+			return nil, nil, err
+		}
 		matches = append(matches, m)
 	}
-	return matches, nil
-}
\ No newline at end of file
+	if err := rows.Err(); err != nil { return nil, nil, err }
+
+	var next *matchedOrdersCursor
+	if len(matches) > limit {
+		matches = matches[:limit]
+		last := matches[len(matches)-1]
+		next = &matchedOrdersCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+	return matches, next, nil
+}
+
+type matchedOrdersCursor struct {
+	CreatedAt time.Time
+	ID        int
+}
+
+// Opaque to clients: base64 of "<created_at unix micros>_<id>"
+func (c *matchedOrdersCursor) encode() string {
+	raw := fmt.Sprintf("%d_%d", c.CreatedAt.UnixMicro(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeMatchedOrdersCursor(value string) (*matchedOrdersCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	var micros int64
+	var id int
+	if _, err := fmt.Sscanf(string(raw), "%d_%d", &micros, &id); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &matchedOrdersCursor{CreatedAt: time.UnixMicro(micros).UTC(), ID: id}, nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+)
+
+type MatchingConfig struct {
+	// Most sellers one buyer may fill against in a single matching transaction
+	// before other buyers get a turn; 0 = unlimited
+	MaxFillsPerMatch int `json:"max_fills_per_match"`
+	// Orders each top table must hold before an order-triggered run starts,
+	// to batch matching; 1 = match as soon as both sides have an order
+	MatchMinPerSide int `json:"match_min_per_side"`
+	// How a buyer picks among sellers at the same price: "priority" keeps the
+	// book order (quantity, then time); "best_fit" takes the seller whose
+	// quantity is closest to what the buyer still needs, to cut partial fills
+	SellerSelection string `json:"seller_selection"`
+}
+
+// Top tables hold at most 10 orders per role, so a higher minimum would never be met
+const maxMatchMinPerSide = 10
+
+const (
+	sellerSelectionPriority = "priority"
+	sellerSelectionBestFit  = "best_fit"
+)
+
+// Cached like the fee rates so the matcher never reads matching_config inside its loop
+var (
+	matchingConfig      MatchingConfig
+	matchingConfigMutex sync.RWMutex
+)
+
+func initMatchingConfigTable(database *sql.DB) {
+	query := `CREATE TABLE IF NOT EXISTS matching_config (
+		id INTEGER PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+		max_fills_per_match INTEGER NOT NULL DEFAULT 0 CHECK (max_fills_per_match >= 0),
+		match_min_per_side INTEGER NOT NULL DEFAULT 1 CHECK (match_min_per_side >= 1),
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`
+
+	_, err := database.Exec(query)
+	if err != nil {
+		log.Fatal("Error creating matching_config table:", err)
+	}
+
+	_, err = database.Exec(`ALTER TABLE matching_config ADD COLUMN IF NOT EXISTS match_min_per_side INTEGER NOT NULL DEFAULT 1 CHECK (match_min_per_side >= 1)`)
+	if err != nil {
+		log.Printf("Warning: Could not add match_min_per_side column: %v", err)
+	}
+
+	_, err = database.Exec(`ALTER TABLE matching_config ADD COLUMN IF NOT EXISTS seller_selection VARCHAR(10) NOT NULL DEFAULT 'priority' CHECK (seller_selection IN ('priority', 'best_fit'))`)
+	if err != nil {
+		log.Printf("Warning: Could not add seller_selection column: %v", err)
+	}
+
+	_, err = database.Exec(`INSERT INTO matching_config (id) VALUES (1) ON CONFLICT (id) DO NOTHING`)
+	if err != nil {
+		log.Printf("Warning: Could not seed matching_config: %v", err)
+	}
+
+	if err := loadMatchingConfig(database); err != nil {
+		log.Printf("Warning: Could not load matching config: %v", err)
+	}
+
+	log.Println("✅ Matching config table created successfully")
+}
+
+func loadMatchingConfig(database *sql.DB) error {
+	var cfg MatchingConfig
+	err := database.QueryRow(`
+		SELECT max_fills_per_match, match_min_per_side, seller_selection FROM matching_config WHERE id = 1
+	`).Scan(&cfg.MaxFillsPerMatch, &cfg.MatchMinPerSide, &cfg.SellerSelection)
+	if err != nil {
+		return err
+	}
+
+	matchingConfigMutex.Lock()
+	matchingConfig = cfg
+	matchingConfigMutex.Unlock()
+	return nil
+}
+
+func currentMatchingConfig() MatchingConfig {
+	matchingConfigMutex.RLock()
+	defer matchingConfigMutex.RUnlock()
+	return matchingConfig
+}
+
+// Anything but an explicit best_fit (including an unloaded config) keeps book priority
+func sellerSelectionBestFitEnabled() bool {
+	return currentMatchingConfig().SellerSelection == sellerSelectionBestFit
+}
+
+// Never below 1, also before the config has been loaded
+func matchMinPerSide() int {
+	if n := currentMatchingConfig().MatchMinPerSide; n > 1 {
+		return n
+	}
+	return 1
+}
+
+// Get matching config (admin)
+func getMatchingConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(currentMatchingConfig())
+}
+
+// Set matching config (admin). Fields omitted from the body keep their current value.
+func setMatchingConfig(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+
+	cfg := currentMatchingConfig()
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		writeBodyDecodeError(w, err)
+		return
+	}
+
+	if cfg.MaxFillsPerMatch < 0 {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_MATCHING_CONFIG", "max_fills_per_match must be 0 (unlimited) or greater")
+		return
+	}
+	if cfg.MatchMinPerSide < 1 || cfg.MatchMinPerSide > maxMatchMinPerSide {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_MATCHING_CONFIG", fmt.Sprintf("match_min_per_side must be between 1 and %d", maxMatchMinPerSide))
+		return
+	}
+	if cfg.SellerSelection == "" {
+		cfg.SellerSelection = sellerSelectionPriority
+	}
+	if cfg.SellerSelection != sellerSelectionPriority && cfg.SellerSelection != sellerSelectionBestFit {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_MATCHING_CONFIG", "seller_selection must be priority or best_fit")
+		return
+	}
+
+	_, err := db.Exec(`
+		UPDATE matching_config
+		SET max_fills_per_match = $1, match_min_per_side = $2, seller_selection = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = 1
+	`, cfg.MaxFillsPerMatch, cfg.MatchMinPerSide, cfg.SellerSelection)
+	if err != nil {
+		log.Println("Error updating matching config:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error updating matching config")
+		return
+	}
+
+	matchingConfigMutex.Lock()
+	matchingConfig = cfg
+	matchingConfigMutex.Unlock()
+
+	log.Printf("⚖️ Matching config updated by admin (User ID: %d): max fills per match %d, min orders per side %d, seller selection %s",
+		userID, cfg.MaxFillsPerMatch, cfg.MatchMinPerSide, cfg.SellerSelection)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Max fills per match set to %d, min orders per side set to %d, seller selection set to %s",
+			cfg.MaxFillsPerMatch, cfg.MatchMinPerSide, cfg.SellerSelection),
+		"config":  cfg,
+	})
+}
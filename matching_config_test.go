@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// Posts cfg to the admin matching config endpoint and restores the defaults
+// when the test ends (matching_config survives the per-test reset)
+func setTestMatchingConfig(t *testing.T, adminToken string, cfg map[string]interface{}) {
+	t.Helper()
+	rec := doTestRequest(t, http.MethodPost, "/api/v1/admin/matching-config", adminToken, cfg)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("set matching config: status %d (%s)", rec.Code, rec.Body.String())
+	}
+	t.Cleanup(func() {
+		db.Exec("UPDATE matching_config SET max_fills_per_match = 0, match_min_per_side = 1, seller_selection = 'priority' WHERE id = 1")
+		loadMatchingConfig(db)
+	})
+}
+
+func TestFairnessCapOfOneAlternatesBuyers(t *testing.T) {
+	openTestDB(t)
+	_, adminToken := createTestUser(t, "admin", true)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+	setTestMatchingConfig(t, adminToken, map[string]interface{}{"max_fills_per_match": 1})
+
+	for i := 0; i < 4; i++ {
+		placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 10, Quantity: wholeQuantity(1)})
+	}
+	first := placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 10, Quantity: wholeQuantity(2)})
+	second := placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 10, Quantity: wholeQuantity(2)})
+
+	if _, err := runMatching(db, defaultProjectID); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.Query("SELECT buyer_order_id FROM matched_orders ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var order []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		order = append(order, id)
+	}
+	want := []int{first.ID, second.ID, first.ID, second.ID}
+	if fmt.Sprint(order) != fmt.Sprint(want) {
+		t.Errorf("fills went to buyers %v, want %v - one fill per turn", order, want)
+	}
+}
+
+func TestMatchingWaitsForMinPerSide(t *testing.T) {
+	openTestDB(t)
+	_, adminToken := createTestUser(t, "admin", true)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+	setTestMatchingConfig(t, adminToken, map[string]interface{}{"match_min_per_side": 3})
+
+	for i := 0; i < 3; i++ {
+		placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 10, Quantity: wholeQuantity(1)})
+	}
+	for i := 0; i < 2; i++ {
+		placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 10, Quantity: wholeQuantity(1)})
+	}
+	if err := checkAndTriggerMatching(db); err != nil {
+		t.Fatal(err)
+	}
+	if n := testCount(t, "matched_orders"); n != 0 {
+		t.Fatalf("%d matches with only 2 sellers, want none until 3 per side", n)
+	}
+
+	placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 10, Quantity: wholeQuantity(1)})
+	if err := checkAndTriggerMatching(db); err != nil {
+		t.Fatal(err)
+	}
+	if n := testCount(t, "matched_orders"); n != 3 {
+		t.Errorf("%d matches with 3 per side, want 3", n)
+	}
+}
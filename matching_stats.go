@@ -0,0 +1,117 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+type MatchingEngineStats struct {
+	MatchingEnabled  bool    `json:"matching_enabled"`
+	MatchesToday     int     `json:"matches_today"`
+	RunsToday        int     `json:"runs_today"` // runs that matched something or were cut short
+	AvgRunDurationMs float64 `json:"avg_run_duration_ms"`
+	MaxRunDurationMs float64 `json:"max_run_duration_ms"`
+	MatchesPerMinute float64 `json:"matches_per_minute"` // over the last hour
+	TopBuyerDepth    int     `json:"top_buyer_depth"`
+	TopSellerDepth   int     `json:"top_seller_depth"`
+}
+
+// One row per runMatching call that matched something or was cut short (see
+// recordMatchingRun). project_id is NULL for runs over all projects.
+func initMatchingRunsTable(database *sql.DB) {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS matching_runs (
+			id SERIAL PRIMARY KEY,
+			project_id INTEGER,
+			match_count INTEGER NOT NULL,
+			iterations INTEGER NOT NULL,
+			duration_ms DECIMAL(12, 3) NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_matching_runs_created ON matching_runs (created_at)`,
+	}
+
+	for _, query := range queries {
+		if _, err := database.Exec(query); err != nil {
+			log.Printf("Warning: Could not create matching_runs table: %v", err)
+		}
+	}
+}
+
+// Failing to record a run never fails the run itself. Runs that found nothing
+// to match are skipped - the matcher fires on every order change and on a
+// timer, and idle runs would otherwise be most of the table and all of its
+// growth.
+func recordMatchingRun(database *sql.DB, projectID int, result MatchingRunResult, duration time.Duration) {
+	if result.Matches == 0 && !result.IterationsCap && !result.TimedOut {
+		return
+	}
+
+	var project interface{}
+	if projectID != 0 {
+		project = projectID
+	}
+
+	_, err := database.Exec(`
+		INSERT INTO matching_runs (project_id, match_count, iterations, duration_ms)
+		VALUES ($1, $2, $3, $4)
+	`, project, result.Matches, result.Iterations, float64(duration.Microseconds())/1000.0)
+	if err != nil {
+		log.Printf("⚠️ Warning: Could not record matching run: %v", err)
+	}
+}
+
+func getMatchingEngineStats(database *sql.DB) (*MatchingEngineStats, error) {
+	stats := &MatchingEngineStats{}
+
+	matchingEnabledMutex.RLock()
+	stats.MatchingEnabled = matchingEnabled
+	matchingEnabledMutex.RUnlock()
+
+	// "Today" and "last hour" are computed in SQL - created_at is a plain TIMESTAMP
+	var matchesLastHour int
+	err := database.QueryRow(`
+		SELECT COUNT(*) FILTER (WHERE created_at >= CURRENT_DATE),
+		       COUNT(*) FILTER (WHERE created_at >= LOCALTIMESTAMP - INTERVAL '1 hour')
+		FROM matched_orders
+		WHERE created_at >= LEAST(CURRENT_DATE, LOCALTIMESTAMP - INTERVAL '1 hour')
+	`).Scan(&stats.MatchesToday, &matchesLastHour)
+	if err != nil {
+		return nil, err
+	}
+	stats.MatchesPerMinute = float64(matchesLastHour) / 60
+
+	err = database.QueryRow(`
+		SELECT COUNT(*), COALESCE(AVG(duration_ms), 0), COALESCE(MAX(duration_ms), 0)
+		FROM matching_runs
+		WHERE created_at >= CURRENT_DATE
+	`).Scan(&stats.RunsToday, &stats.AvgRunDurationMs, &stats.MaxRunDurationMs)
+	if err != nil {
+		return nil, err
+	}
+
+	err = database.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM top_buyer), (SELECT COUNT(*) FROM top_seller)
+	`).Scan(&stats.TopBuyerDepth, &stats.TopSellerDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// GET /api/admin/matching-engine/stats (admin)
+func getMatchingEngineStatsHandler(w http.ResponseWriter, r *http.Request) {
+	stats, err := getMatchingEngineStats(dbRead)
+	if err != nil {
+		log.Println("Error fetching matching engine stats:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error fetching matching engine stats")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestRecordMatchingRunSkipsIdleRuns(t *testing.T) {
+	openTestDB(t)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+
+	if _, err := runMatching(db, defaultProjectID); err != nil {
+		t.Fatal(err)
+	}
+	placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 9, Quantity: wholeQuantity(1)})
+	placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 10, Quantity: wholeQuantity(1)})
+	if _, err := runMatching(db, defaultProjectID); err != nil {
+		t.Fatal(err)
+	}
+	if n := testCount(t, "matching_runs"); n != 0 {
+		t.Fatalf("matching_runs rows = %d after runs with nothing to match, want 0", n)
+	}
+
+	placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 10, Quantity: wholeQuantity(1)})
+	if _, err := runMatching(db, defaultProjectID); err != nil {
+		t.Fatal(err)
+	}
+	var matchCount int
+	if err := db.QueryRow("SELECT match_count FROM matching_runs").Scan(&matchCount); err != nil {
+		t.Fatalf("matching run not recorded: %v", err)
+	}
+	if matchCount != 1 {
+		t.Errorf("recorded match_count = %d, want 1", matchCount)
+	}
+}
@@ -0,0 +1,655 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Plans the first buyer's fills from the project's current book
+func planTestMatch(t *testing.T, projectID int) (OrderData, []plannedFill, Quantity) {
+	t.Helper()
+	ctx := context.Background()
+	buyers, err := loadMatchBuyers(ctx, projectID)
+	if err != nil || len(buyers) == 0 {
+		t.Fatalf("load buyers: %v (%d)", err, len(buyers))
+	}
+	sellers, err := loadMatchSellers(ctx, projectID)
+	if err != nil {
+		t.Fatalf("load sellers: %v", err)
+	}
+	fills, remaining := planBuyerFills(buyers[0], compatibleSellersFor(buyers[0], sellers), FeeRates{}, 0, false)
+	return buyers[0], fills, remaining
+}
+
+func TestExecuteBuyerFillsRejectsStalePlan(t *testing.T) {
+	openTestDB(t)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+
+	seller := placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 10, Quantity: wholeQuantity(5)})
+	buyer := placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 10, Quantity: wholeQuantity(5)})
+
+	planned, fills, remaining := planTestMatch(t, defaultProjectID)
+	if len(fills) != 1 || fills[0].MatchedQty != wholeQuantity(5) {
+		t.Fatalf("planned fills = %+v, want one fill of 5", fills)
+	}
+
+	// The seller is reduced after planning, before the match transaction runs
+	if _, err := db.Exec("UPDATE top_seller SET quantity = $1 WHERE order_id = $2", wholeQuantity(2), seller.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	err := withRetry(db, func(tx *sql.Tx) error {
+		_, err := executeBuyerFillsTx(tx, planned, fills, remaining, time.Now())
+		return err
+	})
+	if !errors.Is(err, errMatchStale) {
+		t.Fatalf("execute stale plan: err = %v, want errMatchStale", err)
+	}
+	if qty, _ := testOrderQuantity(t, "seller", seller.ID); qty != wholeQuantity(2) {
+		t.Errorf("seller quantity = %s, want the reduced 2 left alone", qty)
+	}
+	if qty, _ := testOrderQuantity(t, "buyer", buyer.ID); qty != wholeQuantity(5) {
+		t.Errorf("buyer quantity = %s, want 5", qty)
+	}
+	if n := testCount(t, "matched_orders"); n != 0 {
+		t.Errorf("matched_orders rows = %d, want 0", n)
+	}
+
+	// Planned again from the current book, the match goes through
+	planned, fills, remaining = planTestMatch(t, defaultProjectID)
+	err = withRetry(db, func(tx *sql.Tx) error {
+		_, err := executeBuyerFillsTx(tx, planned, fills, remaining, time.Now())
+		return err
+	})
+	if err != nil {
+		t.Fatalf("execute fresh plan: %v", err)
+	}
+	if _, ok := testOrderQuantity(t, "seller", seller.ID); ok {
+		t.Error("seller still resting after being filled")
+	}
+	if qty, _ := testOrderQuantity(t, "buyer", buyer.ID); qty != wholeQuantity(3) {
+		t.Errorf("buyer quantity = %s, want 3", qty)
+	}
+}
+
+func TestConcurrentFillsUpdateOrderHistoryOnce(t *testing.T) {
+	openTestDB(t)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+
+	seller := placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 10, Quantity: wholeQuantity(10)})
+	placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 10, Quantity: wholeQuantity(5)})
+	placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 10, Quantity: wholeQuantity(5)})
+
+	// The history row is written with the order, before anything can match it
+	if n := testCount(t, "seller_order_history"); n != 1 {
+		t.Fatalf("seller_order_history rows = %d, want 1 right after placing", n)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := runMatching(db, defaultProjectID)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("matching: %v", err)
+		}
+	}
+
+	var matched, remaining Quantity
+	var matchCount int
+	var status string
+	err := db.QueryRow(`
+		SELECT total_matched_qty, remaining_qty, match_count, status FROM seller_order_history WHERE seller_order_id = $1
+	`, seller.ID).Scan(&matched, &remaining, &matchCount, &status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if matched != wholeQuantity(10) || remaining != 0 || matchCount != 2 || status != "Completed" {
+		t.Errorf("seller history = matched %s, remaining %s, %d matches, %s; want 10, 0, 2, Completed",
+			matched, remaining, matchCount, status)
+	}
+	if n := testCount(t, "matched_orders"); n != 2 {
+		t.Errorf("matched_orders rows = %d, want 2", n)
+	}
+}
+
+func TestMeetsMinFillCountsAllCompatibleLiquidity(t *testing.T) {
+	buyer := OrderData{Quantity: wholeQuantity(10), MinFillQty: wholeQuantity(6)}
+	sellers := func(units ...int) []OrderData {
+		var out []OrderData
+		for _, u := range units {
+			out = append(out, OrderData{Quantity: wholeQuantity(u)})
+		}
+		return out
+	}
+
+	tests := []struct {
+		name    string
+		buyer   OrderData
+		sellers []OrderData
+		want    bool
+	}{
+		{"no minimum", OrderData{Quantity: wholeQuantity(10)}, sellers(1), true},
+		{"too little liquidity", buyer, sellers(2, 3), false},
+		{"enough across sellers", buyer, sellers(2, 3, 1), true},
+		{"minimum capped at what is left", OrderData{Quantity: wholeQuantity(4), MinFillQty: wholeQuantity(6)}, sellers(4), true},
+	}
+	for _, tt := range tests {
+		if got := meetsMinFill(tt.buyer, tt.sellers); got != tt.want {
+			t.Errorf("%s: meetsMinFill = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMinFillBuyerWaitsThenFillsFreely(t *testing.T) {
+	openTestDB(t)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+
+	minFill := wholeQuantity(6)
+	buyer := placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 10, Quantity: wholeQuantity(10), MinFillQty: &minFill})
+	placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 10, Quantity: wholeQuantity(4)})
+	if _, err := runMatching(db, defaultProjectID); err != nil {
+		t.Fatal(err)
+	}
+	if n := testCount(t, "matched_orders"); n != 0 {
+		t.Fatalf("matched_orders rows = %d with 4 of the 6 minimum available, want 0", n)
+	}
+	if qty, _ := testOrderQuantity(t, "buyer", buyer.ID); qty != wholeQuantity(10) {
+		t.Fatalf("buyer quantity = %s, want 10 untouched", qty)
+	}
+
+	placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 10, Quantity: wholeQuantity(4)})
+	if _, err := runMatching(db, defaultProjectID); err != nil {
+		t.Fatal(err)
+	}
+	if qty, _ := testOrderQuantity(t, "buyer", buyer.ID); qty != wholeQuantity(2) {
+		t.Fatalf("buyer quantity = %s, want 2 after filling 8", qty)
+	}
+
+	// The minimum was met, so a single unit now fills
+	placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 10, Quantity: wholeQuantity(1)})
+	if _, err := runMatching(db, defaultProjectID); err != nil {
+		t.Fatal(err)
+	}
+	if qty, _ := testOrderQuantity(t, "buyer", buyer.ID); qty != wholeQuantity(1) {
+		t.Errorf("buyer quantity = %s, want 1 - the minimum should be cleared after the first fill", qty)
+	}
+}
+
+func TestPartialFillMatrix(t *testing.T) {
+	tests := []struct {
+		name          string
+		sellers       []int
+		wantFills     []int
+		wantBuyerLeft int // 0 = the buyer is gone
+	}{
+		{"first seller fills part, the next finishes", []int{60, 40, 30}, []int{60, 40}, 0},
+		{"one seller fills exactly", []int{100}, []int{100}, 0},
+		{"sellers run out", []int{30, 30}, []int{30, 30}, 40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			openTestDB(t)
+			buyerUser, _ := createTestUser(t, "buyer", false)
+			sellerUser, _ := createTestUser(t, "seller", false)
+
+			for _, qty := range tt.sellers {
+				placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 10, Quantity: wholeQuantity(qty)})
+			}
+			buyer := placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 10, Quantity: wholeQuantity(100)})
+			if _, err := runMatching(db, defaultProjectID); err != nil {
+				t.Fatal(err)
+			}
+
+			rows, err := db.Query("SELECT matched_qty FROM matched_orders WHERE buyer_order_id = $1 ORDER BY id", buyer.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+			var fills []int
+			for rows.Next() {
+				var qty Quantity
+				if err := rows.Scan(&qty); err != nil {
+					t.Fatal(err)
+				}
+				fills = append(fills, int(qty/wholeQuantity(1)))
+			}
+			if fmt.Sprint(fills) != fmt.Sprint(tt.wantFills) {
+				t.Errorf("fills = %v, want %v", fills, tt.wantFills)
+			}
+
+			var left Quantity
+			err = db.QueryRow("SELECT quantity FROM top_buyer WHERE order_id = $1", buyer.ID).Scan(&left)
+			if err != nil && err != sql.ErrNoRows {
+				t.Fatal(err)
+			}
+			if left != wholeQuantity(tt.wantBuyerLeft) {
+				t.Errorf("top_buyer quantity = %s, want %d", left, tt.wantBuyerLeft)
+			}
+			if n := testCount(t, "buyer"); n != 0 {
+				t.Errorf("buyer main table holds %d orders, want 0", n)
+			}
+		})
+	}
+}
+
+func TestBuyerFillsCheapestSellersFirst(t *testing.T) {
+	buyer := OrderData{ID: 1, Price: 6, Quantity: wholeQuantity(3), MatchType: 1, OrderKind: "limit"}
+	// Book order as loaded: an MLP seller at 5 ranks ahead of the cheaper ones
+	sellers := []OrderData{
+		{ID: 2, Price: 5, Quantity: wholeQuantity(1), MarketLeadProgram: true},
+		{ID: 3, Price: 3, Quantity: wholeQuantity(1)},
+		{ID: 4, Price: 4, Quantity: wholeQuantity(1)},
+	}
+
+	fills, remaining := planBuyerFills(buyer, compatibleSellersFor(buyer, sellers), FeeRates{}, 0, false)
+	var prices []float64
+	for _, fill := range fills {
+		prices = append(prices, fill.Seller.Price)
+	}
+	if fmt.Sprint(prices) != "[3 4 5]" {
+		t.Errorf("fill prices = %v, want [3 4 5]", prices)
+	}
+	if remaining != 0 {
+		t.Errorf("buyer left with %s, want 0", remaining)
+	}
+}
+
+func TestBestFitPicksClosestQuantityOverTimePriority(t *testing.T) {
+	buyer := OrderData{ID: 1, Price: 10, Quantity: wholeQuantity(40)}
+	// Book order: the oldest seller first
+	sellers := []OrderData{
+		{ID: 2, Price: 10, Quantity: wholeQuantity(100)},
+		{ID: 3, Price: 10, Quantity: wholeQuantity(45)},
+		{ID: 4, Price: 10, Quantity: wholeQuantity(40)},
+		{ID: 5, Price: 11, Quantity: wholeQuantity(40)},
+	}
+
+	timeFills, _ := planBuyerFills(buyer, sellers, FeeRates{}, 0, false)
+	fitFills, _ := planBuyerFills(buyer, sellers, FeeRates{}, 0, true)
+	if len(timeFills) != 1 || timeFills[0].Seller.ID != 2 {
+		t.Errorf("time priority fills = %+v, want the oldest seller #2", timeFills)
+	}
+	if len(fitFills) != 1 || fitFills[0].Seller.ID != 4 {
+		t.Errorf("best-fit fills = %+v, want the exact-size seller #4", fitFills)
+	}
+
+	// Never across a price level, however well the size fits
+	if i := bestFitSeller(sellers[:1:1], wholeQuantity(40)); i != 0 {
+		t.Errorf("bestFitSeller with one seller at the level = %d, want 0", i)
+	}
+	if i := bestFitSeller([]OrderData{sellers[0], sellers[3]}, wholeQuantity(40)); i != 0 {
+		t.Errorf("bestFitSeller crossed a price level, picked %d", i)
+	}
+}
+
+// Run with -race: the worker pool shares the prepared statements and caches
+func TestConcurrentProjectMatchingTotals(t *testing.T) {
+	openTestDB(t)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+
+	previous := matchingWorkers
+	matchingWorkers = 4
+	defer func() { matchingWorkers = previous }()
+
+	// Four orders a side fit the shared ten-row top tables
+	projects := []int{defaultProjectID}
+	for i := 1; i < 4; i++ {
+		projects = append(projects, createTestProject(t, fmt.Sprintf("Project %d", i)))
+	}
+	for i, projectID := range projects {
+		price := float64(10 + i)
+		placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: price, Quantity: wholeQuantity(3), ProjectID: intPtr(projectID)})
+		placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: price, Quantity: wholeQuantity(2), ProjectID: intPtr(projectID)})
+		placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: price, Quantity: wholeQuantity(4), ProjectID: intPtr(projectID)})
+		placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: price, Quantity: wholeQuantity(1), ProjectID: intPtr(projectID)})
+	}
+
+	if _, err := runMatching(db, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, projectID := range projects {
+		var fills int
+		var volume Quantity
+		var wrongPrice bool
+		err := db.QueryRow(`
+			SELECT COUNT(*), COALESCE(SUM(matched_qty), 0), COALESCE(BOOL_OR(seller_price <> $2), false)
+			FROM matched_orders WHERE project_id = $1
+		`, projectID, float64(10+i)).Scan(&fills, &volume, &wrongPrice)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if volume != wholeQuantity(5) || wrongPrice {
+			t.Errorf("project %d: %d fills for %s (another project's price: %v), want 5 matched at %d", projectID, fills, volume, wrongPrice, 10+i)
+		}
+	}
+	if n := testCount(t, "top_buyer") + testCount(t, "top_seller"); n != 0 {
+		t.Errorf("%d orders left resting, want every order filled", n)
+	}
+}
+
+func TestNonCrossingProjectDoesNotBlockAnother(t *testing.T) {
+	openTestDB(t)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+	quiet := createTestProject(t, "Quiet")
+
+	// The quiet project's book never crosses: every buyer bids below every seller
+	for i := 0; i < 4; i++ {
+		placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 20, Quantity: wholeQuantity(1), ProjectID: intPtr(quiet)})
+		placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 5, Quantity: wholeQuantity(1), ProjectID: intPtr(quiet)})
+	}
+	placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 10, Quantity: wholeQuantity(2)})
+	placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 10, Quantity: wholeQuantity(2)})
+
+	if _, err := runMatching(db, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var matched, quietMatched int
+	err := db.QueryRow(`
+		SELECT COUNT(*) FILTER (WHERE project_id = $1), COUNT(*) FILTER (WHERE project_id = $2) FROM matched_orders
+	`, defaultProjectID, quiet).Scan(&matched, &quietMatched)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if matched != 1 || quietMatched != 0 {
+		t.Errorf("matches = %d in the default project and %d in the quiet one, want 1 and 0", matched, quietMatched)
+	}
+	if n := testCount(t, "top_buyer"); n != 4 {
+		t.Errorf("top_buyer holds %d orders, want the quiet project's 4", n)
+	}
+}
+
+func TestPartialFillUpdatesMainTableCopyInSameTransaction(t *testing.T) {
+	openTestDB(t)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+
+	seller := placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 10, Quantity: wholeQuantity(10)})
+	// A seller left in both tables, as an interrupted promotion leaves it
+	_, err := db.Exec(`
+		INSERT INTO seller (id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, project_id, created_at)
+		SELECT order_id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, project_id, created_at
+		FROM top_seller WHERE order_id = $1
+	`, seller.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 10, Quantity: wholeQuantity(4)})
+
+	if _, err := runMatching(db, defaultProjectID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Read straight after matching returns - nothing is left to a goroutine
+	var topQty, mainQty Quantity
+	if err := db.QueryRow("SELECT quantity FROM top_seller WHERE order_id = $1", seller.ID).Scan(&topQty); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("SELECT quantity FROM seller WHERE id = $1", seller.ID).Scan(&mainQty); err != nil {
+		t.Fatal(err)
+	}
+	if topQty != wholeQuantity(6) || mainQty != wholeQuantity(6) {
+		t.Errorf("seller quantity = %s in top_seller and %s in seller, want 6 in both", topQty, mainQty)
+	}
+}
+
+func TestAnyTypeBuyerRecordsResolvedTypeInAssignment(t *testing.T) {
+	openTestDB(t)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+
+	placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 10, Quantity: wholeQuantity(3), TransactionType: 0})
+	placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 10, Quantity: wholeQuantity(3), TransactionType: 2})
+	if _, err := runMatching(db, defaultProjectID); err != nil {
+		t.Fatal(err)
+	}
+	waitForTestCount(t, "match_assignments", 1)
+
+	assignments, err := getMatchAssignmentsBySeller(db, sellerUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assignments) != 1 || assignments[0].MatchedTxnType == nil || *assignments[0].MatchedTxnType != 0 {
+		t.Fatalf("assignments = %+v, want one with matched_transaction_type 0", assignments)
+	}
+	var matchedType int
+	if err := db.QueryRow("SELECT transaction_type FROM matched_orders WHERE id = $1", assignments[0].MatchedOrderID).Scan(&matchedType); err != nil {
+		t.Fatal(err)
+	}
+	if matchedType != 0 {
+		t.Errorf("matched order transaction_type = %d, want 0", matchedType)
+	}
+}
+
+func TestAnyTypeBuyerFlagsOnMatchedOrder(t *testing.T) {
+	openTestDB(t)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+
+	placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 10, Quantity: wholeQuantity(3), TransactionType: 0})
+	placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 10, Quantity: wholeQuantity(3), TransactionType: 2})
+	if _, err := runMatching(db, defaultProjectID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Read back through matched_orders_all, as the user's trade history is
+	matches, err := getMatchedOrdersByUser(db, buyerUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("%d matched orders, want 1", len(matches))
+	}
+	m := matches[0]
+	if !m.BuyerAny || m.SellerAny || m.TransactionType != 0 {
+		t.Errorf("buyer_any=%v seller_any=%v transaction_type=%d, want true, false, 0", m.BuyerAny, m.SellerAny, m.TransactionType)
+	}
+}
+
+func TestEqualTimestampsMatchLowerIDFirst(t *testing.T) {
+	openTestDB(t)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+
+	first := placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 10, Quantity: wholeQuantity(1), TradeTime: "10:00:00"})
+	placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 10, Quantity: wholeQuantity(1), TradeTime: "10:00:00"})
+	if _, err := db.Exec("UPDATE top_seller SET created_at = date_trunc('second', LOCALTIMESTAMP)"); err != nil {
+		t.Fatal(err)
+	}
+	// Rewriting the lower id's row moves it after the other in the heap, so
+	// only the id tie-breaker can put it first
+	if _, err := db.Exec("UPDATE top_seller SET quantity = quantity WHERE order_id = $1", first.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 10, Quantity: wholeQuantity(1)})
+	if _, err := runMatching(db, defaultProjectID); err != nil {
+		t.Fatal(err)
+	}
+
+	var sellerID int
+	if err := db.QueryRow("SELECT seller_order_id FROM matched_orders").Scan(&sellerID); err != nil {
+		t.Fatal(err)
+	}
+	if sellerID != first.ID {
+		t.Errorf("matched seller #%d, want the lower id #%d", sellerID, first.ID)
+	}
+}
+
+func TestMatchingHonorsLowIterationCap(t *testing.T) {
+	openTestDB(t)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+
+	previous := matchingMaxIterations
+	matchingMaxIterations = 2
+	defer func() { matchingMaxIterations = previous }()
+
+	// One buyer is matched per iteration
+	placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 10, Quantity: wholeQuantity(5)})
+	for i := 0; i < 5; i++ {
+		placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 10, Quantity: wholeQuantity(1)})
+	}
+
+	result, err := runMatching(db, defaultProjectID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.IterationsCap || result.Iterations != 2 || result.Matches != 2 {
+		t.Errorf("result = %+v, want the cap hit after 2 iterations and 2 matches", result)
+	}
+	if n := testCount(t, "top_buyer"); n != 3 {
+		t.Errorf("%d buyers left, want 3 for the next run", n)
+	}
+
+	matchingMaxIterations = previous
+	if _, err := runMatching(db, defaultProjectID); err != nil {
+		t.Fatal(err)
+	}
+	if n := testCount(t, "top_buyer"); n != 0 {
+		t.Errorf("%d buyers left after an uncapped run, want 0", n)
+	}
+}
+
+func TestMatchingPhasesSumToTotal(t *testing.T) {
+	openTestDB(t)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+	for i := 0; i < 5; i++ {
+		placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 10, Quantity: wholeQuantity(1)})
+		placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 10, Quantity: wholeQuantity(1)})
+	}
+
+	rec := doTestRequest(t, http.MethodPost, "/api/v1/match", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("match: status %d (%s)", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Matches    int            `json:"match_count"`
+		Phases     MatchingPhases `json:"phases"`
+		DurationMs float64        `json:"duration_ms"`
+	}
+	decodeTestResponse(t, rec, &resp)
+	if resp.Matches != 5 {
+		t.Fatalf("%d matches, want 5", resp.Matches)
+	}
+
+	p := resp.Phases
+	if p.FetchSellersMs <= 0 || p.FetchBuyersMs <= 0 || p.ExecutionMs <= 0 {
+		t.Errorf("phases = %+v, want time in fetching and execution", p)
+	}
+	// The handler's total also covers recording the run, so it is slightly larger
+	sum := p.FetchSellersMs + p.FetchBuyersMs + p.ExecutionMs + p.DispatchMs + p.OtherMs
+	if sum > resp.DurationMs || sum < resp.DurationMs*0.8-5 {
+		t.Errorf("phases sum to %.3fms, total is %.3fms", sum, resp.DurationMs)
+	}
+}
+
+func TestMatchingPhasesOtherIsTheRemainder(t *testing.T) {
+	timings := matchPhaseTimings{
+		fetchSellers: 2 * time.Millisecond,
+		fetchBuyers:  3 * time.Millisecond,
+		execution:    10 * time.Millisecond,
+		dispatch:     1 * time.Millisecond,
+	}
+	p := timings.phases(20 * time.Millisecond)
+	if p.OtherMs != 4 {
+		t.Errorf("other = %vms, want the 4ms not covered by a phase", p.OtherMs)
+	}
+	if sum := p.FetchSellersMs + p.FetchBuyersMs + p.ExecutionMs + p.DispatchMs + p.OtherMs; sum != 20 {
+		t.Errorf("phases sum to %vms, want 20", sum)
+	}
+	if p := timings.phases(10 * time.Millisecond); p.OtherMs != 0 {
+		t.Errorf("other = %vms when the phases exceed the total, want 0", p.OtherMs)
+	}
+}
+
+// A non-crossing 20 x 50 book, so every pass reads the whole book and
+// trades nothing. Each pass should cost one read per side regardless of how
+// many buyers it walks.
+func BenchmarkMatchOrdersQueriesPerPass(b *testing.B) {
+	countingDB, counts := openCountingTestDB(b)
+	for _, q := range []string{
+		`INSERT INTO top_buyer (order_id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, project_id)
+		 SELECT 100000 + g, 1, 'b' || g, 5, 1, CURRENT_DATE, CURRENT_TIME, 1, $1 FROM generate_series(1, 20) g`,
+		`INSERT INTO top_seller (order_id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, project_id)
+		 SELECT 200000 + g, 2, 's' || g, 10 + g, 1, CURRENT_DATE, CURRENT_TIME, 1, $1 FROM generate_series(1, 50) g`,
+	} {
+		if _, err := db.Exec(q, defaultProjectID); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := ensurePreparedStatements(countingDB); err != nil {
+		b.Fatal(err)
+	}
+	ctx := context.Background()
+	atomic.StoreInt64(&counts.executions, 0)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		matched, err := matchOrders(ctx, countingDB, defaultProjectID, map[int]bool{}, &matchPhaseTimings{})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if matched {
+			b.Fatal("a non-crossing book matched")
+		}
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(atomic.LoadInt64(&counts.executions))/float64(b.N), "queries/op")
+}
+
+// Repeated runs on an empty book reuse the matcher statements. The
+// "reprepare" case forgets the pool before every run, as each run used to,
+// so the difference in prepares/op is the per-run prepare overhead.
+func BenchmarkRepeatedMatchingPrepares(b *testing.B) {
+	countingDB, counts := openCountingTestDB(b)
+	for _, reprepare := range []bool{false, true} {
+		name := "reuse"
+		if reprepare {
+			name = "reprepare"
+		}
+		b.Run(name, func(b *testing.B) {
+			if err := ensurePreparedStatements(countingDB); err != nil {
+				b.Fatal(err)
+			}
+			atomic.StoreInt64(&counts.prepares, 0)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if reprepare {
+					preparedStmtsMutex.Lock()
+					preparedStmtsDB = nil
+					preparedStmtsMutex.Unlock()
+				}
+				if _, err := runMatching(countingDB, defaultProjectID); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+			b.ReportMetric(float64(atomic.LoadInt64(&counts.prepares))/float64(b.N), "prepares/op")
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Scrapes /metrics and returns the value of the series line starting with
+// series, or 0 when it hasn't been exported yet
+func scrapeTestMetric(t *testing.T, series string) float64 {
+	t.Helper()
+	rec := doTestRequest(t, http.MethodGet, "/metrics", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("/metrics status %d", rec.Code)
+	}
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, series+" ") {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimPrefix(line, series+" "), 64)
+		if err != nil {
+			t.Fatalf("parse %q: %v", line, err)
+		}
+		return v
+	}
+	return 0
+}
+
+func TestMetricsCountCreatedOrders(t *testing.T) {
+	openTestDB(t)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+
+	const series = `trading_orders_created_total{order_kind="limit",role="buyer"}`
+	before := scrapeTestMetric(t, series)
+
+	rec := postTestOrder(t, map[string]interface{}{"user_id": buyerUser, "role": "buyer", "price": 10, "quantity": 1})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create order: status %d (%s)", rec.Code, rec.Body.String())
+	}
+
+	if after := scrapeTestMetric(t, series); after != before+1 {
+		t.Errorf("%s = %v after creating an order, want %v", series, after, before+1)
+	}
+}
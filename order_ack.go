@@ -0,0 +1,74 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Where a new order landed once createOrder's matching check has run
+type OrderBookPosition struct {
+	// top_table, book, or filled when nothing of it is left resting (an
+	// unfilled market order's remainder is cancelled, so it shows as filled)
+	Location string `json:"location"`
+	// 1 + same-side orders in the project ranked ahead of it (MLP first, then
+	// better price); 0 once filled
+	Rank int `json:"rank"`
+}
+
+// The order acknowledgment: the order itself plus its book position
+type OrderAck struct {
+	Order
+	BookPosition *OrderBookPosition `json:"book_position,omitempty"`
+}
+
+// Looks up the order's place in its project's book with a single query.
+// MLP orders rank ahead of the rest, as in the top table; orders at the same
+// price share a rank - time priority isn't counted.
+func getOrderBookPosition(database *sql.DB, order Order) (*OrderBookPosition, error) {
+	tableName := getTableName(order.Role)
+	topTableName := getTopTableName(order.Role)
+	if tableName == "" || topTableName == "" {
+		return nil, fmt.Errorf("invalid role")
+	}
+
+	betterPrice := "price > $3"
+	if order.Role == "seller" {
+		betterPrice = "price < $3"
+	}
+	mlp := "COALESCE(market_lead_program, false)"
+	better := fmt.Sprintf("(%[1]s > $4 OR (%[1]s = $4 AND %[2]s))", mlp, betterPrice)
+
+	projectID := defaultProjectID
+	if order.ProjectID != nil {
+		projectID = *order.ProjectID
+	}
+
+	var inTop, inBook bool
+	var betterCount int
+	// A resting order is in one of the two tables; UNION drops an order caught
+	// mid-move by a top table sync
+	err := database.QueryRow(fmt.Sprintf(`
+		SELECT EXISTS (SELECT 1 FROM %[1]s WHERE order_id = $1),
+		       EXISTS (SELECT 1 FROM %[2]s WHERE id = $1),
+		       (SELECT COUNT(*) FROM (
+		           SELECT order_id FROM %[1]s WHERE %[3]s = $2 AND %[4]s
+		           UNION
+		           SELECT id FROM %[2]s WHERE %[3]s = $2 AND %[4]s
+		       ) b)
+	`, topTableName, tableName, projectIDOrDefault("project_id"), better),
+		order.ID, projectID, order.Price, order.MarketLeadProgram).Scan(&inTop, &inBook, &betterCount)
+	if err != nil {
+		return nil, err
+	}
+
+	position := &OrderBookPosition{Location: "filled"}
+	if inTop {
+		position.Location = "top_table"
+	} else if inBook {
+		position.Location = "book"
+	}
+	if inTop || inBook {
+		position.Rank = betterCount + 1
+	}
+	return position, nil
+}
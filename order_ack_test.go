@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestOrderBookPositionRanksMLPFirst(t *testing.T) {
+	openTestDB(t)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+
+	placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 9, Quantity: wholeQuantity(1)})
+	best := placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 10, Quantity: wholeQuantity(1)})
+	position, err := getOrderBookPosition(db, best)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if position.Rank != 1 || position.Location != "top_table" {
+		t.Errorf("best-priced order position = %+v, want rank 1 in the top table", position)
+	}
+
+	// A lower-priced MLP order still ranks ahead of every non-MLP order
+	mlp := placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 5, Quantity: wholeQuantity(1), MarketLeadProgram: true})
+	if position, err = getOrderBookPosition(db, mlp); err != nil || position.Rank != 1 {
+		t.Errorf("MLP order position = %+v (%v), want rank 1", position, err)
+	}
+	if position, err = getOrderBookPosition(db, best); err != nil || position.Rank != 2 {
+		t.Errorf("best non-MLP order position = %+v (%v), want rank 2 behind the MLP order", position, err)
+	}
+}
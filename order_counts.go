@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// One project's row in the dashboard counts. Open orders include the top table.
+type ProjectOrderCounts struct {
+	ProjectID   int  `json:"project_id"`
+	OpenBuyers  int  `json:"open_buyers"`
+	OpenSellers int  `json:"open_sellers"`
+	TradesToday int  `json:"trades_today"` // busted trades excluded
+	IsHalted    bool `json:"is_halted"`
+}
+
+type OrderCountsSummary struct {
+	Projects    []ProjectOrderCounts `json:"projects"`
+	OpenBuyers  int                  `json:"open_buyers"`
+	OpenSellers int                  `json:"open_sellers"`
+	TradesToday int                  `json:"trades_today"`
+}
+
+// Counts for every project (or just projectID when non-zero) in one query.
+// Each table is counted once and grouped by project rather than per project.
+func getOrderCounts(ctx context.Context, database *sql.DB, projectID int) (*OrderCountsSummary, error) {
+	today := time.Now().Format("2006-01-02")
+	project := projectIDOrDefault("project_id")
+
+	rows, err := database.QueryContext(ctx, `
+		WITH buyers AS (
+			SELECT project_id, COUNT(*) AS n FROM (
+				SELECT `+project+` AS project_id FROM buyer
+				UNION ALL
+				SELECT `+project+` FROM top_buyer
+			) b GROUP BY project_id
+		), sellers AS (
+			SELECT project_id, COUNT(*) AS n FROM (
+				SELECT `+project+` AS project_id FROM seller
+				UNION ALL
+				SELECT `+project+` FROM top_seller
+			) s GROUP BY project_id
+		), trades AS (
+			SELECT `+project+` AS project_id, COUNT(*) AS n FROM matched_orders
+			WHERE created_at >= $2::date AND `+countedTradeCondition+`
+			GROUP BY 1
+		)
+		SELECT p.id, COALESCE(b.n, 0), COALESCE(s.n, 0), COALESCE(t.n, 0), COALESCE(cb.is_halted, false)
+		FROM projects p
+		LEFT JOIN buyers b ON b.project_id = p.id
+		LEFT JOIN sellers s ON s.project_id = p.id
+		LEFT JOIN trades t ON t.project_id = p.id
+		LEFT JOIN project_circuit_breakers cb ON cb.project_id = p.id
+		WHERE $1 = 0 OR p.id = $1
+		ORDER BY p.id
+	`, projectID, today)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summary := &OrderCountsSummary{Projects: []ProjectOrderCounts{}}
+	for rows.Next() {
+		var c ProjectOrderCounts
+		if err := rows.Scan(&c.ProjectID, &c.OpenBuyers, &c.OpenSellers, &c.TradesToday, &c.IsHalted); err != nil {
+			return nil, err
+		}
+		summary.Projects = append(summary.Projects, c)
+		summary.OpenBuyers += c.OpenBuyers
+		summary.OpenSellers += c.OpenSellers
+		summary.TradesToday += c.TradesToday
+	}
+	return summary, rows.Err()
+}
+
+// GET /api/orders/count?project_id= - open order, trade and halt counts per
+// project for dashboards, instead of pulling the order lists
+func getOrderCountsHandler(w http.ResponseWriter, r *http.Request) {
+	projectID := 0
+	if projectIDStr := r.URL.Query().Get("project_id"); projectIDStr != "" {
+		var err error
+		projectID, err = strconv.Atoi(projectIDStr)
+		if err != nil || projectID <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "INVALID_PROJECT_ID", "Invalid project ID")
+			return
+		}
+	}
+
+	ctx, cancel := requestQueryContext(r)
+	defer cancel()
+
+	summary, err := getOrderCounts(ctx, dbRead, projectID)
+	if err != nil {
+		writeQueryError(w, ctx, err, "Error fetching order counts")
+		return
+	}
+	if projectID != 0 && len(summary.Projects) == 0 {
+		writeJSONError(w, http.StatusNotFound, "PROJECT_NOT_FOUND", "Project not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestOrderCountsForSeededBook(t *testing.T) {
+	openTestDB(t)
+	buyerUser, token := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+	other := createTestProject(t, "Other")
+
+	// Two trades today in the default project, one of them busted. The other
+	// project trades once and is halted with a resting order on each side.
+	tradeTestOrders(t, defaultProjectID, buyerUser, sellerUser, 10, 1)
+	busted := tradeTestOrders(t, defaultProjectID, buyerUser, sellerUser, 10, 1)
+	if _, err := db.Exec("UPDATE matched_orders SET status = $1 WHERE id = $2", matchBusted, busted); err != nil {
+		t.Fatal(err)
+	}
+	tradeTestOrders(t, other, buyerUser, sellerUser, 10, 1)
+	placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 1, Quantity: wholeQuantity(1), ProjectID: intPtr(other)})
+	placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 50, Quantity: wholeQuantity(1), ProjectID: intPtr(other)})
+	haltTestProject(t, other, "manual", 0, 60)
+
+	// More buyers than the top table holds, so the book spans both tables
+	for i := 0; i < 12; i++ {
+		placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 1, Quantity: wholeQuantity(1)})
+	}
+	for i := 0; i < 3; i++ {
+		placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 50, Quantity: wholeQuantity(1)})
+	}
+	if testCount(t, "buyer") == 0 || testCount(t, "top_buyer") == 0 {
+		t.Fatalf("buyers are not split across the tables (%d main, %d top)", testCount(t, "buyer"), testCount(t, "top_buyer"))
+	}
+
+	counts := func(projectID int) OrderCountsSummary {
+		t.Helper()
+		rec := doTestRequest(t, http.MethodGet, fmt.Sprintf("/api/v1/orders/count?project_id=%d", projectID), token, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("project %d counts: status %d (%s)", projectID, rec.Code, rec.Body.String())
+		}
+		var summary OrderCountsSummary
+		decodeTestResponse(t, rec, &summary)
+		if len(summary.Projects) != 1 {
+			t.Fatalf("project %d counts: %d project rows, want 1", projectID, len(summary.Projects))
+		}
+		return summary
+	}
+
+	want := ProjectOrderCounts{ProjectID: defaultProjectID, OpenBuyers: 12, OpenSellers: 3, TradesToday: 1, IsHalted: false}
+	summary := counts(defaultProjectID)
+	if summary.Projects[0] != want {
+		t.Errorf("default project counts = %+v, want %+v", summary.Projects[0], want)
+	}
+	if summary.OpenBuyers != 12 || summary.OpenSellers != 3 || summary.TradesToday != 1 {
+		t.Errorf("default project totals = %d buyers, %d sellers, %d trades; want 12, 3, 1 without the other project",
+			summary.OpenBuyers, summary.OpenSellers, summary.TradesToday)
+	}
+
+	want = ProjectOrderCounts{ProjectID: other, OpenBuyers: 1, OpenSellers: 1, TradesToday: 1, IsHalted: true}
+	summary = counts(other)
+	if summary.Projects[0] != want {
+		t.Errorf("other project counts = %+v, want %+v", summary.Projects[0], want)
+	}
+	if summary.OpenBuyers != 1 || summary.OpenSellers != 1 || summary.TradesToday != 1 {
+		t.Errorf("other project totals = %d buyers, %d sellers, %d trades; want 1, 1, 1",
+			summary.OpenBuyers, summary.OpenSellers, summary.TradesToday)
+	}
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rows accepted per import request. The body is also subject to MAX_REQUEST_BODY_BYTES.
+const maxImportRows = 10000
+
+// Columns understood in a CSV import; the header row may list them in any order
+var importCSVColumns = []string{
+	"role", "user_id", "price", "quantity", "trade_date", "trade_time", "transaction_type",
+	"match_type", "market_lead_program", "order_kind", "project_id", "created_at",
+}
+
+type ImportRowError struct {
+	Line    int    `json:"line"` // CSV line number, or 1-based index into a JSON array
+	Message string `json:"message"`
+}
+
+type importRow struct {
+	line  int
+	order Order
+}
+
+// Accepted created_at layouts; timestamps without a zone are taken as-is
+var importTimeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999", "2006-01-02T15:04:05.999999"}
+
+func parseImportTime(value string) (time.Time, error) {
+	for _, layout := range importTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("created_at %q is not a valid timestamp (expected RFC 3339 or YYYY-MM-DD HH:MM:SS)", value)
+}
+
+// Same rules as createOrder for limit orders, except trade_date, which may be
+// any real date since the orders are historical. created_at is required.
+func validateImportedOrder(order *Order) error {
+	if order.OrderKind == "" {
+		order.OrderKind = "limit"
+	}
+	// Market orders are dropped when unfilled, so there's nothing to replay
+	if order.OrderKind != "limit" {
+		return fmt.Errorf("invalid order_kind %q (only limit orders can be imported)", order.OrderKind)
+	}
+	if getTableName(order.Role) == "" {
+		return fmt.Errorf("invalid role %q", order.Role)
+	}
+	if order.UserID <= 0 {
+		return fmt.Errorf("user_id is required")
+	}
+	if order.Quantity <= 0 {
+		return fmt.Errorf("quantity must be greater than 0")
+	}
+	if order.Price <= 0 {
+		return fmt.Errorf("price must be greater than 0")
+	}
+	if order.ProjectID == nil || *order.ProjectID <= 0 {
+		return fmt.Errorf("project_id is required")
+	}
+	if order.TransactionType < 0 || order.TransactionType > 2 {
+		return fmt.Errorf("invalid transaction_type %d", order.TransactionType)
+	}
+	if order.MatchType < 0 || order.MatchType > 1 {
+		return fmt.Errorf("invalid match_type %d", order.MatchType)
+	}
+	if err := validateMaxSlippage(order); err != nil {
+		return err
+	}
+	if _, err := time.Parse("2006-01-02", order.TradeDate); err != nil {
+		return fmt.Errorf("trade_date %q is not a valid date (expected YYYY-MM-DD)", order.TradeDate)
+	}
+	// An already-passed good_till_date is kept; the order loads as expired
+	if err := validateGoodTillDate(order, time.Time{}); err != nil {
+		return err
+	}
+	if err := validateMinFill(order); err != nil {
+		return err
+	}
+	if len(order.TradeTime) == 5 && order.TradeTime[2] == ':' {
+		order.TradeTime = order.TradeTime + ":00"
+	}
+	if err := validateTradeTime(order.TradeTime); err != nil {
+		return err
+	}
+	if order.CreatedAt.IsZero() {
+		return fmt.Errorf("created_at is required")
+	}
+	return nil
+}
+
+func parseImportJSON(body []byte) ([]importRow, []ImportRowError, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, nil, fmt.Errorf("expected a JSON array of orders: %v", err)
+	}
+
+	var rows []importRow
+	var rowErrors []ImportRowError
+	for i, item := range raw {
+		var order Order
+		if err := json.Unmarshal(item, &order); err != nil {
+			rowErrors = append(rowErrors, ImportRowError{Line: i + 1, Message: err.Error()})
+			continue
+		}
+		rows = append(rows, importRow{line: i + 1, order: order})
+	}
+	return rows, rowErrors, nil
+}
+
+func parseImportCSV(body []byte) ([]importRow, []ImportRowError, error) {
+	reader := csv.NewReader(bytes.NewReader(body))
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("missing CSV header row: %v", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"role", "user_id", "quantity", "trade_date", "trade_time", "project_id", "created_at"} {
+		if _, ok := columns[name]; !ok {
+			return nil, nil, fmt.Errorf("CSV header is missing the %q column", name)
+		}
+	}
+
+	var rows []importRow
+	var rowErrors []ImportRowError
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			rowErrors = append(rowErrors, ImportRowError{Line: line, Message: err.Error()})
+			continue
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		order, err := orderFromCSVFields(field)
+		if err != nil {
+			rowErrors = append(rowErrors, ImportRowError{Line: line, Message: err.Error()})
+			continue
+		}
+		rows = append(rows, importRow{line: line, order: order})
+	}
+	return rows, rowErrors, nil
+}
+
+func orderFromCSVFields(field func(string) string) (Order, error) {
+	var order Order
+	var err error
+
+	atoi := func(name string) int {
+		if err != nil || field(name) == "" {
+			return 0
+		}
+		var v int
+		v, err = strconv.Atoi(field(name))
+		if err != nil {
+			err = fmt.Errorf("%s %q is not a whole number", name, field(name))
+		}
+		return v
+	}
+
+	order.Role = field("role")
+	order.UserID = atoi("user_id")
+	if order.Quantity, err = parseQuantity(field("quantity")); err != nil {
+		return order, err
+	}
+	order.TransactionType = atoi("transaction_type")
+	order.MatchType = atoi("match_type")
+	projectID := atoi("project_id")
+	if err != nil {
+		return order, err
+	}
+	order.ProjectID = &projectID
+
+	if p := field("price"); p != "" {
+		if order.Price, err = strconv.ParseFloat(p, 64); err != nil {
+			return order, fmt.Errorf("price %q is not a number", p)
+		}
+	}
+	if mlp := field("market_lead_program"); mlp != "" {
+		if order.MarketLeadProgram, err = strconv.ParseBool(mlp); err != nil {
+			return order, fmt.Errorf("market_lead_program %q is not true or false", mlp)
+		}
+	}
+	order.OrderKind = field("order_kind")
+	order.TradeDate = field("trade_date")
+	order.TradeTime = field("trade_time")
+	if gtd := field("good_till_date"); gtd != "" {
+		order.GoodTillDate = &gtd
+	}
+	if minFill := field("min_fill_quantity"); minFill != "" {
+		qty, err := parseQuantity(minFill)
+		if err != nil {
+			return order, fmt.Errorf("min_fill_quantity: %v", err)
+		}
+		order.MinFillQty = &qty
+	}
+
+	if order.CreatedAt, err = parseImportTime(field("created_at")); err != nil {
+		return order, err
+	}
+	return order, nil
+}
+
+// CSV unless the Content-Type says JSON or the body starts like a JSON array
+func isJSONImport(contentType string, body []byte) bool {
+	contentType = strings.ToLower(contentType)
+	if strings.Contains(contentType, "json") {
+		return true
+	}
+	if strings.Contains(contentType, "csv") {
+		return false
+	}
+	return bytes.HasPrefix(bytes.TrimSpace(body), []byte("["))
+}
+
+// POST /api/admin/import-orders?match=true - load historical orders for
+// backtesting (admin). The body is a CSV file with a header row or a JSON
+// array of orders, each with a created_at that is stored as given, so time
+// priority follows the original sequence. Nothing is imported unless every
+// row is valid; errors are reported per line. Matching only runs afterwards
+// when match=true.
+func importOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeBodyDecodeError(w, err)
+		return
+	}
+
+	var rows []importRow
+	var rowErrors []ImportRowError
+	if isJSONImport(r.Header.Get("Content-Type"), body) {
+		rows, rowErrors, err = parseImportJSON(body)
+	} else {
+		rows, rowErrors, err = parseImportCSV(body)
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_IMPORT_FILE", err.Error())
+		return
+	}
+	if len(rows)+len(rowErrors) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_IMPORT_FILE", "No orders to import")
+		return
+	}
+	if len(rows)+len(rowErrors) > maxImportRows {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_IMPORT_FILE", fmt.Sprintf("At most %d orders per import", maxImportRows))
+		return
+	}
+
+	projectRules := map[int]*ProjectTradingRules{}
+	for i := range rows {
+		order := &rows[i].order
+		if err := validateImportedOrder(order); err != nil {
+			rowErrors = append(rowErrors, ImportRowError{Line: rows[i].line, Message: err.Error()})
+			continue
+		}
+		// Loaded for every project - the top-table placement below needs them
+		// too. A nil entry is a project that doesn't exist.
+		rules, ok := projectRules[*order.ProjectID]
+		if !ok {
+			var err error
+			rules, err = getProjectTradingRules(db, *order.ProjectID)
+			if err == sql.ErrNoRows {
+				rules = nil
+			} else if err != nil {
+				log.Println("Error fetching trading rules for import:", err)
+				writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error importing orders")
+				return
+			}
+			projectRules[*order.ProjectID] = rules
+		}
+		if rules == nil {
+			rowErrors = append(rowErrors, ImportRowError{Line: rows[i].line, Message: fmt.Sprintf("project %d does not exist", *order.ProjectID)})
+			continue
+		}
+		if err := validateQuantityUnits(order.Quantity, rules); err != nil {
+			rowErrors = append(rowErrors, ImportRowError{Line: rows[i].line, Message: err.Error()})
+		}
+	}
+
+	if len(rowErrors) > 0 {
+		sort.Slice(rowErrors, func(i, j int) bool { return rowErrors[i].Line < rowErrors[j].Line })
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":   "INVALID_IMPORT_ROWS",
+			"message": fmt.Sprintf("%d row(s) failed validation - nothing was imported", len(rowErrors)),
+			"errors":  rowErrors,
+		})
+		return
+	}
+
+	// Insert oldest first so order ids follow the historical sequence too
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].order.CreatedAt.Before(rows[j].order.CreatedAt) })
+
+	err = withRetry(db, func(tx *sql.Tx) error {
+		for i := range rows {
+			if err := insertOrderTx(tx, &rows[i].order, projectRules[*rows[i].order.ProjectID]); err != nil {
+				return fmt.Errorf("line %d: %w", rows[i].line, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println("Error importing orders:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error importing orders")
+		return
+	}
+
+	notifyOrderBookChanged("buyer", "seller")
+
+	log.Printf("📥 %d historical orders imported by admin (User ID: %d)", len(rows), userID)
+
+	response := map[string]interface{}{
+		"success":  true,
+		"message":  fmt.Sprintf("Imported %d orders", len(rows)),
+		"imported": len(rows),
+	}
+
+	if r.URL.Query().Get("match") == "true" {
+		result, err := runMatching(db, 0)
+		if err != nil {
+			log.Println("Error matching imported orders:", err)
+			writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR",
+				fmt.Sprintf("Imported %d orders, but matching failed", len(rows)))
+			return
+		}
+		response["matching"] = result
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
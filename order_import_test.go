@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A CSV line in testImportCSVHeader's layout, traded and created on
+// 2024-03-01 at clock in the default project
+func testImportRow(role string, userID int, price float64, clock string) string {
+	return fmt.Sprintf("%s,%d,%v,1,2024-03-01,%s,%d,2024-03-01 %s\n", role, userID, price, clock, defaultProjectID, clock)
+}
+
+const testImportCSVHeader = "role,user_id,price,quantity,trade_date,trade_time,project_id,created_at\n"
+
+// Posts csv to the import endpoint as a text/csv upload
+func postTestImportCSV(t *testing.T, token, csv string) *httptest.ResponseRecorder {
+	t.Helper()
+	testHandlerOnce.Do(func() { testHandler = newHandler() })
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/import-orders", strings.NewReader(csv))
+	req.Header.Set("Content-Type", "text/csv")
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	testHandler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestImportCSVKeepsHistoricalOrder(t *testing.T) {
+	openTestDB(t)
+	_, adminToken := createTestUser(t, "admin", true)
+	sellerID, _ := createTestUser(t, "seller", false)
+
+	// Rows are deliberately not in created_at order
+	csv := testImportCSVHeader +
+		testImportRow("seller", sellerID, 12, "10:02:00") +
+		testImportRow("seller", sellerID, 10, "10:00:00") +
+		testImportRow("seller", sellerID, 13, "10:03:00") +
+		testImportRow("seller", sellerID, 11, "10:01:00")
+	rec := postTestImportCSV(t, adminToken, csv)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("import: status %d (%s)", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Imported int `json:"imported"`
+	}
+	decodeTestResponse(t, rec, &resp)
+	if resp.Imported != 4 {
+		t.Errorf("imported = %d, want 4", resp.Imported)
+	}
+
+	rows, err := db.Query(`
+		SELECT id, price, created_at FROM seller
+		UNION ALL
+		SELECT order_id, price, created_at FROM top_seller
+		ORDER BY 1
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var prices []float64
+	var previous time.Time
+	for rows.Next() {
+		var id int
+		var price float64
+		var createdAt time.Time
+		if err := rows.Scan(&id, &price, &createdAt); err != nil {
+			t.Fatal(err)
+		}
+		if createdAt.Before(previous) {
+			t.Errorf("order #%d created %s, before the lower id's %s", id, createdAt, previous)
+		}
+		previous = createdAt
+		prices = append(prices, price)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each price was imported with its own minute, so ids in price order mean
+	// ids in historical order with the timestamps kept
+	want := []float64{10, 11, 12, 13}
+	if len(prices) != len(want) {
+		t.Fatalf("%d seller orders stored, want %d", len(prices), len(want))
+	}
+	for i := range want {
+		if prices[i] != want[i] {
+			t.Fatalf("prices by id = %v, want %v", prices, want)
+		}
+	}
+	if got := previous.Format("2006-01-02 15:04:05"); got != "2024-03-01 10:03:00" {
+		t.Errorf("latest created_at = %s, want the imported 2024-03-01 10:03:00", got)
+	}
+}
+
+func TestImportCSVReportsBadLinesAndImportsNothing(t *testing.T) {
+	openTestDB(t)
+	_, adminToken := createTestUser(t, "admin", true)
+	sellerID, _ := createTestUser(t, "seller", false)
+
+	csv := testImportCSVHeader +
+		testImportRow("seller", sellerID, 10, "10:00:00") +
+		testImportRow("seller", sellerID, -1, "10:01:00") +
+		testImportRow("trader", sellerID, 10, "10:02:00")
+	rec := postTestImportCSV(t, adminToken, csv)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("import: status %d (%s), want 400", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Errors []ImportRowError `json:"errors"`
+	}
+	decodeTestResponse(t, rec, &resp)
+	if len(resp.Errors) != 2 || resp.Errors[0].Line != 3 || resp.Errors[1].Line != 4 {
+		t.Errorf("row errors = %+v, want lines 3 and 4", resp.Errors)
+	}
+	if n := testCount(t, "seller") + testCount(t, "top_seller"); n != 0 {
+		t.Errorf("%d orders stored from a rejected import", n)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type OrderLookupMatch struct {
+	ID          int       `json:"id"`
+	MatchedQty  Quantity  `json:"matched_qty"`
+	BuyerPrice  float64   `json:"buyer_price"`
+	SellerPrice float64   `json:"seller_price"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type OrderLookup struct {
+	Order Order `json:"order"`
+	// top_buyer, buyer, top_seller or seller while the order rests;
+	// filled or cancelled once it has left the book
+	Location string             `json:"location"`
+	Matches  []OrderLookupMatch `json:"matches"`
+}
+
+// Finds an order by transaction_id wherever it currently is. Resting orders
+// come from the book tables; orders that have left the book are rebuilt from
+// cancelled_orders or the order history, so quantity is then what was
+// cancelled or originally placed. sql.ErrNoRows when nothing matches.
+func lookupOrderByTransactionID(database *sql.DB, transactionID string) (*OrderLookup, error) {
+	lookup := &OrderLookup{Matches: []OrderLookupMatch{}}
+	order := &lookup.Order
+	var projectID int
+	var tradeDate time.Time
+
+	// transaction_seq is shared by both roles, so at most one row matches
+	err := database.QueryRow(`
+		SELECT location, role, id, user_id, transaction_id, price, quantity, trade_date,
+		       TO_CHAR(trade_time, 'HH24:MI:SS'), transaction_type, match_type,
+		       market_lead_program, order_kind, project_id, created_at
+		FROM (
+			SELECT 'top_buyer' AS location, 'buyer' AS role, order_id AS id, user_id, transaction_id, price, quantity, trade_date, trade_time,
+			       transaction_type, match_type, market_lead_program, order_kind, `+projectIDOrDefault("project_id")+` AS project_id, created_at
+			FROM top_buyer WHERE transaction_id = $1
+			UNION ALL
+			SELECT 'buyer', 'buyer', id, user_id, transaction_id, price, quantity, trade_date, trade_time,
+			       transaction_type, match_type, market_lead_program, order_kind, `+projectIDOrDefault("project_id")+`, created_at
+			FROM buyer WHERE transaction_id = $1
+			UNION ALL
+			SELECT 'top_seller', 'seller', order_id, user_id, transaction_id, price, quantity, trade_date, trade_time,
+			       transaction_type, match_type, market_lead_program, order_kind, `+projectIDOrDefault("project_id")+`, created_at
+			FROM top_seller WHERE transaction_id = $1
+			UNION ALL
+			SELECT 'seller', 'seller', id, user_id, transaction_id, price, quantity, trade_date, trade_time,
+			       transaction_type, match_type, market_lead_program, order_kind, `+projectIDOrDefault("project_id")+`, created_at
+			FROM seller WHERE transaction_id = $1
+		) o
+		LIMIT 1
+	`, transactionID).Scan(&lookup.Location, &order.Role, &order.ID, &order.UserID, &order.TransactionID,
+		&order.Price, &order.Quantity, &tradeDate, &order.TradeTime, &order.TransactionType, &order.MatchType,
+		&order.MarketLeadProgram, &order.OrderKind, &projectID, &order.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		lookup.Location = "cancelled"
+		err = database.QueryRow(`
+			SELECT role, order_id, user_id, transaction_id, price, quantity, transaction_type, project_id,
+			       COALESCE(order_created_at, cancelled_at)
+			FROM cancelled_orders WHERE transaction_id = $1
+			ORDER BY cancelled_at DESC LIMIT 1
+		`, transactionID).Scan(&order.Role, &order.ID, &order.UserID, &order.TransactionID, &order.Price,
+			&order.Quantity, &order.TransactionType, &projectID, &order.CreatedAt)
+	}
+
+	if err == sql.ErrNoRows {
+		lookup.Location = "filled"
+		err = database.QueryRow(`
+			SELECT 'buyer', buyer_order_id, buyer_user_id, buyer_transaction_id, original_price, original_qty,
+			       buyer_trade_date, TO_CHAR(buyer_trade_time, 'HH24:MI:SS'), project_id, created_at
+			FROM buyer_order_history WHERE buyer_transaction_id = $1
+			UNION ALL
+			SELECT 'seller', seller_order_id, seller_user_id, seller_transaction_id, original_price, original_qty,
+			       seller_trade_date, TO_CHAR(seller_trade_time, 'HH24:MI:SS'), project_id, created_at
+			FROM seller_order_history WHERE seller_transaction_id = $1
+			LIMIT 1
+		`, transactionID).Scan(&order.Role, &order.ID, &order.UserID, &order.TransactionID, &order.Price,
+			&order.Quantity, &tradeDate, &order.TradeTime, &projectID, &order.CreatedAt)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	order.ProjectID = &projectID
+	if !tradeDate.IsZero() {
+		order.TradeDate = tradeDate.Format("2006-01-02")
+	}
+
+	rows, err := database.Query(`
+		SELECT id, matched_qty, buyer_price, seller_price, created_at
+		FROM matched_orders
+		WHERE buyer_transaction_id = $1 OR seller_transaction_id = $1
+		ORDER BY created_at ASC, id ASC
+	`, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m OrderLookupMatch
+		if err := rows.Scan(&m.ID, &m.MatchedQty, &m.BuyerPrice, &m.SellerPrice, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		lookup.Matches = append(lookup.Matches, m)
+	}
+	return lookup, rows.Err()
+}
+
+// GET /api/orders/by-transaction/{transaction_id} - owner or admin
+func getOrderByTransactionID(w http.ResponseWriter, r *http.Request) {
+	token, err := extractToken(r)
+	if err != nil {
+		writeJSONError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized: "+err.Error())
+		return
+	}
+
+	requesterID, err := getUserIDFromToken(token, db)
+	if err != nil {
+		writeJSONError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized: Invalid token")
+		return
+	}
+
+	transactionID := mux.Vars(r)["transaction_id"]
+	if len(transactionID) == 0 || len(transactionID) > 8 {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_TRANSACTION_ID", "Invalid transaction ID")
+		return
+	}
+
+	lookup, err := lookupOrderByTransactionID(db, transactionID)
+	if err == sql.ErrNoRows {
+		writeJSONError(w, http.StatusNotFound, "ORDER_NOT_FOUND", "Order not found")
+		return
+	} else if err != nil {
+		log.Println("Error looking up order by transaction ID:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error fetching order")
+		return
+	}
+
+	if lookup.Order.UserID != requesterID && !isAdmin(requesterID, db) {
+		writeJSONError(w, http.StatusForbidden, "FORBIDDEN", "Forbidden: You can only view your own orders")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lookup)
+}
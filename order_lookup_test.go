@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestLookupOrderByTransactionID(t *testing.T) {
+	openTestDB(t)
+	buyerUser, buyerToken := createTestUser(t, "buyer", false)
+	sellerUser, sellerToken := createTestUser(t, "seller", false)
+
+	resting := placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 5, Quantity: wholeQuantity(3)})
+	filled := placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 10, Quantity: wholeQuantity(2)})
+	placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 10, Quantity: wholeQuantity(2)})
+	if _, err := runMatching(db, defaultProjectID); err != nil {
+		t.Fatalf("matching: %v", err)
+	}
+	var matchedID int
+	if err := db.QueryRow("SELECT id FROM matched_orders WHERE seller_order_id = $1", filled.ID).Scan(&matchedID); err != nil {
+		t.Fatalf("seller did not match: %v", err)
+	}
+
+	lookup := func(transactionID, token string) OrderLookup {
+		t.Helper()
+		rec := doTestRequest(t, http.MethodGet, "/api/v1/orders/by-transaction/"+transactionID, token, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("lookup %s: status %d (%s)", transactionID, rec.Code, rec.Body.String())
+		}
+		var l OrderLookup
+		decodeTestResponse(t, rec, &l)
+		return l
+	}
+
+	top := lookup(resting.TransactionID, buyerToken)
+	if top.Location != "top_buyer" || top.Order.ID != resting.ID || top.Order.Quantity != wholeQuantity(3) || len(top.Matches) != 0 {
+		t.Errorf("resting buyer = %s #%d qty %s with %d matches, want top_buyer #%d qty 3 with none",
+			top.Location, top.Order.ID, top.Order.Quantity, len(top.Matches), resting.ID)
+	}
+
+	done := lookup(filled.TransactionID, sellerToken)
+	if done.Location != "filled" || done.Order.ID != filled.ID || done.Order.Role != "seller" {
+		t.Errorf("matched seller = %s %s #%d, want filled seller #%d", done.Location, done.Order.Role, done.Order.ID, filled.ID)
+	}
+	if len(done.Matches) != 1 || done.Matches[0].ID != matchedID || done.Matches[0].MatchedQty != wholeQuantity(2) {
+		t.Errorf("matches = %+v, want matched order #%d for 2", done.Matches, matchedID)
+	}
+
+	if rec := doTestRequest(t, http.MethodGet, "/api/v1/orders/by-transaction/"+filled.TransactionID, buyerToken, nil); rec.Code != http.StatusForbidden {
+		t.Errorf("another user's order: status %d, want 403", rec.Code)
+	}
+	if rec := doTestRequest(t, http.MethodGet, "/api/v1/orders/by-transaction/99999999", buyerToken, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown transaction id: status %d, want 404", rec.Code)
+	}
+}
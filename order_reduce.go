@@ -0,0 +1,192 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	errReduceOrderNotFound = errors.New("order not found")
+	errReduceTooLarge      = errors.New("reduction would leave no quantity")
+)
+
+// Lowers a resting order's quantity by reduceBy inside tx, wherever it lives
+// (top or main table). The row keeps its created_at, so time priority is
+// unchanged. Returns the new quantity and whether the order is in the top table.
+func reduceOrderTx(tx *sql.Tx, role string, orderID int, reduceBy Quantity) (Quantity, bool, error) {
+	var newQty Quantity
+
+	// Top first - an order promoted mid-request is found on the second lookup
+	err := tx.QueryRow(fmt.Sprintf(`
+		UPDATE %s SET quantity = quantity - $1
+		WHERE order_id = $2 AND quantity > $1
+		RETURNING quantity
+	`, getTopTableName(role)), reduceBy, orderID).Scan(&newQty)
+	if err == nil {
+		return newQty, true, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, false, fmt.Errorf("reducing top order: %w", err)
+	}
+
+	err = tx.QueryRow(fmt.Sprintf(`
+		UPDATE %s SET quantity = quantity - $1
+		WHERE id = $2 AND quantity > $1
+		RETURNING quantity
+	`, getTableName(role)), reduceBy, orderID).Scan(&newQty)
+	if err == nil {
+		return newQty, false, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, false, fmt.Errorf("reducing order: %w", err)
+	}
+
+	// Nothing updated: either the order is gone or the reduction is too big
+	var exists bool
+	err = tx.QueryRow(fmt.Sprintf(`
+		SELECT EXISTS (SELECT 1 FROM %s WHERE order_id = $1)
+		    OR EXISTS (SELECT 1 FROM %s WHERE id = $1)
+	`, getTopTableName(role), getTableName(role)), orderID).Scan(&exists)
+	if err != nil {
+		return 0, false, fmt.Errorf("checking order: %w", err)
+	}
+	if !exists {
+		return 0, false, errReduceOrderNotFound
+	}
+	return 0, false, errReduceTooLarge
+}
+
+// POST /api/orders/{role}/{id}/reduce - partial cancellation. Shrinks the
+// resting quantity by reduce_by; taking it to zero is a cancel, not a reduce.
+func reduceOrder(w http.ResponseWriter, r *http.Request) {
+	token, err := extractToken(r)
+	if err != nil {
+		writeJSONError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized: "+err.Error())
+		return
+	}
+
+	requesterID, err := getUserIDFromToken(token, db)
+	if err != nil {
+		writeJSONError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized: Invalid token")
+		return
+	}
+
+	vars := mux.Vars(r)
+	role := vars["role"]
+	orderID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_ORDER_ID", "Invalid order ID")
+		return
+	}
+
+	if role != "buyer" && role != "seller" {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_ROLE", "Invalid role")
+		return
+	}
+
+	var req struct {
+		ReduceBy Quantity `json:"reduce_by"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyDecodeError(w, err)
+		return
+	}
+	if req.ReduceBy <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_REDUCE_BY", "reduce_by must be positive")
+		return
+	}
+
+	// Ownership check, same lookup order as cancelOrder
+	var ownerID, projectID int
+	projectCol := projectIDOrDefault("project_id")
+	err = db.QueryRow("SELECT user_id, "+projectCol+" FROM "+getTopTableName(role)+" WHERE order_id = $1", orderID).Scan(&ownerID, &projectID)
+	if err == sql.ErrNoRows {
+		err = db.QueryRow("SELECT user_id, "+projectCol+" FROM "+getTableName(role)+" WHERE id = $1", orderID).Scan(&ownerID, &projectID)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeJSONError(w, http.StatusNotFound, "ORDER_NOT_FOUND", "Order not found")
+		} else {
+			writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database error")
+		}
+		return
+	}
+
+	reducedByRole := "owner"
+	if requesterID != ownerID {
+		if !isAdmin(requesterID, db) {
+			writeJSONError(w, http.StatusForbidden, "FORBIDDEN", "Forbidden: You can only reduce your own orders")
+			return
+		}
+		reducedByRole = "admin"
+	}
+
+	if !req.ReduceBy.IsWhole() {
+		rules, err := getProjectTradingRules(db, projectID)
+		if err != nil {
+			log.Println("Error fetching trading rules:", err)
+			writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to reduce order")
+			return
+		}
+		if err := validateQuantityUnits(req.ReduceBy, rules); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "INVALID_REDUCE_BY", fmt.Sprintf("Invalid reduce_by: %v", err))
+			return
+		}
+	}
+
+	var newQty Quantity
+	var inTopTable bool
+	err = withRetry(db, func(tx *sql.Tx) error {
+		var err error
+		newQty, inTopTable, err = reduceOrderTx(tx, role, orderID, req.ReduceBy)
+		if err != nil {
+			return err
+		}
+
+		// History tracks what is still open, not what was originally placed
+		_, err = tx.Exec(fmt.Sprintf(`
+			UPDATE %s_order_history
+			SET remaining_qty = $1, updated_at = CURRENT_TIMESTAMP
+			WHERE %s_order_id = $2
+		`, role, role), newQty, orderID)
+		if err != nil {
+			return fmt.Errorf("updating history: %w", err)
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errReduceOrderNotFound):
+		writeJSONError(w, http.StatusNotFound, "ORDER_NOT_FOUND", "Order not found")
+		return
+	case errors.Is(err, errReduceTooLarge):
+		writeJSONError(w, http.StatusBadRequest, "REDUCE_EXCEEDS_QUANTITY",
+			"Reduction would leave the order with no quantity - cancel the order instead")
+		return
+	case err != nil:
+		log.Printf("Error reducing order %d: %v", orderID, err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to reduce order")
+		return
+	}
+
+	if inTopTable {
+		notifyOrderBookChanged(role)
+	}
+
+	log.Printf("✂️ Order #%d (%s) reduced by %s to %s by User %d (%s)",
+		orderID, role, req.ReduceBy, newQty, requesterID, reducedByRole)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"message":  "Order reduced successfully",
+		"id":       orderID,
+		"quantity": newQty,
+	})
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestReduceOrder(t *testing.T) {
+	openTestDB(t)
+	ownerID, ownerToken := createTestUser(t, "owner", false)
+	_, otherToken := createTestUser(t, "other", false)
+
+	buyer := placeTestOrder(t, Order{UserID: ownerID, Role: "buyer", Price: 10, Quantity: wholeQuantity(10)})
+	target := fmt.Sprintf("/api/v1/orders/buyer/%d/reduce", buyer.ID)
+
+	rec := doTestRequest(t, http.MethodPost, target, otherToken, map[string]interface{}{"reduce_by": 4})
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("another user's reduce: status %d, want 403", rec.Code)
+	}
+
+	rec = doTestRequest(t, http.MethodPost, target, ownerToken, map[string]interface{}{"reduce_by": 4})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("reduce by 4: status %d (%s)", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Quantity Quantity `json:"quantity"`
+	}
+	decodeTestResponse(t, rec, &resp)
+	if resp.Quantity != wholeQuantity(6) {
+		t.Errorf("response quantity = %s, want 6", resp.Quantity)
+	}
+	if qty, ok := testOrderQuantity(t, "buyer", buyer.ID); !ok || qty != wholeQuantity(6) {
+		t.Errorf("after reducing by 4 the order holds %s (found %v), want 6", qty, ok)
+	}
+
+	// Reducing by the whole remainder (or more) is a cancel, not a reduce
+	for _, reduceBy := range []int{6, 7} {
+		rec = doTestRequest(t, http.MethodPost, target, ownerToken, map[string]interface{}{"reduce_by": reduceBy})
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("reduce by %d: status %d, want 400", reduceBy, rec.Code)
+		}
+		if code := errorCode(t, rec); code != "REDUCE_EXCEEDS_QUANTITY" {
+			t.Errorf("reduce by %d: error code %q, want REDUCE_EXCEEDS_QUANTITY", reduceBy, code)
+		}
+	}
+	if qty, ok := testOrderQuantity(t, "buyer", buyer.ID); !ok || qty != wholeQuantity(6) {
+		t.Errorf("rejected reductions changed the order to %s (found %v), want 6", qty, ok)
+	}
+}
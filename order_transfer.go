@@ -0,0 +1,116 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// Moves every open order of fromUserID (main and top tables, both roles) to
+// toUserID inside tx. Open order history moves with them; filled history and
+// matched_orders stay with the original owner. Returns moved counts per role.
+func transferOpenOrdersTx(tx *sql.Tx, fromUserID, toUserID int) (map[string]int64, error) {
+	moved := map[string]int64{}
+
+	for _, role := range []string{"buyer", "seller"} {
+		for _, table := range []string{getTableName(role), getTopTableName(role)} {
+			result, err := tx.Exec(fmt.Sprintf("UPDATE %s SET user_id = $1 WHERE user_id = $2", table), toUserID, fromUserID)
+			if err != nil {
+				return nil, fmt.Errorf("transferring %s: %v", table, err)
+			}
+			rows, _ := result.RowsAffected()
+			moved[role] += rows
+		}
+
+		_, err := tx.Exec(fmt.Sprintf(`
+			UPDATE %s_order_history
+			SET %s_user_id = $1, updated_at = CURRENT_TIMESTAMP
+			WHERE %s_user_id = $2 AND status IN ('Pending', 'Partially Matched')
+		`, role, role, role), toUserID, fromUserID)
+		if err != nil {
+			return nil, fmt.Errorf("transferring %s history: %v", role, err)
+		}
+	}
+
+	return moved, nil
+}
+
+// Reassign a user's open orders to another account, e.g. a house account
+// before offboarding (admin)
+func transferUserOrders(w http.ResponseWriter, r *http.Request) {
+	adminID := userIDFromContext(r)
+
+	fromUserID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_USER_ID", "Invalid user ID")
+		return
+	}
+
+	var req struct {
+		TargetUserID int `json:"target_user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyDecodeError(w, err)
+		return
+	}
+	if req.TargetUserID <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_TARGET_USER", "target_user_id is required")
+		return
+	}
+	if req.TargetUserID == fromUserID {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_TARGET_USER", "target_user_id must differ from the source user")
+		return
+	}
+
+	var found int
+	err = db.QueryRow("SELECT COUNT(*) FROM users WHERE id IN ($1, $2)", fromUserID, req.TargetUserID).Scan(&found)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database error")
+		return
+	}
+	if found != 2 {
+		writeJSONError(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found")
+		return
+	}
+
+	var moved map[string]int64
+	err = withRetry(db, func(tx *sql.Tx) error {
+		var err error
+		moved, err = transferOpenOrdersTx(tx, fromUserID, req.TargetUserID)
+		return err
+	})
+	if err != nil {
+		log.Printf("Error transferring orders from user %d to %d: %v", fromUserID, req.TargetUserID, err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to transfer orders")
+		return
+	}
+
+	log.Printf("🔀 Transferred open orders from user %d to user %d by admin (User ID: %d): %d buyer, %d seller",
+		fromUserID, req.TargetUserID, adminID, moved["buyer"], moved["seller"])
+
+	// Ownership doesn't change priority, but re-sync so the top tables are
+	// checked against the new state
+	for _, role := range []string{"buyer", "seller"} {
+		if moved[role] == 0 {
+			continue
+		}
+		if err := smartSyncTopOrders(db, role); err != nil {
+			log.Printf("Error syncing top %s orders after transfer: %v", role, err)
+		}
+		notifyOrderBookChanged(role)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":             true,
+		"from_user_id":        fromUserID,
+		"target_user_id":      req.TargetUserID,
+		"transferred_buyers":  moved["buyer"],
+		"transferred_sellers": moved["seller"],
+	})
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestTransferUserOrdersMovesOwnershipAndKeepsTopTables(t *testing.T) {
+	openTestDB(t)
+	_, adminToken := createTestUser(t, "admin", true)
+	leaverID, _ := createTestUser(t, "leaver", false)
+	houseID, _ := createTestUser(t, "house", false)
+	otherID, _ := createTestUser(t, "other", false)
+
+	// Better bids fill the top table, so the leaver's bid rests in the main table
+	for i := 0; i < 10; i++ {
+		placeTestOrder(t, Order{UserID: otherID, Role: "buyer", Price: 20, Quantity: wholeQuantity(1)})
+	}
+	mainBuyer := placeTestOrder(t, Order{UserID: leaverID, Role: "buyer", Price: 5, Quantity: wholeQuantity(1)})
+	topSeller := placeTestOrder(t, Order{UserID: leaverID, Role: "seller", Price: 30, Quantity: wholeQuantity(2)})
+
+	target := fmt.Sprintf("/api/v1/admin/users/%d/transfer-orders", leaverID)
+	rec := doTestRequest(t, http.MethodPost, target, adminToken, map[string]int{"target_user_id": houseID})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("transfer: status %d (%s)", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Buyers  int `json:"transferred_buyers"`
+		Sellers int `json:"transferred_sellers"`
+	}
+	decodeTestResponse(t, rec, &resp)
+	if resp.Buyers != 1 || resp.Sellers != 1 {
+		t.Errorf("transferred %d buyers and %d sellers, want 1 and 1", resp.Buyers, resp.Sellers)
+	}
+
+	var owner int
+	if err := db.QueryRow("SELECT user_id FROM buyer WHERE id = $1", mainBuyer.ID).Scan(&owner); err != nil || owner != houseID {
+		t.Errorf("main-table buyer owner = %d (err %v), want house %d", owner, err, houseID)
+	}
+	if err := db.QueryRow("SELECT user_id FROM top_seller WHERE order_id = $1", topSeller.ID).Scan(&owner); err != nil || owner != houseID {
+		t.Errorf("top-table seller owner = %d (err %v), want house %d", owner, err, houseID)
+	}
+	if err := db.QueryRow("SELECT buyer_user_id FROM buyer_order_history WHERE buyer_order_id = $1", mainBuyer.ID).Scan(&owner); err != nil || owner != houseID {
+		t.Errorf("open buyer history owner = %d (err %v), want house %d", owner, err, houseID)
+	}
+
+	if n := countTestUserOrders(t, "buyer", leaverID) + countTestUserOrders(t, "seller", leaverID); n != 0 {
+		t.Errorf("leaver still owns %d resting orders", n)
+	}
+	if b, s := testCount(t, "top_buyer"), testCount(t, "top_seller"); b != 10 || s != 1 {
+		t.Errorf("top tables hold %d buyers and %d sellers, want 10 and 1", b, s)
+	}
+	var dupes int
+	if err := db.QueryRow("SELECT COUNT(*) FROM buyer b JOIN top_buyer tb ON tb.order_id = b.id").Scan(&dupes); err != nil || dupes != 0 {
+		t.Errorf("%d buyers in both tables (err %v)", dupes, err)
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// How far trade_date may be from today. One day ahead by default so clients
+// in timezones east of the server aren't rejected around midnight.
+var (
+	tradeDateMaxFutureDays = getEnvInt("TRADE_DATE_MAX_FUTURE_DAYS", 1)
+	tradeDateMaxPastDays   = getEnvInt("TRADE_DATE_MAX_PAST_DAYS", 30)
+)
+
+// Open orders a non-admin user may have in one project, both sides combined
+// (MAX_OPEN_ORDERS_PER_USER, 0 = unlimited). Admins are exempt.
+var maxOpenOrdersPerUser = getEnvInt("MAX_OPEN_ORDERS_PER_USER", 100)
+
+// Resting orders of a user in a project, across main and top tables of both roles
+func countOpenOrders(database *sql.DB, userID, projectID int) (int, error) {
+	var count int
+	err := database.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM buyer WHERE user_id = $1 AND `+projectIDOrDefault("project_id")+` = $2)
+		     + (SELECT COUNT(*) FROM top_buyer WHERE user_id = $1 AND `+projectIDOrDefault("project_id")+` = $2)
+		     + (SELECT COUNT(*) FROM seller WHERE user_id = $1 AND `+projectIDOrDefault("project_id")+` = $2)
+		     + (SELECT COUNT(*) FROM top_seller WHERE user_id = $1 AND `+projectIDOrDefault("project_id")+` = $2)
+	`, userID, projectID).Scan(&count)
+	return count, err
+}
+
+// Prices are compared as integers scaled to the column precision (6dp) so that
+// float64 noise never breaks an exact-price match
+const priceScale = 1e6
+
+func scalePrice(price float64) int64 {
+	return int64(math.Round(price * priceScale))
+}
+
+// -1, 0 or 1 as a is below, equal to or above b at 6dp
+func comparePrices(a, b float64) int {
+	sa, sb := scalePrice(a), scalePrice(b)
+	switch {
+	case sa < sb:
+		return -1
+	case sa > sb:
+		return 1
+	}
+	return 0
+}
+
+// Rejects prices with more decimals than the project allows or that overflow DECIMAL(18, 6)
+func validatePricePrecision(price float64, precision int) error {
+	if math.Abs(price) >= 1e12 {
+		return fmt.Errorf("price must be less than 1000000000000")
+	}
+
+	// Relative tolerance absorbs float64 representation error (0.1 * 10 != 1 exactly)
+	scaled := price * math.Pow10(precision)
+	if math.Abs(scaled-math.Round(scaled)) > 1e-9*math.Max(1, math.Abs(scaled)) {
+		return fmt.Errorf("price has more than %d decimal places", precision)
+	}
+	return nil
+}
+
+// Rejects prices that aren't a whole number of ticks, naming the nearest valid
+// prices. Compared at 6dp so float64 noise never rejects an on-tick price.
+func validateTickSize(price, tickSize float64) error {
+	scaledPrice, scaledTick := scalePrice(price), scalePrice(tickSize)
+	if scaledTick <= 0 || scaledPrice%scaledTick == 0 {
+		return nil
+	}
+	below := scaledPrice - scaledPrice%scaledTick
+	formatTick := func(scaled int64) string {
+		return strconv.FormatFloat(float64(scaled)/priceScale, 'f', -1, 64)
+	}
+	return fmt.Errorf("price %s is not a multiple of the tick size %s (nearest valid prices: %s and %s)",
+		formatTick(scaledPrice), formatTick(scaledTick), formatTick(below), formatTick(below+scaledTick))
+}
+
+// max_slippage_percentage bounds how far below its limit a match_type=1 buyer
+// will trade, so it means nothing for sellers, exact-price or market orders.
+// It is stored as DECIMAL(6, 2), so more decimals would be silently rounded.
+func validateMaxSlippage(order *Order) error {
+	if order.MaxSlippagePct == nil {
+		return nil
+	}
+	if order.Role != "buyer" || order.MatchType != 1 || order.OrderKind != "limit" {
+		return fmt.Errorf("max_slippage_percentage only applies to limit buyers with match_type 1")
+	}
+	if *order.MaxSlippagePct <= 0 || *order.MaxSlippagePct >= 100 {
+		return fmt.Errorf("max_slippage_percentage must be greater than 0 and less than 100")
+	}
+	if validatePricePrecision(*order.MaxSlippagePct, 2) != nil {
+		return fmt.Errorf("max_slippage_percentage can have at most 2 decimal places")
+	}
+	return nil
+}
+
+// good_till_date is a calendar date the order rests through; it is dead from
+// the next day on. Market orders never rest, so they can't carry one. today
+// is the database date (see databaseToday), zero for imports, whose
+// historical dates may already have passed.
+func validateGoodTillDate(order *Order, today time.Time) error {
+	if order.GoodTillDate == nil {
+		return nil
+	}
+	if order.OrderKind == "market" {
+		return fmt.Errorf("good_till_date only applies to limit orders")
+	}
+	date, err := time.Parse("2006-01-02", *order.GoodTillDate)
+	if err != nil {
+		return fmt.Errorf("good_till_date %q is not a valid date (expected YYYY-MM-DD)", *order.GoodTillDate)
+	}
+	if tradeDate, err := time.Parse("2006-01-02", order.TradeDate); err == nil && date.Before(tradeDate) {
+		return fmt.Errorf("good_till_date %s is before the trade_date %s", *order.GoodTillDate, order.TradeDate)
+	}
+	if !today.IsZero() && date.Before(today) {
+		return fmt.Errorf("good_till_date %s is in the past", *order.GoodTillDate)
+	}
+	return nil
+}
+
+// min_fill_quantity holds a buyer back until the book holds at least that much
+// it can trade with; once it has traded, the rest fills as usual
+func validateMinFill(order *Order) error {
+	if order.MinFillQty == nil {
+		return nil
+	}
+	if order.Role != "buyer" {
+		return fmt.Errorf("min_fill_quantity only applies to buyers")
+	}
+	if *order.MinFillQty <= 0 || *order.MinFillQty > order.Quantity {
+		return fmt.Errorf("min_fill_quantity must be greater than 0 and at most the order quantity")
+	}
+	return nil
+}
+
+// Whole-unit projects reject fractional quantities rather than rounding them
+func validateQuantityUnits(qty Quantity, rules *ProjectTradingRules) error {
+	if !rules.AllowFractional && !qty.IsWhole() {
+		return fmt.Errorf("quantity %s must be a whole number for this project", qty)
+	}
+	return nil
+}
+
+// Enforces the project's size limits. Market orders carry no price, so the
+// notional cap only applies to limit orders.
+func validateOrderSize(order *Order, rules *ProjectTradingRules) error {
+	if rules.MinQuantity != nil && order.Quantity < *rules.MinQuantity {
+		return fmt.Errorf("quantity %s is below the minimum of %s for this project", order.Quantity, *rules.MinQuantity)
+	}
+	if rules.MaxQuantity != nil && order.Quantity > *rules.MaxQuantity {
+		return fmt.Errorf("quantity %s exceeds the maximum of %s for this project", order.Quantity, *rules.MaxQuantity)
+	}
+	if rules.MaxNotional != nil && order.OrderKind == "limit" {
+		notional := order.Price * order.Quantity.Float64()
+		if notional > *rules.MaxNotional {
+			return fmt.Errorf("order value %.2f (price x quantity) exceeds the maximum of %.2f for this project", notional, *rules.MaxNotional)
+		}
+	}
+	return nil
+}
+
+// Mid of the buyer and seller price of the project's most recent fill that
+// still stands (busted and cancelled trades don't count).
+// ok is false when the project has not traded yet.
+func lastTradedMidPrice(database *sql.DB, projectID int) (mid float64, ok bool, err error) {
+	err = database.QueryRow(`
+		SELECT (buyer_price + seller_price) / 2 FROM matched_orders
+		WHERE `+projectIDOrDefault("project_id")+` = $1
+		AND `+countedTradeCondition+`
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1
+	`, projectID).Scan(&mid)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return mid, true, nil
+}
+
+// Rejects limit prices more than bandPct percent away from the last traded mid
+func validatePriceBand(price, lastMid, bandPct float64) error {
+	low := lastMid * (1 - bandPct/100)
+	high := lastMid * (1 + bandPct/100)
+	if comparePrices(price, low) < 0 || comparePrices(price, high) > 0 {
+		return fmt.Errorf("price %.6g is outside the allowed range %.6g - %.6g (%.2f%% around the last trade at %.6g)",
+			price, math.Max(low, 0), high, bandPct, lastMid)
+	}
+	return nil
+}
+
+// trade_date must be a real YYYY-MM-DD date within the configured window around now
+func validateTradeDate(tradeDate string, now time.Time) error {
+	date, err := time.Parse("2006-01-02", tradeDate)
+	if err != nil {
+		return fmt.Errorf("%q is not a valid date (expected YYYY-MM-DD)", tradeDate)
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if date.After(today.AddDate(0, 0, tradeDateMaxFutureDays)) {
+		return fmt.Errorf("%s is more than %d day(s) in the future", tradeDate, tradeDateMaxFutureDays)
+	}
+	if date.Before(today.AddDate(0, 0, -tradeDateMaxPastDays)) {
+		return fmt.Errorf("%s is more than %d day(s) in the past", tradeDate, tradeDateMaxPastDays)
+	}
+	return nil
+}
+
+// trade_time must be HH:MM:SS (fractional seconds are tolerated)
+func validateTradeTime(tradeTime string) error {
+	if _, err := time.Parse("15:04:05", tradeTime); err != nil {
+		return fmt.Errorf("%q is not a valid time (expected HH:MM:SS)", tradeTime)
+	}
+	return nil
+}
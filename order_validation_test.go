@@ -0,0 +1,138 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func floatPtr(v float64) *float64 { return &v }
+
+func TestValidateMaxSlippage(t *testing.T) {
+	buyer := func(pct float64) *Order {
+		return &Order{Role: "buyer", MatchType: 1, OrderKind: "limit", MaxSlippagePct: floatPtr(pct)}
+	}
+	tests := []struct {
+		name  string
+		order *Order
+		ok    bool
+	}{
+		{"two decimals", buyer(2.25), true},
+		{"three decimals", buyer(2.125), false},
+		{"zero", buyer(0), false},
+		{"100", buyer(100), false},
+		{"exact-price buyer", &Order{Role: "buyer", MatchType: 0, OrderKind: "limit", MaxSlippagePct: floatPtr(5)}, false},
+	}
+	for _, tt := range tests {
+		if err := validateMaxSlippage(tt.order); (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestCompatibleSellersForExcludesSellersBelowSlippageFloor(t *testing.T) {
+	buyer := OrderData{ProjectID: 1, Price: 100, MatchType: 1, OrderKind: "limit", MaxSlippagePct: floatPtr(10)}
+	sellers := []OrderData{
+		{ID: 1, ProjectID: 1, Price: 95},
+		{ID: 2, ProjectID: 1, Price: 89.99}, // below the 90 floor
+		{ID: 3, ProjectID: 1, Price: 90},
+	}
+
+	got := compatibleSellersFor(buyer, sellers)
+	if len(got) != 2 || got[0].ID != 3 || got[1].ID != 1 {
+		t.Errorf("compatible sellers = %+v, want #3 then #1 without the too-cheap #2", got)
+	}
+
+	buyer.MaxSlippagePct = nil
+	if got := compatibleSellersFor(buyer, sellers); len(got) != 3 {
+		t.Errorf("uncapped buyer: %d compatible sellers, want 3", len(got))
+	}
+}
+
+func TestSixDecimalPrices(t *testing.T) {
+	for _, price := range []float64{0.000001, 1.123456, 99999.999999} {
+		if err := validatePricePrecision(price, 6); err != nil {
+			t.Errorf("validatePricePrecision(%v, 6) = %v, want ok", price, err)
+		}
+	}
+	for _, price := range []float64{0.0000001, 1.1234567} {
+		if err := validatePricePrecision(price, 6); err == nil {
+			t.Errorf("validatePricePrecision(%v, 6) accepted 7 decimals", price)
+		}
+	}
+	if err := validatePricePrecision(1.123456, 4); err == nil {
+		t.Error("a 4dp project accepted a 6dp price")
+	}
+
+	if comparePrices(0.1+0.2, 0.3) != 0 {
+		t.Error("0.1+0.2 and 0.3 compare unequal at 6dp")
+	}
+	if comparePrices(1.123456, 1.123457) != -1 || comparePrices(1.123457, 1.123456) != 1 {
+		t.Error("prices one millionth apart don't order")
+	}
+
+	// An exact-price buyer matches the seller at the same 6dp price only
+	buyer := OrderData{ProjectID: 1, Price: 1.123456, OrderKind: "limit"}
+	sellers := []OrderData{
+		{ID: 1, ProjectID: 1, Price: 1.123457},
+		{ID: 2, ProjectID: 1, Price: 1.1234560000001},
+		{ID: 3, ProjectID: 1, Price: 1.123455},
+	}
+	if got := compatibleSellersFor(buyer, sellers); len(got) != 1 || got[0].ID != 2 {
+		t.Errorf("compatible sellers = %+v, want only #2", got)
+	}
+}
+
+func TestValidateTradeDateAndTime(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	future := now.AddDate(0, 0, tradeDateMaxFutureDays+1).Format("2006-01-02")
+	past := now.AddDate(0, 0, -tradeDateMaxPastDays-1).Format("2006-01-02")
+
+	tests := []struct {
+		date string
+		ok   bool
+	}{
+		{"2024-06-15", true},
+		{future, false},
+		{past, false},
+		{"2024-13-45", false},
+		{"2024-6-15", false},
+		{"15/06/2024", false},
+	}
+	for _, tt := range tests {
+		if err := validateTradeDate(tt.date, now); (err == nil) != tt.ok {
+			t.Errorf("validateTradeDate(%q) = %v, want ok %v", tt.date, err, tt.ok)
+		}
+	}
+
+	for _, tm := range []string{"09:30:00", "23:59:59"} {
+		if err := validateTradeTime(tm); err != nil {
+			t.Errorf("validateTradeTime(%q) = %v, want ok", tm, err)
+		}
+	}
+	for _, tm := range []string{"25:00:00", "9:30", "09:30:00pm", ""} {
+		if err := validateTradeTime(tm); err == nil {
+			t.Errorf("validateTradeTime(%q) accepted a malformed time", tm)
+		}
+	}
+}
+
+func TestValidateTickSizeQuarterTick(t *testing.T) {
+	for _, price := range []float64{0.25, 10, 10.75, 0.1 + 0.15} {
+		if err := validateTickSize(price, 0.25); err != nil {
+			t.Errorf("validateTickSize(%v, 0.25) = %v, want ok", price, err)
+		}
+	}
+	for _, price := range []float64{0.1, 10.3, 10.26} {
+		if err := validateTickSize(price, 0.25); err == nil {
+			t.Errorf("validateTickSize(%v, 0.25) accepted an off-tick price", price)
+		}
+	}
+	err := validateTickSize(10.3, 0.25)
+	if err == nil || !strings.Contains(err.Error(), "10.25 and 10.5") {
+		t.Errorf("off-tick error = %v, want the nearest prices 10.25 and 10.5", err)
+	}
+	if err := validateTickSize(10.3, 0); err != nil {
+		t.Errorf("no tick size rejected %v", err)
+	}
+}
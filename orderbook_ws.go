@@ -0,0 +1,232 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type OrderBookEvent struct {
+	Type      string `json:"type"` // add, update or remove
+	Role      string `json:"role"`
+	ProjectID int    `json:"project_id"`
+	Order     Order  `json:"order"`
+}
+
+// Last published state of each top table, diffed on every change so the
+// mutation sites only have to say "this side changed"
+var (
+	orderBookState   = map[string]map[int]Order{}
+	orderBookMutex   sync.Mutex
+	orderBookDirty   = map[string]bool{}
+	orderBookChanged = make(chan struct{}, 1)
+)
+
+// Call after any committed change to top_buyer/top_seller
+func notifyOrderBookChanged(roles ...string) {
+	orderBookMutex.Lock()
+	for _, role := range roles {
+		orderBookDirty[role] = true
+	}
+	orderBookMutex.Unlock()
+
+	select {
+	case orderBookChanged <- struct{}{}:
+	default:
+	}
+}
+
+func startOrderBookPublisher(database *sql.DB) {
+	for _, role := range []string{"buyer", "seller"} {
+		orders, err := loadTopTable(database, role)
+		if err != nil {
+			log.Printf("Warning: Could not load %s order book: %v", role, err)
+			orders = map[int]Order{}
+		}
+		orderBookState[role] = orders
+	}
+
+	go func() {
+		for range orderBookChanged {
+			// Let a burst of changes (e.g. a multi-fill match) settle into one diff
+			time.Sleep(20 * time.Millisecond)
+
+			orderBookMutex.Lock()
+			dirty := orderBookDirty
+			orderBookDirty = map[string]bool{}
+			orderBookMutex.Unlock()
+
+			for role := range dirty {
+				if err := publishOrderBookDiff(database, role); err != nil {
+					log.Printf("⚠️ Warning: Could not publish %s order book changes: %v", role, err)
+				}
+			}
+		}
+	}()
+}
+
+func publishOrderBookDiff(database *sql.DB, role string) error {
+	current, err := loadTopTable(database, role)
+	if err != nil {
+		return err
+	}
+
+	previous := orderBookState[role]
+	orderBookState[role] = current
+
+	for id, order := range current {
+		old, existed := previous[id]
+		switch {
+		case !existed:
+			publishOrderBookEvent("add", role, order)
+		case old.Quantity != order.Quantity || old.Price != order.Price:
+			publishOrderBookEvent("update", role, order)
+		}
+	}
+	for id, order := range previous {
+		if _, exists := current[id]; !exists {
+			publishOrderBookEvent("remove", role, order)
+		}
+	}
+	return nil
+}
+
+func publishOrderBookEvent(eventType, role string, order Order) {
+	projectID := defaultProjectID
+	if order.ProjectID != nil {
+		projectID = *order.ProjectID
+	}
+	hub.publish(wsEvent{
+		Channel:   "orderbook",
+		Key:       fmt.Sprintf("%s:%d", role, order.ID),
+		ProjectID: projectID,
+		Role:      role,
+		Data:      OrderBookEvent{Type: eventType, Role: role, ProjectID: projectID, Order: order},
+	})
+}
+
+func loadTopTable(database *sql.DB, role string) (map[int]Order, error) {
+	orders, err := queryTopOrders(database, role, 0)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]Order, len(orders))
+	for _, order := range orders {
+		byID[order.ID] = order
+	}
+	return byID, nil
+}
+
+// Top orders for a role, optionally limited to one project (0 = all)
+func queryTopOrders(database *sql.DB, role string, projectID int) ([]Order, error) {
+	topTable := getTopTableName(role)
+	if topTable == "" {
+		return nil, fmt.Errorf("invalid role")
+	}
+
+	rows, err := database.Query(fmt.Sprintf(`
+		SELECT order_id, user_id, transaction_id, price, quantity, trade_date,
+		       TO_CHAR(trade_time, 'HH24:MI:SS'), transaction_type, match_type,
+		       market_lead_program, order_kind, ` + projectIDOrDefault("project_id") + `, created_at
+		FROM %s
+		WHERE $1 = 0 OR ` + projectIDOrDefault("project_id") + ` = $1
+	`, topTable), projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := []Order{}
+	for rows.Next() {
+		var order Order
+		var pid int
+		err := rows.Scan(&order.ID, &order.UserID, &order.TransactionID, &order.Price, &order.Quantity,
+			&order.TradeDate, &order.TradeTime, &order.TransactionType, &order.MatchType,
+			&order.MarketLeadProgram, &order.OrderKind, &pid, &order.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		order.ProjectID = &pid
+		order.Role = role
+		orders = append(orders, order)
+	}
+	return orders, rows.Err()
+}
+
+// Coalescing rules within one flush window
+func coalesceOrderBookEvents(prev, next wsEvent) (wsEvent, bool) {
+	prevType := prev.Data.(OrderBookEvent).Type
+	nextEvt := next.Data.(OrderBookEvent)
+
+	switch {
+	case prevType == "add" && nextEvt.Type == "remove":
+		return next, false // Never seen by the client
+	case prevType == "add" && nextEvt.Type == "update":
+		nextEvt.Type = "add"
+		next.Data = nextEvt
+	}
+	return next, true
+}
+
+// GET /ws/orderbook?role=&project_id= - snapshot on connect, then batched
+// add/update/remove events for the top tables. Both filters are optional.
+func orderBookWebSocket(w http.ResponseWriter, r *http.Request) {
+	role := r.URL.Query().Get("role")
+	if role != "" && role != "buyer" && role != "seller" {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_ROLE", "Invalid role")
+		return
+	}
+
+	projectID := 0
+	if projectIDStr := r.URL.Query().Get("project_id"); projectIDStr != "" {
+		var err error
+		projectID, err = strconv.Atoi(projectIDStr)
+		if err != nil || projectID <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "INVALID_PROJECT_ID", "Invalid project ID")
+			return
+		}
+	}
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println("WebSocket upgrade failed:", err)
+		return
+	}
+
+	log.Printf("🔌 Order book subscriber connected (role: %q, project: %d)", role, projectID)
+
+	roles := []string{"buyer", "seller"}
+	if role != "" {
+		roles = []string{role}
+	}
+
+	snapshot := func() (interface{}, error) {
+		orders := map[string][]Order{}
+		for _, rl := range roles {
+			roleOrders, err := queryTopOrders(db, rl, projectID)
+			if err != nil {
+				return nil, err
+			}
+			orders[rl] = roleOrders
+		}
+		return map[string]interface{}{
+			"type":       "snapshot",
+			"project_id": projectID,
+			"orders":     orders,
+		}, nil
+	}
+
+	filter := func(evt wsEvent) bool {
+		return evt.Channel == "orderbook" &&
+			(role == "" || evt.Role == role) &&
+			(projectID == 0 || evt.ProjectID == projectID)
+	}
+
+	serveWSClient(conn, snapshot, filter, coalesceOrderBookEvents)
+
+	log.Printf("🔌 Order book subscriber disconnected (role: %q, project: %d)", role, projectID)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type Position struct {
+	ProjectID     int      `json:"project_id"`
+	ProjectName   string   `json:"project_name"`
+	BoughtQty     Quantity `json:"bought_qty"`
+	SoldQty       Quantity `json:"sold_qty"`
+	NetQty        Quantity `json:"net_qty"`
+	AvgEntryPrice float64  `json:"avg_entry_price"`
+	RealizedPnL   float64  `json:"realized_pnl"`
+	LastPrice     float64  `json:"last_price"`
+	UnrealizedPnL float64  `json:"unrealized_pnl"`
+	Status        string   `json:"status"` // long, short or flat
+}
+
+// Get net position and P&L per project for a user
+func getUserPositionsHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := extractToken(r)
+	if err != nil {
+		writeJSONError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized: "+err.Error())
+		return
+	}
+
+	requesterID, err := getUserIDFromToken(token, db)
+	if err != nil {
+		writeJSONError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized: Invalid token")
+		return
+	}
+
+	vars := mux.Vars(r)
+	userID, err := strconv.Atoi(vars["user_id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_USER_ID", "Invalid user ID")
+		return
+	}
+
+	if userID != requesterID && !isAdmin(requesterID, db) {
+		writeJSONError(w, http.StatusForbidden, "FORBIDDEN", "Forbidden: You can only view your own positions")
+		return
+	}
+
+	positions, err := calculateUserPositions(db, userID)
+	if err != nil {
+		log.Println("Error calculating positions:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error fetching positions")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(positions)
+}
+
+func calculateUserPositions(database *sql.DB, userID int) ([]Position, error) {
+	return calculateUserPositionsAsOf(database, userID, time.Time{})
+}
+
+// Replays the user's fills in time order using average-cost accounting.
+// Fills that reduce the position realize P&L against the average entry price;
+// whatever is left open is marked against the project's last traded price.
+// A non-zero asOf only counts fills (and last prices) before it. Archived
+// trades count as well. Each trade
+// is read once per side the user was on, so a self-trade counts as a buy and
+// a sell at the same price.
+func calculateUserPositionsAsOf(database *sql.DB, userID int, asOf time.Time) ([]Position, error) {
+	rows, err := database.Query(`
+		WITH fills AS (
+			SELECT * FROM matched_orders_all
+			WHERE `+countedTradeCondition+`
+			AND ($2::timestamp IS NULL OR created_at < $2)
+		), last AS (
+			SELECT DISTINCT ON (project_id) project_id, COALESCE(execution_price, seller_price) AS price
+			FROM fills
+			WHERE project_id IN (SELECT project_id FROM fills WHERE buyer_user_id = $1 OR seller_user_id = $1)
+			ORDER BY project_id, created_at DESC, id DESC
+		)
+		SELECT mo.project_id, COALESCE(p.name, 'Unknown Project'),
+		       leg.direction,
+		       mo.matched_qty,
+		       COALESCE(mo.execution_price, mo.seller_price),
+		       last.price
+		FROM fills mo
+		JOIN last ON last.project_id = mo.project_id
+		CROSS JOIN LATERAL (VALUES (1, mo.buyer_user_id), (-1, mo.seller_user_id)) AS leg(direction, user_id)
+		LEFT JOIN projects p ON p.id = mo.project_id
+		WHERE leg.user_id = $1
+		ORDER BY mo.project_id ASC, mo.created_at ASC, mo.id ASC, leg.direction DESC
+	`, userID, optionalTime(asOf))
+	if err != nil {
+		return nil, fmt.Errorf("error querying fills: %v", err)
+	}
+	defer rows.Close()
+
+	positions := []Position{}
+	var current *Position
+
+	for rows.Next() {
+		var projectID, direction int
+		var qty Quantity
+		var projectName string
+		var price, lastPrice float64
+		if err := rows.Scan(&projectID, &projectName, &direction, &qty, &price, &lastPrice); err != nil {
+			return nil, fmt.Errorf("error scanning fill: %v", err)
+		}
+
+		if current == nil || current.ProjectID != projectID {
+			positions = append(positions, Position{ProjectID: projectID, ProjectName: projectName, LastPrice: lastPrice})
+			current = &positions[len(positions)-1]
+		}
+
+		if direction > 0 {
+			current.BoughtQty += qty
+		} else {
+			current.SoldQty += qty
+		}
+		applyFill(current, Quantity(direction)*qty, price)
+	}
+
+	for i := range positions {
+		p := &positions[i]
+		p.UnrealizedPnL = roundMoney((p.LastPrice - p.AvgEntryPrice) * p.NetQty.Float64())
+		p.RealizedPnL = roundMoney(p.RealizedPnL)
+		p.AvgEntryPrice = roundMoney(p.AvgEntryPrice)
+		switch {
+		case p.NetQty > 0:
+			p.Status = "long"
+		case p.NetQty < 0:
+			p.Status = "short"
+		default:
+			p.Status = "flat"
+		}
+	}
+
+	return positions, nil
+}
+
+// signedQty > 0 is a buy, < 0 a sell
+func applyFill(p *Position, signedQty Quantity, price float64) {
+	// Same direction (or flat): extend the position at a new average price
+	if p.NetQty == 0 || (p.NetQty > 0) == (signedQty > 0) {
+		total := p.AvgEntryPrice*math.Abs(p.NetQty.Float64()) + price*math.Abs(signedQty.Float64())
+		p.NetQty += signedQty
+		p.AvgEntryPrice = total / math.Abs(p.NetQty.Float64())
+		return
+	}
+
+	// Opposite direction: close up to the open quantity at the entry price
+	closing := signedQty
+	if absQuantity(signedQty) > absQuantity(p.NetQty) {
+		closing = -p.NetQty
+	}
+	if p.NetQty > 0 {
+		p.RealizedPnL += (price - p.AvgEntryPrice) * absQuantity(closing).Float64()
+	} else {
+		p.RealizedPnL += (p.AvgEntryPrice - price) * absQuantity(closing).Float64()
+	}
+	p.NetQty += closing
+
+	// Anything beyond the open quantity flips the position at this fill's price
+	if remainder := signedQty - closing; remainder != 0 {
+		p.NetQty = remainder
+		p.AvgEntryPrice = price
+	} else if p.NetQty == 0 {
+		p.AvgEntryPrice = 0
+	}
+}
+
+func absQuantity(n Quantity) Quantity {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func roundMoney(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}
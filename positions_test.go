@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPositionsAndStatementUseExecutionPrice(t *testing.T) {
+	openTestDB(t)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+
+	// The resting seller is the maker, so the trade executes at its 10
+	placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 10, Quantity: wholeQuantity(5)})
+	placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 12, Quantity: wholeQuantity(5)})
+	if _, err := runMatching(db, defaultProjectID); err != nil {
+		t.Fatal(err)
+	}
+
+	var executionPrice float64
+	if err := db.QueryRow("SELECT execution_price FROM matched_orders").Scan(&executionPrice); err != nil {
+		t.Fatalf("read execution price: %v", err)
+	}
+	if executionPrice != 10 {
+		t.Fatalf("execution_price = %v, want the maker's 10", executionPrice)
+	}
+
+	for _, userID := range []int{buyerUser, sellerUser} {
+		positions, err := calculateUserPositions(db, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(positions) != 1 || positions[0].AvgEntryPrice != 10 || positions[0].LastPrice != 10 {
+			t.Errorf("user %d positions = %+v, want one entered and marked at 10", userID, positions)
+		}
+	}
+
+	waitForTestCount(t, "match_assignments", 1)
+	var tradePrices []float64
+	err := forEachStatementAssignment(context.Background(), db, buyerUser, time.Time{}, time.Time{}, func(sa SellerAssignment) error {
+		tradePrices = append(tradePrices, sa.TradePrice)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tradePrices) != 1 || tradePrices[0] != 10 {
+		t.Errorf("statement assignment trade prices = %v, want [10]", tradePrices)
+	}
+}
+
+func TestSelfTradeCountsBothLegs(t *testing.T) {
+	openTestDB(t)
+	trader, _ := createTestUser(t, "trader", false)
+	tradeTestOrders(t, defaultProjectID, trader, trader, 10, 4)
+
+	positions, err := calculateUserPositions(db, trader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(positions) != 1 {
+		t.Fatalf("positions = %+v, want one", positions)
+	}
+	p := positions[0]
+	if p.BoughtQty != wholeQuantity(4) || p.SoldQty != wholeQuantity(4) || p.NetQty != 0 || p.RealizedPnL != 0 || p.Status != "flat" {
+		t.Errorf("self-trade position = %+v, want 4 bought, 4 sold, flat with no P&L", p)
+	}
+}
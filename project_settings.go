@@ -0,0 +1,268 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// Highest price precision any project may use; matches the DECIMAL(18, 6) price columns
+const maxPricePrecision = 6
+
+// Per-project order rules. Nil limits mean "no limit".
+type ProjectTradingRules struct {
+	ProjectID           int       `json:"project_id"`
+	PricePrecision      int       `json:"price_precision"`
+	MinQuantity         *Quantity `json:"min_quantity"`
+	MaxQuantity         *Quantity `json:"max_quantity"`
+	MaxNotional         *float64  `json:"max_notional"`
+	PriceBandPercentage *float64  `json:"price_band_percentage"`
+	TickSize            *float64  `json:"tick_size"`
+	AllowFractional     bool      `json:"allow_fractional"` // quantities may have up to 8 decimals
+	// Price improvement over the worst top-table order needed to displace it
+	MinTopImprovement    *float64 `json:"min_top_improvement"`
+	MinTopImprovementPct *float64 `json:"min_top_improvement_percentage"`
+	// New orders get 423 while the circuit breaker has the project halted,
+	// instead of resting until trading resumes
+	RejectOrdersWhenHalted bool `json:"reject_orders_when_halted"`
+	Active                 bool `json:"-"`
+}
+
+func initProjectSettings(database *sql.DB) {
+	alterQueries := []string{
+		fmt.Sprintf(`ALTER TABLE projects ADD COLUMN IF NOT EXISTS price_precision INTEGER NOT NULL DEFAULT 2
+			CHECK (price_precision BETWEEN 0 AND %d)`, maxPricePrecision),
+		`ALTER TABLE projects ADD COLUMN IF NOT EXISTS min_quantity DECIMAL(18, 8) CHECK (min_quantity > 0)`,
+		`ALTER TABLE projects ADD COLUMN IF NOT EXISTS max_quantity DECIMAL(18, 8) CHECK (max_quantity > 0)`,
+		`ALTER TABLE projects ADD COLUMN IF NOT EXISTS max_notional DECIMAL(24, 6) CHECK (max_notional > 0)`,
+		`ALTER TABLE projects ADD COLUMN IF NOT EXISTS price_band_percentage DECIMAL(6, 2) CHECK (price_band_percentage > 0)`,
+		`ALTER TABLE projects ADD COLUMN IF NOT EXISTS tick_size DECIMAL(18, 6) CHECK (tick_size > 0)`,
+		`ALTER TABLE projects ADD COLUMN IF NOT EXISTS allow_fractional BOOLEAN NOT NULL DEFAULT false`,
+		`ALTER TABLE projects ADD COLUMN IF NOT EXISTS min_top_improvement DECIMAL(18, 6) CHECK (min_top_improvement > 0)`,
+		`ALTER TABLE projects ADD COLUMN IF NOT EXISTS min_top_improvement_percentage DECIMAL(6, 2) CHECK (min_top_improvement_percentage > 0)`,
+		`ALTER TABLE projects ADD COLUMN IF NOT EXISTS reject_orders_when_halted BOOLEAN NOT NULL DEFAULT false`,
+	}
+
+	for _, query := range alterQueries {
+		if _, err := database.Exec(query); err != nil {
+			log.Printf("Warning: Could not update projects table: %v", err)
+		}
+	}
+
+	widenPriceColumns(database)
+}
+
+// Existing databases were created with DECIMAL(10, 2) prices; widen them once
+func widenPriceColumns(database *sql.DB) {
+	columns := []struct {
+		table  string
+		column string
+	}{
+		{"buyer", "price"},
+		{"seller", "price"},
+		{"top_buyer", "price"},
+		{"top_seller", "price"},
+		{"matched_orders", "seller_price"},
+		{"matched_orders", "buyer_price"},
+		{"match_assignments", "seller_price"},
+		{"buyer_order_history", "original_price"},
+		{"project_circuit_breakers", "day_open_price"},
+		{"project_circuit_breakers", "current_price"},
+	}
+
+	for _, c := range columns {
+		var scale sql.NullInt64
+		err := database.QueryRow(`
+			SELECT numeric_scale FROM information_schema.columns
+			WHERE table_name = $1 AND column_name = $2
+		`, c.table, c.column).Scan(&scale)
+		if err != nil {
+			log.Printf("Warning: Could not inspect %s.%s: %v", c.table, c.column, err)
+			continue
+		}
+		if scale.Valid && scale.Int64 >= maxPricePrecision {
+			continue
+		}
+
+		_, err = database.Exec(fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE DECIMAL(18, 6)", c.table, c.column))
+		if err != nil {
+			log.Printf("Warning: Could not widen %s.%s: %v", c.table, c.column, err)
+			continue
+		}
+		log.Printf("📐 Widened %s.%s to DECIMAL(18, 6)", c.table, c.column)
+	}
+}
+
+func getProjectTradingRules(database *sql.DB, projectID int) (*ProjectTradingRules, error) {
+	rules := &ProjectTradingRules{ProjectID: projectID}
+	var minQty, maxQty NullQuantity
+	var maxNotional, priceBand, tickSize, minTopImprovement, minTopImprovementPct sql.NullFloat64
+
+	err := database.QueryRow(`
+		SELECT price_precision, min_quantity, max_quantity, max_notional, price_band_percentage, tick_size,
+		       allow_fractional, min_top_improvement, min_top_improvement_percentage, reject_orders_when_halted, active
+		FROM projects WHERE id = $1
+	`, projectID).Scan(&rules.PricePrecision, &minQty, &maxQty, &maxNotional, &priceBand, &tickSize,
+		&rules.AllowFractional, &minTopImprovement, &minTopImprovementPct, &rules.RejectOrdersWhenHalted, &rules.Active)
+	if err != nil {
+		return nil, err
+	}
+
+	if minQty.Valid {
+		rules.MinQuantity = &minQty.Quantity
+	}
+	if maxQty.Valid {
+		rules.MaxQuantity = &maxQty.Quantity
+	}
+	if maxNotional.Valid {
+		rules.MaxNotional = &maxNotional.Float64
+	}
+	if priceBand.Valid {
+		rules.PriceBandPercentage = &priceBand.Float64
+	}
+	if tickSize.Valid {
+		rules.TickSize = &tickSize.Float64
+	}
+	if minTopImprovement.Valid {
+		rules.MinTopImprovement = &minTopImprovement.Float64
+	}
+	if minTopImprovementPct.Valid {
+		rules.MinTopImprovementPct = &minTopImprovementPct.Float64
+	}
+	return rules, nil
+}
+
+// Get a project's trading rules (admin)
+func getProjectTradingRulesHandler(w http.ResponseWriter, r *http.Request) {
+	projectID, err := strconv.Atoi(mux.Vars(r)["project_id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_PROJECT_ID", "Invalid project ID")
+		return
+	}
+
+	rules, err := getProjectTradingRules(db, projectID)
+	if err == sql.ErrNoRows {
+		writeJSONError(w, http.StatusNotFound, "PROJECT_NOT_FOUND", "Project not found")
+		return
+	} else if err != nil {
+		log.Println("Error fetching trading rules:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error fetching trading rules")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rules)
+}
+
+// Set a project's trading rules (admin). The body replaces all limits - omitted
+// or null limits are cleared. price_precision, allow_fractional and
+// reject_orders_when_halted are only changed when present.
+func setProjectTradingRules(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+
+	projectID, err := strconv.Atoi(mux.Vars(r)["project_id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_PROJECT_ID", "Invalid project ID")
+		return
+	}
+
+	var req struct {
+		PricePrecision      *int      `json:"price_precision"`
+		MinQuantity         *Quantity `json:"min_quantity"`
+		MaxQuantity         *Quantity `json:"max_quantity"`
+		MaxNotional         *float64  `json:"max_notional"`
+		PriceBandPercentage *float64  `json:"price_band_percentage"`
+		TickSize            *float64  `json:"tick_size"`
+		AllowFractional     *bool     `json:"allow_fractional"`
+
+		MinTopImprovement    *float64 `json:"min_top_improvement"`
+		MinTopImprovementPct *float64 `json:"min_top_improvement_percentage"`
+
+		RejectOrdersWhenHalted *bool `json:"reject_orders_when_halted"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyDecodeError(w, err)
+		return
+	}
+
+	if req.PricePrecision != nil && (*req.PricePrecision < 0 || *req.PricePrecision > maxPricePrecision) {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_TRADING_RULES", fmt.Sprintf("price_precision must be between 0 and %d", maxPricePrecision))
+		return
+	}
+	if (req.MinQuantity != nil && *req.MinQuantity <= 0) || (req.MaxQuantity != nil && *req.MaxQuantity <= 0) {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_TRADING_RULES", "min_quantity and max_quantity must be positive")
+		return
+	}
+	if req.MinQuantity != nil && req.MaxQuantity != nil && *req.MinQuantity > *req.MaxQuantity {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_TRADING_RULES", "min_quantity cannot exceed max_quantity")
+		return
+	}
+	if req.AllowFractional != nil && !*req.AllowFractional &&
+		((req.MinQuantity != nil && !req.MinQuantity.IsWhole()) || (req.MaxQuantity != nil && !req.MaxQuantity.IsWhole())) {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_TRADING_RULES", "min_quantity and max_quantity must be whole numbers when allow_fractional is false")
+		return
+	}
+	if req.MaxNotional != nil && *req.MaxNotional <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_TRADING_RULES", "max_notional must be positive")
+		return
+	}
+	if req.PriceBandPercentage != nil && (*req.PriceBandPercentage <= 0 || *req.PriceBandPercentage > 1000) {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_TRADING_RULES", "price_band_percentage must be greater than 0 and at most 1000")
+		return
+	}
+	if req.TickSize != nil && (scalePrice(*req.TickSize) <= 0 || validatePricePrecision(*req.TickSize, maxPricePrecision) != nil) {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_TRADING_RULES", fmt.Sprintf("tick_size must be positive with at most %d decimal places", maxPricePrecision))
+		return
+	}
+
+	if req.MinTopImprovement != nil && (scalePrice(*req.MinTopImprovement) <= 0 || validatePricePrecision(*req.MinTopImprovement, maxPricePrecision) != nil) {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_TRADING_RULES", fmt.Sprintf("min_top_improvement must be positive with at most %d decimal places", maxPricePrecision))
+		return
+	}
+	if req.MinTopImprovementPct != nil && (*req.MinTopImprovementPct <= 0 || *req.MinTopImprovementPct > 100) {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_TRADING_RULES", "min_top_improvement_percentage must be greater than 0 and at most 100")
+		return
+	}
+
+	result, err := db.Exec(`
+		UPDATE projects
+		SET price_precision = COALESCE($1, price_precision),
+		    min_quantity = $2, max_quantity = $3, max_notional = $4,
+		    price_band_percentage = $5, tick_size = $6,
+		    allow_fractional = COALESCE($7, allow_fractional),
+		    min_top_improvement = $8, min_top_improvement_percentage = $9,
+		    reject_orders_when_halted = COALESCE($10, reject_orders_when_halted)
+		WHERE id = $11
+	`, req.PricePrecision, req.MinQuantity, req.MaxQuantity, req.MaxNotional, req.PriceBandPercentage, req.TickSize,
+		req.AllowFractional, req.MinTopImprovement, req.MinTopImprovementPct, req.RejectOrdersWhenHalted, projectID)
+	if err != nil {
+		log.Println("Error updating trading rules:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error updating trading rules")
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		writeJSONError(w, http.StatusNotFound, "PROJECT_NOT_FOUND", "Project not found")
+		return
+	}
+
+	rules, err := getProjectTradingRules(db, projectID)
+	if err != nil {
+		log.Println("Error fetching trading rules:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error fetching trading rules")
+		return
+	}
+
+	log.Printf("📏 Trading rules updated for project %d by admin (User ID: %d)", projectID, userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Trading rules updated for project %d", projectID),
+		"rules":   rules,
+	})
+}
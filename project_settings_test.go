@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOrderSizeLimits(t *testing.T) {
+	openTestDB(t)
+	_, adminToken := createTestUser(t, "admin", true)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+
+	target := fmt.Sprintf("/api/v1/admin/projects/%d/trading-rules", defaultProjectID)
+	rec := doTestRequest(t, http.MethodPost, target, adminToken, map[string]interface{}{"min_quantity": 2, "max_quantity": 100, "max_notional": 500})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("set trading rules: status %d (%s)", rec.Code, rec.Body.String())
+	}
+
+	tests := []struct {
+		name     string
+		price    float64
+		quantity int
+		ok       bool
+	}{
+		{"below min", 10, 1, false},
+		{"above max", 1, 101, false},
+		{"over notional", 10, 60, false},
+		{"within limits", 10, 50, true},
+	}
+	for _, tt := range tests {
+		rec := postTestOrder(t, map[string]interface{}{"user_id": buyerUser, "role": "buyer", "price": tt.price, "quantity": tt.quantity})
+		if tt.ok && rec.Code != http.StatusCreated {
+			t.Errorf("%s: status %d (%s), want 201", tt.name, rec.Code, rec.Body.String())
+		}
+		if !tt.ok && (rec.Code != http.StatusBadRequest || errorCode(t, rec) != "INVALID_ORDER_SIZE") {
+			t.Errorf("%s: status %d (%s), want 400 INVALID_ORDER_SIZE", tt.name, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestPriceBandAroundLastTrade(t *testing.T) {
+	openTestDB(t)
+	_, adminToken := createTestUser(t, "admin", true)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+
+	target := fmt.Sprintf("/api/v1/admin/projects/%d/trading-rules", defaultProjectID)
+	rec := doTestRequest(t, http.MethodPost, target, adminToken, map[string]interface{}{"price_band_percentage": 10})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("set trading rules: status %d (%s)", rec.Code, rec.Body.String())
+	}
+
+	order := func(role string, price float64) *httptest.ResponseRecorder {
+		userID := buyerUser
+		if role == "seller" {
+			userID = sellerUser
+		}
+		return postTestOrder(t, map[string]interface{}{"user_id": userID, "role": role, "price": price, "quantity": 1})
+	}
+
+	// No trade yet, so there is nothing to band around
+	if rec := order("buyer", 1); rec.Code != http.StatusCreated {
+		t.Fatalf("order before any trade: status %d (%s), want 201", rec.Code, rec.Body.String())
+	}
+
+	tradeTestOrders(t, defaultProjectID, buyerUser, sellerUser, 10, 1)
+
+	// The in-band seller goes last - it crosses the resting buyers and moves the last trade
+	tests := []struct {
+		role  string
+		price float64
+		ok    bool
+	}{
+		{"buyer", 12, false},
+		{"seller", 8, false},
+		{"buyer", 10.5, true},
+		{"buyer", 11, true},
+		{"seller", 9.2, true},
+	}
+	for _, tt := range tests {
+		rec := order(tt.role, tt.price)
+		if tt.ok && rec.Code != http.StatusCreated {
+			t.Errorf("%s at %v: status %d (%s), want 201", tt.role, tt.price, rec.Code, rec.Body.String())
+		}
+		if !tt.ok {
+			if rec.Code != http.StatusBadRequest || errorCode(t, rec) != "PRICE_OUT_OF_BAND" {
+				t.Errorf("%s at %v: status %d (%s), want 400 PRICE_OUT_OF_BAND", tt.role, tt.price, rec.Code, rec.Body.String())
+			} else if !strings.Contains(rec.Body.String(), "9 - 11") {
+				t.Errorf("%s at %v: message %s does not give the allowed range 9 - 11", tt.role, tt.price, rec.Body.String())
+			}
+		}
+	}
+}
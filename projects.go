@@ -0,0 +1,211 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+type Project struct {
+	ID             int    `json:"id"`
+	Name           string `json:"name"`
+	Description    string `json:"description"`
+	PricePrecision int    `json:"price_precision"`
+	Active         bool   `json:"active"`
+}
+
+type projectRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+}
+
+// Orders and fills reference projects by id, so projects are deactivated
+// rather than deleted. Inactive projects reject new orders.
+func initProjectAdmin(database *sql.DB) {
+	queries := []string{
+		`ALTER TABLE projects ADD COLUMN IF NOT EXISTS active BOOLEAN NOT NULL DEFAULT true`,
+		// Names are unique regardless of case ("Green Energy" vs "green energy")
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_projects_name_lower ON projects (LOWER(name))`,
+	}
+
+	for _, query := range queries {
+		if _, err := database.Exec(query); err != nil {
+			log.Printf("Warning: Could not update projects table: %v", err)
+		}
+	}
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func getProjectByID(database *sql.DB, projectID int) (*Project, error) {
+	var p Project
+	var description sql.NullString
+	err := database.QueryRow(`
+		SELECT id, name, description, price_precision, active FROM projects WHERE id = $1
+	`, projectID).Scan(&p.ID, &p.Name, &description, &p.PricePrecision, &p.Active)
+	if err != nil {
+		return nil, err
+	}
+	p.Description = description.String
+	return &p, nil
+}
+
+// Create a project (admin)
+func createProjectHandler(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+
+	var req projectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyDecodeError(w, err)
+		return
+	}
+
+	if req.Name == nil || strings.TrimSpace(*req.Name) == "" {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_PROJECT_NAME", "Project name is required")
+		return
+	}
+	name := strings.TrimSpace(*req.Name)
+	if len(name) > 255 {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_PROJECT_NAME", "Project name must be at most 255 characters")
+		return
+	}
+	description := ""
+	if req.Description != nil {
+		description = *req.Description
+	}
+
+	var projectID int
+	err := db.QueryRow(`
+		INSERT INTO projects (name, description) VALUES ($1, $2) RETURNING id
+	`, name, description).Scan(&projectID)
+	if isUniqueViolation(err) {
+		writeJSONError(w, http.StatusConflict, "PROJECT_NAME_TAKEN", "A project with this name already exists")
+		return
+	} else if err != nil {
+		log.Println("Error creating project:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error creating project")
+		return
+	}
+
+	project, err := getProjectByID(db, projectID)
+	if err != nil {
+		log.Println("Error fetching project:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error fetching project")
+		return
+	}
+
+	log.Printf("🆕 Project %d (%s) created by admin (User ID: %d)", projectID, name, userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(project)
+}
+
+// Rename or re-describe a project (admin). Omitted fields are left unchanged;
+// "active": true in the body reactivates a deactivated project.
+func updateProjectHandler(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+
+	projectID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_PROJECT_ID", "Invalid project ID")
+		return
+	}
+
+	var req struct {
+		projectRequest
+		Active *bool `json:"active"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyDecodeError(w, err)
+		return
+	}
+
+	var name *string
+	if req.Name != nil {
+		trimmed := strings.TrimSpace(*req.Name)
+		if trimmed == "" {
+			writeJSONError(w, http.StatusBadRequest, "INVALID_PROJECT_NAME", "Project name cannot be empty")
+			return
+		}
+		if len(trimmed) > 255 {
+			writeJSONError(w, http.StatusBadRequest, "INVALID_PROJECT_NAME", "Project name must be at most 255 characters")
+			return
+		}
+		name = &trimmed
+	}
+
+	result, err := db.Exec(`
+		UPDATE projects
+		SET name = COALESCE($1, name),
+		    description = COALESCE($2, description),
+		    active = COALESCE($3, active)
+		WHERE id = $4
+	`, name, req.Description, req.Active, projectID)
+	if isUniqueViolation(err) {
+		writeJSONError(w, http.StatusConflict, "PROJECT_NAME_TAKEN", "A project with this name already exists")
+		return
+	} else if err != nil {
+		log.Println("Error updating project:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error updating project")
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		writeJSONError(w, http.StatusNotFound, "PROJECT_NOT_FOUND", "Project not found")
+		return
+	}
+
+	project, err := getProjectByID(db, projectID)
+	if err != nil {
+		log.Println("Error fetching project:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error fetching project")
+		return
+	}
+
+	log.Printf("✏️ Project %d updated by admin (User ID: %d)", projectID, userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(project)
+}
+
+// Deactivate a project (admin). Resting orders are left alone; only new
+// orders are rejected.
+func deactivateProjectHandler(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+
+	projectID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_PROJECT_ID", "Invalid project ID")
+		return
+	}
+
+	result, err := db.Exec(`UPDATE projects SET active = false WHERE id = $1`, projectID)
+	if err != nil {
+		log.Println("Error deactivating project:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error deactivating project")
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		writeJSONError(w, http.StatusNotFound, "PROJECT_NOT_FOUND", "Project not found")
+		return
+	}
+
+	log.Printf("🗄️ Project %d deactivated by admin (User ID: %d)", projectID, userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Project %d deactivated", projectID),
+	})
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// Projects listed by GET /projects, keyed by id; token may be empty
+func listTestProjects(t *testing.T, query, token string) map[int]Project {
+	t.Helper()
+	rec := doTestRequest(t, http.MethodGet, "/api/v1/projects"+query, token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list projects: status %d (%s)", rec.Code, rec.Body.String())
+	}
+	var projects []Project
+	decodeTestResponse(t, rec, &projects)
+	byID := map[int]Project{}
+	for _, p := range projects {
+		byID[p.ID] = p
+	}
+	return byID
+}
+
+func TestProjectCreateRenameDeactivate(t *testing.T) {
+	openTestDB(t)
+	_, adminToken := createTestUser(t, "admin", true)
+	traderID, traderToken := createTestUser(t, "trader", false)
+
+	if rec := doTestRequest(t, http.MethodPost, "/api/v1/admin/projects", traderToken, map[string]string{"name": "Solar"}); rec.Code != http.StatusForbidden {
+		t.Errorf("create as a non-admin: status %d, want 403", rec.Code)
+	}
+
+	rec := doTestRequest(t, http.MethodPost, "/api/v1/admin/projects", adminToken, map[string]string{"name": "Solar", "description": "PV farms"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: status %d (%s)", rec.Code, rec.Body.String())
+	}
+	var created Project
+	decodeTestResponse(t, rec, &created)
+	if created.Name != "Solar" || !created.Active {
+		t.Errorf("created project = %+v", created)
+	}
+	if rec := doTestRequest(t, http.MethodPost, "/api/v1/admin/projects", adminToken, map[string]string{"name": "solar"}); rec.Code != http.StatusConflict {
+		t.Errorf("duplicate name in another case: status %d, want 409", rec.Code)
+	}
+
+	target := fmt.Sprintf("/api/v1/admin/projects/%d", created.ID)
+	rec = doTestRequest(t, http.MethodPut, target, adminToken, map[string]string{"name": "Solar Power"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("rename: status %d (%s)", rec.Code, rec.Body.String())
+	}
+	var renamed Project
+	decodeTestResponse(t, rec, &renamed)
+	if renamed.Name != "Solar Power" || renamed.Description != "PV farms" {
+		t.Errorf("renamed project = %+v, want the new name and the description kept", renamed)
+	}
+
+	if rec := doTestRequest(t, http.MethodDelete, target, adminToken, nil); rec.Code != http.StatusOK {
+		t.Fatalf("deactivate: status %d (%s)", rec.Code, rec.Body.String())
+	}
+	if _, listed := listTestProjects(t, "", "")[created.ID]; listed {
+		t.Error("deactivated project still listed by default")
+	}
+	if p, listed := listTestProjects(t, "?include_inactive=true", adminToken)[created.ID]; !listed || p.Active {
+		t.Errorf("include_inactive listing = %+v (listed %v), want the project as inactive", p, listed)
+	}
+
+	rec = postTestOrder(t, map[string]interface{}{"user_id": traderID, "role": "buyer", "price": 10, "quantity": 1, "project_id": created.ID})
+	if rec.Code != http.StatusBadRequest || errorCode(t, rec) != "PROJECT_INACTIVE" {
+		t.Errorf("order in a deactivated project: status %d (%s), want 400 PROJECT_INACTIVE", rec.Code, rec.Body.String())
+	}
+}
+
+func TestConfiguredDefaultProjectReceivesOrdersWithoutOne(t *testing.T) {
+	openTestDB(t)
+	previous := defaultProjectID
+	defaultProjectID = 7
+	t.Cleanup(func() {
+		defaultProjectID = previous
+		ensureDefaultProject()
+	})
+	ensureDefaultProject()
+
+	var name string
+	if err := db.QueryRow("SELECT name FROM projects WHERE id = $1", defaultProjectID).Scan(&name); err != nil {
+		t.Fatalf("default project %d was not created: %v", defaultProjectID, err)
+	}
+
+	userID, _ := createTestUser(t, "trader", false)
+	order := placeTestOrder(t, Order{UserID: userID, Role: "buyer", Price: 10, Quantity: wholeQuantity(1)})
+
+	projectOf := func() int {
+		t.Helper()
+		var projectID int
+		err := db.QueryRow(`
+			SELECT project_id FROM buyer WHERE id = $1
+			UNION ALL
+			SELECT project_id FROM top_buyer WHERE order_id = $1
+		`, order.ID).Scan(&projectID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return projectID
+	}
+	if got := projectOf(); got != 7 {
+		t.Errorf("order without a project landed in project %d, want 7", got)
+	}
+
+	// Rows left with a NULL project by older writers are filed under it too
+	for _, q := range []string{
+		"UPDATE buyer SET project_id = NULL WHERE id = $1",
+		"UPDATE top_buyer SET project_id = NULL WHERE order_id = $1",
+	} {
+		if _, err := db.Exec(q, order.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cleanupNullProjectIds()
+	if got := projectOf(); got != 7 {
+		t.Errorf("NULL project_id cleaned up to %d, want 7", got)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Quantities are fixed-point with 8 decimal places, matching the
+// DECIMAL(18, 8) quantity columns, so fills add and subtract exactly. Whole-unit
+// projects (allow_fractional = false) only ever see multiples of quantityScale.
+// JSON and SQL see plain decimals: 5, 0.5, 12.25.
+type Quantity int64
+
+const (
+	quantityDecimals          = 8
+	quantityScale    Quantity = 100000000
+)
+
+// DECIMAL(18, 8) holds less than 10^10 units
+const maxQuantityScaled Quantity = 1000000000000000000
+
+func wholeQuantity(units int) Quantity {
+	return Quantity(units) * quantityScale
+}
+
+// Exact decimal parse of an order quantity; rejects more than 8 decimal places
+// instead of rounding, and anything a quantity column can't hold
+func parseQuantity(s string) (Quantity, error) {
+	q, err := parseDecimalQuantity(s)
+	if err != nil {
+		return 0, err
+	}
+	if q >= maxQuantityScaled || q <= -maxQuantityScaled {
+		return 0, fmt.Errorf("quantity %s is too large", s)
+	}
+	return q, nil
+}
+
+// parseQuantity without the column limit, for values read from the database -
+// a SUM over many orders can be larger than any single order
+func parseDecimalQuantity(s string) (Quantity, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return 0, fmt.Errorf("invalid quantity %q", s)
+	}
+	r.Mul(r, new(big.Rat).SetInt64(int64(quantityScale)))
+	if !r.IsInt() {
+		return 0, fmt.Errorf("quantity %s has more than %d decimal places", s, quantityDecimals)
+	}
+	if !r.Num().IsInt64() {
+		return 0, fmt.Errorf("quantity %s is too large", s)
+	}
+	return Quantity(r.Num().Int64()), nil
+}
+
+func (q Quantity) IsWhole() bool {
+	return q%quantityScale == 0
+}
+
+// For fees, notional and metrics only - never for quantity arithmetic
+func (q Quantity) Float64() float64 {
+	return float64(q) / float64(quantityScale)
+}
+
+func (q Quantity) String() string {
+	sign := ""
+	if q < 0 {
+		sign = "-"
+		q = -q
+	}
+	whole, frac := q/quantityScale, q%quantityScale
+	if frac == 0 {
+		return sign + strconv.FormatInt(int64(whole), 10)
+	}
+	fracStr := strings.TrimRight(fmt.Sprintf("%0*d", quantityDecimals, int64(frac)), "0")
+	return sign + strconv.FormatInt(int64(whole), 10) + "." + fracStr
+}
+
+func (q Quantity) MarshalJSON() ([]byte, error) {
+	return []byte(q.String()), nil
+}
+
+// Accepts a JSON number or a numeric string ("0.5")
+func (q *Quantity) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	parsed, err := parseQuantity(s)
+	if err != nil {
+		return err
+	}
+	*q = parsed
+	return nil
+}
+
+func (q *Quantity) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*q = 0
+	case int64:
+		*q = wholeQuantity(int(v))
+	case float64:
+		*q = Quantity(math.Round(v * float64(quantityScale)))
+	case []byte:
+		parsed, err := parseDecimalQuantity(string(v))
+		if err != nil {
+			return err
+		}
+		*q = parsed
+	case string:
+		parsed, err := parseDecimalQuantity(v)
+		if err != nil {
+			return err
+		}
+		*q = parsed
+	default:
+		return fmt.Errorf("cannot scan %T into Quantity", src)
+	}
+	return nil
+}
+
+func (q Quantity) Value() (driver.Value, error) {
+	return q.String(), nil
+}
+
+// Quantity counterpart of sql.NullInt64
+type NullQuantity struct {
+	Quantity Quantity
+	Valid    bool
+}
+
+func (n *NullQuantity) Scan(src interface{}) error {
+	if src == nil {
+		n.Quantity, n.Valid = 0, false
+		return nil
+	}
+	n.Valid = true
+	return n.Quantity.Scan(src)
+}
+
+func (n NullQuantity) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.Quantity.Value()
+}
+
+// Existing databases were created with INTEGER quantities; widen them once.
+// Runs after every table that holds a quantity has been created.
+func widenQuantityColumns(database *sql.DB) {
+	columns := []struct {
+		table  string
+		column string
+	}{
+		{"buyer", "quantity"},
+		{"seller", "quantity"},
+		{"top_buyer", "quantity"},
+		{"top_seller", "quantity"},
+		{"cancelled_orders", "quantity"},
+		{"matched_orders", "seller_qty"},
+		{"matched_orders", "buyer_qty"},
+		{"matched_orders", "matched_qty"},
+		{"match_assignments", "seller_total_qty"},
+		{"match_assignments", "assigned_qty"},
+		{"buyer_order_history", "original_qty"},
+		{"buyer_order_history", "total_matched_qty"},
+		{"buyer_order_history", "remaining_qty"},
+		{"seller_order_history", "original_qty"},
+		{"seller_order_history", "total_matched_qty"},
+		{"seller_order_history", "remaining_qty"},
+		{"projects", "min_quantity"},
+		{"projects", "max_quantity"},
+	}
+
+	for _, c := range columns {
+		var dataType string
+		err := database.QueryRow(`
+			SELECT data_type FROM information_schema.columns
+			WHERE table_name = $1 AND column_name = $2
+		`, c.table, c.column).Scan(&dataType)
+		if err != nil {
+			log.Printf("Warning: Could not inspect %s.%s: %v", c.table, c.column, err)
+			continue
+		}
+		if dataType == "numeric" {
+			continue
+		}
+
+		_, err = database.Exec(fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE DECIMAL(18, 8)", c.table, c.column))
+		if err != nil {
+			log.Printf("Warning: Could not widen %s.%s: %v", c.table, c.column, err)
+			continue
+		}
+		log.Printf("📐 Widened %s.%s to DECIMAL(18, 8)", c.table, c.column)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import "testing"
+
+func TestQuantityCapAppliesToInputOnly(t *testing.T) {
+	if _, err := parseQuantity("10000000000"); err == nil {
+		t.Error("parseQuantity accepted 10^10 units")
+	}
+	if q, err := parseQuantity("9999999999.5"); err != nil || q.String() != "9999999999.5" {
+		t.Errorf("parseQuantity(9999999999.5) = %s, %v", q, err)
+	}
+
+	// A sum read back from the database may exceed what one order can hold
+	var sum Quantity
+	if err := sum.Scan([]byte("25000000000.50000000")); err != nil {
+		t.Fatalf("Scan of a large sum: %v", err)
+	}
+	if sum.String() != "25000000000.5" {
+		t.Errorf("scanned %s, want 25000000000.5", sum)
+	}
+
+	if err := sum.Scan([]byte("0.123456789")); err == nil {
+		t.Error("Scan accepted more than 8 decimal places")
+	}
+}
+
+func TestHalfUnitFills(t *testing.T) {
+	openTestDB(t)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+	if _, err := db.Exec("UPDATE projects SET allow_fractional = true WHERE id = $1", defaultProjectID); err != nil {
+		t.Fatal(err)
+	}
+
+	half := wholeQuantity(1) / 2
+	buyer := placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 10, Quantity: 3 * half})
+	placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 10, Quantity: half})
+	if _, err := runMatching(db, defaultProjectID); err != nil {
+		t.Fatal(err)
+	}
+	if qty, _ := testOrderQuantity(t, "buyer", buyer.ID); qty != wholeQuantity(1) {
+		t.Fatalf("buyer quantity = %s after a 0.5 fill, want 1", qty)
+	}
+
+	placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 10, Quantity: 3 * half})
+	if _, err := runMatching(db, defaultProjectID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := testOrderQuantity(t, "buyer", buyer.ID); ok {
+		t.Error("buyer still resting after 1.5 filled")
+	}
+
+	var filled Quantity
+	if err := db.QueryRow("SELECT SUM(matched_qty) FROM matched_orders WHERE buyer_order_id = $1", buyer.ID).Scan(&filled); err != nil {
+		t.Fatal(err)
+	}
+	if filled != 3*half {
+		t.Errorf("filled %s, want 1.5", filled)
+	}
+	var seller Quantity
+	if err := db.QueryRow("SELECT quantity FROM top_seller UNION ALL SELECT quantity FROM seller").Scan(&seller); err != nil {
+		t.Fatal(err)
+	}
+	if seller != half {
+		t.Errorf("second seller has %s left, want 0.5", seller)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Upper bound on the queries behind a single request (DB_QUERY_TIMEOUT) and on
+// one matching run (MATCHING_RUN_TIMEOUT). A run that hits its deadline stops
+// like one that hits the iteration cap; the next run carries on.
+var (
+	requestQueryTimeout = getEnvDuration("DB_QUERY_TIMEOUT", 10*time.Second)
+	matchingRunTimeout  = getEnvDuration("MATCHING_RUN_TIMEOUT", 30*time.Second)
+)
+
+// Derived from r.Context(), so a client that disconnects also cancels its queries
+func requestQueryContext(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(r.Context(), requestQueryTimeout)
+}
+
+// 504 when the request's query deadline passed, otherwise a plain 500 with
+// message. The driver doesn't always wrap the context error, so ctx is
+// checked as well as err.
+func writeQueryError(w http.ResponseWriter, ctx context.Context, err error, message string) {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		log.Printf("⏱️ %s: query timed out after %v", message, requestQueryTimeout)
+		writeJSONError(w, http.StatusGatewayTimeout, "QUERY_TIMEOUT", message+": query timed out")
+		return
+	}
+	log.Printf("%s: %v", message, err)
+	writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", message)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestQueryHandlerFailsFastOnExpiredContext(t *testing.T) {
+	openTestDB(t)
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	req := httptest.NewRequest("GET", "/api/admin/analytics", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+
+	start := time.Now()
+	getOverallAnalytics(rec, req)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("handler took %v with an expired context", elapsed)
+	}
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("status = %d, want 504", rec.Code)
+	}
+	if code := errorCode(t, rec); code != "QUERY_TIMEOUT" {
+		t.Errorf("error code = %q, want QUERY_TIMEOUT", code)
+	}
+}
+
+func TestMatchingStopsOnCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var result MatchingRunResult
+	idle, err := matchProjectUntilDone(ctx, nil, defaultProjectID, &result, &matchPhaseTimings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idle || !result.TimedOut || result.Iterations != 0 {
+		t.Errorf("idle = %v, result = %+v, want a timed out run with no iterations", idle, result)
+	}
+}
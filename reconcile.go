@@ -0,0 +1,204 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// An order lives in exactly one of its main table (buyer/seller) or top table
+// (top_buyer/top_seller). Promotion deletes the main row, so a top entry with
+// no main row is the normal state; these are the states that are not.
+type ReconcileRoleReport struct {
+	Role string `json:"role"`
+	// Order ids present in both the main and the top table
+	Duplicates []int `json:"duplicates"`
+	// Top entries for orders that are already finished (no quantity left,
+	// or a buyer whose history is Completed/Cancelled)
+	Orphans []int `json:"orphans"`
+	// Main orders that rank inside the top 10 but were never promoted
+	MissedPromotions []int `json:"missed_promotions"`
+	TopCount         int   `json:"top_count"`
+	MainCount        int   `json:"main_count"`
+	// Top table is under-filled while main has orders waiting, or over capacity
+	CountMismatch bool `json:"count_mismatch"`
+}
+
+type ReconcileReport struct {
+	Consistent bool                  `json:"consistent"`
+	Roles      []ReconcileRoleReport `json:"roles"`
+}
+
+func buildReconcileReport(database *sql.DB) (*ReconcileReport, error) {
+	report := &ReconcileReport{Consistent: true}
+
+	for _, role := range []string{"buyer", "seller"} {
+		roleReport, err := reconcileRole(database, role)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", role, err)
+		}
+		if len(roleReport.Duplicates) > 0 || len(roleReport.Orphans) > 0 ||
+			len(roleReport.MissedPromotions) > 0 || roleReport.CountMismatch {
+			report.Consistent = false
+		}
+		report.Roles = append(report.Roles, *roleReport)
+	}
+
+	return report, nil
+}
+
+func reconcileRole(database *sql.DB, role string) (*ReconcileRoleReport, error) {
+	mainTable := getTableName(role)
+	topTable := getTopTableName(role)
+	report := &ReconcileRoleReport{Role: role}
+
+	var err error
+	report.Duplicates, err = queryIDs(database, fmt.Sprintf(`
+		SELECT t.order_id FROM %s t JOIN %s m ON m.id = t.order_id ORDER BY t.order_id
+	`, topTable, mainTable))
+	if err != nil {
+		return nil, fmt.Errorf("duplicate check failed: %v", err)
+	}
+
+	orphanQuery := fmt.Sprintf(`SELECT order_id FROM %s WHERE quantity <= 0`, topTable)
+	if role == "buyer" {
+		orphanQuery += `
+			UNION
+			SELECT t.order_id FROM top_buyer t
+			JOIN buyer_order_history h ON h.buyer_order_id = t.order_id
+			WHERE h.status IN ('Completed', 'Cancelled')`
+	}
+	report.Orphans, err = queryIDs(database, orphanQuery+" ORDER BY 1")
+	if err != nil {
+		return nil, fmt.Errorf("orphan check failed: %v", err)
+	}
+
+	// Rank both tables together with the same ordering sync uses; any main row
+	// that lands in the first 10 should have been promoted
+	priceOrder := "price DESC"
+	if role == "seller" {
+		priceOrder = "price ASC"
+	}
+	report.MissedPromotions, err = queryIDs(database, fmt.Sprintf(`
+		SELECT id FROM (
+			SELECT id, false AS in_top, market_lead_program, price, quantity, trade_date, trade_time, created_at FROM %s
+			UNION ALL
+			SELECT order_id, true, market_lead_program, price, quantity, trade_date, trade_time, created_at FROM %s
+			ORDER BY market_lead_program DESC, %s, quantity DESC, trade_date ASC, trade_time ASC, created_at ASC, id ASC
+			LIMIT 10
+		) ranked
+		WHERE NOT in_top
+		ORDER BY id
+	`, mainTable, topTable, priceOrder))
+	if err != nil {
+		return nil, fmt.Errorf("promotion check failed: %v", err)
+	}
+
+	err = database.QueryRow(fmt.Sprintf("SELECT (SELECT COUNT(*) FROM %s), (SELECT COUNT(*) FROM %s)",
+		topTable, mainTable)).Scan(&report.TopCount, &report.MainCount)
+	if err != nil {
+		return nil, fmt.Errorf("count check failed: %v", err)
+	}
+	report.CountMismatch = report.TopCount > 10 || (report.TopCount < 10 && report.MainCount > len(report.Duplicates))
+
+	return report, nil
+}
+
+func queryIDs(database *sql.DB, query string) ([]int, error) {
+	rows, err := database.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Drops stale main copies of promoted orders and dead top entries, then
+// refills both top tables
+func fixReconcileIssues(database *sql.DB, report *ReconcileReport) error {
+	for _, roleReport := range report.Roles {
+		mainTable := getTableName(roleReport.Role)
+		topTable := getTopTableName(roleReport.Role)
+
+		// The top row is authoritative - it is what the matcher reads and fills
+		for _, id := range roleReport.Duplicates {
+			if _, err := database.Exec(fmt.Sprintf("DELETE FROM %s WHERE id = $1", mainTable), id); err != nil {
+				return fmt.Errorf("removing duplicate %s #%d: %v", roleReport.Role, id, err)
+			}
+		}
+
+		for _, id := range roleReport.Orphans {
+			if _, err := database.Exec(fmt.Sprintf("DELETE FROM %s WHERE order_id = $1", topTable), id); err != nil {
+				return fmt.Errorf("pruning orphan %s #%d: %v", roleReport.Role, id, err)
+			}
+		}
+	}
+
+	// Refill the freed slots; a full re-rank would skip the projects'
+	// minimum top improvement
+	for _, role := range []string{"buyer", "seller"} {
+		if err := smartSyncTopOrders(database, role); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Report top/main table inconsistencies (admin)
+func getReconcileReport(w http.ResponseWriter, r *http.Request) {
+	report, err := buildReconcileReport(db)
+	if err != nil {
+		log.Println("Error building reconcile report:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error building reconcile report")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// Repair top/main table inconsistencies (admin)
+func fixReconcile(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+
+	found, err := buildReconcileReport(db)
+	if err != nil {
+		log.Println("Error building reconcile report:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error building reconcile report")
+		return
+	}
+
+	if err := fixReconcileIssues(db, found); err != nil {
+		log.Println("Error fixing reconcile issues:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error fixing reconcile issues")
+		return
+	}
+
+	after, err := buildReconcileReport(db)
+	if err != nil {
+		log.Println("Error building reconcile report:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error building reconcile report")
+		return
+	}
+
+	log.Printf("🩹 Top tables reconciled by admin (User ID: %d) - consistent before: %v, after: %v",
+		userID, found.Consistent, after.Consistent)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"found":   found,
+		"after":   after,
+	})
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type ActiveSession struct {
+	ID          int       `json:"id"`
+	UserID      int       `json:"user_id"`
+	Username    string    `json:"username"`
+	TokenPrefix string    `json:"token_prefix"` // never the full token
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// Enough of the token to tell sessions apart in a list, not enough to use it
+func truncateToken(token string) string {
+	if len(token) <= 8 {
+		return "…"
+	}
+	return token[:8] + "…"
+}
+
+// List unexpired sessions, newest first (admin)
+func listSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	rows, err := db.Query(`
+		SELECT s.id, s.user_id, u.username, s.token, s.created_at, s.expires_at
+		FROM sessions s
+		JOIN users u ON s.user_id = u.id
+		WHERE s.expires_at > $1
+		ORDER BY s.created_at DESC, s.id DESC
+	`, time.Now())
+	if err != nil {
+		log.Println("Error fetching sessions:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error fetching sessions")
+		return
+	}
+	defer rows.Close()
+
+	sessions := []ActiveSession{}
+	for rows.Next() {
+		var s ActiveSession
+		var sessionToken string
+		if err := rows.Scan(&s.ID, &s.UserID, &s.Username, &sessionToken, &s.CreatedAt, &s.ExpiresAt); err != nil {
+			log.Println("Error scanning row:", err)
+			continue
+		}
+		s.TokenPrefix = truncateToken(sessionToken)
+		sessions = append(sessions, s)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// Revoke a single session by id (admin)
+func revokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	adminID := userIDFromContext(r)
+
+	sessionID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_SESSION_ID", "Invalid session ID")
+		return
+	}
+
+	var userID int
+	err = db.QueryRow("DELETE FROM sessions WHERE id = $1 RETURNING user_id", sessionID).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeJSONError(w, http.StatusNotFound, "SESSION_NOT_FOUND", "Session not found")
+		} else {
+			log.Println("Error revoking session:", err)
+			writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error revoking session")
+		}
+		return
+	}
+
+	log.Printf("🔒 Session %d (User ID: %d) revoked by admin (User ID: %d)", sessionID, userID, adminID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Session %d revoked", sessionID),
+		"user_id": userID,
+	})
+}
+
+// Revoke every session of a user, e.g. after a compromised account (admin)
+func revokeUserSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	adminID := userIDFromContext(r)
+
+	userID, err := strconv.Atoi(mux.Vars(r)["user_id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_USER_ID", "Invalid user ID")
+		return
+	}
+
+	result, err := db.Exec("DELETE FROM sessions WHERE user_id = $1", userID)
+	if err != nil {
+		log.Println("Error revoking user sessions:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error revoking sessions")
+		return
+	}
+	revoked, _ := result.RowsAffected()
+
+	log.Printf("🔒 %d session(s) of User %d revoked by admin (User ID: %d)", revoked, userID, adminID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Revoked %d session(s) for user %d", revoked, userID),
+		"revoked": revoked,
+	})
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRevokedSessionsFailVerify(t *testing.T) {
+	openTestDB(t)
+	_, adminToken := createTestUser(t, "admin", true)
+	aliceID, aliceToken := createTestUser(t, "alice", false)
+	_, bobToken := createTestUser(t, "bob", false)
+
+	// A second login for alice
+	const aliceLaptop = "test-token-alice-laptop"
+	_, err := db.Exec(`INSERT INTO sessions (user_id, token, expires_at) VALUES ($1, $2, $3)`,
+		aliceID, aliceLaptop, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifies := func(token string) bool {
+		t.Helper()
+		return doTestRequest(t, http.MethodGet, "/api/v1/auth/verify", token, nil).Code == http.StatusOK
+	}
+
+	if rec := doTestRequest(t, http.MethodGet, "/api/v1/admin/sessions", aliceToken, nil); rec.Code != http.StatusForbidden {
+		t.Fatalf("non-admin session list: status %d, want 403", rec.Code)
+	}
+	rec := doTestRequest(t, http.MethodGet, "/api/v1/admin/sessions", adminToken, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list sessions: status %d (%s)", rec.Code, rec.Body.String())
+	}
+	var sessions []ActiveSession
+	decodeTestResponse(t, rec, &sessions)
+	laptopSession := 0
+	for _, s := range sessions {
+		if s.TokenPrefix == truncateToken(aliceLaptop) {
+			laptopSession = s.ID
+		}
+		if s.TokenPrefix == aliceLaptop || s.TokenPrefix == aliceToken {
+			t.Errorf("session %d lists the full token", s.ID)
+		}
+	}
+	if len(sessions) != 4 || laptopSession == 0 {
+		t.Fatalf("listed %d sessions (alice's laptop id %d), want 4 including it", len(sessions), laptopSession)
+	}
+
+	rec = doTestRequest(t, http.MethodDelete, fmt.Sprintf("/api/v1/admin/sessions/%d", laptopSession), adminToken, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("revoke session: status %d (%s)", rec.Code, rec.Body.String())
+	}
+	if verifies(aliceLaptop) {
+		t.Error("revoked session still verifies")
+	}
+	if !verifies(aliceToken) {
+		t.Error("revoking one session ended alice's other one")
+	}
+
+	rec = doTestRequest(t, http.MethodDelete, fmt.Sprintf("/api/v1/admin/sessions/user/%d", aliceID), adminToken, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("revoke user sessions: status %d (%s)", rec.Code, rec.Body.String())
+	}
+	if verifies(aliceToken) {
+		t.Error("alice's session verifies after revoking all of hers")
+	}
+	if !verifies(bobToken) {
+		t.Error("revoking alice's sessions ended bob's")
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// With SETTLEMENT_WORKFLOW=true new matches start out Pending and are moved to
+// Settled or Failed by an admin. Off by default, so matches keep being
+// written as Closed for existing clients.
+var settlementWorkflowEnabled = getEnv("SETTLEMENT_WORKFLOW", "false") == "true"
+
+const (
+	settlementPending = "Pending"
+	settlementSettled = "Settled"
+	settlementFailed  = "Failed"
+)
+
+// Statuses each settlement action may start from. A failed settlement can be
+// retried; Settled and the legacy Closed are final.
+var settlementTransitions = map[string][]string{
+	settlementSettled: {settlementPending, settlementFailed},
+	settlementFailed:  {settlementPending},
+}
+
+type Settlement struct {
+	MatchedOrderID int        `json:"matched_order_id"`
+	ProjectID      int        `json:"project_id"`
+	BuyerUserID    int        `json:"buyer_user_id"`
+	SellerUserID   int        `json:"seller_user_id"`
+	MatchedQty     Quantity   `json:"matched_qty"`
+	BuyerPrice     float64    `json:"buyer_price"`
+	SellerPrice    float64    `json:"seller_price"`
+	Status         string     `json:"status"`
+	SettledAt      *time.Time `json:"settled_at"`
+	Note           string     `json:"note"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func initSettlementColumns(database *sql.DB) {
+	queries := []string{
+		`ALTER TABLE matched_orders ADD COLUMN IF NOT EXISTS settled_at TIMESTAMP`,
+		`ALTER TABLE matched_orders ADD COLUMN IF NOT EXISTS settlement_note TEXT NOT NULL DEFAULT ''`,
+		`CREATE INDEX IF NOT EXISTS idx_matched_orders_status_created ON matched_orders (status, created_at)`,
+	}
+
+	for _, query := range queries {
+		if _, err := database.Exec(query); err != nil {
+			log.Printf("Warning: Could not add settlement columns: %v", err)
+		}
+	}
+}
+
+// Status written for a new match
+func newMatchStatus() string {
+	if settlementWorkflowEnabled {
+		return settlementPending
+	}
+	return "Closed"
+}
+
+const settlementColumns = `id, project_id, buyer_user_id, seller_user_id, matched_qty, buyer_price, seller_price,
+	COALESCE(status, 'Closed'), settled_at, settlement_note, created_at`
+
+func scanSettlement(row interface{ Scan(...interface{}) error }) (*Settlement, error) {
+	var s Settlement
+	var settledAt sql.NullTime
+	err := row.Scan(&s.MatchedOrderID, &s.ProjectID, &s.BuyerUserID, &s.SellerUserID, &s.MatchedQty,
+		&s.BuyerPrice, &s.SellerPrice, &s.Status, &settledAt, &s.Note, &s.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if settledAt.Valid {
+		s.SettledAt = &settledAt.Time
+	}
+	return &s, nil
+}
+
+var errSettlementTransition = errors.New("invalid settlement transition")
+
+// Moves a matched order to Settled or Failed if its current status allows it.
+// settled_at holds the time of the latest transition, successful or not.
+func transitionSettlement(database *sql.DB, matchedOrderID int, target, note string) (*Settlement, error) {
+	row := database.QueryRow(`
+		UPDATE matched_orders
+		SET status = $2, settled_at = CURRENT_TIMESTAMP, settlement_note = $3
+		WHERE id = $1 AND status = ANY($4)
+		RETURNING `+settlementColumns,
+		matchedOrderID, target, note, pq.Array(settlementTransitions[target]))
+	settlement, err := scanSettlement(row)
+	if err != sql.ErrNoRows {
+		return settlement, err
+	}
+
+	// Nothing updated: either no such match or it is in the wrong state
+	var status string
+	err = database.QueryRow(`SELECT COALESCE(status, 'Closed') FROM matched_orders WHERE id = $1`, matchedOrderID).Scan(&status)
+	if err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%w: matched order %d is %s", errSettlementTransition, matchedOrderID, status)
+}
+
+func settleMatchedOrder(w http.ResponseWriter, r *http.Request) {
+	handleSettlementTransition(w, r, settlementSettled)
+}
+
+func failMatchedOrder(w http.ResponseWriter, r *http.Request) {
+	handleSettlementTransition(w, r, settlementFailed)
+}
+
+// POST /api/admin/matched-orders/{id}/settle and /fail (admin). An optional
+// ?note= is stored with the match, e.g. why settlement failed.
+func handleSettlementTransition(w http.ResponseWriter, r *http.Request, target string) {
+	userID := userIDFromContext(r)
+
+	matchedOrderID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_MATCHED_ORDER_ID", "Invalid matched order ID")
+		return
+	}
+
+	note := strings.TrimSpace(r.URL.Query().Get("note"))
+
+	settlement, err := transitionSettlement(db, matchedOrderID, target, note)
+	if err == sql.ErrNoRows {
+		writeJSONError(w, http.StatusNotFound, "MATCHED_ORDER_NOT_FOUND", "Matched order not found")
+		return
+	} else if errors.Is(err, errSettlementTransition) {
+		writeJSONError(w, http.StatusConflict, "INVALID_SETTLEMENT_TRANSITION",
+			fmt.Sprintf("Cannot mark as %s: %v", target, err))
+		return
+	} else if err != nil {
+		log.Println("Error updating settlement:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error updating settlement")
+		return
+	}
+
+	log.Printf("🏦 Matched order #%d marked %s by admin (User ID: %d)", matchedOrderID, target, userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settlement)
+}
+
+// GET /api/admin/settlements?status=Pending&limit=100 - oldest first (admin)
+func getSettlements(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = settlementPending
+	}
+	if status != settlementPending && status != settlementSettled && status != settlementFailed {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_STATUS", "status must be one of Pending, Settled or Failed")
+		return
+	}
+
+	limit := 100
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > 1000 {
+			writeJSONError(w, http.StatusBadRequest, "INVALID_LIMIT", "limit must be between 1 and 1000")
+			return
+		}
+	}
+
+	rows, err := db.Query(`
+		SELECT `+settlementColumns+`
+		FROM matched_orders
+		WHERE status = $1
+		ORDER BY created_at ASC, id ASC
+		LIMIT $2
+	`, status, limit)
+	if err != nil {
+		log.Println("Error fetching settlements:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error fetching settlements")
+		return
+	}
+	defer rows.Close()
+
+	settlements := []Settlement{}
+	for rows.Next() {
+		s, err := scanSettlement(rows)
+		if err != nil {
+			log.Println("Error scanning row:", err)
+			continue
+		}
+		settlements = append(settlements, *s)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settlements)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestSettlementTransitions(t *testing.T) {
+	openTestDB(t)
+	_, adminToken := createTestUser(t, "admin", true)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+
+	closed := tradeTestOrders(t, defaultProjectID, buyerUser, sellerUser, 10, 1)
+	settlementWorkflowEnabled = true
+	t.Cleanup(func() { settlementWorkflowEnabled = false })
+	settled := tradeTestOrders(t, defaultProjectID, buyerUser, sellerUser, 10, 1)
+	failed := tradeTestOrders(t, defaultProjectID, buyerUser, sellerUser, 10, 1)
+
+	transition := func(matchedID int, action string, wantStatus int) Settlement {
+		t.Helper()
+		rec := doTestRequest(t, http.MethodPost, fmt.Sprintf("/api/v1/admin/matched-orders/%d/%s", matchedID, action), adminToken, nil)
+		if rec.Code != wantStatus {
+			t.Fatalf("%s #%d: status %d (%s), want %d", action, matchedID, rec.Code, rec.Body.String(), wantStatus)
+		}
+		var s Settlement
+		if wantStatus == http.StatusOK {
+			decodeTestResponse(t, rec, &s)
+		}
+		return s
+	}
+	listed := func(status string) []Settlement {
+		t.Helper()
+		rec := doTestRequest(t, http.MethodGet, "/api/v1/admin/settlements?status="+status, adminToken, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("list %s: status %d (%s)", status, rec.Code, rec.Body.String())
+		}
+		var list []Settlement
+		decodeTestResponse(t, rec, &list)
+		return list
+	}
+
+	if pending := listed(settlementPending); len(pending) != 2 || pending[0].MatchedOrderID != settled || pending[1].MatchedOrderID != failed {
+		t.Fatalf("pending = %+v, want #%d and #%d", pending, settled, failed)
+	}
+
+	if s := transition(settled, "settle", http.StatusOK); s.Status != settlementSettled || s.SettledAt == nil {
+		t.Errorf("settle = %s at %v, want Settled with a time", s.Status, s.SettledAt)
+	}
+	transition(settled, "settle", http.StatusConflict)
+	transition(settled, "fail", http.StatusConflict)
+
+	if s := transition(failed, "fail", http.StatusOK); s.Status != settlementFailed {
+		t.Errorf("fail = %s, want Failed", s.Status)
+	}
+	// A failed settlement can be retried
+	if s := transition(failed, "settle", http.StatusOK); s.Status != settlementSettled {
+		t.Errorf("retried settle = %s, want Settled", s.Status)
+	}
+
+	// Matches written as Closed before the workflow was on stay final
+	transition(closed, "settle", http.StatusConflict)
+	transition(999999, "settle", http.StatusNotFound)
+
+	if n := len(listed(settlementPending)); n != 0 {
+		t.Errorf("%d matches still pending", n)
+	}
+	if n := len(listed(settlementSettled)); n != 2 {
+		t.Errorf("%d settled matches, want 2", n)
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Everything a user traded over a date range, for tax and record keeping.
+// Positions are as of the end of the range, so they include earlier fills.
+type UserStatement struct {
+	UserID      int                `json:"user_id"`
+	From        string             `json:"from,omitempty"`
+	To          string             `json:"to,omitempty"`
+	GeneratedAt time.Time          `json:"generated_at"`
+	Trades      []MatchedOrder     `json:"trades"`
+	Assignments []SellerAssignment `json:"assignments"`
+	Positions   []Position         `json:"positions"`
+}
+
+// NULL for the zero time, so "$n::timestamp IS NULL" can leave a bound open
+func optionalTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// Calls fn for each match assignment the user took part in, as the seller or
+// as the buyer of the matched order, archived ones included, oldest first.
+// to is exclusive.
+func forEachStatementAssignment(ctx context.Context, database *sql.DB, userID int, from, to time.Time, fn func(SellerAssignment) error) error {
+	rows, err := database.QueryContext(ctx, `
+		SELECT ma.id, ma.buyer_order_id, ma.seller_order_id, ma.seller_user_id, ma.seller_transaction_id,
+		       ma.seller_total_qty, ma.assigned_qty, ma.seller_price, COALESCE(ma.matched_order_id, 0),
+		       ma.matched_transaction_type, ma.assigned_at,
+		       COALESCE(mo.buyer_transaction_id, ''), COALESCE(mo.execution_price, mo.seller_price, ma.seller_price), `+projectIDOrDefault("mo.project_id")+`
+		FROM match_assignments_all ma
+		LEFT JOIN matched_orders_all mo ON mo.id = ma.matched_order_id
+		WHERE (ma.seller_user_id = $1 OR mo.buyer_user_id = $1)
+		AND ($2::timestamp IS NULL OR ma.assigned_at >= $2)
+		AND ($3::timestamp IS NULL OR ma.assigned_at < $3)
+		ORDER BY ma.assigned_at ASC, ma.id ASC
+	`, userID, optionalTime(from), optionalTime(to))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sa SellerAssignment
+		if err := rows.Scan(&sa.ID, &sa.BuyerOrderID, &sa.SellerOrderID, &sa.SellerUserID,
+			&sa.SellerTransactionID, &sa.SellerTotalQty, &sa.AssignedQty,
+			&sa.SellerPrice, &sa.MatchedOrderID, &sa.MatchedTxnType, &sa.AssignedAt,
+			&sa.BuyerTransactionID, &sa.TradePrice, &sa.ProjectID); err != nil {
+			return err
+		}
+		if err := fn(sa); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Parses ?from= and ?to= (YYYY-MM-DD, both inclusive, either optional).
+// The returned to is the start of the following day.
+func parseStatementRange(r *http.Request) (from, to time.Time, err error) {
+	if s := r.URL.Query().Get("from"); s != "" {
+		if from, err = time.Parse("2006-01-02", s); err != nil {
+			return from, to, fmt.Errorf("from must be a date (YYYY-MM-DD)")
+		}
+	}
+	if s := r.URL.Query().Get("to"); s != "" {
+		if to, err = time.Parse("2006-01-02", s); err != nil {
+			return from, to, fmt.Errorf("to must be a date (YYYY-MM-DD)")
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return from, to, fmt.Errorf("from cannot be after to")
+	}
+	return from, to, nil
+}
+
+// GET /api/statement/user/{user_id}?from=&to=&format=json|csv - the user's
+// trades, match assignments and positions in one download (owner or admin)
+func getUserStatementHandler(w http.ResponseWriter, r *http.Request) {
+	requesterID := userIDFromContext(r)
+
+	userID, err := strconv.Atoi(mux.Vars(r)["user_id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_USER_ID", "Invalid user ID")
+		return
+	}
+
+	if userID != requesterID && !isAdmin(requesterID, db) {
+		writeJSONError(w, http.StatusForbidden, "FORBIDDEN", "Forbidden: You can only view your own statement")
+		return
+	}
+
+	from, to, err := parseStatementRange(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_DATE_RANGE", err.Error())
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_FORMAT", "format must be json or csv")
+		return
+	}
+
+	positions, err := calculateUserPositionsAsOf(dbRead, userID, to)
+	if err != nil {
+		log.Println("Error calculating statement positions:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error generating statement")
+		return
+	}
+
+	if format == "csv" {
+		writeUserStatementCSV(w, r, userID, from, to, positions)
+		return
+	}
+
+	statement := &UserStatement{
+		UserID:      userID,
+		From:        r.URL.Query().Get("from"),
+		To:          r.URL.Query().Get("to"),
+		GeneratedAt: time.Now(),
+		Trades:      []MatchedOrder{},
+		Assignments: []SellerAssignment{},
+		Positions:   positions,
+	}
+	err = forEachMatchedOrderByUser(r.Context(), dbRead, userID, from, to, func(m MatchedOrder) error {
+		statement.Trades = append(statement.Trades, m)
+		return nil
+	})
+	if err == nil {
+		err = forEachStatementAssignment(r.Context(), dbRead, userID, from, to, func(sa SellerAssignment) error {
+			statement.Assignments = append(statement.Assignments, sa)
+			return nil
+		})
+	}
+	if err != nil {
+		log.Println("Error generating statement:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error generating statement")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statement)
+}
+
+// Streams the statement as one CSV with a section per record type. Each
+// section starts with its own header row; the first column names the record.
+// Rows are written as they are read, so a failure part way through can only
+// be logged - the 200 has already gone out.
+func writeUserStatementCSV(w http.ResponseWriter, r *http.Request, userID int, from, to time.Time, positions []Position) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="statement_user_%d.csv"`, userID))
+
+	cw := csv.NewWriter(w)
+	defer cw.Flush()
+
+	money := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+
+	cw.Write([]string{"record", "id", "project_id", "side", "execution_price", "matched_qty",
+		"fee", "status", "counterparty_user_id", "transaction_id", "created_at"})
+	err := forEachMatchedOrderByUser(r.Context(), dbRead, userID, from, to, func(m MatchedOrder) error {
+		role, side, counterparty, transactionID := "buyer", "buy", m.SellerUserID, m.BuyerTransactionID
+		if m.BuyerUserID != userID {
+			role, side, counterparty, transactionID = "seller", "sell", m.BuyerUserID, m.SellerTransactionID
+		}
+		fee := m.MakerFee
+		if m.TakerSide == role {
+			fee = m.TakerFee
+		}
+		return cw.Write([]string{"trade", strconv.Itoa(m.ID), strconv.Itoa(m.ProjectID), side,
+			money(m.ExecutionPrice), m.MatchedQty.String(), money(fee), m.Status,
+			strconv.Itoa(counterparty), transactionID, m.CreatedAt.Format(time.RFC3339)})
+	})
+	if err != nil {
+		log.Printf("❌ Statement CSV for user %d failed during trades: %v", userID, err)
+		return
+	}
+
+	cw.Write([]string{"record", "id", "project_id", "matched_order_id", "buyer_order_id", "seller_order_id",
+		"seller_user_id", "assigned_qty", "seller_price", "trade_price", "assigned_at"})
+	err = forEachStatementAssignment(r.Context(), dbRead, userID, from, to, func(sa SellerAssignment) error {
+		return cw.Write([]string{"assignment", strconv.Itoa(sa.ID), strconv.Itoa(sa.ProjectID),
+			strconv.Itoa(sa.MatchedOrderID), strconv.Itoa(sa.BuyerOrderID), strconv.Itoa(sa.SellerOrderID),
+			strconv.Itoa(sa.SellerUserID), sa.AssignedQty.String(), money(sa.SellerPrice),
+			money(sa.TradePrice), sa.AssignedAt.Format(time.RFC3339)})
+	})
+	if err != nil {
+		log.Printf("❌ Statement CSV for user %d failed during assignments: %v", userID, err)
+		return
+	}
+
+	cw.Write([]string{"record", "project_id", "project_name", "bought_qty", "sold_qty", "net_qty",
+		"avg_entry_price", "realized_pnl", "last_price", "unrealized_pnl", "status"})
+	for _, p := range positions {
+		cw.Write([]string{"position", strconv.Itoa(p.ProjectID), p.ProjectName, p.BoughtQty.String(),
+			p.SoldQty.String(), p.NetQty.String(), money(p.AvgEntryPrice), money(p.RealizedPnL),
+			money(p.LastPrice), money(p.UnrealizedPnL), p.Status})
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestUserStatementAcrossTwoProjects(t *testing.T) {
+	openTestDB(t)
+	buyerUser, buyerToken := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+	otherProject := createTestProject(t, "Other")
+
+	old := tradeTestOrders(t, defaultProjectID, buyerUser, sellerUser, 10, 3)
+	tradeTestOrders(t, otherProject, buyerUser, sellerUser, 20, 2)
+	waitForTestCount(t, "match_assignments", 2)
+
+	// The first trade has since been archived
+	if _, err := db.Exec("UPDATE matched_orders SET created_at = created_at - INTERVAL '10 days' WHERE id = $1", old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := archiveOldTrades(db, 5, true); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := doTestRequest(t, http.MethodGet, fmt.Sprintf("/api/statement/user/%d", buyerUser), buyerToken, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d (%s), want 200", rec.Code, rec.Body.String())
+	}
+	var statement UserStatement
+	decodeTestResponse(t, rec, &statement)
+
+	if len(statement.Trades) != 2 || len(statement.Assignments) != 2 {
+		t.Fatalf("statement has %d trades, %d assignments; want 2 and 2", len(statement.Trades), len(statement.Assignments))
+	}
+	projects := map[int]bool{}
+	for _, trade := range statement.Trades {
+		projects[trade.ProjectID] = true
+	}
+	if !projects[defaultProjectID] || !projects[otherProject] {
+		t.Errorf("trades cover projects %v, want %d and %d", projects, defaultProjectID, otherProject)
+	}
+
+	if len(statement.Positions) != 2 {
+		t.Fatalf("positions = %+v, want one per project", statement.Positions)
+	}
+	for _, p := range statement.Positions {
+		want := wholeQuantity(3)
+		if p.ProjectID == otherProject {
+			want = wholeQuantity(2)
+		}
+		if p.NetQty != want {
+			t.Errorf("project %d net quantity = %s, want %s", p.ProjectID, p.NetQty, want)
+		}
+	}
+}
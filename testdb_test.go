@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Database tests run against the scratch PostgreSQL database in
+// TEST_DATABASE_URL and are skipped without it. Every table except the fee and
+// matching config is emptied before each test, so never point it at real data.
+var (
+	testDBOnce sync.Once
+	testDBErr  error
+)
+
+// Opens the test database (once per run), migrates it and empties it. The
+// package globals db and dbRead both point at it afterwards.
+func openTestDB(t testing.TB) *sql.DB {
+	t.Helper()
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set - skipping database test")
+	}
+
+	testDBOnce.Do(func() {
+		connStr, _, err := postgresConnString(databaseURL)
+		if err != nil {
+			testDBErr = err
+			return
+		}
+		if db, err = sql.Open("postgres", connStr); err != nil {
+			testDBErr = err
+			return
+		}
+		if testDBErr = db.Ping(); testDBErr != nil {
+			return
+		}
+		dbRead = db
+		initSchema()
+		testDBErr = ensurePreparedStatements(db)
+	})
+	if testDBErr != nil {
+		t.Fatalf("test database: %v", testDBErr)
+	}
+
+	resetTestDB(t)
+	return db
+}
+
+// Empties every table but the fee and matching config, recreates the default
+// project and clears the in-memory state that mirrors the database
+func resetTestDB(t testing.TB) {
+	t.Helper()
+	_, err := db.Exec(`
+		DO $$
+		DECLARE r RECORD;
+		BEGIN
+			FOR r IN SELECT tablename FROM pg_tables
+			         WHERE schemaname = current_schema()
+			         AND tablename NOT IN ('fee_config', 'matching_config') LOOP
+				EXECUTE 'TRUNCATE TABLE ' || quote_ident(r.tablename) || ' RESTART IDENTITY CASCADE';
+			END LOOP;
+		END $$
+	`)
+	if err != nil {
+		t.Fatalf("reset test database: %v", err)
+	}
+	ensureDefaultProject()
+
+	breakerCacheMutex.Lock()
+	breakerCache = make(map[int]bool)
+	breakerMLPExempt = make(map[int]bool)
+	breakerCacheMutex.Unlock()
+
+	tickerMutex.Lock()
+	tickerCache = map[int]*TickerEntry{}
+	tickerMutex.Unlock()
+
+	matchingEnabledMutex.Lock()
+	matchingEnabled, matchingPauseReason = true, ""
+	matchingEnabledMutex.Unlock()
+}
+
+// Inserts a user with a live session and returns the id and bearer token
+func createTestUser(t testing.TB, username string, admin bool) (int, string) {
+	t.Helper()
+	var userID int
+	err := db.QueryRow(`
+		INSERT INTO users (username, email, password, is_admin) VALUES ($1, $2, 'x', $3) RETURNING id
+	`, username, username+"@example.com", admin).Scan(&userID)
+	if err != nil {
+		t.Fatalf("create user %s: %v", username, err)
+	}
+
+	token := fmt.Sprintf("test-token-%s", username)
+	_, err = db.Exec(`INSERT INTO sessions (user_id, token, expires_at) VALUES ($1, $2, $3)`,
+		userID, token, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("create session for %s: %v", username, err)
+	}
+	return userID, token
+}
+
+// Inserts a project and returns its id
+func createTestProject(t testing.TB, name string) int {
+	t.Helper()
+	var projectID int
+	if err := db.QueryRow("INSERT INTO projects (name) VALUES ($1) RETURNING id", name).Scan(&projectID); err != nil {
+		t.Fatalf("create project %s: %v", name, err)
+	}
+	return projectID
+}
+
+// Places o the way createOrder does, without matching. Date, time and kind
+// default to today, now and limit.
+func placeTestOrder(t testing.TB, o Order) Order {
+	t.Helper()
+	if o.TradeDate == "" {
+		o.TradeDate = time.Now().Format("2006-01-02")
+	}
+	if o.TradeTime == "" {
+		o.TradeTime = time.Now().Format("15:04:05")
+	}
+	if o.OrderKind == "" {
+		o.OrderKind = "limit"
+	}
+	projectID := defaultProjectID
+	if o.ProjectID != nil {
+		projectID = *o.ProjectID
+	}
+	rules, err := getProjectTradingRules(db, projectID)
+	if err != nil {
+		t.Fatalf("load project %d rules: %v", projectID, err)
+	}
+	if err := intelligentOrderInsertion(db, &o, rules, ""); err != nil {
+		t.Fatalf("place %s order: %v", o.Role, err)
+	}
+	return o
+}
+
+// Places a crossing seller and buyer for qty units at price in the project,
+// matches them and returns the matched order's id
+func tradeTestOrders(t testing.TB, projectID, buyerUser, sellerUser int, price float64, qty int) int {
+	t.Helper()
+	seller := placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: price, Quantity: wholeQuantity(qty), ProjectID: intPtr(projectID)})
+	placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: price, Quantity: wholeQuantity(qty), ProjectID: intPtr(projectID)})
+	if _, err := runMatching(db, projectID); err != nil {
+		t.Fatalf("matching: %v", err)
+	}
+
+	var matchedID int
+	err := db.QueryRow("SELECT id FROM matched_orders WHERE seller_order_id = $1 ORDER BY id DESC LIMIT 1", seller.ID).Scan(&matchedID)
+	if err != nil {
+		t.Fatalf("trade at %v did not match: %v", price, err)
+	}
+	return matchedID
+}
+
+// Current quantity of an order in its main or top table; ok is false once
+// the order is gone from both
+func testOrderQuantity(t testing.TB, role string, orderID int) (qty Quantity, ok bool) {
+	t.Helper()
+	err := db.QueryRow(fmt.Sprintf(`
+		SELECT quantity FROM %s WHERE id = $1
+		UNION ALL
+		SELECT quantity FROM %s WHERE order_id = $1
+	`, getTableName(role), getTopTableName(role)), orderID).Scan(&qty)
+	if err == sql.ErrNoRows {
+		return 0, false
+	}
+	if err != nil {
+		t.Fatalf("read %s #%d quantity: %v", role, orderID, err)
+	}
+	return qty, true
+}
+
+// Number of rows in table
+func testCount(t testing.TB, table string) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", table)).Scan(&n); err != nil {
+		t.Fatalf("count %s: %v", table, err)
+	}
+	return n
+}
+
+// Waits up to five seconds for table to hold n rows, for writes the matcher
+// makes in the background such as match assignments
+func waitForTestCount(t testing.TB, table string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for testCount(t, table) != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s rows = %d after 5s, want %d", table, testCount(t, table), n)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+var (
+	testHandler     http.Handler
+	testHandlerOnce sync.Once
+)
+
+// Sends a request through the full HTTP stack (routes, middleware, CORS).
+// body is sent as is when it is a string, JSON-encoded otherwise; token,
+// when set, goes in the Authorization header.
+func doTestRequest(t testing.TB, method, target, token string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	testHandlerOnce.Do(func() { testHandler = newHandler() })
+
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(b)
+	default:
+		payload, err := json.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req := httptest.NewRequest(method, target, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	testHandler.ServeHTTP(rec, req)
+	return rec
+}
+
+// Decodes a JSON response body into v
+func decodeTestResponse(t testing.TB, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode response %q: %v", rec.Body.String(), err)
+	}
+}
+
+// Code of a writeJSONError response
+func errorCode(t testing.TB, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body map[string]apiError
+	decodeTestResponse(t, rec, &body)
+	return body["error"].Code
+}
+
+func intPtr(v int) *int { return &v }
+
+// Statement executions and prepares seen by a counting pool
+type testQueryCounts struct {
+	executions, prepares int64
+}
+
+// Wraps lib/pq so a benchmark can count what reaches the server. The wrapper
+// only implements the basic driver interfaces, so database/sql routes every
+// query and exec through Prepare and a statement, where it is counted.
+type countingTestDriver struct{ counts *testQueryCounts }
+
+func (d countingTestDriver) Open(name string) (driver.Conn, error) {
+	conn, err := pq.Driver{}.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return countingTestConn{conn, d.counts}, nil
+}
+
+type countingTestConn struct {
+	driver.Conn
+	counts *testQueryCounts
+}
+
+func (c countingTestConn) Prepare(query string) (driver.Stmt, error) {
+	atomic.AddInt64(&c.counts.prepares, 1)
+	stmt, err := c.Conn.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return countingTestStmt{stmt, c.counts}, nil
+}
+
+type countingTestStmt struct {
+	driver.Stmt
+	counts *testQueryCounts
+}
+
+func (s countingTestStmt) Exec(args []driver.Value) (driver.Result, error) {
+	atomic.AddInt64(&s.counts.executions, 1)
+	return s.Stmt.Exec(args)
+}
+
+func (s countingTestStmt) Query(args []driver.Value) (driver.Rows, error) {
+	atomic.AddInt64(&s.counts.executions, 1)
+	return s.Stmt.Query(args)
+}
+
+// Hands out counting connections without registering a global driver name
+type countingTestConnector struct {
+	name   string
+	driver countingTestDriver
+}
+
+func (c countingTestConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.name)
+}
+
+func (c countingTestConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// Opens a second pool on the test database whose statements are counted.
+// The matcher's prepared statements move to it until the benchmark ends.
+func openCountingTestDB(b *testing.B) (*sql.DB, *testQueryCounts) {
+	b.Helper()
+	openTestDB(b)
+	connStr, _, err := postgresConnString(os.Getenv("TEST_DATABASE_URL"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	counts := &testQueryCounts{}
+	counting := sql.OpenDB(countingTestConnector{connStr, countingTestDriver{counts}})
+	b.Cleanup(func() {
+		ensurePreparedStatements(db)
+		counting.Close()
+	})
+	return counting, counts
+}
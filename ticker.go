@@ -0,0 +1,130 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type TickerEntry struct {
+	ProjectID     int       `json:"project_id"`
+	LastPrice     float64   `json:"last_price"` // Mid of buyer and seller price, as in analytics
+	LastTradeTime time.Time `json:"last_trade_time"`
+	TodayVolume   Quantity  `json:"today_volume"`
+}
+
+// Last trade per project, kept in memory so the ticker never reads matched_orders.
+// Updated by matchOrders right after each match commits.
+var (
+	tickerCache = map[int]*TickerEntry{}
+	tickerDay   string // Server-local date TodayVolume belongs to
+	tickerMutex sync.RWMutex
+)
+
+// Rebuilds the cache from matched_orders (startup, and after data is cleared)
+func loadTickerCache(database *sql.DB) error {
+	today := time.Now().Format("2006-01-02")
+
+	rows, err := database.Query(`
+		SELECT DISTINCT ON (project_id)
+		       project_id, (buyer_price + seller_price) / 2, created_at,
+		       (SELECT COALESCE(SUM(v.matched_qty), 0) FROM matched_orders v
+		        WHERE v.project_id = m.project_id AND v.created_at >= $1::date AND `+countedTradeCondition+`)
+		FROM matched_orders m
+		WHERE project_id IS NOT NULL
+		AND `+countedTradeCondition+`
+		ORDER BY project_id, created_at DESC, id DESC
+	`, today)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	cache := map[int]*TickerEntry{}
+	for rows.Next() {
+		var entry TickerEntry
+		if err := rows.Scan(&entry.ProjectID, &entry.LastPrice, &entry.LastTradeTime, &entry.TodayVolume); err != nil {
+			return err
+		}
+		cache[entry.ProjectID] = &entry
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	tickerMutex.Lock()
+	tickerCache = cache
+	tickerDay = today
+	tickerMutex.Unlock()
+
+	log.Printf("📈 Ticker cache loaded for %d projects", len(cache))
+	return nil
+}
+
+func recordTickerTrade(projectID int, price float64, qty Quantity, at time.Time) {
+	tickerMutex.Lock()
+	defer tickerMutex.Unlock()
+
+	// New day: yesterday's volume no longer counts
+	if day := at.Format("2006-01-02"); day != tickerDay {
+		for _, entry := range tickerCache {
+			entry.TodayVolume = 0
+		}
+		tickerDay = day
+	}
+
+	entry, ok := tickerCache[projectID]
+	if !ok {
+		entry = &TickerEntry{ProjectID: projectID}
+		tickerCache[projectID] = entry
+	}
+	entry.LastPrice = price
+	entry.LastTradeTime = at
+	entry.TodayVolume += qty
+}
+
+func tickerSnapshot() []TickerEntry {
+	tickerMutex.RLock()
+	defer tickerMutex.RUnlock()
+
+	stale := time.Now().Format("2006-01-02") != tickerDay
+	entries := make([]TickerEntry, 0, len(tickerCache))
+	for _, entry := range tickerCache {
+		e := *entry
+		if stale {
+			e.TodayVolume = 0
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ProjectID < entries[j].ProjectID })
+	return entries
+}
+
+// GET /api/ticker?project_id= - last price, last trade time and today's volume
+// per project, served from memory
+func getTicker(w http.ResponseWriter, r *http.Request) {
+	entries := tickerSnapshot()
+
+	if projectIDStr := r.URL.Query().Get("project_id"); projectIDStr != "" {
+		projectID, err := strconv.Atoi(projectIDStr)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "INVALID_PROJECT_ID", "Invalid project ID")
+			return
+		}
+		filtered := []TickerEntry{}
+		for _, entry := range entries {
+			if entry.ProjectID == projectID {
+				filtered = append(filtered, entry)
+			}
+		}
+		entries = filtered
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
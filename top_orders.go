@@ -4,7 +4,10 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"math"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 func initTopOrdersTables(database *sql.DB) {
@@ -14,13 +17,14 @@ func initTopOrdersTables(database *sql.DB) {
 			order_id INTEGER NOT NULL,
 			user_id INTEGER NOT NULL,
 			transaction_id VARCHAR(8) NOT NULL,
-			price DECIMAL(10, 2) NOT NULL,
-			quantity INTEGER NOT NULL,
+			price DECIMAL(18, 6) NOT NULL,
+			quantity DECIMAL(18, 8) NOT NULL,
 			trade_date DATE NOT NULL,
 			trade_time TIME NOT NULL,
 			transaction_type INTEGER NOT NULL,
 			match_type INTEGER NOT NULL DEFAULT 0,
 			market_lead_program BOOLEAN NOT NULL DEFAULT false,
+			order_kind VARCHAR(10) NOT NULL DEFAULT 'limit',
 			project_id INTEGER DEFAULT 1,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			UNIQUE(order_id)
@@ -30,13 +34,14 @@ func initTopOrdersTables(database *sql.DB) {
 			order_id INTEGER NOT NULL,
 			user_id INTEGER NOT NULL,
 			transaction_id VARCHAR(8) NOT NULL,
-			price DECIMAL(10, 2) NOT NULL,
-			quantity INTEGER NOT NULL,
+			price DECIMAL(18, 6) NOT NULL,
+			quantity DECIMAL(18, 8) NOT NULL,
 			trade_date DATE NOT NULL,
 			trade_time TIME NOT NULL,
 			transaction_type INTEGER NOT NULL,
 			match_type INTEGER NOT NULL DEFAULT 0,
 			market_lead_program BOOLEAN NOT NULL DEFAULT false,
+			order_kind VARCHAR(10) NOT NULL DEFAULT 'limit',
 			project_id INTEGER DEFAULT 1,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			UNIQUE(order_id)
@@ -54,9 +59,17 @@ func initTopOrdersTables(database *sql.DB) {
 		`ALTER TABLE top_buyer ADD COLUMN IF NOT EXISTS match_type INTEGER NOT NULL DEFAULT 0`,
 		`ALTER TABLE top_buyer ADD COLUMN IF NOT EXISTS market_lead_program BOOLEAN NOT NULL DEFAULT false`,
 		`ALTER TABLE top_buyer ADD COLUMN IF NOT EXISTS project_id INTEGER DEFAULT 1`,
+		`ALTER TABLE top_buyer ADD COLUMN IF NOT EXISTS order_kind VARCHAR(10) NOT NULL DEFAULT 'limit'`,
 		`ALTER TABLE top_seller ADD COLUMN IF NOT EXISTS match_type INTEGER NOT NULL DEFAULT 0`,
 		`ALTER TABLE top_seller ADD COLUMN IF NOT EXISTS market_lead_program BOOLEAN NOT NULL DEFAULT false`,
 		`ALTER TABLE top_seller ADD COLUMN IF NOT EXISTS project_id INTEGER DEFAULT 1`,
+		`ALTER TABLE top_seller ADD COLUMN IF NOT EXISTS order_kind VARCHAR(10) NOT NULL DEFAULT 'limit'`,
+		`ALTER TABLE top_buyer ADD COLUMN IF NOT EXISTS max_slippage_percentage DECIMAL(6, 2)`,
+		`ALTER TABLE top_seller ADD COLUMN IF NOT EXISTS max_slippage_percentage DECIMAL(6, 2)`,
+		`ALTER TABLE top_buyer ADD COLUMN IF NOT EXISTS good_till_date DATE`,
+		`ALTER TABLE top_seller ADD COLUMN IF NOT EXISTS good_till_date DATE`,
+		`ALTER TABLE top_buyer ADD COLUMN IF NOT EXISTS min_fill_quantity DECIMAL(18, 8)`,
+		`ALTER TABLE top_seller ADD COLUMN IF NOT EXISTS min_fill_quantity DECIMAL(18, 8)`,
 	}
 
 	for _, query := range alterQueries {
@@ -93,56 +106,106 @@ func initTopOrdersTables(database *sql.DB) {
 	log.Println("✅ All top orders tables and indexes created with project_id field")
 }
 
-func intelligentOrderInsertion(database *sql.DB, order *Order) error {
-	tableName := getTableName(order.Role)
-	topTableName := getTopTableName(order.Role)
-
-	if tableName == "" || topTableName == "" {
+func intelligentOrderInsertion(database *sql.DB, order *Order, rules *ProjectTradingRules, idempotencyKey string) error {
+	if getTableName(order.Role) == "" || getTopTableName(order.Role) == "" {
 		return fmt.Errorf("invalid role")
 	}
 
-	tx, err := database.Begin()
-	if err != nil {
-		return fmt.Errorf("transaction start failed: %v", err)
+	err := withRetry(database, func(tx *sql.Tx) error {
+		if err := insertOrderTx(tx, order, rules); err != nil {
+			return err
+		}
+		if idempotencyKey == "" {
+			return nil
+		}
+		return bindIdempotencyKeyTx(tx, order.UserID, idempotencyKey, order.ID)
+	})
+	if err == nil {
+		notifyOrderBookChanged(order.Role)
 	}
-	defer tx.Rollback()
+	return err
+}
+
+// Advisory lock namespace for top table changes; the second key is the role
+const topTableLockNamespace = 7301
+
+// Serializes count-then-swap changes to a role's top table until tx ends.
+// The 10-slot limit is shared by every project, so the lock is per role
+// rather than per (role, project) - two projects racing for the last slot
+// would otherwise still overflow it.
+func lockTopTableTx(tx *sql.Tx, role string) error {
+	roleKey := 1
+	if role == "seller" {
+		roleKey = 2
+	}
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1, $2)", topTableLockNamespace, roleKey); err != nil {
+		return fmt.Errorf("top table lock failed: %w", err)
+	}
+	return nil
+}
 
-	// Step 1: Insert into main table - NOW WITH PROJECT_ID
+// Inserts the order and its history row, and promotes it to the top table if
+// it qualifies. rules are the order's project's, already loaded by the caller.
+// Runs inside withRetry, so it may execute more than once per order.
+func insertOrderTx(tx *sql.Tx, order *Order, rules *ProjectTradingRules) error {
+	tableName := getTableName(order.Role)
+	topTableName := getTopTableName(order.Role)
+
+	// Step 1: Insert into main table - NOW WITH PROJECT_ID. created_at is only
+	// preset for imported historical orders; everything else gets the current time.
 	query := fmt.Sprintf(`
-		INSERT INTO %s (user_id, price, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, project_id)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		INSERT INTO %s (user_id, price, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, order_kind, project_id, created_at, max_slippage_percentage, good_till_date, min_fill_quantity)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, COALESCE($11, LOCALTIMESTAMP), $12, $13, $14)
 		RETURNING id, transaction_id, created_at
 	`, tableName)
 
+	var createdAt interface{}
+	if !order.CreatedAt.IsZero() {
+		createdAt = order.CreatedAt
+	}
+
 	var projectID int
 	if order.ProjectID != nil {
 		projectID = *order.ProjectID
 	} else {
-		projectID = 1 // Default to project 1 if not provided
+		projectID = defaultProjectID
 	}
 
 	// Fix: order is now a pointer, so updates here reflect in main.go
-	err = tx.QueryRow(query, order.UserID, order.Price, order.Quantity,
-		order.TradeDate, order.TradeTime, order.TransactionType, order.MatchType, order.MarketLeadProgram, projectID).
+	err := tx.QueryRow(query, order.UserID, order.Price, order.Quantity,
+		order.TradeDate, order.TradeTime, order.TransactionType, order.MatchType, order.MarketLeadProgram, order.OrderKind, projectID, createdAt, order.MaxSlippagePct, order.GoodTillDate, order.MinFillQty).
 		Scan(&order.ID, &order.TransactionID, &order.CreatedAt)
 
 	if err != nil {
-		return fmt.Errorf("main table insert failed: %v", err)
+		return fmt.Errorf("main table insert failed: %w", err)
+	}
+
+	if order.Role == "buyer" {
+		err = recordBuyerOrderHistoryTx(tx, *order)
+	} else {
+		err = recordSellerOrderHistoryTx(tx, *order)
+	}
+	if err != nil {
+		return fmt.Errorf("order history insert failed: %w", err)
 	}
 
 	mlpIndicator := ""
 	if order.MarketLeadProgram {
 		mlpIndicator = " ⭐ MLP"
 	}
-	log.Printf("🎯 New %s order #%d%s (TXN: %s, price: $%.2f, qty: %d, date: %s, time: %s, user: %d, match_type: %d, project: %d)",
+	log.Printf("🎯 New %s order #%d%s (TXN: %s, price: $%.2f, qty: %s, date: %s, time: %s, user: %d, match_type: %d, project: %d)",
 		order.Role, order.ID, mlpIndicator, order.TransactionID, order.Price, order.Quantity,
 		order.TradeDate, order.TradeTime, order.UserID, order.MatchType, projectID)
 
-	// Step 2: Check top table count
+	// Step 2: Check top table count (under the lock, so no other insert can
+	// fill the slot between the count and the swap)
+	if err := lockTopTableTx(tx, order.Role); err != nil {
+		return err
+	}
 	var topCount int
 	err = tx.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", topTableName)).Scan(&topCount)
 	if err != nil {
-		return fmt.Errorf("top table count failed: %v", err)
+		return fmt.Errorf("top table count failed: %w", err)
 	}
 
 	log.Printf("📊 Current top table status: %d/10 orders", topCount)
@@ -158,16 +221,20 @@ func intelligentOrderInsertion(database *sql.DB, order *Order) error {
 	} else {
 		switch order.Role {
 		case "buyer":
-			// MLP BUYERS ALWAYS QUALIFY - BYPASS PRICE CHECK
-			if order.MarketLeadProgram {
+			// MLP AND MARKET BUYERS ALWAYS QUALIFY - BYPASS PRICE CHECK
+			if order.MarketLeadProgram || order.OrderKind == "market" {
 				shouldMoveToTop = true
-				log.Printf("⭐ MLP Buyer detected - PRIORITY ACCESS to top table (bypassing all checks)")
+				if order.OrderKind == "market" {
+					log.Printf("🏃 Market Buyer detected - PRIORITY ACCESS to top table (executes immediately)")
+				} else {
+					log.Printf("⭐ MLP Buyer detected - PRIORITY ACCESS to top table (bypassing all checks)")
+				}
 
 				// Find worst NON-MLP buyer to replace (LOWEST price with tie-breaking)
 				err = tx.QueryRow(fmt.Sprintf(`
 					SELECT order_id, price FROM %s 
 					WHERE market_lead_program = false
-					ORDER BY price ASC, quantity ASC, trade_date DESC, trade_time DESC
+					ORDER BY price ASC, quantity ASC, trade_date DESC, trade_time DESC, created_at DESC, order_id DESC
 					LIMIT 1
 				`, topTableName)).Scan(&worstOrderID, &worstPrice)
 
@@ -175,16 +242,16 @@ func intelligentOrderInsertion(database *sql.DB, order *Order) error {
 					// All buyers are MLP, replace the worst MLP buyer by price + tie-breaking
 					err = tx.QueryRow(fmt.Sprintf(`
 						SELECT order_id, price FROM %s 
-						ORDER BY price ASC, quantity ASC, trade_date DESC, trade_time DESC
+						ORDER BY price ASC, quantity ASC, trade_date DESC, trade_time DESC, created_at DESC, order_id DESC
 						LIMIT 1
 					`, topTableName)).Scan(&worstOrderID, &worstPrice)
 
 					if err != nil {
-						return fmt.Errorf("buyer worst MLP order check failed: %v", err)
+						return fmt.Errorf("buyer worst MLP order check failed: %w", err)
 					}
 					log.Printf("📋 All buyers are MLP - replacing worst MLP buyer ($%.2f)", worstPrice)
 				} else if err != nil {
-					return fmt.Errorf("buyer worst non-MLP order check failed: %v", err)
+					return fmt.Errorf("buyer worst non-MLP order check failed: %w", err)
 				} else {
 					log.Printf("🔄 Will replace worst non-MLP buyer #%d ($%.2f)", worstOrderID, worstPrice)
 				}
@@ -192,15 +259,15 @@ func intelligentOrderInsertion(database *sql.DB, order *Order) error {
 				// Normal price-based logic for non-MLP buyers WITH TIE-BREAKING
 				err = tx.QueryRow(fmt.Sprintf(`
 					SELECT order_id, price FROM %s 
-					ORDER BY price ASC, quantity ASC, trade_date DESC, trade_time DESC
+					ORDER BY price ASC, quantity ASC, trade_date DESC, trade_time DESC, created_at DESC, order_id DESC
 					LIMIT 1
 				`, topTableName)).Scan(&worstOrderID, &worstPrice)
 
 				if err != nil {
-					return fmt.Errorf("buyer worst order check failed: %v", err)
+					return fmt.Errorf("buyer worst order check failed: %w", err)
 				}
 
-				var worstQty int
+				var worstQty Quantity
 				var worstDate string
 				var worstTime string
 				tx.QueryRow(fmt.Sprintf(`
@@ -208,14 +275,26 @@ func intelligentOrderInsertion(database *sql.DB, order *Order) error {
 					FROM %s WHERE order_id = $1
 				`, topTableName), worstOrderID).Scan(&worstQty, &worstDate, &worstTime)
 
-				if order.Price > worstPrice {
+				minImprovement := minTopImprovement(rules, worstPrice)
+
+				if minImprovement > 0 {
+					// Only a big enough price improvement displaces - no tie-breaks
+					if comparePrices(order.Price-worstPrice, minImprovement) >= 0 {
+						shouldMoveToTop = true
+						log.Printf("🔄 New buyer ($%.2f) BEATS worst ($%.2f) by at least the minimum $%.6g - will swap",
+							order.Price, worstPrice, minImprovement)
+					} else {
+						log.Printf("📏 New buyer ($%.2f) doesn't beat worst ($%.2f) by the minimum $%.6g - stays in main table",
+							order.Price, worstPrice, minImprovement)
+					}
+				} else if comparePrices(order.Price, worstPrice) > 0 {
 					shouldMoveToTop = true
 					log.Printf("🔄 New buyer ($%.2f) BEATS worst ($%.2f) on PRICE - will swap",
 						order.Price, worstPrice)
-				} else if order.Price == worstPrice {
+				} else if comparePrices(order.Price, worstPrice) == 0 {
 					if order.Quantity > worstQty {
 						shouldMoveToTop = true
-						log.Printf("🔄 Same price ($%.2f), new qty (%d) BEATS worst (%d) - will swap",
+						log.Printf("🔄 Same price ($%.2f), new qty (%s) BEATS worst (%s) - will swap",
 							order.Price, order.Quantity, worstQty)
 					} else if order.Quantity == worstQty {
 						if order.TradeDate < worstDate {
@@ -243,7 +322,7 @@ func intelligentOrderInsertion(database *sql.DB, order *Order) error {
 				err = tx.QueryRow(fmt.Sprintf(`
 					SELECT order_id, price FROM %s 
 					WHERE market_lead_program = false
-					ORDER BY price DESC, quantity ASC, trade_date DESC, trade_time DESC
+					ORDER BY price DESC, quantity ASC, trade_date DESC, trade_time DESC, created_at DESC, order_id DESC
 					LIMIT 1
 				`, topTableName)).Scan(&worstOrderID, &worstPrice)
 
@@ -251,16 +330,16 @@ func intelligentOrderInsertion(database *sql.DB, order *Order) error {
 					// All sellers are MLP, replace the worst MLP seller by price + tie-breaking
 					err = tx.QueryRow(fmt.Sprintf(`
 						SELECT order_id, price FROM %s 
-						ORDER BY price DESC, quantity ASC, trade_date DESC, trade_time DESC
+						ORDER BY price DESC, quantity ASC, trade_date DESC, trade_time DESC, created_at DESC, order_id DESC
 						LIMIT 1
 					`, topTableName)).Scan(&worstOrderID, &worstPrice)
 
 					if err != nil {
-						return fmt.Errorf("seller worst MLP order check failed: %v", err)
+						return fmt.Errorf("seller worst MLP order check failed: %w", err)
 					}
 					log.Printf("📋 All sellers are MLP - replacing worst MLP seller ($%.2f)", worstPrice)
 				} else if err != nil {
-					return fmt.Errorf("seller worst non-MLP order check failed: %v", err)
+					return fmt.Errorf("seller worst non-MLP order check failed: %w", err)
 				} else {
 					log.Printf("🔄 Will replace worst non-MLP seller #%d ($%.2f)", worstOrderID, worstPrice)
 				}
@@ -268,15 +347,15 @@ func intelligentOrderInsertion(database *sql.DB, order *Order) error {
 				// Normal price-based logic for non-MLP sellers WITH TIE-BREAKING
 				err = tx.QueryRow(fmt.Sprintf(`
 					SELECT order_id, price FROM %s 
-					ORDER BY price DESC, quantity ASC, trade_date DESC, trade_time DESC
+					ORDER BY price DESC, quantity ASC, trade_date DESC, trade_time DESC, created_at DESC, order_id DESC
 					LIMIT 1
 				`, topTableName)).Scan(&worstOrderID, &worstPrice)
 
 				if err != nil {
-					return fmt.Errorf("seller worst order check failed: %v", err)
+					return fmt.Errorf("seller worst order check failed: %w", err)
 				}
 
-				var worstQty int
+				var worstQty Quantity
 				var worstDate string
 				var worstTime string
 				tx.QueryRow(fmt.Sprintf(`
@@ -284,14 +363,26 @@ func intelligentOrderInsertion(database *sql.DB, order *Order) error {
 					FROM %s WHERE order_id = $1
 				`, topTableName), worstOrderID).Scan(&worstQty, &worstDate, &worstTime)
 
-				if order.Price < worstPrice {
+				minImprovement := minTopImprovement(rules, worstPrice)
+
+				if minImprovement > 0 {
+					// Only a big enough price improvement displaces - no tie-breaks
+					if comparePrices(worstPrice-order.Price, minImprovement) >= 0 {
+						shouldMoveToTop = true
+						log.Printf("🔄 New seller ($%.2f) BEATS worst ($%.2f) by at least the minimum $%.6g - will swap",
+							order.Price, worstPrice, minImprovement)
+					} else {
+						log.Printf("📏 New seller ($%.2f) doesn't beat worst ($%.2f) by the minimum $%.6g - stays in main table",
+							order.Price, worstPrice, minImprovement)
+					}
+				} else if comparePrices(order.Price, worstPrice) < 0 {
 					shouldMoveToTop = true
 					log.Printf("🔄 New seller ($%.2f) BEATS worst ($%.2f) on PRICE - will swap",
 						order.Price, worstPrice)
-				} else if order.Price == worstPrice {
+				} else if comparePrices(order.Price, worstPrice) == 0 {
 					if order.Quantity > worstQty {
 						shouldMoveToTop = true
-						log.Printf("🔄 Same price ($%.2f), new qty (%d) BEATS worst (%d) - will swap",
+						log.Printf("🔄 Same price ($%.2f), new qty (%s) BEATS worst (%s) - will swap",
 							order.Price, order.Quantity, worstQty)
 					} else if order.Quantity == worstQty {
 						if order.TradeDate < worstDate {
@@ -316,48 +407,52 @@ func intelligentOrderInsertion(database *sql.DB, order *Order) error {
 		if worstOrderID > 0 {
 			var worstUserID int
 			var worstTransactionID string
-			var worstQty int
+			var worstQty Quantity
 			var worstDate string
 			var worstTradeTime time.Time
 			var worstTxnType int
 			var worstMatchType int
 			var worstMLP bool
+			var worstOrderKind string
 			var worstProjectID int
 			var worstCreatedAt time.Time
+			var worstSlippage sql.NullFloat64
+			var worstGoodTillDate sql.NullTime
+			var worstMinFill NullQuantity
 
 			err = tx.QueryRow(fmt.Sprintf(`
-				SELECT user_id, transaction_id, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, COALESCE(project_id, 1), created_at
+				SELECT user_id, transaction_id, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, order_kind, ` + projectIDOrDefault("project_id") + `, created_at, max_slippage_percentage, good_till_date, min_fill_quantity
 				FROM %s WHERE order_id = $1
 			`, topTableName), worstOrderID).Scan(&worstUserID, &worstTransactionID, &worstQty,
-				&worstDate, &worstTradeTime, &worstTxnType, &worstMatchType, &worstMLP, &worstProjectID, &worstCreatedAt)
+				&worstDate, &worstTradeTime, &worstTxnType, &worstMatchType, &worstMLP, &worstOrderKind, &worstProjectID, &worstCreatedAt, &worstSlippage, &worstGoodTillDate, &worstMinFill)
 
 			if err != nil {
-				return fmt.Errorf("failed to get worst order data: %v", err)
+				return fmt.Errorf("failed to get worst order data: %w", err)
 			}
 
 			var existsInMain bool
 			err = tx.QueryRow(fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM %s WHERE id = $1)", tableName),
 				worstOrderID).Scan(&existsInMain)
 			if err != nil {
-				return fmt.Errorf("worst order existence check failed: %v", err)
+				return fmt.Errorf("worst order existence check failed: %w", err)
 			}
 
 			if !existsInMain {
 				_, err = tx.Exec(fmt.Sprintf(`
-					INSERT INTO %s (id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, project_id, created_at)
-					VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+					INSERT INTO %s (id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, order_kind, project_id, created_at, max_slippage_percentage, good_till_date, min_fill_quantity)
+					VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
 				`, tableName), worstOrderID, worstUserID, worstTransactionID, worstPrice,
-					worstQty, worstDate, worstTradeTime, worstTxnType, worstMatchType, worstMLP, worstProjectID, worstCreatedAt)
+					worstQty, worstDate, worstTradeTime, worstTxnType, worstMatchType, worstMLP, worstOrderKind, worstProjectID, worstCreatedAt, worstSlippage, worstGoodTillDate, worstMinFill)
 
 				if err != nil {
-					return fmt.Errorf("failed to restore worst order to main table: %v", err)
+					return fmt.Errorf("failed to restore worst order to main table: %w", err)
 				}
 				log.Printf("♻️ Restored order #%d ($%.2f) to main table", worstOrderID, worstPrice)
 			}
 
 			_, err = tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE order_id = $1", topTableName), worstOrderID)
 			if err != nil {
-				return fmt.Errorf("worst order removal from top table failed: %v", err)
+				return fmt.Errorf("worst order removal from top table failed: %w", err)
 			}
 			log.Printf("🗑️ Removed order #%d ($%.2f) from top table", worstOrderID, worstPrice)
 		}
@@ -366,23 +461,29 @@ func intelligentOrderInsertion(database *sql.DB, order *Order) error {
 		err = tx.QueryRow(fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM %s WHERE order_id = $1)", topTableName),
 			order.ID).Scan(&alreadyInTop)
 		if err != nil {
-			return fmt.Errorf("new order top table check failed: %v", err)
+			return fmt.Errorf("new order top table check failed: %w", err)
 		}
 
 		if !alreadyInTop {
-			_, err = tx.Exec(fmt.Sprintf(`
-				INSERT INTO %s (order_id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, project_id, created_at)
-				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+			// ON CONFLICT keeps a racing promotion of the same order from
+			// failing the whole insert; either way the order ends up in top
+			inserted, err := tx.Exec(fmt.Sprintf(`
+				INSERT INTO %s (order_id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, order_kind, project_id, created_at, max_slippage_percentage, good_till_date, min_fill_quantity)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+				ON CONFLICT (order_id) DO NOTHING
 			`, topTableName), order.ID, order.UserID, order.TransactionID, order.Price,
-				order.Quantity, order.TradeDate, order.TradeTime, order.TransactionType, order.MatchType, order.MarketLeadProgram, projectID, order.CreatedAt)
+				order.Quantity, order.TradeDate, order.TradeTime, order.TransactionType, order.MatchType, order.MarketLeadProgram, order.OrderKind, projectID, order.CreatedAt, order.MaxSlippagePct, order.GoodTillDate, order.MinFillQty)
 
 			if err != nil {
-				return fmt.Errorf("top table insert failed: %v", err)
+				return fmt.Errorf("top table insert failed: %w", err)
+			}
+			if rows, _ := inserted.RowsAffected(); rows == 0 {
+				log.Printf("⚠️ Order #%d was already in %s - skipped duplicate insert", order.ID, topTableName)
 			}
 
 			result, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE id = $1", tableName), order.ID)
 			if err != nil {
-				return fmt.Errorf("main table deletion failed: %v", err)
+				return fmt.Errorf("main table deletion failed: %w", err)
 			}
 
 			rowsDeleted, _ := result.RowsAffected()
@@ -392,13 +493,269 @@ func intelligentOrderInsertion(database *sql.DB, order *Order) error {
 		}
 	}
 
+	return nil
+}
+
+// The price improvement a non-MLP order of the project needs over the worst
+// top-table price to displace it: the larger of the project's absolute and
+// percentage minimums. 0 when neither is set (any improvement, then tie-breaks).
+func minTopImprovement(rules *ProjectTradingRules, worstPrice float64) float64 {
+	if rules == nil {
+		return 0
+	}
+	var minImprovement float64
+	if rules.MinTopImprovement != nil {
+		minImprovement = *rules.MinTopImprovement
+	}
+	if rules.MinTopImprovementPct != nil {
+		minImprovement = math.Max(minImprovement, math.Abs(worstPrice)*(*rules.MinTopImprovementPct)/100)
+	}
+	return minImprovement
+}
+
+// Remove whatever is left of a market order after matching (fully filled orders are already gone)
+func cancelUnfilledMarketOrder(database *sql.DB, order *Order) error {
+	tableName := getTableName(order.Role)
+	topTableName := getTopTableName(order.Role)
+
+	if tableName == "" || topTableName == "" {
+		return fmt.Errorf("invalid role")
+	}
+
+	tx, err := database.Begin()
+	if err != nil {
+		return fmt.Errorf("transaction start failed: %v", err)
+	}
+	defer tx.Rollback()
+
+	var remainingQty Quantity
+	inTopTable := true
+	err = tx.QueryRow(fmt.Sprintf("DELETE FROM %s WHERE order_id = $1 RETURNING quantity", topTableName),
+		order.ID).Scan(&remainingQty)
+	if err == sql.ErrNoRows {
+		inTopTable = false
+		err = tx.QueryRow(fmt.Sprintf("DELETE FROM %s WHERE id = $1 RETURNING quantity", tableName),
+			order.ID).Scan(&remainingQty)
+	}
+	if err == sql.ErrNoRows {
+		return nil // Fully filled
+	}
+	if err != nil {
+		return fmt.Errorf("market order removal failed: %v", err)
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(`
+		UPDATE %s_order_history
+		SET status = 'Cancelled', updated_at = CURRENT_TIMESTAMP
+		WHERE %s_order_id = $1
+	`, order.Role, order.Role), order.ID)
+	if err != nil {
+		return fmt.Errorf("history update failed: %v", err)
+	}
+
 	if err = tx.Commit(); err != nil {
 		return fmt.Errorf("commit failed: %v", err)
 	}
 
+	log.Printf("🏃 Market %s order #%d: dropped unfilled remainder of %s/%s",
+		order.Role, order.ID, remainingQty, order.Quantity)
+
+	if inTopTable {
+		notifyOrderBookChanged(order.Role)
+		go smartSyncTopOrders(database, order.Role)
+	}
+
 	return nil
 }
 
+// Market buyers sweep the project's sellers until filled. Matching only sees
+// the shared 10-row top seller table, so after each pass the project's
+// main-table sellers are promoted into it and the project is matched again.
+// Stops once the order is filled, or when a pass neither filled anything nor
+// had new sellers to try - the project has nothing left the order can take.
+func sweepMarketOrder(database *sql.DB, order *Order) error {
+	projectID := defaultProjectID
+	if order.ProjectID != nil {
+		projectID = *order.ProjectID
+	}
+
+	remainingQty := order.Quantity
+	for {
+		if _, err := runMatching(database, projectID); err != nil {
+			return fmt.Errorf("matching failed: %w", err)
+		}
+
+		var qty Quantity
+		err := database.QueryRow(fmt.Sprintf(`
+			SELECT quantity FROM %s WHERE order_id = $1
+			UNION ALL
+			SELECT quantity FROM %s WHERE id = $1
+		`, getTopTableName(order.Role), getTableName(order.Role)), order.ID).Scan(&qty)
+		if err == sql.ErrNoRows {
+			return nil // Fully filled
+		}
+		if err != nil {
+			return fmt.Errorf("market order lookup failed: %w", err)
+		}
+
+		promoted, err := promoteProjectOrders(database, "seller", projectID)
+		if err != nil {
+			return err
+		}
+		if qty == remainingQty && promoted == 0 {
+			return nil
+		}
+		remainingQty = qty
+	}
+}
+
+// Matches one project until neither its top-table orders nor the ones
+// promoted from its main tables can trade. Plain runMatching only sees the
+// shared top tables, which other projects' orders can fill completely.
+// Passes add up into one result; a pass that hits the iteration cap or the
+// run timeout ends it.
+func matchProjectWithRefill(database *sql.DB, projectID int) (MatchingRunResult, error) {
+	var total MatchingRunResult
+	for {
+		result, err := runMatching(database, projectID)
+		total.Matches += result.Matches
+		total.Iterations += result.Iterations
+		total.IterationsCap = total.IterationsCap || result.IterationsCap
+		total.TimedOut = total.TimedOut || result.TimedOut
+		total.Phases.FetchSellersMs += result.Phases.FetchSellersMs
+		total.Phases.FetchBuyersMs += result.Phases.FetchBuyersMs
+		total.Phases.ExecutionMs += result.Phases.ExecutionMs
+		total.Phases.DispatchMs += result.Phases.DispatchMs
+		total.Phases.OtherMs += result.Phases.OtherMs
+		if err != nil {
+			return total, err
+		}
+		if result.IterationsCap || result.TimedOut {
+			return total, nil
+		}
+
+		promotedBuyers, err := promoteProjectOrders(database, "buyer", projectID)
+		if err != nil {
+			return total, err
+		}
+		promotedSellers, err := promoteProjectOrders(database, "seller", projectID)
+		if err != nil {
+			return total, err
+		}
+		if result.Matches == 0 && promotedBuyers == 0 && promotedSellers == 0 {
+			return total, nil
+		}
+	}
+}
+
+// Moves the project's best main-table orders into the role's top table. The
+// top tables are shared by every project, so a project whose orders rank
+// below the rest never reaches them and can't match; the lowest-ranked
+// orders of other projects go back to the main table to make room.
+// Returns how many orders were promoted.
+func promoteProjectOrders(database *sql.DB, role string, projectID int) (int, error) {
+	sourceTable := getTableName(role)
+	topTable := getTopTableName(role)
+
+	if sourceTable == "" || topTable == "" {
+		return 0, fmt.Errorf("invalid role")
+	}
+
+	// Best first among the main table, worst first among the top table
+	bestOrder := "market_lead_program DESC, price DESC, quantity DESC, trade_date ASC, trade_time ASC, created_at ASC, id ASC"
+	worstOrder := "market_lead_program ASC, price ASC, quantity ASC, trade_date DESC, trade_time DESC, created_at DESC, order_id DESC"
+	if role == "seller" {
+		bestOrder = "market_lead_program DESC, price ASC, quantity DESC, trade_date ASC, trade_time ASC, created_at ASC, id ASC"
+		worstOrder = "market_lead_program ASC, price DESC, quantity ASC, trade_date DESC, trade_time DESC, created_at DESC, order_id DESC"
+	}
+	projectColumn := projectIDOrDefault("project_id")
+
+	var promoted int
+	err := withRetry(database, func(tx *sql.Tx) error {
+		promoted = 0
+		if err := lockTopTableTx(tx, role); err != nil {
+			return err
+		}
+
+		var topCount, projectTopCount int
+		err := tx.QueryRow(fmt.Sprintf(`
+			SELECT COUNT(*), COUNT(*) FILTER (WHERE %s = $1) FROM %s
+		`, projectColumn, topTable), projectID).Scan(&topCount, &projectTopCount)
+		if err != nil {
+			return fmt.Errorf("top table count failed: %w", err)
+		}
+		if projectTopCount >= 10 {
+			return nil
+		}
+
+		rows, err := tx.Query(fmt.Sprintf(`
+			SELECT id FROM %s WHERE %s = $1 ORDER BY %s LIMIT $2
+		`, sourceTable, projectColumn, bestOrder), projectID, 10-projectTopCount)
+		if err != nil {
+			return fmt.Errorf("project order lookup failed: %w", err)
+		}
+		var orderIDs []int64
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return fmt.Errorf("project order lookup failed: %w", err)
+			}
+			orderIDs = append(orderIDs, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("project order lookup failed: %w", err)
+		}
+		if len(orderIDs) == 0 {
+			return nil
+		}
+
+		if overflow := topCount + len(orderIDs) - 10; overflow > 0 {
+			_, err = tx.Exec(fmt.Sprintf(`
+				WITH demoted AS (
+					DELETE FROM %[1]s
+					WHERE order_id IN (
+						SELECT order_id FROM %[1]s WHERE %[3]s <> $1 ORDER BY %[4]s LIMIT $2
+					)
+					RETURNING order_id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, order_kind, project_id, created_at, max_slippage_percentage, good_till_date, min_fill_quantity
+				)
+				INSERT INTO %[2]s (id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, order_kind, project_id, created_at, max_slippage_percentage, good_till_date, min_fill_quantity)
+				SELECT * FROM demoted
+				ON CONFLICT (id) DO NOTHING
+			`, topTable, sourceTable, projectColumn, worstOrder), projectID, overflow)
+			if err != nil {
+				return fmt.Errorf("making room in top table failed: %w", err)
+			}
+		}
+
+		_, err = tx.Exec(fmt.Sprintf(`
+			INSERT INTO %s (order_id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, order_kind, project_id, created_at, max_slippage_percentage, good_till_date, min_fill_quantity)
+			SELECT id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, order_kind, `+projectColumn+`, created_at, max_slippage_percentage, good_till_date, min_fill_quantity
+			FROM %s WHERE id = ANY($1)
+			ON CONFLICT (order_id) DO NOTHING
+		`, topTable, sourceTable), pq.Array(orderIDs))
+		if err != nil {
+			return fmt.Errorf("top table insert failed: %w", err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE id = ANY($1)", sourceTable), pq.Array(orderIDs)); err != nil {
+			return fmt.Errorf("main table deletion failed: %w", err)
+		}
+
+		promoted = len(orderIDs)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if promoted > 0 {
+		log.Printf("📈 Promoted %d project %d %s order(s) to the top table", promoted, projectID, role)
+		notifyOrderBookChanged(role)
+	}
+	return promoted, nil
+}
+
 func smartSyncTopOrders(database *sql.DB, role string) error {
 	topTable := getTopTableName(role)
 	sourceTable := getTableName(role)
@@ -407,8 +764,18 @@ func smartSyncTopOrders(database *sql.DB, role string) error {
 		return fmt.Errorf("invalid role")
 	}
 
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockTopTableTx(tx, role); err != nil {
+		return err
+	}
+
 	var currentCount int
-	err := database.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", topTable)).Scan(&currentCount)
+	err = tx.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", topTable)).Scan(&currentCount)
 	if err != nil {
 		return err
 	}
@@ -419,30 +786,28 @@ func smartSyncTopOrders(database *sql.DB, role string) error {
 
 	needed := 10 - currentCount
 
-	tx, err := database.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
+	// ON CONFLICT skips an order that is already in the top table instead
+	// of failing the whole sync
 	var query string
 	if role == "buyer" {
 		query = fmt.Sprintf(`
-			INSERT INTO %s (order_id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, project_id, created_at)
-			SELECT id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, COALESCE(project_id, 1), created_at
+			INSERT INTO %s (order_id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, order_kind, project_id, created_at, max_slippage_percentage, good_till_date, min_fill_quantity)
+			SELECT id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, order_kind, ` + projectIDOrDefault("project_id") + `, created_at, max_slippage_percentage, good_till_date, min_fill_quantity
 			FROM %s
 			WHERE id NOT IN (SELECT order_id FROM %s)
-			ORDER BY market_lead_program DESC, price DESC, quantity DESC, trade_date ASC, trade_time ASC
+			ORDER BY market_lead_program DESC, price DESC, quantity DESC, trade_date ASC, trade_time ASC, created_at ASC, id ASC
 			LIMIT $1
+			ON CONFLICT (order_id) DO NOTHING
 		`, topTable, sourceTable, topTable)
 	} else {
 		query = fmt.Sprintf(`
-			INSERT INTO %s (order_id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, project_id, created_at)
-			SELECT id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, COALESCE(project_id, 1), created_at
+			INSERT INTO %s (order_id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, order_kind, project_id, created_at, max_slippage_percentage, good_till_date, min_fill_quantity)
+			SELECT id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, order_kind, ` + projectIDOrDefault("project_id") + `, created_at, max_slippage_percentage, good_till_date, min_fill_quantity
 			FROM %s
 			WHERE id NOT IN (SELECT order_id FROM %s)
-			ORDER BY market_lead_program DESC, price ASC, quantity DESC, trade_date ASC, trade_time ASC
+			ORDER BY market_lead_program DESC, price ASC, quantity DESC, trade_date ASC, trade_time ASC, created_at ASC, id ASC
 			LIMIT $1
+			ON CONFLICT (order_id) DO NOTHING
 		`, topTable, sourceTable, topTable)
 	}
 
@@ -464,7 +829,14 @@ func smartSyncTopOrders(database *sql.DB, role string) error {
 		tx.Exec(deleteQuery)
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if rowsAdded > 0 {
+		notifyOrderBookChanged(role)
+	}
+	return nil
 }
 
 func syncTopOrders(database *sql.DB, role string) error {
@@ -481,6 +853,22 @@ func syncTopOrders(database *sql.DB, role string) error {
 	}
 	defer tx.Rollback()
 
+	if err := lockTopTableTx(tx, role); err != nil {
+		return err
+	}
+
+	// Promoted orders only exist in the top table - move them back to main
+	// before clearing, otherwise the re-rank below would lose them
+	_, err = tx.Exec(fmt.Sprintf(`
+		INSERT INTO %s (id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, order_kind, project_id, created_at, max_slippage_percentage, good_till_date, min_fill_quantity)
+		SELECT order_id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, order_kind, project_id, created_at, max_slippage_percentage, good_till_date, min_fill_quantity
+		FROM %s
+		WHERE order_id NOT IN (SELECT id FROM %s)
+	`, sourceTable, topTable, sourceTable))
+	if err != nil {
+		return fmt.Errorf("error restoring top orders to main table: %v", err)
+	}
+
 	_, err = tx.Exec(fmt.Sprintf("DELETE FROM %s", topTable))
 	if err != nil {
 		return fmt.Errorf("error clearing top table: %v", err)
@@ -489,19 +877,21 @@ func syncTopOrders(database *sql.DB, role string) error {
 	var query string
 	if role == "buyer" {
 		query = fmt.Sprintf(`
-			INSERT INTO %s (order_id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, project_id, created_at)
-			SELECT id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, COALESCE(project_id, 1), created_at
+			INSERT INTO %s (order_id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, order_kind, project_id, created_at, max_slippage_percentage, good_till_date, min_fill_quantity)
+			SELECT id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, order_kind, ` + projectIDOrDefault("project_id") + `, created_at, max_slippage_percentage, good_till_date, min_fill_quantity
 			FROM %s
-			ORDER BY market_lead_program DESC, price DESC, quantity DESC, trade_date ASC, trade_time ASC
+			ORDER BY market_lead_program DESC, price DESC, quantity DESC, trade_date ASC, trade_time ASC, created_at ASC, id ASC
 			LIMIT 10
+			ON CONFLICT (order_id) DO NOTHING
 		`, topTable, sourceTable)
 	} else {
 		query = fmt.Sprintf(`
-			INSERT INTO %s (order_id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, project_id, created_at)
-			SELECT id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, COALESCE(project_id, 1), created_at
+			INSERT INTO %s (order_id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, order_kind, project_id, created_at, max_slippage_percentage, good_till_date, min_fill_quantity)
+			SELECT id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, order_kind, ` + projectIDOrDefault("project_id") + `, created_at, max_slippage_percentage, good_till_date, min_fill_quantity
 			FROM %s
-			ORDER BY market_lead_program DESC, price ASC, quantity DESC, trade_date ASC, trade_time ASC
+			ORDER BY market_lead_program DESC, price ASC, quantity DESC, trade_date ASC, trade_time ASC, created_at ASC, id ASC
 			LIMIT 10
+			ON CONFLICT (order_id) DO NOTHING
 		`, topTable, sourceTable)
 	}
 
@@ -518,10 +908,17 @@ func syncTopOrders(database *sql.DB, role string) error {
 			WHERE id IN (SELECT order_id FROM %s)
 		`, sourceTable, topTable)
 
-		tx.Exec(deleteQuery)
+		if _, err := tx.Exec(deleteQuery); err != nil {
+			return fmt.Errorf("error removing promoted orders from main table: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
 	}
 
-	return tx.Commit()
+	notifyOrderBookChanged(role)
+	return nil
 }
 
 // Replace the existing checkAndTriggerMatching function with this updated version
@@ -551,8 +948,9 @@ func checkAndTriggerMatching(database *sql.DB) error {
 
 	log.Printf("📊 Top tables status - Buyers: %d, Sellers: %d", buyerCount, sellerCount)
 
-	// Changed condition: Start matching if BOTH tables have at least 1 order
-	if buyerCount >= 1 && sellerCount >= 1 {
+	// Start matching once BOTH tables have at least match_min_per_side orders
+	minPerSide := matchMinPerSide()
+	if buyerCount >= minPerSide && sellerCount >= minPerSide {
 		// ========== CHECK CIRCUIT BREAKERS BEFORE MATCHING ==========
 		if err := checkAndUpdateCircuitBreakers(database); err != nil {
 			log.Printf("⚠️ Warning: Circuit breaker check failed: %v", err)
@@ -571,12 +969,12 @@ func checkAndTriggerMatching(database *sql.DB) error {
 
 		log.Printf("⚡ Total matching session completed in %.3fms", durationMs)
 	} else {
-		if buyerCount == 0 && sellerCount == 0 {
-			log.Printf("⏳ Waiting for orders - Need at least 1 buyer and 1 seller")
-		} else if buyerCount == 0 {
-			log.Printf("⏳ Waiting for buyers - Have %d sellers ready", sellerCount)
-		} else if sellerCount == 0 {
-			log.Printf("⏳ Waiting for sellers - Have %d buyers ready", buyerCount)
+		if buyerCount < minPerSide && sellerCount < minPerSide {
+			log.Printf("⏳ Waiting for orders - Need at least %d buyer(s) and %d seller(s)", minPerSide, minPerSide)
+		} else if buyerCount < minPerSide {
+			log.Printf("⏳ Waiting for buyers - Have %d sellers ready, %d/%d buyers", sellerCount, buyerCount, minPerSide)
+		} else {
+			log.Printf("⏳ Waiting for sellers - Have %d buyers ready, %d/%d sellers", buyerCount, sellerCount, minPerSide)
 		}
 	}
 
@@ -615,6 +1013,37 @@ func syncTopOrdersIfEmpty(database *sql.DB) error {
 	return syncAllTopOrders(database)
 }
 
+// Safety net for the async smartSyncTopOrders calls after matches and
+// cancels: if one fails, a top table stays under-filled and matching starves.
+// Every TOP_ORDERS_SYNC_INTERVAL (0 disables) each top table below 10 orders
+// is topped up from its main table; full tables are left alone.
+func startTopOrdersSweeper(database *sql.DB) {
+	interval := getEnvDuration("TOP_ORDERS_SYNC_INTERVAL", 30*time.Second)
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			sweepTopOrders(database)
+		}
+	}()
+
+	log.Printf("🧹 Top orders sweeper enabled (every %s)", interval)
+}
+
+// One sweep; smartSyncTopOrders already skips a full table under its lock
+func sweepTopOrders(database *sql.DB) {
+	for _, role := range []string{"buyer", "seller"} {
+		if err := smartSyncTopOrders(database, role); err != nil {
+			log.Printf("⚠️ Top orders sweep failed for %s: %v", role, err)
+		}
+	}
+}
+
 func getTopTableName(role string) string {
 	switch role {
 	case "buyer":
@@ -625,7 +1054,8 @@ func getTopTableName(role string) string {
 	return ""
 }
 
-func getTopOrdersData(database *sql.DB, role string, transactionType int) ([]Order, error) {
+// projectID 0 returns every project's orders
+func getTopOrdersData(database *sql.DB, role string, transactionType int, projectID int) ([]Order, error) {
 	topTable := getTopTableName(role)
 	if topTable == "" {
 		return nil, fmt.Errorf("invalid role")
@@ -636,23 +1066,25 @@ func getTopOrdersData(database *sql.DB, role string, transactionType int) ([]Ord
 		query = fmt.Sprintf(`
 			SELECT order_id as id, user_id, transaction_id, price, quantity, trade_date, 
 			       TO_CHAR(trade_time, 'HH24:MI:SS') as trade_time, transaction_type, match_type, 
-			       market_lead_program, COALESCE(project_id, 1) as project_id, created_at
+			       market_lead_program, order_kind, ` + projectIDOrDefault("project_id") + ` as project_id, created_at,
+			       TO_CHAR(good_till_date, 'YYYY-MM-DD') as good_till_date, min_fill_quantity
 			FROM %s
-			WHERE transaction_type = $1
-			ORDER BY market_lead_program DESC, price DESC, quantity DESC, trade_date ASC, trade_time ASC
+			WHERE transaction_type = $1 AND ($2 = 0 OR ` + projectIDOrDefault("project_id") + ` = $2)
+			ORDER BY market_lead_program DESC, price DESC, quantity DESC, trade_date ASC, trade_time ASC, created_at ASC, order_id ASC
 		`, topTable)
 	} else {
 		query = fmt.Sprintf(`
 			SELECT order_id as id, user_id, transaction_id, price, quantity, trade_date, 
 			       TO_CHAR(trade_time, 'HH24:MI:SS') as trade_time, transaction_type, match_type, 
-			       market_lead_program, COALESCE(project_id, 1) as project_id, created_at
+			       market_lead_program, order_kind, ` + projectIDOrDefault("project_id") + ` as project_id, created_at,
+			       TO_CHAR(good_till_date, 'YYYY-MM-DD') as good_till_date, min_fill_quantity
 			FROM %s
-			WHERE transaction_type = $1
-			ORDER BY market_lead_program DESC, price ASC, quantity DESC, trade_date ASC, trade_time ASC
+			WHERE transaction_type = $1 AND ($2 = 0 OR ` + projectIDOrDefault("project_id") + ` = $2)
+			ORDER BY market_lead_program DESC, price ASC, quantity DESC, trade_date ASC, trade_time ASC, created_at ASC, order_id ASC
 		`, topTable)
 	}
 
-	rows, err := database.Query(query, transactionType)
+	rows, err := database.Query(query, transactionType, projectID)
 	if err != nil {
 		return nil, fmt.Errorf("error querying top orders: %v", err)
 	}
@@ -664,7 +1096,7 @@ func getTopOrdersData(database *sql.DB, role string, transactionType int) ([]Ord
 		var projectID int
 		err := rows.Scan(&order.ID, &order.UserID, &order.TransactionID, &order.Price, &order.Quantity,
 			&order.TradeDate, &order.TradeTime, &order.TransactionType, &order.MatchType,
-			&order.MarketLeadProgram, &projectID, &order.CreatedAt)
+			&order.MarketLeadProgram, &order.OrderKind, &projectID, &order.CreatedAt, &order.GoodTillDate, &order.MinFillQty)
 		if err != nil {
 			log.Println("Error scanning row:", err)
 			continue
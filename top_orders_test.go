@@ -0,0 +1,282 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSweepMarketOrderAcrossTwoPriceLevels(t *testing.T) {
+	openTestDB(t)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+	otherProject := createTestProject(t, "Other")
+
+	// Another project's cheaper sellers fill the shared top table, so all
+	// of this project's sellers start out in the main table
+	for i := 0; i < 10; i++ {
+		placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 1, Quantity: wholeQuantity(1), ProjectID: intPtr(otherProject)})
+	}
+	for i := 0; i < 10; i++ {
+		placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 10, Quantity: wholeQuantity(1)})
+	}
+	for i := 0; i < 2; i++ {
+		placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 11, Quantity: wholeQuantity(1)})
+	}
+
+	order := placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", OrderKind: "market", Quantity: wholeQuantity(12)})
+	if err := sweepMarketOrder(db, &order); err != nil {
+		t.Fatalf("sweep: %v", err)
+	}
+	if err := cancelUnfilledMarketOrder(db, &order); err != nil {
+		t.Fatalf("cancel remainder: %v", err)
+	}
+
+	var fills, atTen, atEleven int
+	var filled Quantity
+	err := db.QueryRow(`
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE seller_price = 10), COUNT(*) FILTER (WHERE seller_price = 11),
+		       COALESCE(SUM(matched_qty), 0)
+		FROM matched_orders WHERE buyer_order_id = $1
+	`, order.ID).Scan(&fills, &atTen, &atEleven, &filled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filled != wholeQuantity(12) || atTen != 10 || atEleven != 2 {
+		t.Errorf("filled %s in %d fills (%d at 10, %d at 11), want 12 in 12 fills (10 at 10, 2 at 11)",
+			filled, fills, atTen, atEleven)
+	}
+
+	var otherSellers int
+	if err := db.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM seller WHERE project_id = $1) + (SELECT COUNT(*) FROM top_seller WHERE project_id = $1)
+	`, otherProject).Scan(&otherSellers); err != nil {
+		t.Fatal(err)
+	}
+	if otherSellers != 10 {
+		t.Errorf("other project has %d sellers left, want its 10 untouched", otherSellers)
+	}
+	if n := testCount(t, "top_seller"); n > 10 {
+		t.Errorf("top_seller has %d rows, want at most 10", n)
+	}
+}
+
+func TestMinTopImprovementHoldsThroughRefill(t *testing.T) {
+	openTestDB(t)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	if _, err := db.Exec("UPDATE projects SET min_top_improvement = 1 WHERE id = $1", defaultProjectID); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 10, Quantity: wholeQuantity(1)})
+	}
+
+	inTop := func(orderID int) bool {
+		var exists bool
+		if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM top_buyer WHERE order_id = $1)", orderID).Scan(&exists); err != nil {
+			t.Fatal(err)
+		}
+		return exists
+	}
+
+	below := placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 10.5, Quantity: wholeQuantity(1)})
+	if inTop(below.ID) {
+		t.Error("buyer 0.5 better than the worst top buyer displaced it with a minimum of 1")
+	}
+	above := placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 11, Quantity: wholeQuantity(1)})
+	if !inTop(above.ID) {
+		t.Error("buyer 1 better than the worst top buyer stayed out of the top table")
+	}
+
+	// The repair that used to re-rank from scratch leaves the table alone
+	report, err := buildReconcileReport(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fixReconcileIssues(db, report); err != nil {
+		t.Fatal(err)
+	}
+	if inTop(below.ID) {
+		t.Error("refill pulled in the sub-threshold buyer")
+	}
+	if n := testCount(t, "top_buyer"); n != 10 {
+		t.Errorf("top_buyer rows = %d, want 10", n)
+	}
+}
+
+func TestSweeperRefillsEmptiedTopTable(t *testing.T) {
+	openTestDB(t)
+	sellerUser, _ := createTestUser(t, "seller", false)
+	otherProject := createTestProject(t, "Other")
+
+	// Another project's cheaper sellers fill the top table, so these stay in the main table
+	for i := 0; i < 10; i++ {
+		placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 1, Quantity: wholeQuantity(1), ProjectID: intPtr(otherProject)})
+	}
+	for i := 0; i < 3; i++ {
+		placeTestOrder(t, Order{UserID: sellerUser, Role: "seller", Price: 10, Quantity: wholeQuantity(1)})
+	}
+
+	// The top table empties and the async sync that should follow never runs
+	if _, err := db.Exec("DELETE FROM top_seller"); err != nil {
+		t.Fatal(err)
+	}
+	if n := testCount(t, "seller"); n != 3 {
+		t.Fatalf("main seller table holds %d orders, want 3", n)
+	}
+
+	sweepTopOrders(db)
+
+	if n := testCount(t, "top_seller"); n != 3 {
+		t.Errorf("top_seller has %d orders after the sweep, want 3", n)
+	}
+	if n := testCount(t, "seller"); n != 0 {
+		t.Errorf("main seller table still holds %d promoted orders", n)
+	}
+}
+
+func TestAllTopOrdersProjectFilter(t *testing.T) {
+	openTestDB(t)
+	userID, _ := createTestUser(t, "trader", false)
+	other := createTestProject(t, "Other")
+
+	for _, projectID := range []int{defaultProjectID, other} {
+		placeTestOrder(t, Order{UserID: userID, Role: "buyer", Price: 5, Quantity: wholeQuantity(1), ProjectID: intPtr(projectID)})
+		placeTestOrder(t, Order{UserID: userID, Role: "seller", Price: 20, Quantity: wholeQuantity(1), ProjectID: intPtr(projectID), TransactionType: 1})
+	}
+
+	projectsIn := func(query string) map[int]int {
+		t.Helper()
+		rec := doTestRequest(t, http.MethodGet, "/api/v1/top-orders/all"+query, "", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("top-orders/all%s: status %d (%s)", query, rec.Code, rec.Body.String())
+		}
+		var all map[string][]Order
+		decodeTestResponse(t, rec, &all)
+		seen := map[int]int{}
+		for _, orders := range all {
+			for _, o := range orders {
+				if o.ProjectID != nil {
+					seen[*o.ProjectID]++
+				}
+			}
+		}
+		return seen
+	}
+
+	if seen := projectsIn(""); seen[defaultProjectID] != 2 || seen[other] != 2 {
+		t.Errorf("unfiltered orders per project = %v, want 2 in each", seen)
+	}
+	if seen := projectsIn(fmt.Sprintf("?project_id=%d", other)); len(seen) != 1 || seen[other] != 2 {
+		t.Errorf("filtered orders per project = %v, want only project %d's 2", seen, other)
+	}
+	if rec := doTestRequest(t, http.MethodGet, "/api/v1/top-orders/all?project_id=abc", "", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid project_id: status %d, want 400", rec.Code)
+	}
+}
+
+func TestConcurrentInsertsNeverOverfillTopTable(t *testing.T) {
+	openTestDB(t)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	second := createTestProject(t, "Second")
+
+	rules := map[int]*ProjectTradingRules{}
+	for _, projectID := range []int{defaultProjectID, second} {
+		r, err := getProjectTradingRules(db, projectID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rules[projectID] = r
+	}
+
+	// Samples the top table while the inserts run
+	done := make(chan struct{})
+	maxSeen := make(chan int)
+	go func() {
+		highest := 0
+		for {
+			select {
+			case <-done:
+				maxSeen <- highest
+				return
+			default:
+			}
+			var n int
+			if err := db.QueryRow("SELECT COUNT(*) FROM top_buyer").Scan(&n); err == nil && n > highest {
+				highest = n
+			}
+		}
+	}()
+
+	// Rising prices, so every late arrival wants to swap out the worst entry
+	const orders = 40
+	var wg sync.WaitGroup
+	errs := make(chan error, orders)
+	now := time.Now()
+	for i := 0; i < orders; i++ {
+		projectID := defaultProjectID
+		if i%2 == 1 {
+			projectID = second
+		}
+		o := Order{
+			UserID: buyerUser, Role: "buyer", Price: float64(10 + i), Quantity: wholeQuantity(1),
+			TradeDate: now.Format("2006-01-02"), TradeTime: now.Format("15:04:05"), OrderKind: "limit",
+			ProjectID: intPtr(projectID),
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- intelligentOrderInsertion(db, &o, rules[*o.ProjectID], "")
+		}()
+	}
+	wg.Wait()
+	close(done)
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("insert: %v", err)
+		}
+	}
+
+	if highest := <-maxSeen; highest > 10 {
+		t.Errorf("top_buyer reached %d rows during the inserts, limit is 10", highest)
+	}
+	if n := testCount(t, "top_buyer"); n != 10 {
+		t.Errorf("top_buyer holds %d orders, want 10", n)
+	}
+	if total := testCount(t, "top_buyer") + testCount(t, "buyer"); total != orders {
+		t.Errorf("%d buyer orders across both tables, want %d", total, orders)
+	}
+}
+
+func TestPromotingAnOrderAlreadyInTopTableIsSkipped(t *testing.T) {
+	openTestDB(t)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	order := placeTestOrder(t, Order{UserID: buyerUser, Role: "buyer", Price: 10, Quantity: wholeQuantity(1)})
+
+	// A racing writer left the promoted order in the main table as well
+	_, err := db.Exec(`
+		INSERT INTO buyer (id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, order_kind, project_id, created_at, max_slippage_percentage, good_till_date, min_fill_quantity)
+		SELECT order_id, user_id, transaction_id, price, quantity, trade_date, trade_time, transaction_type, match_type, market_lead_program, order_kind, project_id, created_at, max_slippage_percentage, good_till_date, min_fill_quantity
+		FROM top_buyer WHERE order_id = $1
+	`, order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := promoteProjectOrders(db, "buyer", defaultProjectID); err != nil {
+		t.Fatalf("promoting an order already in the top table: %v", err)
+	}
+	if n := testCount(t, "top_buyer"); n != 1 {
+		t.Errorf("top_buyer holds %d rows, want the one order once", n)
+	}
+	if n := testCount(t, "buyer"); n != 0 {
+		t.Errorf("main buyer table still holds %d rows", n)
+	}
+	if qty, ok := testOrderQuantity(t, "buyer", order.ID); !ok || qty != wholeQuantity(1) {
+		t.Errorf("order #%d holds %s (found %v), want 1", order.ID, qty, ok)
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// matched_orders older than this many days are moved out by the archive job
+// (MATCHED_ORDERS_RETENTION_DAYS, 0 = keep everything). With
+// MATCHED_ORDERS_ARCHIVE=false they are deleted instead of archived.
+// Positions and statements read archived rows through the *_all views;
+// analytics only look at today and yesterday, which minRetentionDays keeps live.
+var (
+	matchedOrdersRetentionDays = getEnvInt("MATCHED_ORDERS_RETENTION_DAYS", 0)
+	matchedOrdersArchiveRows   = getEnv("MATCHED_ORDERS_ARCHIVE", "true") != "false"
+)
+
+// Yesterday's trades are at most two days old, so this keeps every row the
+// daily analytics read in matched_orders
+const minRetentionDays = 2
+
+// Rows moved per transaction, so a large backlog doesn't hold locks for long
+const archiveBatchSize = 5000
+
+type ArchiveResult struct {
+	RetentionDays    int  `json:"retention_days"`
+	Archived         bool `json:"archived"` // false = deleted without a copy
+	MatchedOrders    int  `json:"matched_orders"`
+	MatchAssignments int  `json:"match_assignments"`
+}
+
+// Rows are kept as JSONB snapshots so the archive doesn't have to follow
+// every column added to the live tables
+func initTradeArchiveTables(database *sql.DB) {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS matched_orders_archive (
+			id INTEGER PRIMARY KEY,
+			project_id INTEGER NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			data JSONB NOT NULL,
+			archived_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS match_assignments_archive (
+			id INTEGER PRIMARY KEY,
+			matched_order_id INTEGER,
+			data JSONB NOT NULL,
+			archived_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_matched_orders_archive_created ON matched_orders_archive (created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_match_assignments_archive_matched ON match_assignments_archive (matched_order_id)`,
+	}
+
+	for _, query := range queries {
+		if _, err := database.Exec(query); err != nil {
+			log.Printf("Warning: Could not create trade archive tables: %v", err)
+		}
+	}
+}
+
+// matched_orders_all and match_assignments_all read the live table and the
+// archive as one, archived rows expanded back into the live columns. Recreated
+// on every start so they pick up new columns; must run after the column
+// migrations, since a column a view depends on can't change type.
+func initTradeHistoryViews(database *sql.DB) {
+	queries := []string{
+		`DROP VIEW IF EXISTS matched_orders_all`,
+		`CREATE VIEW matched_orders_all AS
+			SELECT * FROM matched_orders
+			UNION ALL
+			SELECT (jsonb_populate_record(NULL::matched_orders, data)).* FROM matched_orders_archive`,
+		`DROP VIEW IF EXISTS match_assignments_all`,
+		`CREATE VIEW match_assignments_all AS
+			SELECT * FROM match_assignments
+			UNION ALL
+			SELECT (jsonb_populate_record(NULL::match_assignments, data)).* FROM match_assignments_archive`,
+	}
+
+	for _, query := range queries {
+		if _, err := database.Exec(query); err != nil {
+			log.Fatal("Error creating trade history views:", err)
+		}
+	}
+}
+
+// Moves (or deletes) one batch of matched_orders older than retentionDays together
+// with their match_assignments. Assignments are copied before the parent
+// rows go, since deleting a matched order cascades to its assignments.
+func archiveTradesBatchTx(tx *sql.Tx, retentionDays int, archive bool) (int, int, error) {
+	// Cutoff computed in SQL - created_at is a plain TIMESTAMP in the DB's zone
+	_, err := tx.Exec(`
+		CREATE TEMP TABLE archive_batch ON COMMIT DROP AS
+		SELECT id FROM matched_orders
+		WHERE created_at < LOCALTIMESTAMP - $1 * INTERVAL '1 day'
+		ORDER BY id LIMIT $2
+	`, retentionDays, archiveBatchSize)
+	if err != nil {
+		return 0, 0, fmt.Errorf("selecting batch: %w", err)
+	}
+
+	var assignments int64
+	if archive {
+		result, err := tx.Exec(`
+			INSERT INTO match_assignments_archive (id, matched_order_id, data)
+			SELECT ma.id, ma.matched_order_id, to_jsonb(ma)
+			FROM match_assignments ma
+			WHERE ma.matched_order_id IN (SELECT id FROM archive_batch)
+			ON CONFLICT (id) DO NOTHING
+		`)
+		if err != nil {
+			return 0, 0, fmt.Errorf("archiving match assignments: %w", err)
+		}
+		assignments, _ = result.RowsAffected()
+
+		_, err = tx.Exec(`
+			INSERT INTO matched_orders_archive (id, project_id, created_at, data)
+			SELECT mo.id, mo.project_id, mo.created_at, to_jsonb(mo)
+			FROM matched_orders mo
+			WHERE mo.id IN (SELECT id FROM archive_batch)
+			ON CONFLICT (id) DO NOTHING
+		`)
+		if err != nil {
+			return 0, 0, fmt.Errorf("archiving matched orders: %w", err)
+		}
+	} else {
+		err := tx.QueryRow(`
+			SELECT COUNT(*) FROM match_assignments WHERE matched_order_id IN (SELECT id FROM archive_batch)
+		`).Scan(&assignments)
+		if err != nil {
+			return 0, 0, fmt.Errorf("counting match assignments: %w", err)
+		}
+	}
+
+	// ON DELETE CASCADE removes the assignments
+	result, err := tx.Exec(`DELETE FROM matched_orders WHERE id IN (SELECT id FROM archive_batch)`)
+	if err != nil {
+		return 0, 0, fmt.Errorf("removing matched orders: %w", err)
+	}
+	matched, _ := result.RowsAffected()
+
+	return int(matched), int(assignments), nil
+}
+
+// Runs batches until nothing older than retentionDays is left
+func archiveOldTrades(database *sql.DB, retentionDays int, archive bool) (*ArchiveResult, error) {
+	result := &ArchiveResult{RetentionDays: retentionDays, Archived: archive}
+
+	for {
+		var matched, assignments int
+		err := withRetry(database, func(tx *sql.Tx) error {
+			var err error
+			matched, assignments, err = archiveTradesBatchTx(tx, retentionDays, archive)
+			return err
+		})
+		if err != nil {
+			return result, err
+		}
+		result.MatchedOrders += matched
+		result.MatchAssignments += assignments
+		if matched < archiveBatchSize {
+			break
+		}
+	}
+
+	if result.MatchedOrders > 0 {
+		verb := "Archived"
+		if !archive {
+			verb = "Deleted"
+		}
+		log.Printf("🗄️ %s %d matched orders (%d assignments) older than %d days",
+			verb, result.MatchedOrders, result.MatchAssignments, retentionDays)
+	}
+	return result, nil
+}
+
+// Daily by default (MATCHED_ORDERS_ARCHIVE_INTERVAL); off unless a retention
+// period is configured
+func startTradeArchiver(database *sql.DB) {
+	if matchedOrdersRetentionDays <= 0 {
+		return
+	}
+	if matchedOrdersRetentionDays < minRetentionDays {
+		log.Printf("⚠️ MATCHED_ORDERS_RETENTION_DAYS=%d is below the %d-day minimum, trade archiver disabled",
+			matchedOrdersRetentionDays, minRetentionDays)
+		return
+	}
+	interval := getEnvDuration("MATCHED_ORDERS_ARCHIVE_INTERVAL", 24*time.Hour)
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if _, err := archiveOldTrades(database, matchedOrdersRetentionDays, matchedOrdersArchiveRows); err != nil {
+				log.Printf("⚠️ Trade archival failed: %v", err)
+			}
+		}
+	}()
+
+	log.Printf("🗄️ Trade archiver enabled (retention %d days, every %s)", matchedOrdersRetentionDays, interval)
+}
+
+// POST /api/admin/archive-trades?retention_days= - run the archive job now.
+// retention_days defaults to MATCHED_ORDERS_RETENTION_DAYS and must be at
+// least minRetentionDays.
+func archiveTradesHandler(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+
+	retentionDays := matchedOrdersRetentionDays
+	if daysStr := r.URL.Query().Get("retention_days"); daysStr != "" {
+		var err error
+		retentionDays, err = strconv.Atoi(daysStr)
+		if err != nil {
+			retentionDays = 0
+		}
+	}
+	if retentionDays < minRetentionDays {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_RETENTION_DAYS",
+			fmt.Sprintf("retention_days must be an integer of at least %d", minRetentionDays))
+		return
+	}
+
+	result, err := archiveOldTrades(db, retentionDays, matchedOrdersArchiveRows)
+	if err != nil {
+		log.Println("Error archiving trades:", err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Error archiving trades")
+		return
+	}
+
+	log.Printf("🗄️ Trade archival run by admin (User ID: %d): %d matched orders", userID, result.MatchedOrders)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestArchiveOldTradesKeepsRecentAndHistory(t *testing.T) {
+	openTestDB(t)
+	_, adminToken := createTestUser(t, "admin", true)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+
+	old := tradeTestOrders(t, defaultProjectID, buyerUser, sellerUser, 10, 3)
+	recent := tradeTestOrders(t, defaultProjectID, buyerUser, sellerUser, 12, 2)
+	waitForTestCount(t, "match_assignments", 2)
+	if _, err := db.Exec("UPDATE matched_orders SET created_at = created_at - INTERVAL '10 days' WHERE id = $1", old); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := doTestRequest(t, http.MethodPost, "/api/admin/archive-trades?retention_days=1", adminToken, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("retention_days=1: status %d, want 400", rec.Code)
+	}
+
+	result, err := archiveOldTrades(db, 5, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.MatchedOrders != 1 || result.MatchAssignments != 1 {
+		t.Errorf("archived %d matched orders, %d assignments; want 1 and 1", result.MatchedOrders, result.MatchAssignments)
+	}
+
+	var liveID int
+	if err := db.QueryRow("SELECT id FROM matched_orders").Scan(&liveID); err != nil || liveID != recent {
+		t.Errorf("live matched order = %d (%v), want only the recent #%d", liveID, err, recent)
+	}
+	if n := testCount(t, "matched_orders_archive"); n != 1 {
+		t.Errorf("matched_orders_archive rows = %d, want 1", n)
+	}
+
+	// Positions still count the archived trade
+	positions, err := calculateUserPositions(db, buyerUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(positions) != 1 || positions[0].BoughtQty != wholeQuantity(5) || positions[0].LastPrice != 12 {
+		t.Errorf("buyer positions = %+v, want 5 bought, last price 12", positions)
+	}
+
+	// An assignment recorded after its trade was archived goes to the archive
+	recordMatchAssignment(db, 1, 2, sellerUser, "ABCDEFGH", wholeQuantity(3), wholeQuantity(3), 10, old, 0)
+	waitForTestCount(t, "match_assignments_archive", 2)
+	if n := testCount(t, "match_assignments_all"); n != 3 {
+		t.Errorf("match_assignments_all rows = %d, want 3", n)
+	}
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Status of a matched order that an admin has cancelled after the fact
+const matchBusted = "Busted"
+
+var errMatchNotAdjustable = errors.New("matched order cannot be adjusted")
+
+// Audit record for a bust or amend. For a bust the new values are zero.
+type MatchAdjustment struct {
+	ID             int       `json:"id"`
+	MatchedOrderID int       `json:"matched_order_id"`
+	Action         string    `json:"action"` // bust or amend
+	OldQty         Quantity  `json:"old_qty"`
+	NewQty         Quantity  `json:"new_qty"`
+	OldPrice       float64   `json:"old_price"`
+	NewPrice       float64   `json:"new_price"`
+	Reason         string    `json:"reason"`
+	ActedBy        int       `json:"acted_by"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+func initMatchAdjustmentsTable(database *sql.DB) {
+	query := `CREATE TABLE IF NOT EXISTS matched_order_adjustments (
+		id SERIAL PRIMARY KEY,
+		matched_order_id INTEGER NOT NULL,
+		action VARCHAR(10) NOT NULL CHECK (action IN ('bust', 'amend')),
+		old_qty DECIMAL(18, 8) NOT NULL,
+		new_qty DECIMAL(18, 8) NOT NULL,
+		old_price DECIMAL(18, 6) NOT NULL,
+		new_price DECIMAL(18, 6) NOT NULL,
+		reason TEXT NOT NULL,
+		acted_by INTEGER NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`
+	if _, err := database.Exec(query); err != nil {
+		log.Printf("Warning: Could not create matched_order_adjustments table: %v", err)
+	}
+	database.Exec(`CREATE INDEX IF NOT EXISTS idx_matched_order_adjustments_match ON matched_order_adjustments (matched_order_id)`)
+}
+
+// The parts of a matched order a bust or amend works from
+type adjustableMatch struct {
+	qty           Quantity
+	price         float64 // execution price
+	makerFee      float64
+	takerFee      float64
+	buyerOrderID  int
+	sellerOrderID int
+	projectID     int
+}
+
+// Locks the matched order for the rest of tx. Busted matches are final, and
+// so are settled ones - the money has already moved.
+func lockMatchForAdjustmentTx(tx *sql.Tx, matchedOrderID int) (*adjustableMatch, error) {
+	var m adjustableMatch
+	var status string
+	err := tx.QueryRow(`
+		SELECT COALESCE(status, 'Closed'), matched_qty, COALESCE(execution_price, seller_price),
+		       maker_fee, taker_fee, buyer_order_id, seller_order_id, `+projectIDOrDefault("project_id")+`
+		FROM matched_orders
+		WHERE id = $1
+		FOR UPDATE
+	`, matchedOrderID).Scan(&status, &m.qty, &m.price, &m.makerFee, &m.takerFee,
+		&m.buyerOrderID, &m.sellerOrderID, &m.projectID)
+	if err != nil {
+		return nil, err
+	}
+	if status == matchBusted || status == settlementSettled {
+		return nil, fmt.Errorf("%w: matched order %d is %s", errMatchNotAdjustable, matchedOrderID, status)
+	}
+	return &m, nil
+}
+
+// Gives qty back to one side of a match. The order is only topped up if it
+// is still resting (top or main table, as matchOrders writes both); a fully
+// filled order is gone and stays gone. History always drops the fill
+// quantity, and removeFill also takes the fill out of the counts. The status
+// only moves back for a restored order - one that is gone keeps its final
+// status (Completed, Cancelled).
+func restoreMatchedQuantityTx(tx *sql.Tx, role string, orderID int, qty Quantity, removeFill bool) (bool, error) {
+	topResult, err := tx.Exec(fmt.Sprintf("UPDATE %s SET quantity = quantity + $1 WHERE order_id = $2", getTopTableName(role)), qty, orderID)
+	if err != nil {
+		return false, fmt.Errorf("restoring top %s order: %w", role, err)
+	}
+	mainResult, err := tx.Exec(fmt.Sprintf("UPDATE %s SET quantity = quantity + $1 WHERE id = $2", getTableName(role)), qty, orderID)
+	if err != nil {
+		return false, fmt.Errorf("restoring %s order: %w", role, err)
+	}
+	topRows, _ := topResult.RowsAffected()
+	mainRows, _ := mainResult.RowsAffected()
+	restored := topRows+mainRows > 0
+
+	counterpartCount := "seller_count"
+	if role == "seller" {
+		counterpartCount = "buyer_count"
+	}
+	_, err = tx.Exec(fmt.Sprintf(`
+		UPDATE %[1]s_order_history
+		SET total_matched_qty = GREATEST(total_matched_qty - $1, 0),
+		    remaining_qty = remaining_qty + CASE WHEN $3 THEN $1 ELSE 0 END,
+		    match_count = GREATEST(match_count - CASE WHEN $4 THEN 1 ELSE 0 END, 0),
+		    %[2]s = GREATEST(%[2]s - CASE WHEN $4 THEN 1 ELSE 0 END, 0),
+		    updated_at = CURRENT_TIMESTAMP,
+		    status = CASE
+		        WHEN status = 'Cancelled' OR NOT $3 THEN status
+		        WHEN total_matched_qty - $1 <= 0 THEN 'Pending'
+		        ELSE 'Partially Matched'
+		    END
+		WHERE %[1]s_order_id = $2
+	`, role, counterpartCount), qty, orderID, restored, removeFill)
+	if err != nil {
+		return false, fmt.Errorf("updating %s history: %w", role, err)
+	}
+	return restored, nil
+}
+
+func recordMatchAdjustmentTx(tx *sql.Tx, adj *MatchAdjustment) error {
+	return tx.QueryRow(`
+		INSERT INTO matched_order_adjustments (matched_order_id, action, old_qty, new_qty, old_price, new_price, reason, acted_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at
+	`, adj.MatchedOrderID, adj.Action, adj.OldQty, adj.NewQty, adj.OldPrice, adj.NewPrice, adj.Reason, adj.ActedBy).
+		Scan(&adj.ID, &adj.CreatedAt)
+}
+
+// Shared id and body handling for bust and amend (routes are requireAdmin).
+// Returns false once an error response has been written.
+func decodeMatchAdjustmentRequest(w http.ResponseWriter, r *http.Request, req interface{}) (userID, matchedOrderID int, ok bool) {
+	userID = userIDFromContext(r)
+
+	matchedOrderID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_MATCHED_ORDER_ID", "Invalid matched order ID")
+		return 0, 0, false
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		writeBodyDecodeError(w, err)
+		return 0, 0, false
+	}
+	return userID, matchedOrderID, true
+}
+
+func writeMatchAdjustmentError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case err == sql.ErrNoRows:
+		writeJSONError(w, http.StatusNotFound, "MATCHED_ORDER_NOT_FOUND", "Matched order not found")
+	case errors.Is(err, errMatchNotAdjustable):
+		writeJSONError(w, http.StatusConflict, "MATCH_NOT_ADJUSTABLE", err.Error())
+	default:
+		log.Printf("Error during trade %s: %v", action, err)
+		writeJSONError(w, http.StatusInternalServerError, "INTERNAL_ERROR", fmt.Sprintf("Error during trade %s", action))
+	}
+}
+
+// POST /api/admin/matched-orders/{id}/bust {"reason": "..."} - cancels a trade
+// (admin). The match is marked Busted and its quantity goes back to both
+// orders if they are still resting. The ticker is rebuilt without it; metrics
+// and user events already sent are not reversed.
+func bustMatchedOrder(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	userID, matchedOrderID, ok := decodeMatchAdjustmentRequest(w, r, &req)
+	if !ok {
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		writeJSONError(w, http.StatusBadRequest, "MISSING_REASON", "reason is required")
+		return
+	}
+
+	adj := &MatchAdjustment{MatchedOrderID: matchedOrderID, Action: "bust", Reason: req.Reason, ActedBy: userID}
+	var buyerRestored, sellerRestored bool
+	err := withRetry(db, func(tx *sql.Tx) error {
+		m, err := lockMatchForAdjustmentTx(tx, matchedOrderID)
+		if err != nil {
+			return err
+		}
+		adj.OldQty, adj.OldPrice = m.qty, m.price
+
+		if _, err := tx.Exec(`UPDATE matched_orders SET status = $1 WHERE id = $2`, matchBusted, matchedOrderID); err != nil {
+			return fmt.Errorf("marking match busted: %w", err)
+		}
+		if buyerRestored, err = restoreMatchedQuantityTx(tx, "buyer", m.buyerOrderID, m.qty, true); err != nil {
+			return err
+		}
+		if sellerRestored, err = restoreMatchedQuantityTx(tx, "seller", m.sellerOrderID, m.qty, true); err != nil {
+			return err
+		}
+		return recordMatchAdjustmentTx(tx, adj)
+	})
+	if err != nil {
+		writeMatchAdjustmentError(w, err, "bust")
+		return
+	}
+
+	if buyerRestored || sellerRestored {
+		notifyOrderBookChanged("buyer", "seller")
+	}
+	if err := loadTickerCache(db); err != nil {
+		log.Printf("⚠️ Warning: Could not reload ticker after bust: %v", err)
+	}
+	log.Printf("💥 Matched order #%d busted by admin (User ID: %d): %s", matchedOrderID, userID, req.Reason)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":         true,
+		"status":          matchBusted,
+		"buyer_restored":  buyerRestored,
+		"seller_restored": sellerRestored,
+		"adjustment":      adj,
+	})
+}
+
+// POST /api/admin/matched-orders/{id}/amend {"matched_qty", "price", "reason"}
+// - corrects a trade (admin). The quantity can only be lowered; the
+// difference goes back to both orders like a partial bust. A new price
+// becomes the trade's price for both sides. Fees are rescaled to the new
+// notional.
+func amendMatchedOrder(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		MatchedQty *Quantity `json:"matched_qty"`
+		Price      *float64  `json:"price"`
+		Reason     string    `json:"reason"`
+	}
+	userID, matchedOrderID, ok := decodeMatchAdjustmentRequest(w, r, &req)
+	if !ok {
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		writeJSONError(w, http.StatusBadRequest, "MISSING_REASON", "reason is required")
+		return
+	}
+	if req.MatchedQty == nil && req.Price == nil {
+		writeJSONError(w, http.StatusBadRequest, "NOTHING_TO_AMEND", "matched_qty or price is required")
+		return
+	}
+	if req.MatchedQty != nil && *req.MatchedQty <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_QUANTITY", "matched_qty must be greater than 0 - bust the trade instead")
+		return
+	}
+	if req.Price != nil && *req.Price <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_PRICE", "price must be greater than 0")
+		return
+	}
+
+	adj := &MatchAdjustment{MatchedOrderID: matchedOrderID, Action: "amend", Reason: req.Reason, ActedBy: userID}
+	var validationErr error
+	err := withRetry(db, func(tx *sql.Tx) error {
+		validationErr = nil
+		m, err := lockMatchForAdjustmentTx(tx, matchedOrderID)
+		if err != nil {
+			return err
+		}
+		adj.OldQty, adj.OldPrice = m.qty, m.price
+		adj.NewQty, adj.NewPrice = m.qty, m.price
+		if req.MatchedQty != nil {
+			adj.NewQty = *req.MatchedQty
+		}
+		if req.Price != nil {
+			adj.NewPrice = *req.Price
+		}
+
+		rules, err := getProjectTradingRules(db, m.projectID)
+		if err != nil {
+			return fmt.Errorf("loading trading rules: %w", err)
+		}
+		switch {
+		case adj.NewQty > m.qty:
+			validationErr = fmt.Errorf("matched_qty can only be lowered (currently %s)", m.qty)
+		case validateQuantityUnits(adj.NewQty, rules) != nil:
+			validationErr = validateQuantityUnits(adj.NewQty, rules)
+		case validatePricePrecision(adj.NewPrice, rules.PricePrecision) != nil:
+			validationErr = validatePricePrecision(adj.NewPrice, rules.PricePrecision)
+		case adj.NewQty == m.qty && comparePrices(adj.NewPrice, m.price) == 0:
+			validationErr = errors.New("the amendment does not change the trade")
+		}
+		if validationErr != nil {
+			return nil
+		}
+
+		// Fees follow the notional they were charged on
+		feeScale := 0.0
+		if oldNotional := m.qty.Float64() * m.price; oldNotional > 0 {
+			feeScale = adj.NewQty.Float64() * adj.NewPrice / oldNotional
+		}
+		makerFee := math.Round(m.makerFee*feeScale*1e6) / 1e6
+		takerFee := math.Round(m.takerFee*feeScale*1e6) / 1e6
+
+		_, err = tx.Exec(`
+			UPDATE matched_orders
+			SET matched_qty = $1, execution_price = $2, buyer_price = $2, seller_price = $2,
+			    maker_fee = $3, taker_fee = $4
+			WHERE id = $5
+		`, adj.NewQty, adj.NewPrice, makerFee, takerFee, matchedOrderID)
+		if err != nil {
+			return fmt.Errorf("amending match: %w", err)
+		}
+		if _, err := tx.Exec(`UPDATE match_assignments SET assigned_qty = $1, seller_price = $2 WHERE matched_order_id = $3`,
+			adj.NewQty, adj.NewPrice, matchedOrderID); err != nil {
+			return fmt.Errorf("amending match assignment: %w", err)
+		}
+
+		if released := m.qty - adj.NewQty; released > 0 {
+			if _, err := restoreMatchedQuantityTx(tx, "buyer", m.buyerOrderID, released, false); err != nil {
+				return err
+			}
+			if _, err := restoreMatchedQuantityTx(tx, "seller", m.sellerOrderID, released, false); err != nil {
+				return err
+			}
+		}
+		return recordMatchAdjustmentTx(tx, adj)
+	})
+	if err != nil {
+		writeMatchAdjustmentError(w, err, "amend")
+		return
+	}
+	if validationErr != nil {
+		writeJSONError(w, http.StatusBadRequest, "INVALID_AMENDMENT", fmt.Sprintf("Invalid amendment: %v", validationErr))
+		return
+	}
+
+	if adj.NewQty < adj.OldQty {
+		notifyOrderBookChanged("buyer", "seller")
+	}
+	if err := loadTickerCache(db); err != nil {
+		log.Printf("⚠️ Warning: Could not reload ticker after amend: %v", err)
+	}
+	log.Printf("✏️ Matched order #%d amended by admin (User ID: %d): qty %s -> %s, price %.6f -> %.6f: %s",
+		matchedOrderID, userID, adj.OldQty, adj.NewQty, adj.OldPrice, adj.NewPrice, req.Reason)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"adjustment": adj,
+	})
+}
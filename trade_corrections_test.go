@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestBustedTradeLeavesPricesAndFinishedHistory(t *testing.T) {
+	openTestDB(t)
+	_, adminToken := createTestUser(t, "admin", true)
+	buyerUser, _ := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+
+	tradeTestOrders(t, defaultProjectID, buyerUser, sellerUser, 10, 5)
+	busted := tradeTestOrders(t, defaultProjectID, buyerUser, sellerUser, 12, 5)
+
+	var sellerOrderID int
+	if err := db.QueryRow("SELECT seller_order_id FROM matched_orders WHERE id = $1", busted).Scan(&sellerOrderID); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := doTestRequest(t, http.MethodPost, "/api/v1/admin/matched-orders/"+strconv.Itoa(busted)+"/bust",
+		adminToken, map[string]string{"reason": "fat finger"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("bust: status %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		SellerRestored bool `json:"seller_restored"`
+	}
+	decodeTestResponse(t, rec, &resp)
+	if resp.SellerRestored {
+		t.Error("seller_restored = true for a fully filled order")
+	}
+
+	if mid, ok, err := lastTradedMidPrice(db, defaultProjectID); err != nil || !ok || mid != 10 {
+		t.Errorf("lastTradedMidPrice = %v, %v, %v; want 10 from the standing trade", mid, ok, err)
+	}
+
+	tickerMutex.RLock()
+	entry := tickerCache[defaultProjectID]
+	tickerMutex.RUnlock()
+	if entry == nil || entry.LastPrice != 10 || entry.TodayVolume != wholeQuantity(5) {
+		t.Errorf("ticker = %+v, want last price 10 and volume 5", entry)
+	}
+
+	report, err := buildDailyReport(context.Background(), db, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range report.Projects {
+		if p.ProjectID == defaultProjectID && (p.TotalMatches != 1 || p.High == nil || *p.High != 10) {
+			t.Errorf("daily report = %d matches, high %v; want 1 match, high 10", p.TotalMatches, p.High)
+		}
+	}
+
+	// The order is gone, so its history keeps its final status
+	var status string
+	var matched Quantity
+	err = db.QueryRow("SELECT status, total_matched_qty FROM seller_order_history WHERE seller_order_id = $1",
+		sellerOrderID).Scan(&status, &matched)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != "Completed" || matched != 0 {
+		t.Errorf("seller history = %s with %s matched, want Completed with 0", status, matched)
+	}
+}
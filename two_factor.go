@@ -0,0 +1,286 @@
+package main
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+)
+
+// Shown as the account's label in authenticator apps
+var totpIssuer = getEnv("TOTP_ISSUER", "Trading Platform")
+
+// After TOTP_MAX_FAILURES wrong codes in a row, codes are refused for
+// TOTP_LOCKOUT whether or not they are right
+var (
+	totpMaxFailures = getEnvInt("TOTP_MAX_FAILURES", 5)
+	totpLockout     = getEnvDuration("TOTP_LOCKOUT", 15*time.Minute)
+)
+
+// Codes are valid for 30s, with one step of clock drift either way - the
+// same window as totp.Validate
+const (
+	totpPeriod = 30
+	totpSkew   = 1
+)
+
+var (
+	errTOTPInvalid = errors.New("invalid two-factor code")
+	errTOTPLocked  = errors.New("too many invalid two-factor codes, try again later")
+)
+
+// Time step the code belongs to, if it is valid for secret around now
+func totpCodeStep(code, secret string, now time.Time) (int64, bool) {
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		expected, err := totp.GenerateCode(secret, time.Unix(step*totpPeriod, 0))
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// Checks a code for the user. Each code is accepted once: a code from the
+// last accepted time step or earlier is a replay and fails like a wrong one.
+// Wrong codes count towards the lockout; an accepted one resets the count.
+func checkTOTPCode(database *sql.DB, userID int, secret, code string) error {
+	var locked bool
+	err := database.QueryRow(`
+		SELECT COALESCE(totp_locked_until > LOCALTIMESTAMP, false) FROM users WHERE id = $1
+	`, userID).Scan(&locked)
+	if err != nil {
+		return err
+	}
+	if locked {
+		return errTOTPLocked
+	}
+
+	if step, ok := totpCodeStep(strings.TrimSpace(code), secret, time.Now()); ok {
+		// Claimed atomically so two requests racing with the same code can't both pass
+		result, err := database.Exec(`
+			UPDATE users SET totp_last_step = $2, totp_failed_attempts = 0, totp_locked_until = NULL
+			WHERE id = $1 AND (totp_last_step IS NULL OR totp_last_step < $2)
+		`, userID, step)
+		if err != nil {
+			return err
+		}
+		if rows, _ := result.RowsAffected(); rows == 1 {
+			return nil
+		}
+	}
+
+	_, err = database.Exec(`
+		UPDATE users SET
+			totp_failed_attempts = CASE WHEN totp_failed_attempts + 1 >= $2 THEN 0 ELSE totp_failed_attempts + 1 END,
+			totp_locked_until = CASE WHEN totp_failed_attempts + 1 >= $2
+			                         THEN LOCALTIMESTAMP + $3 * INTERVAL '1 second' ELSE totp_locked_until END
+		WHERE id = $1
+	`, userID, totpMaxFailures, totpLockout.Seconds())
+	if err != nil {
+		return err
+	}
+	return errTOTPInvalid
+}
+
+// Status and message for a failed checkTOTPCode
+func totpErrorResponse(err error) (int, string) {
+	switch err {
+	case errTOTPLocked:
+		return http.StatusTooManyRequests, "Too many invalid two-factor codes. Try again later"
+	case errTOTPInvalid:
+		return http.StatusUnauthorized, "Invalid two-factor code"
+	default:
+		log.Println("Error checking two-factor code:", err)
+		return http.StatusInternalServerError, "Database error"
+	}
+}
+
+type TwoFactorEnrollResponse struct {
+	Success    bool   `json:"success"`
+	Message    string `json:"message"`
+	Secret     string `json:"secret,omitempty"`
+	OTPAuthURL string `json:"otpauth_url,omitempty"`
+}
+
+type TwoFactorVerifyRequest struct {
+	Code string `json:"code"`
+}
+
+// Start 2FA enrollment: stores a fresh secret that only takes effect once a
+// code from it has been confirmed via /api/auth/2fa/verify
+func enrollTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := extractToken(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(TwoFactorEnrollResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	userID, err := getUserIDFromToken(token, db)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(TwoFactorEnrollResponse{
+			Success: false,
+			Message: "Invalid or expired token",
+		})
+		return
+	}
+
+	var email string
+	var enabled bool
+	err = db.QueryRow("SELECT email, totp_enabled FROM users WHERE id = $1", userID).Scan(&email, &enabled)
+	if err != nil {
+		log.Println("Error fetching user for 2FA enrollment:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(TwoFactorEnrollResponse{
+			Success: false,
+			Message: "Database error",
+		})
+		return
+	}
+
+	// Re-enrolling would silently replace a working secret
+	if enabled {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(TwoFactorEnrollResponse{
+			Success: false,
+			Message: "Two-factor authentication is already enabled",
+		})
+		return
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      totpIssuer,
+		AccountName: email,
+	})
+	if err != nil {
+		log.Println("Error generating TOTP secret:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(TwoFactorEnrollResponse{
+			Success: false,
+			Message: "Error generating secret",
+		})
+		return
+	}
+
+	_, err = db.Exec("UPDATE users SET totp_secret = $1 WHERE id = $2", key.Secret(), userID)
+	if err != nil {
+		log.Println("Error storing TOTP secret:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(TwoFactorEnrollResponse{
+			Success: false,
+			Message: "Database error",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(TwoFactorEnrollResponse{
+		Success:    true,
+		Message:    "Scan the QR code or enter the secret in your authenticator app, then confirm with a code",
+		Secret:     key.Secret(),
+		OTPAuthURL: key.URL(),
+	})
+}
+
+// Confirm enrollment with a current code; 2FA is required at login from then on
+func verifyTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := extractToken(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(AuthResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	userID, err := getUserIDFromToken(token, db)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(AuthResponse{
+			Success: false,
+			Message: "Invalid or expired token",
+		})
+		return
+	}
+
+	var req TwoFactorVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyDecodeError(w, err)
+		return
+	}
+
+	var secret sql.NullString
+	var enabled bool
+	err = db.QueryRow("SELECT totp_secret, totp_enabled FROM users WHERE id = $1", userID).Scan(&secret, &enabled)
+	if err != nil {
+		log.Println("Error fetching user for 2FA verification:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(AuthResponse{
+			Success: false,
+			Message: "Database error",
+		})
+		return
+	}
+
+	if enabled {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(AuthResponse{
+			Success: false,
+			Message: "Two-factor authentication is already enabled",
+		})
+		return
+	}
+
+	if !secret.Valid || secret.String == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(AuthResponse{
+			Success: false,
+			Message: "No pending enrollment. Call " + currentAPIPrefix + "/auth/2fa/enroll first",
+		})
+		return
+	}
+
+	if err := checkTOTPCode(db, userID, secret.String, req.Code); err != nil {
+		status, message := totpErrorResponse(err)
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(AuthResponse{
+			Success: false,
+			Message: message,
+		})
+		return
+	}
+
+	_, err = db.Exec("UPDATE users SET totp_enabled = true WHERE id = $1", userID)
+	if err != nil {
+		log.Println("Error enabling 2FA:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(AuthResponse{
+			Success: false,
+			Message: "Database error",
+		})
+		return
+	}
+
+	log.Printf("🔐 Two-factor authentication enabled for user ID: %d", userID)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(AuthResponse{
+		Success: true,
+		Message: "Two-factor authentication enabled",
+	})
+}
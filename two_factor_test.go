@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+)
+
+func newTestTOTPSecret(t *testing.T) string {
+	t.Helper()
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: "test", AccountName: "alice@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key.Secret()
+}
+
+func TestTOTPCodeStep(t *testing.T) {
+	secret := newTestTOTPSecret(t)
+	now := time.Unix(1_700_000_000, 0)
+	current := now.Unix() / totpPeriod
+
+	for _, offset := range []int64{-1, 0, 1} {
+		code, err := totp.GenerateCode(secret, time.Unix((current+offset)*totpPeriod, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if step, ok := totpCodeStep(code, secret, now); !ok || step != current+offset {
+			t.Errorf("code from step %+d: step = %d, %v; want %d, true", offset, step, ok, current+offset)
+		}
+	}
+
+	stale, _ := totp.GenerateCode(secret, now.Add(-2*totpPeriod*time.Second))
+	if _, ok := totpCodeStep(stale, secret, now); ok {
+		t.Error("code from two steps back accepted")
+	}
+	if _, ok := totpCodeStep("abcdef", secret, now); ok {
+		t.Error("malformed code accepted")
+	}
+}
+
+func TestCheckTOTPCodeRejectsReplayAndLocksOut(t *testing.T) {
+	openTestDB(t)
+	userID, _ := createTestUser(t, "alice", false)
+	secret := newTestTOTPSecret(t)
+	if _, err := db.Exec("UPDATE users SET totp_secret = $1, totp_enabled = true WHERE id = $2", secret, userID); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _ := totp.GenerateCode(secret, time.Now())
+	if err := checkTOTPCode(db, userID, secret, code); err != nil {
+		t.Fatalf("valid code: %v", err)
+	}
+	if err := checkTOTPCode(db, userID, secret, code); err != errTOTPInvalid {
+		t.Errorf("replayed code: err = %v, want errTOTPInvalid", err)
+	}
+
+	// The replay was the first failure
+	for i := 2; i <= totpMaxFailures; i++ {
+		if err := checkTOTPCode(db, userID, secret, "abcdef"); err != errTOTPInvalid {
+			t.Fatalf("wrong code %d: err = %v, want errTOTPInvalid", i, err)
+		}
+	}
+	next, _ := totp.GenerateCode(secret, time.Now().Add(totpPeriod*time.Second))
+	if err := checkTOTPCode(db, userID, secret, next); err != errTOTPLocked {
+		t.Errorf("valid code while locked out: err = %v, want errTOTPLocked", err)
+	}
+
+	if _, err := db.Exec("UPDATE users SET totp_locked_until = LOCALTIMESTAMP - INTERVAL '1 second' WHERE id = $1", userID); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkTOTPCode(db, userID, secret, next); err != nil {
+		t.Errorf("valid code after the lockout: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// A fill on one of the user's own orders. Side and RemainingQty are from the
+// receiving user's point of view; the rest mirrors the matched_orders row and
+// its match assignment.
+type UserMatchEvent struct {
+	Type            string    `json:"type"` // always "match"
+	Side            string    `json:"side"` // buyer or seller
+	OrderID         int       `json:"order_id"`
+	RemainingQty    Quantity  `json:"remaining_qty"`
+	MatchedOrderID  int       `json:"matched_order_id"`
+	ProjectID       int       `json:"project_id"`
+	BuyerOrderID    int       `json:"buyer_order_id"`
+	SellerOrderID   int       `json:"seller_order_id"`
+	BuyerPrice      float64   `json:"buyer_price"`
+	SellerPrice     float64   `json:"seller_price"`
+	MatchedQty      Quantity  `json:"matched_qty"`
+	TransactionType int       `json:"transaction_type"`
+	MatchedAt       time.Time `json:"matched_at"`
+}
+
+// Sends a committed fill to the buyer's and the seller's private streams only
+func publishUserMatch(evt UserMatchEvent, buyerUserID int, buyerRemaining Quantity, sellerUserID int, sellerRemaining Quantity) {
+	evt.Type = "match"
+
+	buyerEvt := evt
+	buyerEvt.Side, buyerEvt.OrderID, buyerEvt.RemainingQty = "buyer", evt.BuyerOrderID, buyerRemaining
+	sellerEvt := evt
+	sellerEvt.Side, sellerEvt.OrderID, sellerEvt.RemainingQty = "seller", evt.SellerOrderID, sellerRemaining
+
+	for _, e := range []struct {
+		userID int
+		data   UserMatchEvent
+	}{{buyerUserID, buyerEvt}, {sellerUserID, sellerEvt}} {
+		hub.publish(wsEvent{
+			Channel:   "user",
+			Key:       fmt.Sprintf("match:%d:%s", evt.MatchedOrderID, e.data.Side),
+			ProjectID: evt.ProjectID,
+			Role:      e.data.Side,
+			UserID:    e.userID,
+			Data:      e.data,
+		})
+	}
+}
+
+// GET /ws/user?token= - private stream of the caller's own fills. Browsers
+// can't set headers on WebSocket handshakes, so the session token comes in
+// the query string.
+func userWebSocket(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		writeJSONError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized: No token provided")
+		return
+	}
+
+	userID, err := getUserIDFromToken(token, db)
+	if err != nil {
+		writeJSONError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized: Invalid token")
+		return
+	}
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println("WebSocket upgrade failed:", err)
+		return
+	}
+
+	log.Printf("🔌 User stream connected (User ID: %d)", userID)
+
+	initial := func() (interface{}, error) {
+		return map[string]interface{}{
+			"type":    "subscribed",
+			"user_id": userID,
+		}, nil
+	}
+
+	filter := func(evt wsEvent) bool {
+		return evt.Channel == "user" && evt.UserID == userID
+	}
+
+	// Keys are unique per fill and side, so nothing needs merging
+	serveWSClient(conn, initial, filter, nil)
+
+	log.Printf("🔌 User stream disconnected (User ID: %d)", userID)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Opens /ws/user as the token's user and reads the subscription message
+func dialTestUserStream(t *testing.T, server *httptest.Server, token string) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/user?token=" + token
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial user stream: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	var hello map[string]interface{}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err := conn.ReadJSON(&hello); err != nil || hello["type"] != "subscribed" {
+		t.Fatalf("subscription message = %v (err %v)", hello, err)
+	}
+	return conn
+}
+
+func TestUserStreamOnlyCarriesOwnFills(t *testing.T) {
+	openTestDB(t)
+	buyerUser, buyerToken := createTestUser(t, "buyer", false)
+	sellerUser, _ := createTestUser(t, "seller", false)
+	_, bystanderToken := createTestUser(t, "bystander", false)
+
+	testHandlerOnce.Do(func() { testHandler = newHandler() })
+	server := httptest.NewServer(testHandler)
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/user?token=not-a-session"
+	if _, resp, err := websocket.DefaultDialer.Dial(url, nil); err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("invalid token: err %v, response %v; want a 401", err, resp)
+	}
+
+	buyerConn := dialTestUserStream(t, server, buyerToken)
+	bystanderConn := dialTestUserStream(t, server, bystanderToken)
+
+	tradeTestOrders(t, defaultProjectID, buyerUser, sellerUser, 10, 2)
+
+	var update struct {
+		Type   string           `json:"type"`
+		Events []UserMatchEvent `json:"events"`
+	}
+	buyerConn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err := buyerConn.ReadJSON(&update); err != nil {
+		t.Fatalf("buyer stream: %v", err)
+	}
+	if len(update.Events) != 1 || update.Events[0].Side != "buyer" || update.Events[0].MatchedQty != wholeQuantity(2) {
+		t.Errorf("buyer stream events = %+v, want one buyer-side fill of 2", update.Events)
+	}
+
+	// Several flush intervals pass without anything for the bystander
+	bystanderConn.SetReadDeadline(time.Now().Add(4 * wsFlushInterval))
+	var leaked map[string]interface{}
+	if err := bystanderConn.ReadJSON(&leaked); err == nil {
+		t.Errorf("bystander received %v", leaked)
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsWriteTimeout = 10 * time.Second
+	wsPongTimeout  = 60 * time.Second
+	wsPingInterval = 50 * time.Second
+)
+
+// Per-client flush interval; events arriving in between are coalesced
+var wsFlushInterval = getEnvDuration("WS_FLUSH_INTERVAL", 250*time.Millisecond)
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin: func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true // Non-browser clients
+		}
+		if allowsAnyOrigin(allowedOrigins) {
+			return true
+		}
+		for _, allowed := range allowedOrigins {
+			if origin == allowed {
+				return true
+			}
+		}
+		return false
+	},
+}
+
+// An event published to the hub. Channel selects the stream (e.g. "orderbook"),
+// Key identifies the entity so pending events for it can be coalesced.
+// UserID addresses private events to a single user (0 on public channels).
+type wsEvent struct {
+	Channel   string
+	Key       string
+	ProjectID int
+	Role      string
+	UserID    int
+	Data      interface{}
+}
+
+type wsClient struct {
+	conn   *websocket.Conn
+	filter func(wsEvent) bool
+	// Merges a pending event with a newer one for the same key; returning
+	// false drops both (e.g. an add followed by a remove)
+	coalesce func(prev, next wsEvent) (wsEvent, bool)
+
+	mu      sync.Mutex
+	pending map[string]wsEvent
+	order   []string
+	done    chan struct{}
+	once    sync.Once
+}
+
+type wsHub struct {
+	mu      sync.RWMutex
+	clients map[*wsClient]bool
+}
+
+var hub = &wsHub{clients: make(map[*wsClient]bool)}
+
+func (h *wsHub) register(c *wsClient) {
+	h.mu.Lock()
+	h.clients[c] = true
+	h.mu.Unlock()
+}
+
+func (h *wsHub) unregister(c *wsClient) {
+	h.mu.Lock()
+	delete(h.clients, c)
+	h.mu.Unlock()
+}
+
+func (h *wsHub) publish(evt wsEvent) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for c := range h.clients {
+		if c.filter(evt) {
+			c.enqueue(evt)
+		}
+	}
+}
+
+func (c *wsClient) enqueue(evt wsEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prev, ok := c.pending[evt.Key]
+	if !ok {
+		c.pending[evt.Key] = evt
+		c.order = append(c.order, evt.Key)
+		return
+	}
+
+	merged, keep := evt, true
+	if c.coalesce != nil {
+		merged, keep = c.coalesce(prev, evt)
+	}
+	if keep {
+		c.pending[evt.Key] = merged
+	} else {
+		// Forget the key's slot too, or a later event for it would be
+		// queued - and sent - twice
+		delete(c.pending, evt.Key)
+		for i, key := range c.order {
+			if key == evt.Key {
+				c.order = append(c.order[:i], c.order[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Takes pending events in arrival order
+func (c *wsClient) drain() []interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	batch := []interface{}{}
+	for _, key := range c.order {
+		if evt, ok := c.pending[key]; ok {
+			batch = append(batch, evt.Data)
+		}
+	}
+	c.pending = make(map[string]wsEvent)
+	c.order = nil
+	return batch
+}
+
+func (c *wsClient) close() {
+	c.once.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+// Serves an upgraded connection: sends the initial message, then flushes
+// coalesced events every wsFlushInterval until the client goes away
+func serveWSClient(conn *websocket.Conn, initial func() (interface{}, error), filter func(wsEvent) bool,
+	coalesce func(prev, next wsEvent) (wsEvent, bool)) {
+
+	c := &wsClient{
+		conn:     conn,
+		filter:   filter,
+		coalesce: coalesce,
+		pending:  make(map[string]wsEvent),
+		done:     make(chan struct{}),
+	}
+
+	// Register before building the initial message so nothing published in
+	// between is lost; clients must treat add/update as upserts
+	hub.register(c)
+	defer func() {
+		hub.unregister(c)
+		c.close()
+	}()
+
+	msg, err := initial()
+	if err != nil {
+		log.Printf("⚠️ WebSocket initial message failed: %v", err)
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "initial state unavailable"),
+			time.Now().Add(wsWriteTimeout))
+		return
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if err := conn.WriteJSON(msg); err != nil {
+		return
+	}
+
+	// Reader: handles pongs and notices disconnects
+	go func() {
+		defer c.close()
+		conn.SetReadLimit(512)
+		conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+		conn.SetPongHandler(func(string) error {
+			conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+			return nil
+		})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	flush := time.NewTicker(wsFlushInterval)
+	defer flush.Stop()
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-flush.C:
+			batch := c.drain()
+			if len(batch) == 0 {
+				continue
+			}
+			conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := conn.WriteJSON(map[string]interface{}{"type": "updates", "events": batch}); err != nil {
+				log.Printf("🔌 WebSocket write failed, dropping client: %v", err)
+				return
+			}
+		case <-ping.C:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnqueueDroppedKeyIsNotSentTwice(t *testing.T) {
+	c := &wsClient{
+		pending: make(map[string]wsEvent),
+		coalesce: func(prev, next wsEvent) (wsEvent, bool) {
+			// An add cancelled by a remove drops both
+			return next, !(prev.Data == "add" && next.Data == "remove")
+		},
+	}
+
+	c.enqueue(wsEvent{Key: "buyer:1", Data: "add"})
+	c.enqueue(wsEvent{Key: "buyer:2", Data: "add"})
+	c.enqueue(wsEvent{Key: "buyer:1", Data: "remove"})
+	c.enqueue(wsEvent{Key: "buyer:1", Data: "add again"})
+
+	want := []interface{}{"add", "add again"}
+	if got := c.drain(); !reflect.DeepEqual(got, want) {
+		t.Errorf("drain() = %v, want %v", got, want)
+	}
+}